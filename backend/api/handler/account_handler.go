@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/service"
+)
+
+type AccountHandler struct {
+	service *service.AccountService
+}
+
+func NewAccountHandler(service *service.AccountService) *AccountHandler {
+	return &AccountHandler{
+		service: service,
+	}
+}
+
+func (h *AccountHandler) RequestEmailVerification(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	if err := h.service.RequestEmailVerification(r.Context(), user.ID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *AccountHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req domain.TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "invalid_body", "Invalid request body")
+		return
+	}
+
+	user, err := h.service.VerifyEmail(r.Context(), req.Token)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+func (h *AccountHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req domain.ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "invalid_body", "Invalid request body")
+		return
+	}
+
+	// Always answer the same way so the endpoint cannot be used to discover accounts.
+	if err := h.service.RequestPasswordReset(r.Context(), req.Email); err != nil {
+		log.Printf("Failed to send password reset email: %v", err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *AccountHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req domain.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "invalid_body", "Invalid request body")
+		return
+	}
+
+	if err := h.service.ResetPassword(r.Context(), req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/service"
+	"certificate-ledger/tsa"
+
+	"github.com/gorilla/mux"
+)
+
+type AnchorHandler struct {
+	service *service.AnchorService
+}
+
+func NewAnchorHandler(service *service.AnchorService) *AnchorHandler {
+	return &AnchorHandler{
+		service: service,
+	}
+}
+
+// ListAnchors handles GET /chain/anchors?limit=, newest first.
+func (h *AnchorHandler) ListAnchors(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeProblem(w, r, http.StatusBadRequest, "invalid_query", "limit must be a positive number")
+			return
+		}
+		limit = n
+	}
+
+	anchors, err := h.service.ListAnchors(r.Context(), limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(anchors)
+}
+
+func (h *AnchorHandler) GetAnchor(w http.ResponseWriter, r *http.Request) {
+	anchor, err := h.service.GetAnchor(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(anchor)
+}
+
+// GetReceipt handles GET /chain/anchors/{id}/receipt: the authority's RFC
+// 3161 reply as received, which tools such as "openssl ts -verify" check
+// against the anchor's block hash without trusting this server.
+func (h *AnchorHandler) GetReceipt(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	anchor, err := h.service.GetAnchor(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", tsa.ReplyContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "anchor-"+id+".tsr"))
+	w.Write(anchor.Receipt)
+}
+
+// VerifyAnchor handles GET /chain/anchors/{id}/verify: whether the anchor's
+// receipt still verifies against the chain as stored.
+func (h *AnchorHandler) VerifyAnchor(w http.ResponseWriter, r *http.Request) {
+	check, err := h.service.VerifyAnchor(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(check)
+}
+
+// CreateAnchor handles POST /admin/anchors: it anchors the head now.
+func (h *AnchorHandler) CreateAnchor(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	anchor, err := h.service.AnchorNow(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(anchor)
+}
+
+// VerifyCertificate handles GET /certificates/verify/{hash}/anchor: whether
+// an anchor shows the certificate existed by a given time.
+func (h *AnchorHandler) VerifyCertificate(w http.ResponseWriter, r *http.Request) {
+	result, err := h.service.VerifyCertificate(r.Context(), mux.Vars(r)["hash"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/service"
+
+	"github.com/gorilla/mux"
+)
+
+type APIKeyHandler struct {
+	service *service.APIKeyService
+}
+
+func NewAPIKeyHandler(service *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		service: service,
+	}
+}
+
+func (h *APIKeyHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	var req domain.APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "invalid_body", "Invalid request body")
+		return
+	}
+
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	resp, err := h.service.CreateKey(r.Context(), req, user)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *APIKeyHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	keys, err := h.service.ListKeys(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if keys == nil {
+		keys = []*domain.APIKey{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+func (h *APIKeyHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if err := h.service.RevokeKey(r.Context(), id, user); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/service"
+)
+
+type AuditHandler struct {
+	service *service.AuditService
+}
+
+func NewAuditHandler(service *service.AuditService) *AuditHandler {
+	return &AuditHandler{
+		service: service,
+	}
+}
+
+// ListEntries returns a page of the audit log, newest first.
+func (h *AuditHandler) ListEntries(w http.ResponseWriter, r *http.Request) {
+	q, err := parseAuditQuery(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	page, err := h.service.List(r.Context(), q)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// Verify checks the integrity of the whole log. The optional headSeq and
+// headHash parameters name a head recorded earlier that must still be present.
+func (h *AuditHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	var headSeq int64
+	if v := r.URL.Query().Get("headSeq"); v != "" {
+		seq, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || seq < 1 {
+			writeProblem(w, r, http.StatusBadRequest, "invalid_query", "headSeq must be a positive number")
+			return
+		}
+		headSeq = seq
+	}
+
+	result, err := h.service.Verify(r.Context(), headSeq, r.URL.Query().Get("headHash"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// parseAuditQuery reads listing parameters: actorId, action, targetType,
+// targetId, from and to (RFC 3339, to exclusive), cursor and limit.
+func parseAuditQuery(r *http.Request) (domain.AuditQuery, error) {
+	values := r.URL.Query()
+	q := domain.AuditQuery{
+		Filter: domain.AuditFilter{
+			ActorID:    values.Get("actorId"),
+			Action:     values.Get("action"),
+			TargetType: values.Get("targetType"),
+			TargetID:   values.Get("targetId"),
+		},
+	}
+
+	for param, dst := range map[string]**time.Time{
+		"from": &q.Filter.From,
+		"to":   &q.Filter.To,
+	} {
+		if v := values.Get(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return q, domain.Invalid("invalid_query", "%s must be an RFC 3339 timestamp", param)
+			}
+			t = t.UTC()
+			*dst = &t
+		}
+	}
+
+	if v := values.Get("cursor"); v != "" {
+		seq, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || seq < 1 {
+			return q, domain.Invalid("invalid_query", "cursor is not valid")
+		}
+		q.BeforeSeq = seq
+	}
+
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return q, domain.Invalid("invalid_query", "limit must be a positive number")
+		}
+		q.Limit = limit
+	}
+	return q, nil
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 
 	"certificate-ledger/domain"
@@ -9,28 +10,34 @@ import (
 )
 
 type AuthHandler struct {
-	service *service.AuthService
+	service  *service.AuthService
+	accounts *service.AccountService
 }
 
-func NewAuthHandler(service *service.AuthService) *AuthHandler {
+func NewAuthHandler(service *service.AuthService, accounts *service.AccountService) *AuthHandler {
 	return &AuthHandler{
-		service: service,
+		service:  service,
+		accounts: accounts,
 	}
 }
 
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	var req domain.UserRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, "invalid_body", "Invalid request body")
 		return
 	}
 
-	user, err := h.service.Register(req)
+	user, err := h.service.Register(r.Context(), req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
+	if err := h.accounts.RequestEmailVerification(r.Context(), user.ID); err != nil {
+		log.Printf("Failed to send verification email: %v", err)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(user)
@@ -39,16 +46,158 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req domain.LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, "invalid_body", "Invalid request body")
+		return
+	}
+
+	authResp, err := h.service.Login(r.Context(), req, clientIP(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(authResp)
+}
+
+func (h *AuthHandler) LoginTOTP(w http.ResponseWriter, r *http.Request) {
+	var req domain.TOTPLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "invalid_body", "Invalid request body")
 		return
 	}
 
-	authResp, err := h.service.Login(req)
+	authResp, err := h.service.LoginTOTP(r.Context(), req, clientIP(r))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		writeError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(authResp)
 }
+
+func (h *AuthHandler) BeginLoginEnrollment(w http.ResponseWriter, r *http.Request) {
+	var req domain.TOTPLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "invalid_body", "Invalid request body")
+		return
+	}
+
+	enrollment, err := h.service.BeginLoginEnrollment(r.Context(), req.MFAToken)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(enrollment)
+}
+
+func (h *AuthHandler) BeginEnrollment(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	enrollment, err := h.service.BeginEnrollment(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(enrollment)
+}
+
+func (h *AuthHandler) ConfirmEnrollment(w http.ResponseWriter, r *http.Request) {
+	var req domain.TOTPCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "invalid_body", "Invalid request body")
+		return
+	}
+
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	codes, err := h.service.ConfirmEnrollment(r.Context(), user.ID, req.Code)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(domain.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *AuthHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	var req domain.TOTPCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "invalid_body", "Invalid request body")
+		return
+	}
+
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	if err := h.service.DisableTOTP(r.Context(), user.ID, req.Code); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ChangePassword sets a new password for the signed-in user. It is the only
+// endpoint open to a user who must change their password.
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var req domain.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "invalid_body", "Invalid request body")
+		return
+	}
+
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	if err := h.service.ChangePassword(r.Context(), user.ID, req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var req domain.TOTPCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "invalid_body", "Invalid request body")
+		return
+	}
+
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	codes, err := h.service.RegenerateRecoveryCodes(r.Context(), user.ID, req.Code)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(domain.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
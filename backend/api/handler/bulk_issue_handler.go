@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/service"
+
+	"github.com/gorilla/mux"
+)
+
+// maxBulkUploadBytes bounds the size of an uploaded spreadsheet.
+const maxBulkUploadBytes = 10 << 20
+
+type BulkIssueHandler struct {
+	service *service.BulkIssueService
+}
+
+func NewBulkIssueHandler(service *service.BulkIssueService) *BulkIssueHandler {
+	return &BulkIssueHandler{
+		service: service,
+	}
+}
+
+// Upload accepts a multipart form with a "file" field holding a CSV or XLSX
+// file. With ?dryRun=true it only reports validation errors.
+func (h *BulkIssueHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBulkUploadBytes)
+	if err := r.ParseMultipartForm(maxBulkUploadBytes); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "invalid_upload", "Invalid upload: send a multipart form of at most 10 MB")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "invalid_upload", "Invalid upload: missing file field")
+		return
+	}
+	defer file.Close()
+
+	report, err := h.service.Upload(r.Context(), filepath.Base(header.Filename), file, dryRun, user)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Job != nil {
+		w.Header().Set("Location", "/api/certificates/bulk/"+report.Job.ID)
+		w.WriteHeader(http.StatusAccepted)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+func (h *BulkIssueHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	jobs, err := h.service.ListJobs(r.Context(), user)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if jobs == nil {
+		jobs = []*domain.BulkIssueJob{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs)
+}
+
+func (h *BulkIssueHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	vars := mux.Vars(r)
+	job, err := h.service.GetJob(r.Context(), vars["id"], user)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+func (h *BulkIssueHandler) GetRows(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	vars := mux.Vars(r)
+	rows, err := h.service.GetRows(r.Context(), vars["id"], user)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rows)
+}
+
+// DownloadResult serves the job's per-row results as a CSV attachment.
+func (h *BulkIssueHandler) DownloadResult(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	vars := mux.Vars(r)
+	if _, err := h.service.GetJob(r.Context(), vars["id"], user); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "bulk-issue-"+vars["id"]+".csv"))
+	if err := h.service.WriteResultCSV(r.Context(), vars["id"], user, w); err != nil {
+		writeError(w, r, err)
+	}
+}
@@ -2,7 +2,10 @@ package handler
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"certificate-ledger/domain"
 	"certificate-ledger/service"
@@ -23,19 +26,19 @@ func NewCertificateHandler(service *service.CertificateService) *CertificateHand
 func (h *CertificateHandler) CreateCertificate(w http.ResponseWriter, r *http.Request) {
     var req domain.CertificateRequest
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        http.Error(w, "Invalid request body", http.StatusBadRequest)
+        writeProblem(w, r, http.StatusBadRequest, "invalid_body", "Invalid request body")
         return
     }
 
     user, ok := r.Context().Value("user").(*domain.User)
     if !ok || user == nil {
-        http.Error(w, "Unauthorized: User not found in context", http.StatusUnauthorized)
+        writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
         return
     }
 
-    cert, err := h.service.CreateCertificate(req, user.ID)
+    cert, err := h.service.CreateCertificate(r.Context(), req, user.ID)
     if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        writeError(w, r, err)
         return
     }
 
@@ -48,9 +51,9 @@ func (h *CertificateHandler) GetCertificate(w http.ResponseWriter, r *http.Reque
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	cert, err := h.service.GetCertificate(id)
+	cert, err := h.service.GetCertificate(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeError(w, r, err)
 		return
 	}
 
@@ -62,9 +65,9 @@ func (h *CertificateHandler) VerifyCertificate(w http.ResponseWriter, r *http.Re
 	vars := mux.Vars(r)
 	hash := vars["hash"]
 
-	isValid, err := h.service.VerifyCertificate(hash)
+	isValid, err := h.service.VerifyCertificate(r.Context(), hash)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeError(w, r, err)
 		return
 	}
 
@@ -72,13 +75,171 @@ func (h *CertificateHandler) VerifyCertificate(w http.ResponseWriter, r *http.Re
 	json.NewEncoder(w).Encode(map[string]bool{"valid": isValid})
 }
 
+// GetAllCertificates lists the certificates the caller may see one page at a
+// time. See parseCertificateQuery for the supported query parameters.
 func (h *CertificateHandler) GetAllCertificates(w http.ResponseWriter, r *http.Request) {
-	certs, err := h.service.GetAllCertificates()
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	q, err := parseCertificateQuery(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	page, err := h.service.ListCertificates(r.Context(), user, q)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// SearchCertificates handles GET /certificates/search?q=&limit=.
+func (h *CertificateHandler) SearchCertificates(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeProblem(w, r, http.StatusBadRequest, "invalid_query", "limit must be a positive number")
+			return
+		}
+		limit = n
+	}
+
+	result, err := h.service.SearchCertificates(r.Context(), r.URL.Query().Get("q"), limit, user)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (h *CertificateHandler) ClaimCertificate(w http.ResponseWriter, r *http.Request) {
+	var req domain.ClaimRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "invalid_body", "Invalid request body")
+		return
+	}
+
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	vars := mux.Vars(r)
+	cert, err := h.service.ClaimCertificate(r.Context(), vars["id"], req.ClaimCode, user)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(certs)
+	json.NewEncoder(w).Encode(cert)
+}
+
+// RevokeCertificate handles POST /certificates/{id}/revoke. The body, with an
+// optional reason, may be omitted.
+func (h *CertificateHandler) RevokeCertificate(w http.ResponseWriter, r *http.Request) {
+	var req domain.RevokeCertificateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeProblem(w, r, http.StatusBadRequest, "invalid_body", "Invalid request body")
+		return
+	}
+
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	vars := mux.Vars(r)
+	cert, err := h.service.RevokeCertificate(r.Context(), vars["id"], req, user)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cert)
+}
+
+// RenewCertificate handles POST /certificates/{id}/renew. The body, with an
+// optional issue date, may be omitted.
+func (h *CertificateHandler) RenewCertificate(w http.ResponseWriter, r *http.Request) {
+	var req domain.RenewCertificateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeProblem(w, r, http.StatusBadRequest, "invalid_body", "Invalid request body")
+		return
+	}
+
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	vars := mux.Vars(r)
+	cert, err := h.service.RenewCertificate(r.Context(), vars["id"], req, user)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(cert)
+}
+
+// parseCertificateQuery reads listing parameters: issuerId, recipientEmail,
+// title, issuedFrom and issuedTo (YYYY-MM-DD), status, sort, order, cursor and limit.
+func parseCertificateQuery(r *http.Request) (domain.CertificateQuery, error) {
+	values := r.URL.Query()
+	q := domain.CertificateQuery{
+		Filter: domain.CertificateFilter{
+			IssuerID:       values.Get("issuerId"),
+			RecipientEmail: values.Get("recipientEmail"),
+			Title:          values.Get("title"),
+			Status:         values.Get("status"),
+		},
+		Sort:   values.Get("sort"),
+		Order:  values.Get("order"),
+		Cursor: values.Get("cursor"),
+	}
+
+	for param, dst := range map[string]**time.Time{
+		"issuedFrom": &q.Filter.IssuedFrom,
+		"issuedTo":   &q.Filter.IssuedTo,
+	} {
+		if v := values.Get(param); v != "" {
+			t, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				return q, domain.Invalid("invalid_query", "%s must be a date in YYYY-MM-DD format", param)
+			}
+			*dst = &t
+		}
+	}
+
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return q, domain.Invalid("invalid_query", "limit must be a positive number")
+		}
+		q.Limit = limit
+	}
+	return q, nil
 }
@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"certificate-ledger/blockchain"
+	"certificate-ledger/domain"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// chainEventBuffer is how far a stream may fall behind before it is
+	// dropped; the client then reconnects and catches up from Last-Event-ID.
+	chainEventBuffer = 64
+	// chainHeartbeatInterval keeps proxies from closing an idle stream.
+	chainHeartbeatInterval = 15 * time.Second
+	// chainRetryMillis tells EventSource clients how soon to reconnect.
+	chainRetryMillis = 3000
+)
+
+type ChainHandler struct {
+	blockchain *blockchain.Blockchain
+}
+
+func NewChainHandler(bc *blockchain.Blockchain) *ChainHandler {
+	return &ChainHandler{
+		blockchain: bc,
+	}
+}
+
+// GetChain handles GET /chain: the chain's height and head hash.
+func (h *ChainHandler) GetChain(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.blockchain.Info())
+}
+
+// ListBlocks handles GET /chain/blocks?cursor=&limit=, newest first. Admins
+// list every block; issuers only those of their own certificates, and other
+// users none.
+func (h *ChainHandler) ListBlocks(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	before := -1
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeProblem(w, r, http.StatusBadRequest, "invalid_query", "cursor is not valid")
+			return
+		}
+		before = n
+	}
+	limit := blockchain.DefaultBlockLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeProblem(w, r, http.StatusBadRequest, "invalid_query", "limit must be a positive number")
+			return
+		}
+		limit = n
+	}
+	if limit > blockchain.MaxBlockLimit {
+		limit = blockchain.MaxBlockLimit
+	}
+
+	var include func(*blockchain.Block) bool
+	if user.Role != domain.RoleAdmin {
+		include = func(block *blockchain.Block) bool {
+			return canSee(user, blockchain.BlockIssuer(block))
+		}
+	}
+	blocks := h.blockchain.Blocks(before, limit, include)
+	page := blockchain.BlockPage{Items: make([]blockchain.BlockSummary, 0, len(blocks))}
+	for _, block := range blocks {
+		page.Items = append(page.Items, blockchain.Summarize(block))
+	}
+	if len(blocks) == limit && blocks[len(blocks)-1].Index > 0 {
+		page.NextCursor = strconv.Itoa(blocks[len(blocks)-1].Index)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// GetBlock handles GET /chain/blocks/{ref}, where ref is a block index or
+// hash. Only the block's summary and commitments are returned, never the
+// certificate's fields. Blocks the caller may not list are not found.
+func (h *ChainHandler) GetBlock(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+	ref := mux.Vars(r)["ref"]
+
+	var block *blockchain.Block
+	if index, err := strconv.Atoi(ref); err == nil && len(ref) < 64 {
+		block, _ = h.blockchain.BlockByIndex(index)
+	} else {
+		block, _ = h.blockchain.GetBlock(strings.ToLower(ref))
+	}
+	if block == nil || !canSee(user, blockchain.BlockIssuer(block)) {
+		writeProblem(w, r, http.StatusNotFound, "block_not_found", "Block "+ref+" not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(blockchain.Detail(block))
+}
+
+// GetHealth handles GET /chain/health. It checks every block, so it costs
+// time in proportion to the chain's length, and only admins may run it.
+func (h *ChainHandler) GetHealth(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+	if user.Role != domain.RoleAdmin {
+		writeProblem(w, r, http.StatusForbidden, "admin_required", "Forbidden: Admin access required")
+		return
+	}
+	health := h.blockchain.Health()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(health)
+}
+
+// StreamEvents handles GET /chain/events, a Server-Sent Events stream. It
+// starts with a status event, then sends block, revocation and alert events
+// as they happen. Block events carry the block index as their ID, so a client
+// reconnecting with Last-Event-ID first receives the blocks it missed.
+// Admins receive every event; issuers only the blocks and revocations of
+// their own certificates, and other users none.
+func (h *ChainHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	lastIndex := -1
+	if v := strings.TrimSpace(r.Header.Get("Last-Event-ID")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeProblem(w, r, http.StatusBadRequest, "invalid_last_event_id", "Last-Event-ID must be a block index")
+			return
+		}
+		lastIndex = n
+	}
+
+	rc := http.NewResponseController(w)
+	// The stream outlives the server's write timeout.
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, "streaming_unsupported", "Streaming is not supported")
+		return
+	}
+
+	// Subscribe before reading the chain, so no block falls between the two.
+	sub := h.blockchain.Events().Subscribe(chainEventBuffer)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", chainRetryMillis)
+
+	if err := writeSSE(w, "status", "", h.blockchain.Status()); err != nil {
+		return
+	}
+	if lastIndex >= 0 {
+		for _, block := range h.blockchain.BlocksAfter(lastIndex) {
+			if !canSee(user, blockchain.BlockIssuer(block)) {
+				continue
+			}
+			if err := writeSSE(w, blockchain.EventBlock, strconv.Itoa(block.Index), blockchain.Summarize(block)); err != nil {
+				return
+			}
+			lastIndex = block.Index
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(chainHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-sub.Events():
+			if !ok {
+				// Dropped for falling behind, or the server is shutting down.
+				return
+			}
+			id := ""
+			if event.Type == blockchain.EventBlock {
+				if event.Index <= lastIndex {
+					continue
+				}
+				lastIndex = event.Index
+				id = strconv.Itoa(event.Index)
+			}
+			if !canSee(user, event.IssuerID) {
+				continue
+			}
+			if err := writeSSE(w, event.Type, id, event.Data); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// canSeeEvent reports whether the user may see an event about a certificate
+// of issuerID. Events without an issuer, such as alerts, are for admins.
+func canSee(user *domain.User, issuerID string) bool {
+	if user.Role == domain.RoleAdmin {
+		return true
+	}
+	return user.Role == domain.RoleIssuer && issuerID != "" && issuerID == user.ID
+}
+
+// writeSSE writes one event. Events without an ID leave the client's last
+// event ID unchanged.
+func writeSSE(w http.ResponseWriter, event, id string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
+}
@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"certificate-ledger/blockchain"
+	"certificate-ledger/domain"
+	"certificate-ledger/repository/memory"
+
+	"github.com/gorilla/mux"
+)
+
+func newTestChain(t *testing.T) *blockchain.Blockchain {
+	t.Helper()
+	bc, err := blockchain.NewBlockchain(context.Background(), 1, memory.NewRepositories().Blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bc
+}
+
+// mine adds a block recording a certificate of the issuer.
+func mine(t *testing.T, bc *blockchain.Blockchain, certID, issuerID string) *blockchain.Block {
+	t.Helper()
+	data, err := json.Marshal(&domain.CertificateBlock{ID: certID, IssuerID: issuerID, Commitments: map[string]string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := bc.AddBlock(context.Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return block
+}
+
+// asUser serves h as if user had authenticated.
+func asUser(user *domain.User, h http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h(w, r.WithContext(context.WithValue(r.Context(), "user", user)))
+	})
+}
+
+// sseEvent is an event read off a stream.
+type sseEvent struct {
+	name, id, data string
+}
+
+// readEvents reads events from the stream until stop returns true for one.
+func readEvents(t *testing.T, body *bufio.Reader, stop func(sseEvent) bool) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var current sseEvent
+	for {
+		line, err := body.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream ended after %+v: %v", events, err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			current.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "id: "):
+			current.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			current.data = strings.TrimPrefix(line, "data: ")
+		case line == "" && current.name != "":
+			events = append(events, current)
+			if stop(current) {
+				return events
+			}
+			current = sseEvent{}
+		}
+	}
+}
+
+func TestStreamEventsShowsIssuersOnlyTheirOwnCertificates(t *testing.T) {
+	bc := newTestChain(t)
+	issuer := &domain.User{ID: "issuer", Role: domain.RoleIssuer}
+	own := mine(t, bc, "CERT-own", issuer.ID)
+	mine(t, bc, "CERT-other", "other")
+
+	server := httptest.NewServer(asUser(issuer, NewChainHandler(bc).StreamEvents))
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Catch up from the genesis block.
+	req.Header.Set("Last-Event-ID", "0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body := bufio.NewReader(resp.Body)
+
+	caughtUp := readEvents(t, body, func(e sseEvent) bool { return e.name == blockchain.EventBlock })
+	if last := caughtUp[len(caughtUp)-1]; last.id != "1" || caughtUp[0].name != "status" {
+		t.Errorf("catching up gave %+v, want the status and then block %d only", caughtUp, own.Index)
+	}
+
+	// Events about the ledger or another issuer's certificates come first
+	// and must be skipped; the stream stops at the issuer's own block.
+	bc.Publish(blockchain.EventAlert, blockchain.Alert{Message: "mismatch", At: time.Now()})
+	bc.PublishFor(blockchain.EventRevocation, "other", blockchain.Revocation{CertificateID: "CERT-other"})
+	mine(t, bc, "CERT-other-2", "other")
+	bc.PublishFor(blockchain.EventRevocation, issuer.ID, blockchain.Revocation{CertificateID: "CERT-own"})
+	last := mine(t, bc, "CERT-own-2", issuer.ID)
+
+	live := readEvents(t, body, func(e sseEvent) bool { return e.name == blockchain.EventBlock })
+	if len(live) != 2 || live[0].name != blockchain.EventRevocation || !strings.Contains(live[0].data, "CERT-own") || live[1].id != "4" {
+		t.Errorf("live events are %+v, want the revocation of CERT-own and block %d", live, last.Index)
+	}
+}
+
+func TestCanSee(t *testing.T) {
+	admin := &domain.User{ID: "admin", Role: domain.RoleAdmin}
+	issuer := &domain.User{ID: "issuer", Role: domain.RoleIssuer}
+	user := &domain.User{ID: "user", Role: domain.RoleUser}
+
+	for _, tc := range []struct {
+		user     *domain.User
+		issuerID string
+		want     bool
+	}{
+		{admin, "", true},
+		{admin, "issuer", true},
+		{issuer, "issuer", true},
+		{issuer, "other", false},
+		{issuer, "", false},
+		{user, "user", false},
+		{user, "", false},
+	} {
+		if got := canSee(tc.user, tc.issuerID); got != tc.want {
+			t.Errorf("canSee(%s, %q) = %v, want %v", tc.user.Role, tc.issuerID, got, tc.want)
+		}
+	}
+}
+
+func TestExplorerShowsCallersOnlyTheirOwnBlocks(t *testing.T) {
+	bc := newTestChain(t)
+	h := NewChainHandler(bc)
+	admin := &domain.User{ID: "admin", Role: domain.RoleAdmin}
+	issuer := &domain.User{ID: "issuer", Role: domain.RoleIssuer}
+	user := &domain.User{ID: "user", Role: domain.RoleUser}
+	own := mine(t, bc, "CERT-own", issuer.ID)
+	other := mine(t, bc, "CERT-other", "other")
+
+	list := func(u *domain.User) []int {
+		rec := httptest.NewRecorder()
+		asUser(u, h.ListBlocks).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/chain/blocks", nil))
+		var page blockchain.BlockPage
+		if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+			t.Fatalf("%s: %v", u.Role, err)
+		}
+		var indexes []int
+		for _, block := range page.Items {
+			indexes = append(indexes, block.Index)
+		}
+		return indexes
+	}
+	if got := list(admin); len(got) != 3 {
+		t.Errorf("admin lists blocks %v, want all three", got)
+	}
+	if got := list(issuer); len(got) != 1 || got[0] != own.Index {
+		t.Errorf("issuer lists blocks %v, want only block %d", got, own.Index)
+	}
+	if got := list(user); len(got) != 0 {
+		t.Errorf("user lists blocks %v, want none", got)
+	}
+
+	get := func(u *domain.User, ref string) int {
+		rec := httptest.NewRecorder()
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/chain/blocks/"+ref, nil), map[string]string{"ref": ref})
+		asUser(u, h.GetBlock).ServeHTTP(rec, req)
+		return rec.Code
+	}
+	for _, tc := range []struct {
+		user *domain.User
+		ref  string
+		want int
+	}{
+		{issuer, strconv.Itoa(own.Index), http.StatusOK},
+		{issuer, own.Hash, http.StatusOK},
+		{issuer, strconv.Itoa(other.Index), http.StatusNotFound},
+		{issuer, other.Hash, http.StatusNotFound},
+		{issuer, "0", http.StatusNotFound},
+		{admin, other.Hash, http.StatusOK},
+		{user, strconv.Itoa(own.Index), http.StatusNotFound},
+	} {
+		if got := get(tc.user, tc.ref); got != tc.want {
+			t.Errorf("%s getting block %s: status %d, want %d", tc.user.Role, tc.ref, got, tc.want)
+		}
+	}
+
+	for u, want := range map[*domain.User]int{admin: http.StatusOK, issuer: http.StatusForbidden} {
+		rec := httptest.NewRecorder()
+		asUser(u, h.GetHealth).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/chain/health", nil))
+		if rec.Code != want {
+			t.Errorf("%s checking health: status %d, want %d", u.Role, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/service"
+
+	"github.com/gorilla/mux"
+)
+
+type CheckpointHandler struct {
+	service *service.CheckpointService
+}
+
+func NewCheckpointHandler(service *service.CheckpointService) *CheckpointHandler {
+	return &CheckpointHandler{
+		service: service,
+	}
+}
+
+// ListCheckpoints handles GET /chain/checkpoints?limit=, newest first.
+func (h *CheckpointHandler) ListCheckpoints(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeProblem(w, r, http.StatusBadRequest, "invalid_query", "limit must be a positive number")
+			return
+		}
+		limit = n
+	}
+
+	checkpoints, err := h.service.ListCheckpoints(r.Context(), limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(checkpoints)
+}
+
+func (h *CheckpointHandler) GetCheckpoint(w http.ResponseWriter, r *http.Request) {
+	checkpoint, err := h.service.GetCheckpoint(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(checkpoint)
+}
+
+// CreateCheckpoint handles POST /admin/checkpoints: it has the witnesses
+// co-sign the head now.
+func (h *CheckpointHandler) CreateCheckpoint(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	checkpoint, err := h.service.CheckpointNow(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(checkpoint)
+}
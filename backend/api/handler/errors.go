@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/service"
+	"certificate-ledger/validation"
+)
+
+// Problem is an RFC 7807 problem details body. Code is a stable,
+// machine-readable identifier; Detail is meant for humans and may change.
+type Problem struct {
+	Type     string                  `json:"type"`
+	Title    string                  `json:"title"`
+	Status   int                     `json:"status"`
+	Detail   string                  `json:"detail,omitempty"`
+	Instance string                  `json:"instance,omitempty"`
+	Code     string                  `json:"code"`
+	Errors   []validation.FieldError `json:"errors,omitempty"`
+}
+
+// errorKinds maps domain error kinds to their status and fallback code.
+var errorKinds = []struct {
+	kind   error
+	status int
+	code   string
+}{
+	{domain.ErrNotFound, http.StatusNotFound, "not_found"},
+	{domain.ErrConflict, http.StatusConflict, "conflict"},
+	{domain.ErrValidation, http.StatusUnprocessableEntity, "validation_failed"},
+	{domain.ErrForbidden, http.StatusForbidden, "forbidden"},
+	{domain.ErrUnauthorized, http.StatusUnauthorized, "unauthorized"},
+}
+
+// writeError turns err into a problem response. Domain and validation errors
+// keep their message; anything else is logged and reported as a generic 500
+// so that driver and internal messages never reach the client.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var fieldErrs validation.Errors
+	if errors.As(err, &fieldErrs) {
+		writeJSONProblem(w, r, Problem{
+			Status: http.StatusUnprocessableEntity,
+			Detail: "The request has invalid fields",
+			Code:   "validation_failed",
+			Errors: fieldErrs,
+		})
+		return
+	}
+
+	var throttled *service.LoginThrottledError
+	if errors.As(err, &throttled) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(throttled.RetryAfter.Seconds())+1))
+		writeProblem(w, r, http.StatusTooManyRequests, "too_many_attempts", err.Error())
+		return
+	}
+
+	for _, k := range errorKinds {
+		if errors.Is(err, k.kind) {
+			code := domain.ErrorCode(err)
+			if code == "" {
+				code = k.code
+			}
+			writeProblem(w, r, k.status, code, err.Error())
+			return
+		}
+	}
+
+	// The client went away or the server is shutting down; repositories wrap
+	// driver errors, so the request context is checked as well.
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || r.Context().Err() != nil {
+		log.Printf("%s %s: cancelled: %v", r.Method, r.URL.Path, err)
+		writeProblem(w, r, http.StatusServiceUnavailable, "request_cancelled", "The request was cancelled before it completed")
+		return
+	}
+
+	log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	writeProblem(w, r, http.StatusInternalServerError, "internal_error", "An unexpected error occurred")
+}
+
+// writeProblem writes a problem response for errors detected in the handler itself.
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	writeJSONProblem(w, r, Problem{Status: status, Code: code, Detail: detail})
+}
+
+func writeJSONProblem(w http.ResponseWriter, r *http.Request, p Problem) {
+	p.Type = "about:blank"
+	p.Title = http.StatusText(p.Status)
+	if r != nil {
+		p.Instance = r.URL.Path
+	}
+
+	recordAuthFailure(p.Status, p.Code)
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/service"
+
+	"github.com/gorilla/mux"
+)
+
+type IntegrityHandler struct {
+	service *service.IntegrityService
+}
+
+func NewIntegrityHandler(service *service.IntegrityService) *IntegrityHandler {
+	return &IntegrityHandler{
+		service: service,
+	}
+}
+
+// ListScans handles GET /admin/integrity/scans?limit=, newest first.
+func (h *IntegrityHandler) ListScans(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeProblem(w, r, http.StatusBadRequest, "invalid_query", "limit must be a positive number")
+			return
+		}
+		limit = n
+	}
+
+	scans, err := h.service.ListScans(r.Context(), limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scans)
+}
+
+func (h *IntegrityHandler) GetScan(w http.ResponseWriter, r *http.Request) {
+	scan, err := h.service.GetScan(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scan)
+}
+
+// RunScan handles POST /admin/integrity/scans: it scans the ledger now and
+// returns the result.
+func (h *IntegrityHandler) RunScan(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	scan, err := h.service.RunScan(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(scan)
+}
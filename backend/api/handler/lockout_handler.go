@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/service"
+
+	"github.com/gorilla/mux"
+)
+
+type LockoutHandler struct {
+	service *service.LoginThrottleService
+}
+
+func NewLockoutHandler(service *service.LoginThrottleService) *LockoutHandler {
+	return &LockoutHandler{
+		service: service,
+	}
+}
+
+func (h *LockoutHandler) ListLockouts(w http.ResponseWriter, r *http.Request) {
+	throttles, err := h.service.ListLocked(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if throttles == nil {
+		throttles = []*domain.LoginThrottle{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(throttles)
+}
+
+func (h *LockoutHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	events, err := h.service.ListEvents(r.Context(), limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if events == nil {
+		events = []*domain.LockoutEvent{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+func (h *LockoutHandler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	admin, ok := r.Context().Value("user").(*domain.User)
+	if !ok || admin == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	vars := mux.Vars(r)
+	if err := h.service.UnlockUser(r.Context(), vars["id"], admin.ID, clientIP(r)); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *LockoutHandler) UnlockIP(w http.ResponseWriter, r *http.Request) {
+	admin, ok := r.Context().Value("user").(*domain.User)
+	if !ok || admin == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	vars := mux.Vars(r)
+	if err := h.service.UnlockIP(r.Context(), vars["ip"], admin.ID, clientIP(r)); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
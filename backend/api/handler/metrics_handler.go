@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"certificate-ledger/metrics"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	httpRequests = metrics.NewCounterVec("ledger_http_requests_total",
+		"HTTP requests by route, method and status code.", "route", "method", "code")
+	httpRequestDuration = metrics.NewHistogramVec("ledger_http_request_duration_seconds",
+		"Time taken to serve HTTP requests, by route and method.", metrics.DefaultBuckets, "route", "method")
+	authFailures = metrics.NewCounterVec("ledger_auth_failures_total",
+		"Rejected logins and credentials, by the problem code returned.", "reason")
+)
+
+// MetricsHandler serves the metrics at GET /metrics for Prometheus.
+type MetricsHandler struct {
+	token string
+}
+
+// NewMetricsHandler requires scrapers to send token as a bearer token, unless
+// it is empty.
+func NewMetricsHandler(token string) *MetricsHandler {
+	return &MetricsHandler{
+		token: token,
+	}
+}
+
+func (h *MetricsHandler) GetMetrics(w http.ResponseWriter, r *http.Request) {
+	if h.token != "" {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+			writeProblem(w, r, http.StatusUnauthorized, "invalid_metrics_token", "Invalid metrics token")
+			return
+		}
+	}
+	metrics.Handler().ServeHTTP(w, r)
+}
+
+// streamingRoutes name the routes whose responses stay open for as long as
+// the client listens. Their duration says nothing of latency, so they are
+// left out of the latency histogram.
+var streamingRoutes = map[string]bool{
+	RouteChainEvents: true,
+}
+
+// MetricsMiddleware counts requests and their latency by route template, so
+// that /api/certificates/{id} is one series whatever the id. Requests no
+// route matches are not counted.
+func MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		route, streaming := "unknown", false
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+			streaming = streamingRoutes[current.GetName()]
+		}
+		httpRequests.Inc(route, r.Method, strconv.Itoa(recorder.status))
+		if !streaming {
+			httpRequestDuration.ObserveSince(start, route, r.Method)
+		}
+	})
+}
+
+// recordAuthFailure counts a problem response that rejects a caller's
+// credentials: every 401, and logins refused for too many attempts.
+func recordAuthFailure(status int, code string) {
+	if status == http.StatusUnauthorized || code == "too_many_attempts" {
+		authFailures.Inc(code)
+	}
+}
+
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the connection, which the event
+// stream flushes through.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"certificate-ledger/metrics"
+
+	"github.com/gorilla/mux"
+)
+
+func TestMetricsMiddlewareLeavesStreamsOutOfLatency(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(MetricsMiddleware)
+	api := r.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/chain/events", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET").Name(RouteChainEvents)
+	api.HandleFunc("/chain/blocks/{index}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}).Methods("GET").Name(RouteGetBlock)
+
+	for _, path := range []string{"/api/chain/events", "/api/chain/blocks/1", "/api/chain/blocks/2"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	var b strings.Builder
+	if err := metrics.Write(&b); err != nil {
+		t.Fatalf("metrics.Write: %v", err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		`ledger_http_requests_total{route="/api/chain/events",method="GET",code="200"} 1`,
+		`ledger_http_requests_total{route="/api/chain/blocks/{index}",method="GET",code="418"} 2`,
+		`ledger_http_request_duration_seconds_count{route="/api/chain/blocks/{index}",method="GET"} 2`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics lack %s", want)
+		}
+	}
+	if strings.Contains(out, `ledger_http_request_duration_seconds_count{route="/api/chain/events"`) {
+		t.Error("the event stream's duration is in the latency histogram")
+	}
+}
@@ -2,28 +2,96 @@ package handler
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"log"
+	"net"
 	"net/http"
-	"os"
 	"strings"
 
 	"certificate-ledger/domain"
-	"certificate-ledger/repository"
+	"certificate-ledger/service"
 
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
 )
 
+// RouteChangePassword stays reachable while a password change is required.
+const RouteChangePassword = "auth.password.change"
+
+// Route names for the endpoints that accept API keys.
+const (
+	RouteCreateCertificate  = "certificates.create"
+	RouteListCertificates   = "certificates.list"
+	RouteSearchCertificates = "certificates.search"
+	RouteGetCertificate     = "certificates.get"
+	RouteVerifyCertificate  = "certificates.verify"
+	RouteRevokeCertificate  = "certificates.revoke"
+	RouteRenewCertificate   = "certificates.renew"
+
+	RouteBulkIssue               = "certificates.bulk.create"
+	RouteListBulkIssueJobs       = "certificates.bulk.list"
+	RouteGetBulkIssueJob         = "certificates.bulk.get"
+	RouteGetBulkIssueRows        = "certificates.bulk.rows"
+	RouteDownloadBulkIssueResult = "certificates.bulk.result"
+
+	RouteChainInfo   = "chain.info"
+	RouteListBlocks  = "chain.blocks.list"
+	RouteGetBlock    = "chain.blocks.get"
+	RouteChainHealth = "chain.health"
+	RouteChainEvents = "chain.events"
+
+	RouteListAnchors      = "chain.anchors.list"
+	RouteGetAnchor        = "chain.anchors.get"
+	RouteGetAnchorReceipt = "chain.anchors.receipt"
+	RouteCheckAnchor      = "chain.anchors.verify"
+	RouteVerifyAnchor     = "certificates.verify.anchor"
+
+	RouteListCheckpoints = "chain.checkpoints.list"
+	RouteGetCheckpoint   = "chain.checkpoints.get"
+)
+
+// apiKeyScopes maps each route reachable with an API key to the scope it requires.
+// Routes that are not listed only accept JWT bearer tokens.
+var apiKeyScopes = map[string]string{
+	RouteCreateCertificate:  domain.ScopeCertificatesIssue,
+	RouteListCertificates:   domain.ScopeCertificatesRead,
+	RouteSearchCertificates: domain.ScopeCertificatesRead,
+	RouteGetCertificate:     domain.ScopeCertificatesRead,
+	RouteVerifyCertificate:  domain.ScopeCertificatesRead,
+	RouteRevokeCertificate:  domain.ScopeCertificatesIssue,
+	RouteRenewCertificate:   domain.ScopeCertificatesIssue,
+
+	RouteBulkIssue:               domain.ScopeCertificatesIssue,
+	RouteListBulkIssueJobs:       domain.ScopeCertificatesIssue,
+	RouteGetBulkIssueJob:         domain.ScopeCertificatesIssue,
+	RouteGetBulkIssueRows:        domain.ScopeCertificatesIssue,
+	RouteDownloadBulkIssueResult: domain.ScopeCertificatesIssue,
+
+	RouteChainInfo:   domain.ScopeCertificatesRead,
+	RouteListBlocks:  domain.ScopeCertificatesRead,
+	RouteGetBlock:    domain.ScopeCertificatesRead,
+	RouteChainHealth: domain.ScopeCertificatesRead,
+	RouteChainEvents: domain.ScopeCertificatesRead,
+
+	RouteListAnchors:      domain.ScopeCertificatesRead,
+	RouteGetAnchor:        domain.ScopeCertificatesRead,
+	RouteGetAnchorReceipt: domain.ScopeCertificatesRead,
+	RouteCheckAnchor:      domain.ScopeCertificatesRead,
+	RouteVerifyAnchor:     domain.ScopeCertificatesRead,
+
+	RouteListCheckpoints: domain.ScopeCertificatesRead,
+	RouteGetCheckpoint:   domain.ScopeCertificatesRead,
+}
+
 func AdminMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user, ok := r.Context().Value("user").(*domain.User)
 		if !ok || user == nil {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized")
 			return
 		}
 
 		if user.Role != "admin" {
-			http.Error(w, "Forbidden: Admin access required", http.StatusForbidden)
+			writeProblem(w, r, http.StatusForbidden, "admin_required", "Forbidden: Admin access required")
 			return
 		}
 
@@ -31,66 +99,132 @@ func AdminMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func AuthMiddleware(userRepo *repository.UserRepository) func(http.Handler) http.Handler {
+func AuthMiddleware(authService *service.AuthService, apiKeyService *service.APIKeyService) func(http.Handler) http.Handler {
     return func(next http.Handler) http.Handler {
         return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if rawKey := apiKeyFromRequest(r); rawKey != "" {
+                authenticateAPIKey(w, r, next, apiKeyService, rawKey)
+                return
+            }
+
             authHeader := r.Header.Get("Authorization")
             if authHeader == "" {
                 log.Println("Missing token")
-                http.Error(w, "Missing token", http.StatusUnauthorized)
+                writeProblem(w, r, http.StatusUnauthorized, "missing_token", "Missing token")
                 return
             }
 
             tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-            log.Printf("Received token: %s", tokenString)
-            token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-                if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-                    log.Printf("Unexpected signing method: %v", token.Header["alg"])
-                    return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-                }
-                secret := os.Getenv("JWT_SECRET")
-                log.Printf("Using secret: %s", secret)
-                if secret == "" {
-                    log.Println("JWT_SECRET is empty")
-                }
-                return []byte(secret), nil
-            })
-            if err != nil {
+            user, err := authService.AuthenticateSession(r.Context(), tokenString)
+            if errors.Is(err, domain.ErrUnauthorized) {
                 log.Printf("Token validation error: %v", err)
-                http.Error(w, "Invalid token", http.StatusUnauthorized)
-                return
-            }
-
-            if !token.Valid {
-                log.Println("Token is not valid")
-                http.Error(w, "Invalid token", http.StatusUnauthorized)
-                return
-            }
-
-            claims, ok := token.Claims.(jwt.MapClaims)
-            if !ok {
-                log.Println("Invalid token claims")
-                http.Error(w, "Invalid token claims", http.StatusUnauthorized)
+                writeProblem(w, r, http.StatusUnauthorized, "invalid_token", "Invalid token")
                 return
             }
-
-            userID, ok := claims["user_id"].(string)
-            if !ok {
-                log.Printf("Invalid user_id in claims: %v", claims)
-                http.Error(w, "Invalid user_id in token", http.StatusUnauthorized)
+            if err != nil {
+                writeError(w, r, err)
                 return
             }
 
-            user, err := userRepo.FindByID(userID)
-            if err != nil {
-                log.Printf("User not found: %v", err)
-                http.Error(w, "Invalid token", http.StatusUnauthorized)
+            if passwordChangePending(w, r, user) {
                 return
             }
 
-            log.Printf("User found: %v", user)
             ctx := context.WithValue(r.Context(), "user", user)
             next.ServeHTTP(w, r.WithContext(ctx))
         })
     }
-}
\ No newline at end of file
+}
+
+// passwordChangePending rejects every request but the password change from a
+// user who must choose a new password, and reports whether it did.
+func passwordChangePending(w http.ResponseWriter, r *http.Request, user *domain.User) bool {
+	if !user.PasswordChangeRequired {
+		return false
+	}
+	if route := mux.CurrentRoute(r); route != nil && route.GetName() == RouteChangePassword {
+		return false
+	}
+	writeProblem(w, r, http.StatusForbidden, "password_change_required", "Forbidden: change your password to continue")
+	return true
+}
+
+// apiKeyFromRequest reads an API key from the X-API-Key header or an "ApiKey" authorization scheme.
+func apiKeyFromRequest(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if authHeader := r.Header.Get("Authorization"); strings.HasPrefix(authHeader, "ApiKey ") {
+		return strings.TrimPrefix(authHeader, "ApiKey ")
+	}
+	return ""
+}
+
+func authenticateAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, apiKeyService *service.APIKeyService, rawKey string) {
+	key, user, err := apiKeyService.Authenticate(r.Context(), rawKey)
+	if errors.Is(err, domain.ErrUnauthorized) {
+		log.Printf("API key authentication failed: %v", err)
+		writeProblem(w, r, http.StatusUnauthorized, "invalid_api_key", "Invalid API key")
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if passwordChangePending(w, r, user) {
+		return
+	}
+
+	routeName := ""
+	if route := mux.CurrentRoute(r); route != nil {
+		routeName = route.GetName()
+	}
+	scope, ok := apiKeyScopes[routeName]
+	if !ok {
+		writeProblem(w, r, http.StatusForbidden, "api_key_not_allowed", "Forbidden: API keys cannot access this endpoint")
+		return
+	}
+	// A key keeps its scopes when its owner's role is lowered, but may no
+	// longer use the ones the new role lacks.
+	if !key.HasScope(scope) || !domain.RoleAllowsScope(user.Role, scope) {
+		writeProblem(w, r, http.StatusForbidden, "insufficient_scope", "Forbidden: API key lacks scope "+scope)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), "user", user)
+	ctx = context.WithValue(ctx, "apiKey", key)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// RequestMetaMiddleware records the client address and user agent in the
+// request context for the audit log and login throttling. X-Forwarded-For is
+// only honoured when trustProxy is set, since clients can set it freely.
+func RequestMetaMiddleware(trustProxy bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := service.WithRequestMeta(r.Context(), service.RequestMeta{
+				IP:        remoteIP(r, trustProxy),
+				UserAgent: r.UserAgent(),
+			})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// clientIP returns the caller's address as found by RequestMetaMiddleware.
+func clientIP(r *http.Request) string {
+	return service.RequestMetaFrom(r.Context()).IP
+}
+
+func remoteIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/service"
+
+	"github.com/gorilla/mux"
+)
+
+type ShareHandler struct {
+	service *service.ShareService
+}
+
+func NewShareHandler(service *service.ShareService) *ShareHandler {
+	return &ShareHandler{
+		service: service,
+	}
+}
+
+func (h *ShareHandler) CreateLink(w http.ResponseWriter, r *http.Request) {
+	var req domain.ShareLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "invalid_body", "Invalid request body")
+		return
+	}
+
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	vars := mux.Vars(r)
+	link, err := h.service.CreateLink(r.Context(), vars["id"], req, user)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(link)
+}
+
+func (h *ShareHandler) ListLinks(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	vars := mux.Vars(r)
+	links, err := h.service.ListLinks(r.Context(), vars["id"], user)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if links == nil {
+		links = []*domain.ShareLink{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(links)
+}
+
+func (h *ShareHandler) RevokeLink(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*domain.User)
+	if !ok || user == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	vars := mux.Vars(r)
+	if err := h.service.RevokeLink(r.Context(), vars["token"], user); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ViewLink is public: anyone holding the link sees only the disclosed fields.
+func (h *ShareHandler) ViewLink(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	shared, err := h.service.View(r.Context(), vars["token"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(shared)
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/service"
+)
+
+const ssoStateCookie = "sso_state"
+
+type SSOHandler struct {
+	service *service.SSOService
+	// appBaseURL is the frontend origin the callback redirects to.
+	appBaseURL string
+}
+
+func NewSSOHandler(service *service.SSOService, appBaseURL string) *SSOHandler {
+	return &SSOHandler{
+		service:    service,
+		appBaseURL: strings.TrimRight(appBaseURL, "/"),
+	}
+}
+
+func (h *SSOHandler) Login(w http.ResponseWriter, r *http.Request) {
+	authURL, state, err := h.service.Begin(r.Context())
+	if err != nil {
+		log.Printf("Failed to start SSO login: %v", err)
+		writeProblem(w, r, http.StatusBadGateway, "sso_unavailable", "Single sign-on is unavailable")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoStateCookie,
+		Value:    state,
+		Path:     "/api/auth/sso",
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Callback handles the provider redirect and sends the browser back to the
+// frontend with the login result in the URL fragment, which is never sent to servers.
+func (h *SSOHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoStateCookie,
+		Value:    "",
+		Path:     "/api/auth/sso",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	fragment := url.Values{}
+	q := r.URL.Query()
+	if providerErr := q.Get("error"); providerErr != "" {
+		fragment.Set("error", providerErr)
+		h.redirectToApp(w, r, fragment)
+		return
+	}
+
+	cookie, err := r.Cookie(ssoStateCookie)
+	if err != nil {
+		fragment.Set("error", "missing sso state")
+		h.redirectToApp(w, r, fragment)
+		return
+	}
+
+	authResp, err := h.service.Callback(r.Context(), q.Get("code"), q.Get("state"), cookie.Value)
+	if err != nil {
+		log.Printf("SSO login failed: %v", err)
+		message := "single sign-on failed"
+		var domainErr *domain.Error
+		if errors.As(err, &domainErr) {
+			message = domainErr.Message
+		}
+		fragment.Set("error", message)
+		h.redirectToApp(w, r, fragment)
+		return
+	}
+
+	if authResp.MFARequired {
+		fragment.Set("mfaToken", authResp.MFAToken)
+		fragment.Set("enrollmentRequired", strconv.FormatBool(authResp.EnrollmentRequired))
+	} else {
+		fragment.Set("token", authResp.Token)
+	}
+	h.redirectToApp(w, r, fragment)
+}
+
+func (h *SSOHandler) redirectToApp(w http.ResponseWriter, r *http.Request, fragment url.Values) {
+	http.Redirect(w, r, h.appBaseURL+"/auth/sso#"+fragment.Encode(), http.StatusFound)
+}
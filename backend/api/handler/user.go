@@ -10,25 +10,33 @@ import (
 )
 
 type UserHandler struct {
-	service *service.UserService
+	service      *service.UserService
+	certificates *service.CertificateService
 }
 
-func NewUserHandler(service *service.UserService) *UserHandler {
+func NewUserHandler(service *service.UserService, certificates *service.CertificateService) *UserHandler {
 	return &UserHandler{
-		service: service,
+		service:      service,
+		certificates: certificates,
 	}
 }
 
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	caller, ok := r.Context().Value("user").(*domain.User)
+	if !ok || caller == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
 	var req domain.UserRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, "invalid_body", "Invalid request body")
 		return
 	}
 
-	user, err := h.service.CreateUser(req)
+	user, err := h.service.CreateUser(r.Context(), req, caller)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
@@ -41,9 +49,9 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	user, err := h.service.GetUser(id)
+	user, err := h.service.GetUser(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeError(w, r, err)
 		return
 	}
 
@@ -51,16 +59,55 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(user)
 }
 
+// GetUserCertificates returns a page of a recipient's wallet and accepts the
+// same query parameters as the certificate listing. Users may only read their
+// own wallet; admins may read anyone's.
 func (h *UserHandler) GetUserCertificates(w http.ResponseWriter, r *http.Request) {
+	caller, ok := r.Context().Value("user").(*domain.User)
+	if !ok || caller == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	owner := caller
+	if id != caller.ID {
+		if caller.Role != domain.RoleAdmin {
+			writeProblem(w, r, http.StatusForbidden, "forbidden", "Forbidden: You can only view your own certificates")
+			return
+		}
+		user, err := h.service.GetUser(r.Context(), id)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		owner = user
+	}
+
+	q, err := parseCertificateQuery(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	page, err := h.certificates.ListWallet(r.Context(), owner, q)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode([]interface{}{})
+	json.NewEncoder(w).Encode(page)
 }
 
+// ListUsers and DeleteUser are mounted on the admin router, which has
+// already checked the caller's role.
 func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
-	// TODO: Kiểm tra quyền admin qua JWT
-	users, err := h.service.GetAllUsers()
+	users, err := h.service.GetAllUsers(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
@@ -69,12 +116,17 @@ func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
-	// TODO: Kiểm tra quyền admin qua JWT
+	admin, ok := r.Context().Value("user").(*domain.User)
+	if !ok || admin == nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Unauthorized: User not found in context")
+		return
+	}
+
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	if err := h.service.DeleteUser(id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.service.DeleteUser(r.Context(), id, admin.ID); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
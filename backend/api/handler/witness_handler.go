@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"certificate-ledger/service"
+	"certificate-ledger/witness"
+
+	"github.com/gorilla/mux"
+)
+
+// maxCosignRequestBytes bounds a co-signing request. Each block adds a
+// header of under 200 bytes to its proof.
+const maxCosignRequestBytes = 32 << 20
+
+// WitnessHandler serves the witness protocol to other ledgers. It takes no
+// user credentials: a co-signing request is authenticated by the origin's
+// signature, which the service checks.
+type WitnessHandler struct {
+	service *service.WitnessService
+}
+
+func NewWitnessHandler(service *service.WitnessService) *WitnessHandler {
+	return &WitnessHandler{
+		service: service,
+	}
+}
+
+// GetKey handles GET /witness/v1/key.
+func (h *WitnessHandler) GetKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.service.Key())
+}
+
+// GetChain handles GET /witness/v1/chains/{origin}: the last checkpoint
+// co-signed for the origin.
+func (h *WitnessHandler) GetChain(w http.ResponseWriter, r *http.Request) {
+	checkpoint, err := h.service.Latest(r.Context(), mux.Vars(r)["origin"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(checkpoint)
+}
+
+// Cosign handles POST /witness/v1/cosign.
+func (h *WitnessHandler) Cosign(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxCosignRequestBytes)
+	var req witness.CosignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "invalid_body", "Invalid request body")
+		return
+	}
+
+	cosignature, err := h.service.Cosign(r.Context(), req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cosignature)
+}
@@ -1,6 +1,7 @@
 package blockchain
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -18,37 +19,79 @@ type Block struct {
 	Nonce        int
 }
 
+// Store keeps the chain's blocks so that the chain outlives the process.
+// FindAll returns every stored block in index order; Append stores a newly
+// mined block and fails if a block with its index is already stored.
+type Store interface {
+	FindAll(ctx context.Context) ([]*Block, error)
+	Append(ctx context.Context, block *Block) error
+}
+
 type Blockchain struct {
-	Chain  []*Block
-	mu     sync.Mutex
+	Chain []*Block
+	store Store
+	// mu serializes AddBlock, mining included. chainMu guards Chain and is
+	// only held briefly, so readers never wait for mining.
+	mu      sync.Mutex
+	chainMu sync.RWMutex
+	// difficulty is the number of leading zero hex digits a mined hash needs.
+	difficulty int
+	events     *Hub
+	// checkpoints maps heights to the hashes witnesses co-signed for them;
+	// guarded by chainMu.
+	checkpoints map[int]string
 }
 
-func NewBlockchain() *Blockchain {
+// NewBlockchain loads the chain kept in store. An empty store gets a new
+// genesis block, so the chain keeps its genesis block across restarts.
+func NewBlockchain(ctx context.Context, difficulty int, store Store) (*Blockchain, error) {
 	bc := &Blockchain{
-		Chain: make([]*Block, 0),
+		store:       store,
+		difficulty:  difficulty,
+		events:      NewHub(),
+		checkpoints: make(map[int]string),
+	}
+	blocks, err := store.FindAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the chain: %v", err)
+	}
+	if len(blocks) == 0 {
+		genesis := bc.genesisBlock()
+		if err := store.Append(ctx, genesis); err != nil {
+			return nil, fmt.Errorf("failed to store the genesis block: %v", err)
+		}
+		blocks = []*Block{genesis}
+	}
+	for i, block := range blocks {
+		if block.Index != i {
+			return nil, fmt.Errorf("the stored chain has block %d in place of block %d", block.Index, i)
+		}
 	}
-	bc.addGenesisBlock()
-	return bc
+	bc.Chain = blocks
+	return bc, nil
 }
 
-func (bc *Blockchain) addGenesisBlock() {
+func (bc *Blockchain) genesisBlock() *Block {
 	genesisBlock := &Block{
 		Index:        0,
-		Timestamp:    time.Now(),
+		Timestamp:    time.Now().UTC(),
 		Data:         []byte("Genesis Block"),
 		PreviousHash: "0",
 		Nonce:        0,
 	}
 	genesisBlock.Hash = bc.calculateHash(genesisBlock)
-	bc.Chain = append(bc.Chain, genesisBlock)
+	return genesisBlock
 }
 
+// calculateHash hashes the block's contents. The timestamp is written in
+// UTC to the nanosecond, so a block loaded from the store hashes as it did
+// when it was mined.
 func (bc *Blockchain) calculateHash(block *Block) string {
-	record := fmt.Sprintf("%d%s%s%s%d", 
-		block.Index, 
-		block.Timestamp.String(), 
-		block.Data, 
-		block.PreviousHash, 
+	record := fmt.Sprintf("%d%s%s%s%d",
+		block.Index,
+		block.Timestamp.UTC().Format(time.RFC3339Nano),
+		block.Data,
+		block.PreviousHash,
 		block.Nonce,
 	)
 	h := sha256.New()
@@ -57,58 +100,129 @@ func (bc *Blockchain) calculateHash(block *Block) string {
 	return hex.EncodeToString(hashed)
 }
 
-func (bc *Blockchain) mineBlock(block *Block, difficulty int) {
+// cancelCheckInterval is how many nonces mineBlock tries between checks of its context.
+const cancelCheckInterval = 1 << 12
+
+// mineBlock searches for a nonce that gives the block a hash with difficulty
+// leading zeros. It gives up with the context's error once ctx is done.
+func (bc *Blockchain) mineBlock(ctx context.Context, block *Block, difficulty int) error {
 	target := ""
 	for i := 0; i < difficulty; i++ {
 		target += "0"
 	}
 
+	start := time.Now()
 	for {
+		if block.Nonce%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				recordMining(start, block.Nonce, miningCancelled)
+				return err
+			}
+		}
 		block.Hash = bc.calculateHash(block)
 		if block.Hash[:difficulty] == target {
 			break
 		}
 		block.Nonce++
 	}
+	recordMining(start, block.Nonce+1, miningMined)
 
 	fmt.Printf("Block mined: %s\n", block.Hash)
+	return nil
 }
 
-func (bc *Blockchain) AddBlock(data []byte) *Block {
+// AddBlock mines a block holding data, stores it and appends it to the
+// chain. When ctx is cancelled before the block is mined, the chain is left
+// unchanged and the context's error is returned.
+func (bc *Blockchain) AddBlock(ctx context.Context, data []byte) (*Block, error) {
 	bc.mu.Lock()
 	defer bc.mu.Unlock()
 
 	prevBlock := bc.Chain[len(bc.Chain)-1]
 	newBlock := &Block{
 		Index:        prevBlock.Index + 1,
-		Timestamp:    time.Now(),
+		Timestamp:    time.Now().UTC(),
 		Data:         data,
 		PreviousHash: prevBlock.Hash,
 		Nonce:        0,
 	}
 
-	bc.mineBlock(newBlock, 4)
+	if err := bc.mineBlock(ctx, newBlock, bc.difficulty); err != nil {
+		return nil, err
+	}
+	// A mined block is stored even if ctx is cancelled meanwhile, since the
+	// caller may already be recording it elsewhere.
+	if err := bc.store.Append(context.WithoutCancel(ctx), newBlock); err != nil {
+		return nil, fmt.Errorf("failed to store block %d: %v", newBlock.Index, err)
+	}
+	bc.chainMu.Lock()
 	bc.Chain = append(bc.Chain, newBlock)
-	return newBlock
+	bc.chainMu.Unlock()
+	bc.events.Publish(Event{Type: EventBlock, Index: newBlock.Index, IssuerID: BlockIssuer(newBlock), Data: Summarize(newBlock)})
+	return newBlock, nil
 }
 
-func (bc *Blockchain) IsValid() bool {
-	for i := 1; i < len(bc.Chain); i++ {
-		currentBlock := bc.Chain[i]
-		prevBlock := bc.Chain[i-1]
+// Events returns the hub that announces new blocks and anything published
+// with Publish.
+func (bc *Blockchain) Events() *Hub {
+	return bc.events
+}
 
-		if currentBlock.Hash != bc.calculateHash(currentBlock) {
-			return false
-		}
+// Publish announces an event about the ledger as a whole, such as an alert.
+func (bc *Blockchain) Publish(eventType string, data interface{}) {
+	bc.events.Publish(Event{Type: eventType, Data: data})
+}
 
-		if currentBlock.PreviousHash != prevBlock.Hash {
-			return false
-		}
+// PublishFor announces an event about a certificate of the issuer, such as
+// a revocation.
+func (bc *Blockchain) PublishFor(eventType, issuerID string, data interface{}) {
+	bc.events.Publish(Event{Type: eventType, IssuerID: issuerID, Data: data})
+}
+
+// Status summarizes the chain for monitoring.
+type Status struct {
+	Height     int    `json:"height"`
+	LatestHash string `json:"latestHash"`
+	Valid      bool   `json:"valid"`
+}
+
+// Status reports the latest block and whether the chain is intact.
+func (bc *Blockchain) Status() Status {
+	bc.chainMu.RLock()
+	defer bc.chainMu.RUnlock()
+
+	latest := bc.Chain[len(bc.Chain)-1]
+	return Status{
+		Height:     latest.Index,
+		LatestHash: latest.Hash,
+		Valid:      bc.firstInvalid() == nil,
 	}
-	return true
+}
+
+// BlocksAfter returns the blocks with an index greater than index, oldest
+// first.
+func (bc *Blockchain) BlocksAfter(index int) []*Block {
+	bc.chainMu.RLock()
+	defer bc.chainMu.RUnlock()
+
+	if index < -1 {
+		index = -1
+	}
+	if index+1 >= len(bc.Chain) {
+		return nil
+	}
+	return append([]*Block(nil), bc.Chain[index+1:]...)
+}
+
+func (bc *Blockchain) IsValid() bool {
+	bc.chainMu.RLock()
+	defer bc.chainMu.RUnlock()
+	return bc.firstInvalid() == nil
 }
 
 func (bc *Blockchain) GetBlock(hash string) (*Block, error) {
+	bc.chainMu.RLock()
+	defer bc.chainMu.RUnlock()
 	for _, block := range bc.Chain {
 		if block.Hash == hash {
 			return block, nil
@@ -118,6 +232,8 @@ func (bc *Blockchain) GetBlock(hash string) (*Block, error) {
 }
 
 func (bc *Blockchain) GetLatestBlock() *Block {
+	bc.chainMu.RLock()
+	defer bc.chainMu.RUnlock()
 	return bc.Chain[len(bc.Chain)-1]
 }
 
@@ -0,0 +1,139 @@
+package blockchain
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types published on a chain's hub.
+const (
+	EventBlock      = "block"
+	EventRevocation = "revocation"
+	EventAlert      = "alert"
+)
+
+// Event is something that happened to the ledger. Index is the new block's
+// index on block events and zero otherwise; blocks are the only events a
+// subscriber can catch up on after missing them. IssuerID is the issuer of
+// the certificate the event is about, and empty for events about the ledger
+// as a whole, such as alerts.
+type Event struct {
+	Type     string
+	Index    int
+	IssuerID string
+	Data     interface{}
+}
+
+// BlockSummary describes a block without its data, which holds the
+// certificate's personal details.
+type BlockSummary struct {
+	Index        int       `json:"index"`
+	Timestamp    time.Time `json:"timestamp"`
+	Hash         string    `json:"hash"`
+	PreviousHash string    `json:"previousHash"`
+	Nonce        int       `json:"nonce"`
+}
+
+func Summarize(block *Block) BlockSummary {
+	return BlockSummary{
+		Index:        block.Index,
+		Timestamp:    block.Timestamp,
+		Hash:         block.Hash,
+		PreviousHash: block.PreviousHash,
+		Nonce:        block.Nonce,
+	}
+}
+
+// Revocation is the data of a revocation event.
+type Revocation struct {
+	CertificateID string    `json:"certificateId"`
+	BlockIndex    int       `json:"blockIndex"`
+	RevokedAt     time.Time `json:"revokedAt"`
+}
+
+// Alert is the data of an alert event: a problem found while checking the
+// ledger. BlockIndex is zero when the problem is not tied to one block.
+type Alert struct {
+	Message    string    `json:"message"`
+	BlockIndex int       `json:"blockIndex,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+// Hub fans events out to subscribers. Publishing never blocks: a subscriber
+// that falls a full buffer behind is dropped, and is expected to resubscribe
+// and catch up on the blocks it missed.
+type Hub struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscription receives the events published after it was created.
+type Subscription struct {
+	hub    *Hub
+	events chan Event
+}
+
+// Events is closed when the subscription is closed, dropped for falling
+// behind, or the hub is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close stops the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// Subscribe returns a subscription buffering up to buffer events.
+func (h *Hub) Subscribe(buffer int) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub := &Subscription{hub: h, events: make(chan Event, buffer)}
+	if h.closed {
+		close(sub.events)
+		return sub
+	}
+	h.subs[sub] = struct{}{}
+	return sub
+}
+
+func (h *Hub) Publish(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs {
+		select {
+		case sub.events <- event:
+		default:
+			h.remove(sub)
+		}
+	}
+}
+
+// Close ends every subscription, for example when the server shuts down.
+// Later subscriptions are closed at once.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for sub := range h.subs {
+		h.remove(sub)
+	}
+}
+
+// remove must be called with h.mu held.
+func (h *Hub) remove(sub *Subscription) {
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.events)
+	}
+}
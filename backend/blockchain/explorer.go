@@ -0,0 +1,171 @@
+package blockchain
+
+import (
+	"fmt"
+	"time"
+
+	"certificate-ledger/domain"
+)
+
+// Listing limits for the explorer.
+const (
+	DefaultBlockLimit = 20
+	MaxBlockLimit     = 100
+)
+
+// Info describes the chain as a whole.
+type Info struct {
+	Height      int    `json:"height"`
+	HeadHash    string `json:"headHash"`
+	GenesisHash string `json:"genesisHash"`
+	Difficulty  int    `json:"difficulty"`
+}
+
+// InvalidBlock is the first block that breaks the chain and why.
+type InvalidBlock struct {
+	Index  int    `json:"index"`
+	Hash   string `json:"hash"`
+	Reason string `json:"reason"`
+}
+
+// Health is the result of checking every block of the chain.
+type Health struct {
+	Valid         bool          `json:"valid"`
+	Height        int           `json:"height"`
+	CheckedBlocks int           `json:"checkedBlocks"`
+	FirstInvalid  *InvalidBlock `json:"firstInvalid,omitempty"`
+	CheckedAt     time.Time     `json:"checkedAt"`
+}
+
+// BlockPage lists blocks newest first. NextCursor is the index of the last
+// block listed; pass it back to continue below it.
+type BlockPage struct {
+	Items      []BlockSummary `json:"items"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
+
+// BlockDetail is a block with the certificate record it holds: the
+// certificate's ID, timestamp and the commitments to its fields, which reveal
+// nothing of the values. Data is left out for blocks that hold no
+// certificate, such as the genesis block, rather than passed on undecoded.
+type BlockDetail struct {
+	BlockSummary
+	Data *domain.CertificateBlock `json:"data,omitempty"`
+}
+
+func Detail(block *Block) BlockDetail {
+	detail := BlockDetail{BlockSummary: Summarize(block)}
+	if record, err := domain.ParseCertificateBlock(block.Data); err == nil {
+		detail.Data = record
+	}
+	return detail
+}
+
+// BlockIssuer returns the issuer of the certificate a block records, or ""
+// for the genesis block and any block that records no certificate.
+func BlockIssuer(block *Block) string {
+	record, err := domain.ParseCertificateBlock(block.Data)
+	if err != nil {
+		return ""
+	}
+	return record.IssuerID
+}
+
+func (bc *Blockchain) Info() Info {
+	bc.chainMu.RLock()
+	defer bc.chainMu.RUnlock()
+
+	return Info{
+		Height:      bc.Chain[len(bc.Chain)-1].Index,
+		HeadHash:    bc.Chain[len(bc.Chain)-1].Hash,
+		GenesisHash: bc.Chain[0].Hash,
+		Difficulty:  bc.difficulty,
+	}
+}
+
+// Health checks every block, as IsValid does, and names the first one that
+// fails.
+func (bc *Blockchain) Health() Health {
+	bc.chainMu.RLock()
+	defer bc.chainMu.RUnlock()
+
+	invalid := bc.firstInvalid()
+	return Health{
+		Valid:         invalid == nil,
+		Height:        bc.Chain[len(bc.Chain)-1].Index,
+		CheckedBlocks: len(bc.Chain),
+		FirstInvalid:  invalid,
+		CheckedAt:     time.Now().UTC(),
+	}
+}
+
+// Blocks returns up to limit blocks, newest first, starting below index
+// before; a negative before starts at the latest block. When include is not
+// nil, only the blocks it accepts are returned.
+func (bc *Blockchain) Blocks(before, limit int, include func(*Block) bool) []*Block {
+	bc.chainMu.RLock()
+	defer bc.chainMu.RUnlock()
+
+	if before < 0 || before > len(bc.Chain) {
+		before = len(bc.Chain)
+	}
+	blocks := make([]*Block, 0, limit)
+	for i := before - 1; i >= 0 && len(blocks) < limit; i-- {
+		if include == nil || include(bc.Chain[i]) {
+			blocks = append(blocks, bc.Chain[i])
+		}
+	}
+	return blocks
+}
+
+// BlockByIndex returns the block at index, or false if there is none.
+func (bc *Blockchain) BlockByIndex(index int) (*Block, bool) {
+	bc.chainMu.RLock()
+	defer bc.chainMu.RUnlock()
+
+	if index < 0 || index >= len(bc.Chain) {
+		return nil, false
+	}
+	return bc.Chain[index], true
+}
+
+// Pin records a checkpoint co-signed by witnesses: from now on the chain is
+// only valid if its block at height has hash.
+func (bc *Blockchain) Pin(height int, hash string) {
+	bc.chainMu.Lock()
+	defer bc.chainMu.Unlock()
+
+	bc.checkpoints[height] = hash
+}
+
+// firstInvalid returns the first block whose hash does not match its
+// contents, that does not link to the block before it or that conflicts with
+// a pinned checkpoint. A chain shorter than a checkpoint is invalid at the
+// checkpoint's height. The caller must hold chainMu.
+func (bc *Blockchain) firstInvalid() *InvalidBlock {
+	for i := 0; i < len(bc.Chain); i++ {
+		block := bc.Chain[i]
+		if i > 0 {
+			prev := bc.Chain[i-1]
+			switch {
+			case block.Hash != bc.calculateHash(block):
+				return &InvalidBlock{Index: block.Index, Hash: block.Hash, Reason: "hash does not match the block's contents"}
+			case block.PreviousHash != prev.Hash:
+				return &InvalidBlock{Index: block.Index, Hash: block.Hash, Reason: fmt.Sprintf("previous hash does not match block %d", prev.Index)}
+			}
+		}
+		if hash, ok := bc.checkpoints[i]; ok && block.Hash != hash {
+			return &InvalidBlock{Index: block.Index, Hash: block.Hash, Reason: fmt.Sprintf("conflicts with the co-signed checkpoint %s", hash)}
+		}
+	}
+	missing := -1
+	for height := range bc.checkpoints {
+		if height >= len(bc.Chain) && (missing < 0 || height < missing) {
+			missing = height
+		}
+	}
+	if missing >= 0 {
+		return &InvalidBlock{Index: missing, Reason: fmt.Sprintf("chain ends below the co-signed checkpoint %s", bc.checkpoints[missing])}
+	}
+	return nil
+}
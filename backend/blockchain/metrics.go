@@ -0,0 +1,28 @@
+package blockchain
+
+import (
+	"time"
+
+	"certificate-ledger/metrics"
+)
+
+// Results of a mining run, in the "result" label.
+const (
+	miningMined     = "mined"
+	miningCancelled = "cancelled"
+)
+
+var (
+	miningDuration = metrics.NewHistogramVec("ledger_mining_duration_seconds",
+		"Time spent mining a block, by result.",
+		metrics.ExponentialBuckets(.001, 4, 10), "result")
+	miningNonceAttempts = metrics.NewHistogramVec("ledger_mining_nonce_attempts",
+		"Nonces tried to mine a block, by result.",
+		metrics.ExponentialBuckets(1, 16, 8), "result")
+)
+
+// recordMining records a mining run that tried attempts nonces.
+func recordMining(start time.Time, attempts int, result string) {
+	miningDuration.ObserveSince(start, result)
+	miningNonceAttempts.Observe(float64(attempts), result)
+}
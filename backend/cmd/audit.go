@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"certificate-ledger/config"
+	"certificate-ledger/repository"
+	"certificate-ledger/service"
+)
+
+func runAudit(cfg config.Database, action string, args []string) error {
+	if action != "verify" {
+		return fmt.Errorf("unknown action %q\n%s", action, commandUsage)
+	}
+	if cfg.Backend == config.BackendMemory {
+		return fmt.Errorf("the memory backend keeps no audit log between runs")
+	}
+
+	var headSeq int64
+	var headHash string
+	if len(args) > 0 {
+		seq, hash, ok := strings.Cut(args[0], ":")
+		n, err := strconv.ParseInt(seq, 10, 64)
+		if !ok || err != nil || n < 1 || hash == "" {
+			return fmt.Errorf("head must be given as seq:hash")
+		}
+		headSeq, headHash = n, hash
+	}
+
+	conn, _, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	audit := service.NewAuditService(repository.NewSQLAuditRepository(conn))
+	result, err := audit.Verify(ctx, headSeq, headHash)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("checked %d entries\n", result.Entries)
+	if result.Entries > 0 {
+		fmt.Printf("head %d:%s\n", result.HeadSeq, result.HeadHash)
+	}
+	for _, p := range result.Problems {
+		fmt.Printf("entry %d: %s\n", p.Seq, p.Problem)
+	}
+	if !result.Valid() {
+		return fmt.Errorf("found %d problems", len(result.Problems))
+	}
+	fmt.Println("audit log is intact")
+	return nil
+}
@@ -2,8 +2,17 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,10 +21,19 @@ import (
 
 	"certificate-ledger/api/handler"
 	"certificate-ledger/blockchain"
+	"certificate-ledger/config"
 	"certificate-ledger/db"
 	"certificate-ledger/domain"
+	"certificate-ledger/mail"
+	"certificate-ledger/metrics"
+	"certificate-ledger/oidc"
 	"certificate-ledger/repository"
+	"certificate-ledger/repository/memory"
+	"certificate-ledger/search"
 	"certificate-ledger/service"
+	"certificate-ledger/tsa"
+	"certificate-ledger/tsa/tsatest"
+	"certificate-ledger/witness"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -27,74 +45,299 @@ func main() {
 	// Load .env
     if err := godotenv.Load(); err != nil {
     log.Printf("Error loading .env file: %v", err)
-	} else {
-		log.Printf("JWT_SECRET from env: %s", os.Getenv("JWT_SECRET"))
 	}
 
-	// Khởi tạo kết nối MySQL
-	db, err := db.NewDB()
+	// Đọc cấu hình: file JSON, biến môi trường rồi flag
+	cfg, args, err := config.Load(os.Args[1:])
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		if errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, commandUsage)
+			os.Exit(0)
+		}
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Lệnh quản trị: migrate up|down|status
+	if len(args) > 0 {
+		os.Exit(runCommand(cfg, args))
 	}
-	defer db.Close()
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+
+	// Context của cả tiến trình: mọi request và job nền kế thừa nó, huỷ khi tắt server
+	appCtx, stopApp := context.WithCancel(context.Background())
+	defer stopApp()
+
+	// Khởi tạo kho lưu trữ (MySQL, SQLite hoặc bộ nhớ)
+	repos, closeStorage, err := openStorage(appCtx, cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to open %s storage: %v", cfg.Database.Backend, err)
+	}
+	defer closeStorage()
 
 	// Khởi tạo blockchain
-	bc := blockchain.NewBlockchain()
+	bc, err := blockchain.NewBlockchain(appCtx, cfg.Blockchain.Difficulty, repos.Blocks)
+	if err != nil {
+		log.Fatalf("Failed to load blockchain: %v", err)
+	}
+	metrics.PublishRuntimeStats()
+	metrics.NewGaugeFunc("ledger_chain_height", "Index of the last block of the chain.", func() float64 {
+		return float64(bc.Info().Height)
+	})
 
 	// Khởi tạo repository
-	certRepo := repository.NewCertificateRepository(db)
-	userRepo := repository.NewUserRepository(db)
+	certRepo := repos.Certificates
+	userRepo := repos.Users
+	apiKeyRepo := repos.APIKeys
+	recoveryCodeRepo := repos.RecoveryCodes
+	userTokenRepo := repos.UserTokens
+	loginThrottleRepo := repos.LoginThrottles
+	identityRepo := repos.Identities
+	shareLinkRepo := repos.ShareLinks
+	bulkIssueRepo := repos.BulkIssues
+	auditRepo := repos.Audit
+	webhookRepo := repos.Webhooks
+	notificationRepo := repos.Notifications
+	integrityScanRepo := repos.IntegrityScans
+	anchorRepo := repos.Anchors
+	checkpointRepo := repos.Checkpoints
+	witnessedChainRepo := repos.Witnessed
+
+	// Khởi tạo mailer
+	mailer := newMailer(cfg.Mail)
+
+	searchIndex, err := newSearchIndex(appCtx, cfg, certRepo)
+	if err != nil {
+		log.Fatalf("Failed to build search index: %v", err)
+	}
 
 	// Khởi tạo service
-	certService := service.NewCertificateService(certRepo, bc)
-	userService := service.NewUserService(userRepo)
-	authService := service.NewAuthService(userRepo)
+	auditService := service.NewAuditService(auditRepo)
+	certService := service.NewCertificateService(certRepo, bc, searchIndex, auditService)
+	userService := service.NewUserService(userRepo, auditService)
+	loginThrottleService := service.NewLoginThrottleService(loginThrottleRepo, userRepo, auditService)
+	authService := service.NewAuthService(userRepo, recoveryCodeRepo, loginThrottleService, service.JWTConfig{
+		Secret: cfg.JWT.Secret,
+		TTL:    cfg.JWT.TTL,
+		Issuer: cfg.JWT.Issuer,
+	}, auditService, cfg.TOTP.Issuer)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo, userRepo, auditService)
+	shareService := service.NewShareService(shareLinkRepo, certRepo, bc)
+	bulkIssueService := service.NewBulkIssueService(appCtx, bulkIssueRepo, certService, auditService)
+	webhookService := service.NewWebhookService(webhookRepo, auditService, service.WebhookConfig{
+		Timeout:      cfg.Webhooks.Timeout,
+		MaxAttempts:  cfg.Webhooks.MaxAttempts,
+		RetryBase:    cfg.Webhooks.RetryBase,
+		RetryMax:     cfg.Webhooks.RetryMax,
+		PollInterval: cfg.Webhooks.PollInterval,
+	})
+	certService.AddListener(webhookService)
+	notificationService := service.NewNotificationService(notificationRepo, mailer, auditService, service.NotificationConfig{
+		BaseURL:       cfg.Server.AppBaseURL,
+		DefaultLocale: cfg.Notifications.DefaultLocale,
+		MaxAttempts:   cfg.Notifications.MaxAttempts,
+		RetryBase:     cfg.Notifications.RetryBase,
+		RetryMax:      cfg.Notifications.RetryMax,
+		PollInterval:  cfg.Notifications.PollInterval,
+	})
+	certService.AddListener(notificationService)
+	accountService := service.NewAccountService(userRepo, userTokenRepo, apiKeyRepo, certService, notificationService, loginThrottleService, cfg.JWT.Secret, cfg.Server.AppBaseURL)
+	integrityService := service.NewIntegrityService(certRepo, integrityScanRepo, repos.Blocks, bc, webhookService, mailer, auditService, service.IntegrityConfig{
+		Interval:    cfg.Integrity.Interval,
+		AlertEmails: cfg.Integrity.AlertEmails,
+	})
+
+	// Neo đầu chuỗi vào time-stamp authority (RFC 3161) nếu được cấu hình.
+	// Chứng chỉ gốc được nạp riêng để vẫn kiểm tra được các neo đã lưu khi tắt neo
+	anchorRoots, err := newTSARoots(cfg.Anchor)
+	if err != nil {
+		log.Fatalf("Failed to load the time-stamp authority roots: %v", err)
+	}
+	tsaClient, closeTSA, err := newTSAClient(cfg.Anchor, anchorRoots)
+	if err != nil {
+		log.Fatalf("Failed to set up the time-stamp authority: %v", err)
+	}
+	defer closeTSA()
+	if cfg.Anchor.TSAURL == config.LocalTSA && anchorRoots == nil {
+		// TSA cục bộ tự cấp chứng chỉ nên chỉ tin chính nó
+		anchorRoots = tsaClient.Roots
+	}
+	if anchorRoots == nil {
+		log.Println("No time-stamp authority roots configured, stored anchors cannot be verified")
+	}
+	anchorService := service.NewAnchorService(anchorRepo, certRepo, bc, tsaClient, auditService, service.AnchorConfig{
+		Interval: cfg.Anchor.Interval,
+		Roots:    anchorRoots,
+	})
+
+	// Checkpoint do các witness (tổ chức khác) đồng ký; checkpoint đủ chữ ký được ghim vào chuỗi
+	witnesses, err := newWitnessClients(cfg.Checkpoints)
+	if err != nil {
+		log.Fatalf("Failed to set up witnesses: %v", err)
+	}
+	// Khóa ký yêu cầu gửi witness; chỉ cần khi có witness (đã kiểm tra trong config)
+	originKey, _ := witness.ParsePrivateKey(cfg.Checkpoints.SigningKey)
+	if len(witnesses) > 0 {
+		log.Printf("Checkpoint origin %s signs with public key %s", cfg.Checkpoints.Origin, hex.EncodeToString(originKey.Public().(ed25519.PublicKey)))
+	}
+	checkpointService := service.NewCheckpointService(checkpointRepo, bc, witnesses, auditService, service.CheckpointConfig{
+		Origin:   cfg.Checkpoints.Origin,
+		Key:      originKey,
+		Interval: cfg.Checkpoints.Interval,
+		Quorum:   cfg.Checkpoints.Quorum,
+	})
+	if err := checkpointService.PinCosigned(appCtx); err != nil {
+		log.Fatalf("Failed to load co-signed checkpoints: %v", err)
+	}
 
-	// Tạo tài khoản admin nếu chưa tồn tại
-	if err := createAdminUser(userRepo); err != nil {
+	// Tạo tài khoản admin nếu chưa có admin nào
+	if err := createAdminUser(appCtx, userRepo, auditService, cfg.Admin); err != nil {
 		log.Printf("Failed to create admin user: %v", err)
 	}
 
+	bulkIssueService.Start(appCtx)
+	webhookService.Start(appCtx)
+	notificationService.Start(appCtx)
+	integrityService.Start(appCtx)
+	anchorService.Start(appCtx)
+	checkpointService.Start(appCtx)
+
 	// Khởi tạo handler
 	certHandler := handler.NewCertificateHandler(certService)
-	userHandler := handler.NewUserHandler(userService)
-	authHandler := handler.NewAuthHandler(authService)
+	userHandler := handler.NewUserHandler(userService, certService)
+	authHandler := handler.NewAuthHandler(authService, accountService)
+	accountHandler := handler.NewAccountHandler(accountService)
+	lockoutHandler := handler.NewLockoutHandler(loginThrottleService)
+	shareHandler := handler.NewShareHandler(shareService)
+	bulkIssueHandler := handler.NewBulkIssueHandler(bulkIssueService)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService)
+	auditHandler := handler.NewAuditHandler(auditService)
+	notificationHandler := handler.NewNotificationHandler(notificationService)
+	integrityHandler := handler.NewIntegrityHandler(integrityService)
+	chainHandler := handler.NewChainHandler(bc)
+	anchorHandler := handler.NewAnchorHandler(anchorService)
+	checkpointHandler := handler.NewCheckpointHandler(checkpointService)
+	webhookHandler := handler.NewWebhookHandler(webhookService)
 
 	// Thiết lập router
 	r := mux.NewRouter()
+	r.Use(handler.MetricsMiddleware)
+	r.Use(handler.RequestMetaMiddleware(cfg.Server.TrustProxy))
+
+	// Metrics cho Prometheus (cần METRICS_TOKEN làm bearer token nếu được đặt)
+	r.HandleFunc("/metrics", handler.NewMetricsHandler(cfg.Server.MetricsToken).GetMetrics).Methods("GET")
 
 	// API công khai
 	r.HandleFunc("/api/auth/login", authHandler.Login).Methods("POST")
 	r.HandleFunc("/api/auth/register", authHandler.Register).Methods("POST")
+	r.HandleFunc("/api/auth/login/totp", authHandler.LoginTOTP).Methods("POST")
+	r.HandleFunc("/api/auth/login/totp/enroll", authHandler.BeginLoginEnrollment).Methods("POST")
+	r.HandleFunc("/api/auth/email/verify", accountHandler.VerifyEmail).Methods("POST")
+	r.HandleFunc("/api/auth/password/forgot", accountHandler.ForgotPassword).Methods("POST")
+	r.HandleFunc("/api/auth/password/reset", accountHandler.ResetPassword).Methods("POST")
+	r.HandleFunc("/api/shares/{token}", shareHandler.ViewLink).Methods("GET")
+
+	// Đăng nhập SSO qua OpenID Connect (chỉ bật khi có OIDC_ISSUER_URL)
+	if cfg.OIDC.Enabled() {
+		ssoHandler := handler.NewSSOHandler(newSSOService(cfg.OIDC, userRepo, identityRepo, authService), cfg.Server.AppBaseURL)
+		r.HandleFunc("/api/auth/sso/login", ssoHandler.Login).Methods("GET")
+		r.HandleFunc("/api/auth/sso/callback", ssoHandler.Callback).Methods("GET")
+	}
+
+	// Giao thức witness: đồng ký checkpoint của ledger khác (chỉ bật khi có WITNESS_SIGNING_KEY)
+	if cfg.Witness.Enabled() {
+		signingKey, _ := witness.ParsePrivateKey(cfg.Witness.SigningKey)
+		origins, _ := cfg.Witness.OriginKeys()
+		witnessHandler := handler.NewWitnessHandler(service.NewWitnessService(witnessedChainRepo, cfg.Witness.Name, signingKey, origins))
+		r.HandleFunc(witness.PathPrefix+"/key", witnessHandler.GetKey).Methods("GET")
+		r.HandleFunc(witness.PathPrefix+"/chains/{origin}", witnessHandler.GetChain).Methods("GET")
+		r.HandleFunc(witness.PathPrefix+"/cosign", witnessHandler.Cosign).Methods("POST")
+		log.Printf("Witnessing %d other ledgers as %s", len(origins), cfg.Witness.Name)
+	}
 
 	// API yêu cầu xác thực
 	protectedRouter := r.PathPrefix("/api").Subrouter()
-	protectedRouter.Use(handler.AuthMiddleware(userRepo))
-	protectedRouter.HandleFunc("/certificates", certHandler.CreateCertificate).Methods("POST")
-	protectedRouter.HandleFunc("/certificates", certHandler.GetAllCertificates).Methods("GET")
-	protectedRouter.HandleFunc("/certificates/{id}", certHandler.GetCertificate).Methods("GET")
-	protectedRouter.HandleFunc("/certificates/verify/{hash}", certHandler.VerifyCertificate).Methods("GET")
+	protectedRouter.Use(handler.AuthMiddleware(authService, apiKeyService))
+	protectedRouter.HandleFunc("/certificates", certHandler.CreateCertificate).Methods("POST").Name(handler.RouteCreateCertificate)
+	protectedRouter.HandleFunc("/certificates", certHandler.GetAllCertificates).Methods("GET").Name(handler.RouteListCertificates)
+	protectedRouter.HandleFunc("/certificates/bulk", bulkIssueHandler.Upload).Methods("POST").Name(handler.RouteBulkIssue)
+	protectedRouter.HandleFunc("/certificates/bulk", bulkIssueHandler.ListJobs).Methods("GET").Name(handler.RouteListBulkIssueJobs)
+	protectedRouter.HandleFunc("/certificates/bulk/{id}", bulkIssueHandler.GetJob).Methods("GET").Name(handler.RouteGetBulkIssueJob)
+	protectedRouter.HandleFunc("/certificates/bulk/{id}/rows", bulkIssueHandler.GetRows).Methods("GET").Name(handler.RouteGetBulkIssueRows)
+	protectedRouter.HandleFunc("/certificates/bulk/{id}/result.csv", bulkIssueHandler.DownloadResult).Methods("GET").Name(handler.RouteDownloadBulkIssueResult)
+	protectedRouter.HandleFunc("/certificates/search", certHandler.SearchCertificates).Methods("GET").Name(handler.RouteSearchCertificates)
+	protectedRouter.HandleFunc("/certificates/{id}", certHandler.GetCertificate).Methods("GET").Name(handler.RouteGetCertificate)
+	protectedRouter.HandleFunc("/certificates/verify/{hash}", certHandler.VerifyCertificate).Methods("GET").Name(handler.RouteVerifyCertificate)
+	protectedRouter.HandleFunc("/certificates/verify/{hash}/anchor", anchorHandler.VerifyCertificate).Methods("GET").Name(handler.RouteVerifyAnchor)
+	protectedRouter.HandleFunc("/certificates/{id}/claim", certHandler.ClaimCertificate).Methods("POST")
+	protectedRouter.HandleFunc("/certificates/{id}/revoke", certHandler.RevokeCertificate).Methods("POST").Name(handler.RouteRevokeCertificate)
+	protectedRouter.HandleFunc("/certificates/{id}/renew", certHandler.RenewCertificate).Methods("POST").Name(handler.RouteRenewCertificate)
+	protectedRouter.HandleFunc("/certificates/{id}/shares", shareHandler.CreateLink).Methods("POST")
+	protectedRouter.HandleFunc("/certificates/{id}/shares", shareHandler.ListLinks).Methods("GET")
+	protectedRouter.HandleFunc("/shares/{token}", shareHandler.RevokeLink).Methods("DELETE")
 	protectedRouter.HandleFunc("/users", userHandler.CreateUser).Methods("POST")
 	protectedRouter.HandleFunc("/users/{id}", userHandler.GetUser).Methods("GET")
 	protectedRouter.HandleFunc("/users/{id}/certificates", userHandler.GetUserCertificates).Methods("GET")
+	protectedRouter.HandleFunc("/auth/password/change", authHandler.ChangePassword).Methods("POST").Name(handler.RouteChangePassword)
+	protectedRouter.HandleFunc("/auth/email/verify/request", accountHandler.RequestEmailVerification).Methods("POST")
+	protectedRouter.HandleFunc("/auth/totp/enroll", authHandler.BeginEnrollment).Methods("POST")
+	protectedRouter.HandleFunc("/auth/totp/confirm", authHandler.ConfirmEnrollment).Methods("POST")
+	protectedRouter.HandleFunc("/auth/totp/disable", authHandler.DisableTOTP).Methods("POST")
+	protectedRouter.HandleFunc("/auth/totp/recovery-codes", authHandler.RegenerateRecoveryCodes).Methods("POST")
+	protectedRouter.HandleFunc("/keys", apiKeyHandler.CreateKey).Methods("POST")
+	protectedRouter.HandleFunc("/keys", apiKeyHandler.ListKeys).Methods("GET")
+	protectedRouter.HandleFunc("/keys/{id}", apiKeyHandler.RevokeKey).Methods("DELETE")
+	protectedRouter.HandleFunc("/chain", chainHandler.GetChain).Methods("GET").Name(handler.RouteChainInfo)
+	protectedRouter.HandleFunc("/chain/blocks", chainHandler.ListBlocks).Methods("GET").Name(handler.RouteListBlocks)
+	protectedRouter.HandleFunc("/chain/blocks/{ref}", chainHandler.GetBlock).Methods("GET").Name(handler.RouteGetBlock)
+	protectedRouter.HandleFunc("/chain/health", chainHandler.GetHealth).Methods("GET").Name(handler.RouteChainHealth)
+	protectedRouter.HandleFunc("/chain/events", chainHandler.StreamEvents).Methods("GET").Name(handler.RouteChainEvents)
+	protectedRouter.HandleFunc("/chain/anchors", anchorHandler.ListAnchors).Methods("GET").Name(handler.RouteListAnchors)
+	protectedRouter.HandleFunc("/chain/anchors/{id}", anchorHandler.GetAnchor).Methods("GET").Name(handler.RouteGetAnchor)
+	protectedRouter.HandleFunc("/chain/anchors/{id}/receipt", anchorHandler.GetReceipt).Methods("GET").Name(handler.RouteGetAnchorReceipt)
+	protectedRouter.HandleFunc("/chain/anchors/{id}/verify", anchorHandler.VerifyAnchor).Methods("GET").Name(handler.RouteCheckAnchor)
+	protectedRouter.HandleFunc("/chain/checkpoints", checkpointHandler.ListCheckpoints).Methods("GET").Name(handler.RouteListCheckpoints)
+	protectedRouter.HandleFunc("/chain/checkpoints/{id}", checkpointHandler.GetCheckpoint).Methods("GET").Name(handler.RouteGetCheckpoint)
+	protectedRouter.HandleFunc("/webhooks", webhookHandler.CreateSubscription).Methods("POST")
+	protectedRouter.HandleFunc("/webhooks", webhookHandler.ListSubscriptions).Methods("GET")
+	protectedRouter.HandleFunc("/webhooks/{id}", webhookHandler.GetSubscription).Methods("GET")
+	protectedRouter.HandleFunc("/webhooks/{id}", webhookHandler.DeleteSubscription).Methods("DELETE")
+	protectedRouter.HandleFunc("/webhooks/{id}/deliveries", webhookHandler.ListDeliveries).Methods("GET")
+	protectedRouter.HandleFunc("/webhooks/{id}/deliveries/{deliveryId}", webhookHandler.GetDelivery).Methods("GET")
+	protectedRouter.HandleFunc("/webhooks/{id}/deliveries/{deliveryId}/replay", webhookHandler.ReplayDelivery).Methods("POST")
+	protectedRouter.HandleFunc("/webhooks/{id}/dead-letters/replay", webhookHandler.ReplayDeadLetters).Methods("POST")
 
 	// API admin
 	adminRouter := r.PathPrefix("/api/admin").Subrouter()
-	adminRouter.Use(handler.AuthMiddleware(userRepo))
+	adminRouter.Use(handler.AuthMiddleware(authService, apiKeyService))
 	adminRouter.Use(handler.AdminMiddleware)
 	adminRouter.HandleFunc("/users", userHandler.ListUsers).Methods("GET")
 	adminRouter.HandleFunc("/users/{id}", userHandler.DeleteUser).Methods("DELETE")
+	adminRouter.HandleFunc("/users/{id}/unlock", lockoutHandler.UnlockUser).Methods("POST")
+	adminRouter.HandleFunc("/lockouts", lockoutHandler.ListLockouts).Methods("GET")
+	adminRouter.HandleFunc("/lockouts/events", lockoutHandler.ListEvents).Methods("GET")
+	adminRouter.HandleFunc("/lockouts/ip/{ip}/unlock", lockoutHandler.UnlockIP).Methods("POST")
+	adminRouter.HandleFunc("/audit", auditHandler.ListEntries).Methods("GET")
+	adminRouter.HandleFunc("/audit/verify", auditHandler.Verify).Methods("GET")
+	adminRouter.HandleFunc("/notifications", notificationHandler.ListNotifications).Methods("GET")
+	adminRouter.HandleFunc("/notifications/{id}/retry", notificationHandler.RetryNotification).Methods("POST")
+	adminRouter.HandleFunc("/integrity/scans", integrityHandler.ListScans).Methods("GET")
+	adminRouter.HandleFunc("/integrity/scans", integrityHandler.RunScan).Methods("POST")
+	adminRouter.HandleFunc("/integrity/scans/{id}", integrityHandler.GetScan).Methods("GET")
+	adminRouter.HandleFunc("/anchors", anchorHandler.CreateAnchor).Methods("POST")
+	adminRouter.HandleFunc("/checkpoints", checkpointHandler.CreateCheckpoint).Methods("POST")
 
 	// CORS middleware
-	allowedOrigins := os.Getenv("ALLOWED_ORIGINS")
-	if allowedOrigins == "" {
-		allowedOrigins = "http://localhost:3000"
-	}
 	corsMiddleware := func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", allowedOrigins)
+			w.Header().Add("Vary", "Origin")
+			if origin := allowedOrigin(cfg.Server.AllowedOrigins, r.Header.Get("Origin")); origin != "" {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, Last-Event-ID")
 			if r.Method == "OPTIONS" {
 				w.WriteHeader(http.StatusOK)
 				return
@@ -105,17 +348,27 @@ func main() {
 
 	// Tạo server với timeout
 	srv := &http.Server{
-		Addr:         ":8080",
+		Addr:         cfg.Server.Addr,
 		Handler:      corsMiddleware(r),
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  15 * time.Second,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		BaseContext:  func(net.Listener) context.Context { return appCtx },
 	}
+	// Shutdown không chờ các kết nối SSE đang mở: đóng hub để chúng kết thúc
+	srv.RegisterOnShutdown(bc.Events().Close)
 
 	// Graceful shutdown
 	go func() {
-		log.Println("Server starting on port 8080...")
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if cfg.Server.TLSEnabled() {
+			log.Printf("Server starting on %s (TLS)...", cfg.Server.Addr)
+			err = srv.ListenAndServeTLS(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+		} else {
+			log.Printf("Server starting on %s...", cfg.Server.Addr)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server failed: %v", err)
 		}
 	}()
@@ -125,41 +378,275 @@ func main() {
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 	<-stop
 
-	// Tắt server an toàn
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// Tắt server an toàn: chờ request đang chạy tối đa ShutdownTimeout, rồi huỷ
+	// phần còn lại (kể cả việc đào block) và chờ chúng trả lời
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server shutdown failed: %v", err)
+		log.Printf("Requests still running after %s, cancelling them", cfg.Server.ShutdownTimeout)
+		stopApp()
+		drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		defer cancelDrain()
+		if err := srv.Shutdown(drainCtx); err != nil {
+			log.Fatalf("Server shutdown failed: %v", err)
+		}
 	}
+
+	// Dừng các job nền; job dở dang sẽ tiếp tục ở lần khởi động sau
+	stopApp()
+	bulkIssueService.Wait()
+	webhookService.Wait()
+	notificationService.Wait()
+	integrityService.Wait()
+	anchorService.Wait()
+	checkpointService.Wait()
 	log.Println("Server stopped gracefully")
 }
 
-func createAdminUser(repo *repository.UserRepository) error {
-	_, err := repo.FindByEmail("tunhoipro0306@gmail.com")
-	if err == nil {
-		log.Println("Admin user already exists")
-		return nil
+// newMailer chọn cách gửi email theo cấu hình: SMTP, ghi file .eml, hoặc giữ trong bộ nhớ
+func newMailer(cfg config.Mail) mail.Mailer {
+	switch cfg.EffectiveTransport() {
+	case config.MailSMTP:
+		return mail.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From)
+	case config.MailFile:
+		log.Printf("Outgoing emails are written to %s", cfg.DropDir)
+		return mail.NewFileMailer(cfg.DropDir, cfg.From)
+	default:
+		log.Println("No mail transport configured, outgoing emails are kept in memory only")
+		return mail.NewMemoryMailer()
 	}
+}
+
+// newTSARoots nạp các chứng chỉ mà chứng chỉ ký của time-stamp authority phải nối tới:
+// cfg.CAFile nếu có, nếu không thì chứng chỉ gốc của hệ thống khi neo với một TSA bên ngoài.
+// Trả về nil nếu không có gì để tin; khi đó không neo nào kiểm tra được
+func newTSARoots(cfg config.Anchor) (*x509.CertPool, error) {
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", cfg.CAFile)
+		}
+		return roots, nil
+	}
+	if cfg.TSAURL == "" || cfg.TSAURL == config.LocalTSA {
+		return nil, nil
+	}
+	return x509.SystemCertPool()
+}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("admin123"), bcrypt.DefaultCost)
+// newTSAClient trả về client của time-stamp authority dùng để neo chuỗi, hoặc nil nếu
+// không neo. "local" khởi động một TSA tạm trong tiến trình, chỉ để phát triển và kiểm thử;
+// khóa của nó được giữ trong cfg.LocalKeyFile nếu có, để biên nhận cũ vẫn kiểm tra được sau khi khởi động lại
+func newTSAClient(cfg config.Anchor, roots *x509.CertPool) (*tsa.Client, func(), error) {
+	switch cfg.TSAURL {
+	case "":
+		return nil, func() {}, nil
+	case config.LocalTSA:
+		newServer := tsatest.NewServer
+		if cfg.LocalKeyFile != "" {
+			newServer = func() (*tsatest.Server, error) { return tsatest.NewServerFromFile(cfg.LocalKeyFile) }
+		}
+		server, err := newServer()
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Printf("WARNING: anchoring with a local test TSA at %s; its time-stamps prove nothing to anyone else", server.URL)
+		log.Printf("Local TSA certificate:\n%s", server.CertificatePEM())
+		if roots == nil {
+			roots = server.Roots()
+		}
+		return tsa.NewClient(server.URL, roots, cfg.Timeout), server.Close, nil
+	}
+
+	log.Printf("Anchoring the chain with %s every %s", cfg.TSAURL, cfg.Interval)
+	return tsa.NewClient(cfg.TSAURL, roots, cfg.Timeout), func() {}, nil
+}
+
+// newWitnessClients trả về client của các witness được cấu hình; không có witness thì không tạo checkpoint
+func newWitnessClients(cfg config.Checkpoints) ([]*witness.Client, error) {
+	peers, err := cfg.Peers()
+	if err != nil {
+		return nil, err
+	}
+	var clients []*witness.Client
+	for _, peer := range peers {
+		clients = append(clients, witness.NewClient(peer.Name, peer.URL, peer.PublicKey, cfg.Timeout))
+	}
+	if len(clients) > 0 {
+		log.Printf("Checkpointing the chain with %d witnesses every %s, quorum %d", len(clients), cfg.Interval, cfg.Quorum)
+	}
+	return clients, nil
+}
+
+// newSSOService tạo SSO service từ cấu hình OIDC đã được kiểm tra
+func newSSOService(cfg config.OIDC, userRepo repository.UserRepository, identityRepo repository.IdentityRepository, authService *service.AuthService) *service.SSOService {
+	client := oidc.NewClient(oidc.Config{
+		IssuerURL:    cfg.IssuerURL,
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURL,
+	})
+
+	log.Printf("OIDC single sign-on enabled for %s", cfg.IssuerURL)
+	return service.NewSSOService(client, cfg.IssuerURL, service.SSOConfig{
+		AllowedDomains: cfg.AllowedDomains,
+		RoleClaim:      cfg.RoleClaim,
+		RoleMappings:   cfg.RoleMap(),
+		DefaultRole:    cfg.DefaultRole,
+	}, userRepo, identityRepo, authService)
+}
+
+// allowedOrigin returns the value for Access-Control-Allow-Origin, or "" when
+// origin may not make cross-origin requests.
+func allowedOrigin(allowed []string, origin string) string {
+	for _, candidate := range allowed {
+		if candidate == "*" {
+			return "*"
+		}
+		if origin != "" && candidate == origin {
+			return origin
+		}
+	}
+	return ""
+}
+
+// createAdminUser bootstraps the first admin when there is none. The admin
+// must choose a new password at first login.
+func createAdminUser(ctx context.Context, repo repository.UserRepository, audit *service.AuditService, cfg config.Admin) error {
+	users, err := repo.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if user.Role == domain.RoleAdmin {
+			log.Println("Admin user already exists")
+			return nil
+		}
+	}
+
+	password := cfg.Password
+	if password == "" {
+		password, err = randomPassword()
+		if err != nil {
+			return err
+		}
+		log.Printf("Generated password for bootstrap admin %s: %s (it must be changed at first login)", cfg.Email, password)
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return fmt.Errorf("failed to hash admin password: %v", err)
 	}
 
 	admin := &domain.User{
-		ID:        uuid.New().String(),
-		Name:      "Admin",
-		Email:     "tunhoipro0306@gmail.com",
-		Password:  string(hashedPassword),
-		Role:      "admin",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		ID:                     uuid.New().String(),
+		Name:                   cfg.Name,
+		Email:                  cfg.Email,
+		Password:               string(hashedPassword),
+		Role:                   domain.RoleAdmin,
+		EmailVerified:          true,
+		PasswordChangeRequired: true,
+		CreatedAt:              time.Now(),
+		UpdatedAt:              time.Now(),
 	}
 
-	if err := repo.Save(admin); err != nil {
+	if err := repo.Save(ctx, admin); err != nil {
 		return fmt.Errorf("failed to save admin user: %v", err)
 	}
+	audit.Record(ctx, service.AuditEvent{
+		ActorID:    domain.AuditActorSystem,
+		Action:     domain.AuditAdminBootstrap,
+		TargetType: domain.AuditTargetUser,
+		TargetID:   admin.ID,
+		After:      admin,
+	})
 
-	log.Println("Admin user created successfully")
+	log.Printf("Admin user %s created successfully", cfg.Email)
 	return nil
-}
\ No newline at end of file
+}
+
+func randomPassword() (string, error) {
+	buf := make([]byte, 18)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate admin password: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// openStorage returns the repositories of the chosen backend and a function
+// that releases it. "mysql" and "sqlite" apply pending migrations first;
+// "memory" keeps everything in process and loses it on restart.
+func openStorage(ctx context.Context, cfg config.Database) (*repository.Repositories, func(), error) {
+	if cfg.Backend == config.BackendMemory {
+		log.Println("Storage: in-memory, data is lost on restart")
+		return memory.NewRepositories(), func() {}, nil
+	}
+
+	conn, driver, err := openDatabase(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	migrator, err := db.NewMigrator(conn, driver)
+	if err == nil {
+		var applied []db.Migration
+		applied, err = migrator.Up(ctx)
+		for _, m := range applied {
+			log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	metrics.PublishDBStats(conn)
+
+	dialect := repository.DialectMySQL
+	if driver == db.SQLite {
+		dialect = repository.DialectSQLite
+	}
+	return repository.NewSQLRepositories(conn, dialect), func() { conn.Close() }, nil
+}
+
+// openDatabase connects to the SQL database of the backend and returns its driver name.
+func openDatabase(cfg config.Database) (*sql.DB, string, error) {
+	switch cfg.Backend {
+	case config.BackendMySQL:
+		conn, err := db.NewDB(cfg.DSN)
+		if err != nil {
+			return nil, "", err
+		}
+		conn.SetMaxOpenConns(cfg.MaxOpenConns)
+		conn.SetMaxIdleConns(cfg.MaxIdleConns)
+		conn.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+		return conn, db.MySQL, nil
+	case config.BackendSQLite:
+		log.Printf("Storage: SQLite database %s", cfg.SQLitePath)
+		conn, err := db.NewSQLiteDB(cfg.SQLitePath)
+		return conn, db.SQLite, err
+	}
+	return nil, "", fmt.Errorf("unknown storage backend %q, want mysql, sqlite or memory", cfg.Backend)
+}
+
+// newSearchIndex uses the MySQL FULLTEXT index when storage is MySQL, unless
+// the search backend is memory. Otherwise existing certificates are loaded
+// into an in-memory index.
+func newSearchIndex(ctx context.Context, cfg *config.Config, certRepo repository.CertificateRepository) (search.Index, error) {
+	if searcher, ok := certRepo.(search.FullTextSearcher); ok && cfg.Database.Backend == config.BackendMySQL && cfg.Search.Backend == config.SearchDatabase {
+		return search.NewMySQLIndex(searcher), nil
+	}
+
+	index := search.NewMemoryIndex(certRepo.FindByID)
+	certs, err := certRepo.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, cert := range certs {
+		index.Add(cert)
+	}
+	log.Printf("Search: in-memory index with %d certificates", len(certs))
+	return index, nil
+}
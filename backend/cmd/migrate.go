@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+
+	"certificate-ledger/config"
+	"certificate-ledger/db"
+)
+
+const commandUsage = `usage:
+  server [flags]                       start the API server
+  server [flags] migrate up            apply pending migrations
+  server [flags] migrate down [steps]  roll back the last steps migrations (default 1)
+  server [flags] migrate status        list migrations and when they were applied
+  server [flags] audit verify [seq:hash]
+                                       check the audit log chain; seq:hash is a
+                                       head printed by an earlier run that must
+                                       still be present
+  server witness keygen                print a new witness signing key and its
+                                       public key
+
+run "server -h" for the flags`
+
+// runCommand runs an administrative subcommand and returns the exit code.
+// Only the database settings are needed, so only those are validated; the
+// witness command needs none.
+func runCommand(cfg *config.Config, args []string) int {
+	if args[0] == "witness" {
+		return runWitness(args[1:])
+	}
+	if (args[0] != "migrate" && args[0] != "audit") || len(args) < 2 {
+		fmt.Fprintln(os.Stderr, commandUsage)
+		return 2
+	}
+	if err := cfg.Database.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		return 2
+	}
+
+	run := runMigrate
+	if args[0] == "audit" {
+		run = runAudit
+	}
+	if err := run(cfg.Database, args[1], args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s: %v\n", args[0], args[1], err)
+		return 1
+	}
+	return 0
+}
+
+func runMigrate(cfg config.Database, action string, args []string) error {
+	if cfg.Backend == config.BackendMemory {
+		return fmt.Errorf("the memory backend has no schema to migrate")
+	}
+	conn, driver, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	migrator, err := db.NewMigrator(conn, driver)
+	if err != nil {
+		return err
+	}
+	// Ctrl-C cancels the running statement instead of killing the process mid-migration.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch action {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 0 {
+			if steps, err = strconv.Atoi(args[0]); err != nil || steps < 1 {
+				return fmt.Errorf("steps must be a positive number")
+			}
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		for _, m := range rolledBack {
+			fmt.Printf("rolled back %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.UTC().Format("2006-01-02 15:04:05")
+			}
+			name := s.Name
+			if s.Up == "" {
+				name += " (unknown to this build)"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, name, appliedAt)
+		}
+		return w.Flush()
+	}
+	return fmt.Errorf("unknown action %q\n%s", action, commandUsage)
+}
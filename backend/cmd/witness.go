@@ -0,0 +1,26 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+)
+
+// runWitness runs "witness keygen", which prints a new signing key for
+// WITNESS_SIGNING_KEY and the public key ledgers list the witness with.
+func runWitness(args []string) int {
+	if len(args) != 1 || args[0] != "keygen" {
+		fmt.Fprintln(os.Stderr, commandUsage)
+		return 2
+	}
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "witness keygen: %v\n", err)
+		return 1
+	}
+	fmt.Printf("WITNESS_SIGNING_KEY=%s\n", hex.EncodeToString(privateKey.Seed()))
+	fmt.Printf("public key: %s\n", hex.EncodeToString(publicKey))
+	return 0
+}
@@ -0,0 +1,960 @@
+// Package config loads server settings from defaults, an optional JSON file,
+// environment variables and command-line flags, each overriding the one before.
+package config
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/validation"
+	"certificate-ledger/witness"
+)
+
+// Storage backends.
+const (
+	BackendMySQL  = "mysql"
+	BackendSQLite = "sqlite"
+	BackendMemory = "memory"
+)
+
+// Mail transports. MailAuto sends through SMTP when a host is configured
+// and keeps mail in memory otherwise.
+const (
+	MailAuto   = ""
+	MailSMTP   = "smtp"
+	MailFile   = "file"
+	MailMemory = "memory"
+)
+
+// Search backends. SearchDatabase uses the database's full-text index when
+// it has one and falls back to the in-memory index otherwise.
+const (
+	SearchDatabase = "database"
+	SearchMemory   = "memory"
+)
+
+const (
+	MinJWTSecretLength    = 32
+	MinMetricsTokenLength = 16
+	MaxMiningDifficulty   = 8
+)
+
+type Config struct {
+	Server        Server
+	Database      Database
+	Search        Search
+	JWT           JWT
+	TOTP          TOTP
+	OIDC          OIDC
+	Blockchain    Blockchain
+	Admin         Admin
+	Webhooks      Webhooks
+	Mail          Mail
+	Notifications Notifications
+	Integrity     Integrity
+	Anchor        Anchor
+	Checkpoints   Checkpoints
+	Witness       Witness
+}
+
+type Server struct {
+	Addr            string
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+	// TLSCertFile and TLSKeyFile enable HTTPS when both are set.
+	TLSCertFile    string
+	TLSKeyFile     string
+	AllowedOrigins []string
+	// MetricsToken, when set, is the bearer token /metrics requires.
+	MetricsToken string
+	// AppBaseURL is the frontend origin used in links sent to users.
+	AppBaseURL string
+	// TrustProxy honours X-Forwarded-For, which only a reverse proxy in
+	// front of the server may be trusted to set.
+	TrustProxy bool
+}
+
+type Database struct {
+	Backend         string
+	DSN             string
+	SQLitePath      string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+type Search struct {
+	Backend string
+}
+
+type JWT struct {
+	Secret string
+	TTL    time.Duration
+	Issuer string
+}
+
+// TOTP names the server in authenticator apps.
+type TOTP struct {
+	Issuer string
+}
+
+// OIDC enables single sign-on through an OpenID Connect provider when
+// IssuerURL is set. RoleMappings are written value=role: a user whose
+// RoleClaim holds value gets role; others get DefaultRole.
+type OIDC struct {
+	IssuerURL      string
+	ClientID       string
+	ClientSecret   string
+	RedirectURL    string
+	AllowedDomains []string
+	RoleClaim      string
+	RoleMappings   []string
+	DefaultRole    string
+}
+
+// Enabled reports whether single sign-on is on.
+func (o OIDC) Enabled() bool {
+	return o.IssuerURL != ""
+}
+
+// RoleMap returns RoleMappings as a map from claim value to role. Entries
+// that are not value=role are skipped; Validate reports them.
+func (o OIDC) RoleMap() map[string]string {
+	roles := make(map[string]string)
+	for _, mapping := range o.RoleMappings {
+		if value, role, ok := strings.Cut(mapping, "="); ok {
+			roles[strings.TrimSpace(value)] = strings.TrimSpace(role)
+		}
+	}
+	return roles
+}
+
+type Blockchain struct {
+	Difficulty int
+}
+
+// Admin is the account created when the database has no admin yet. Without
+// a password a random one is generated and logged. Either way the admin must
+// change it on first login.
+type Admin struct {
+	Email    string
+	Name     string
+	Password string
+}
+
+// Webhooks tunes webhook delivery. A failed delivery is retried after
+// RetryBase, doubling each time up to RetryMax, until MaxAttempts attempts
+// have failed; it then moves to the dead-letter queue.
+type Webhooks struct {
+	Timeout      time.Duration
+	MaxAttempts  int
+	RetryBase    time.Duration
+	RetryMax     time.Duration
+	PollInterval time.Duration
+}
+
+// Mail selects how outgoing email leaves the server. The file transport
+// writes each message as an .eml file into DropDir instead of sending it.
+type Mail struct {
+	Transport    string
+	From         string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	DropDir      string
+}
+
+// Notifications tunes the emails sent to certificate recipients. A failed
+// email is retried after RetryBase, doubling each time up to RetryMax, until
+// MaxAttempts attempts have failed.
+type Notifications struct {
+	DefaultLocale string
+	MaxAttempts   int
+	RetryBase     time.Duration
+	RetryMax      time.Duration
+	PollInterval  time.Duration
+}
+
+// Integrity schedules the scan that compares the chain with the database.
+// AlertEmails are emailed when a scan finds new discrepancies.
+type Integrity struct {
+	Interval    time.Duration
+	AlertEmails []string
+}
+
+// LocalTSA as Anchor.TSAURL starts a throwaway time-stamp authority inside
+// the server. Its time-stamps prove nothing to anyone else; it exists for
+// development and tests.
+const LocalTSA = "local"
+
+// Anchor time-stamps the chain's head with an RFC 3161 authority every
+// Interval. Anchoring is off when TSAURL is empty. The authority's signing
+// certificate must chain to the PEM certificates in CAFile, or to the system
+// roots when CAFile is empty. Stored anchors are verified against the same
+// roots, so with anchoring off they only verify if CAFile is set. LocalKeyFile keeps the local authority's key
+// and certificate across restarts; without it, receipts from an earlier run
+// no longer verify.
+type Anchor struct {
+	TSAURL       string
+	CAFile       string
+	LocalKeyFile string
+	Interval     time.Duration
+	Timeout      time.Duration
+}
+
+// Enabled reports whether the chain is anchored.
+func (a Anchor) Enabled() bool {
+	return a.TSAURL != ""
+}
+
+// Checkpoints has Witnesses co-sign the chain's height and head hash every
+// Interval; checkpointing is off without witnesses. Each witness is written
+// name=publicKey@url, with the witness's hex public key and base URL. A
+// checkpoint signed by Quorum of them is co-signed, and the chain must agree
+// with it from then on. Origin names this ledger to the witnesses, and
+// SigningKey, a hex Ed25519 seed, signs its requests; each witness must be
+// given the key's public half for the origin.
+type Checkpoints struct {
+	Origin     string
+	SigningKey string
+	Witnesses  []string
+	Quorum     int
+	Interval   time.Duration
+	Timeout    time.Duration
+}
+
+// WitnessPeer is one parsed entry of Checkpoints.Witnesses.
+type WitnessPeer struct {
+	Name      string
+	PublicKey ed25519.PublicKey
+	URL       string
+}
+
+// Peers parses the witnesses.
+func (c Checkpoints) Peers() ([]WitnessPeer, error) {
+	var peers []WitnessPeer
+	var errs []error
+	for _, entry := range c.Witnesses {
+		name, rest, ok1 := strings.Cut(entry, "=")
+		key, rawURL, ok2 := strings.Cut(rest, "@")
+		if !ok1 || !ok2 || name == "" {
+			errs = append(errs, fmt.Errorf("checkpoints.witnesses: %q is not name=publicKey@url", entry))
+			continue
+		}
+		publicKey, err := witness.ParsePublicKey(key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("checkpoints.witnesses: %s: %v", name, err))
+			continue
+		}
+		if !isHTTPURL(rawURL) {
+			errs = append(errs, fmt.Errorf("checkpoints.witnesses: %s: %q is not an http(s) URL", name, rawURL))
+			continue
+		}
+		peers = append(peers, WitnessPeer{Name: name, PublicKey: publicKey, URL: rawURL})
+	}
+	return peers, errors.Join(errs...)
+}
+
+// Witness makes this server a witness for other ledgers when SigningKey, a
+// hex Ed25519 seed, is set. Name is how it introduces itself. It co-signs
+// only for the ledgers in Origins, each written origin=publicKey with the hex
+// public key the ledger signs its requests with.
+type Witness struct {
+	Name       string
+	SigningKey string
+	Origins    []string
+}
+
+// OriginKeys parses the witness's origins.
+func (w Witness) OriginKeys() (map[string]ed25519.PublicKey, error) {
+	keys := make(map[string]ed25519.PublicKey)
+	var errs []error
+	for _, entry := range w.Origins {
+		origin, key, ok := strings.Cut(entry, "=")
+		if !ok || !witness.ValidOrigin(origin) {
+			errs = append(errs, fmt.Errorf("witness.origins: %q is not origin=publicKey", entry))
+			continue
+		}
+		if _, ok := keys[origin]; ok {
+			errs = append(errs, fmt.Errorf("witness.origins: %s is listed twice", origin))
+			continue
+		}
+		publicKey, err := witness.ParsePublicKey(key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("witness.origins: %s: %v", origin, err))
+			continue
+		}
+		keys[origin] = publicKey
+	}
+	return keys, errors.Join(errs...)
+}
+
+// Enabled reports whether this server is a witness.
+func (w Witness) Enabled() bool {
+	return w.SigningKey != ""
+}
+
+// TLSEnabled reports whether the server should serve HTTPS.
+func (s Server) TLSEnabled() bool {
+	return s.TLSCertFile != ""
+}
+
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Addr:            ":8080",
+			ReadTimeout:     5 * time.Second,
+			WriteTimeout:    10 * time.Second,
+			IdleTimeout:     15 * time.Second,
+			ShutdownTimeout: 5 * time.Second,
+			AllowedOrigins:  []string{"http://localhost:3000"},
+			AppBaseURL:      "http://localhost:3000",
+		},
+		Database: Database{
+			Backend:         BackendMySQL,
+			DSN:             "root:rootpassword@tcp(localhost:3309)/certificate_ledger?parseTime=true",
+			SQLitePath:      "certificate_ledger.db",
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 5 * time.Minute,
+		},
+		Search: Search{Backend: SearchDatabase},
+		JWT: JWT{
+			TTL: 24 * time.Hour,
+		},
+		TOTP:       TOTP{Issuer: "Certificate Ledger"},
+		OIDC:       OIDC{DefaultRole: domain.RoleUser},
+		Blockchain: Blockchain{Difficulty: 4},
+		Admin: Admin{
+			Email: "admin@certificate-ledger.local",
+			Name:  "Admin",
+		},
+		Webhooks: Webhooks{
+			Timeout:      10 * time.Second,
+			MaxAttempts:  8,
+			RetryBase:    30 * time.Second,
+			RetryMax:     time.Hour,
+			PollInterval: 5 * time.Second,
+		},
+		Mail: Mail{
+			From:     "no-reply@certificate-ledger.local",
+			SMTPPort: "587",
+		},
+		Notifications: Notifications{
+			DefaultLocale: domain.DefaultLocale,
+			MaxAttempts:   6,
+			RetryBase:     time.Minute,
+			RetryMax:      time.Hour,
+			PollInterval:  10 * time.Second,
+		},
+		Integrity: Integrity{Interval: time.Hour},
+		Anchor: Anchor{
+			Interval: time.Hour,
+			Timeout:  30 * time.Second,
+		},
+		Checkpoints: Checkpoints{
+			Origin:   "certificate-ledger",
+			Quorum:   1,
+			Interval: time.Hour,
+			Timeout:  30 * time.Second,
+		},
+	}
+}
+
+// setting binds one field to its file key, environment variable and flag.
+// The flag has the same name as the file key.
+type setting struct {
+	key   string
+	env   string
+	usage string
+	set   func(string) error
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"server.addr", "LISTEN_ADDR", "address to listen on", setString(&c.Server.Addr)},
+		{"server.readTimeout", "SERVER_READ_TIMEOUT", "maximum duration for reading a request", setDuration(&c.Server.ReadTimeout)},
+		{"server.writeTimeout", "SERVER_WRITE_TIMEOUT", "maximum duration for writing a response", setDuration(&c.Server.WriteTimeout)},
+		{"server.idleTimeout", "SERVER_IDLE_TIMEOUT", "keep-alive idle timeout", setDuration(&c.Server.IdleTimeout)},
+		{"server.shutdownTimeout", "SERVER_SHUTDOWN_TIMEOUT", "grace period for in-flight requests on shutdown", setDuration(&c.Server.ShutdownTimeout)},
+		{"server.tlsCertFile", "TLS_CERT_FILE", "TLS certificate file; enables HTTPS", setString(&c.Server.TLSCertFile)},
+		{"server.tlsKeyFile", "TLS_KEY_FILE", "TLS private key file", setString(&c.Server.TLSKeyFile)},
+		{"server.allowedOrigins", "ALLOWED_ORIGINS", "comma-separated CORS origins, or *", setList(&c.Server.AllowedOrigins)},
+		{"server.metricsToken", "METRICS_TOKEN", "bearer token required to scrape /metrics; open to anyone when empty", setString(&c.Server.MetricsToken)},
+		{"server.appBaseURL", "APP_BASE_URL", "frontend origin used in links sent to users", setString(&c.Server.AppBaseURL)},
+		{"server.trustProxy", "TRUST_PROXY", "take client addresses from X-Forwarded-For; only behind a reverse proxy", setBool(&c.Server.TrustProxy)},
+		{"database.backend", "STORAGE_BACKEND", "storage backend: mysql, sqlite or memory", setString(&c.Database.Backend)},
+		{"database.dsn", "DB_DSN", "MySQL data source name", setString(&c.Database.DSN)},
+		{"database.sqlitePath", "SQLITE_PATH", "SQLite database file", setString(&c.Database.SQLitePath)},
+		{"database.maxOpenConns", "DB_MAX_OPEN_CONNS", "maximum open MySQL connections (0 is unlimited)", setInt(&c.Database.MaxOpenConns)},
+		{"database.maxIdleConns", "DB_MAX_IDLE_CONNS", "maximum idle MySQL connections", setInt(&c.Database.MaxIdleConns)},
+		{"database.connMaxLifetime", "DB_CONN_MAX_LIFETIME", "maximum lifetime of a MySQL connection (0 is unlimited)", setDuration(&c.Database.ConnMaxLifetime)},
+		{"search.backend", "SEARCH_BACKEND", "search index: database or memory", setString(&c.Search.Backend)},
+		{"jwt.secret", "JWT_SECRET", "secret signing session tokens and emailed links", setString(&c.JWT.Secret)},
+		{"jwt.ttl", "JWT_TTL", "session token lifetime", setDuration(&c.JWT.TTL)},
+		{"jwt.issuer", "JWT_ISSUER", "iss claim of session tokens; checked when set", setString(&c.JWT.Issuer)},
+		{"totp.issuer", "TOTP_ISSUER", "name of the server in authenticator apps", setString(&c.TOTP.Issuer)},
+		{"oidc.issuerURL", "OIDC_ISSUER_URL", "OpenID Connect provider; single sign-on is off when empty", setString(&c.OIDC.IssuerURL)},
+		{"oidc.clientID", "OIDC_CLIENT_ID", "client ID registered with the provider", setString(&c.OIDC.ClientID)},
+		{"oidc.clientSecret", "OIDC_CLIENT_SECRET", "client secret registered with the provider", setString(&c.OIDC.ClientSecret)},
+		{"oidc.redirectURL", "OIDC_REDIRECT_URL", "callback URL registered with the provider", setString(&c.OIDC.RedirectURL)},
+		{"oidc.allowedDomains", "OIDC_ALLOWED_DOMAINS", "comma-separated email domains that may sign in; any when empty", setList(&c.OIDC.AllowedDomains)},
+		{"oidc.roleClaim", "OIDC_ROLE_CLAIM", "ID token claim mapped to roles, such as groups", setString(&c.OIDC.RoleClaim)},
+		{"oidc.roleMappings", "OIDC_ROLE_MAPPINGS", "comma-separated claim-value=role mappings", setList(&c.OIDC.RoleMappings)},
+		{"oidc.defaultRole", "OIDC_DEFAULT_ROLE", "role of provisioned users without a mapped role", setString(&c.OIDC.DefaultRole)},
+		{"blockchain.difficulty", "MINING_DIFFICULTY", "leading zero hex digits required of block hashes", setInt(&c.Blockchain.Difficulty)},
+		{"admin.email", "ADMIN_EMAIL", "email of the bootstrap admin", setString(&c.Admin.Email)},
+		{"admin.name", "ADMIN_NAME", "name of the bootstrap admin", setString(&c.Admin.Name)},
+		{"admin.password", "ADMIN_PASSWORD", "initial password of the bootstrap admin; generated when empty", setString(&c.Admin.Password)},
+		{"webhooks.timeout", "WEBHOOK_TIMEOUT", "time allowed for a webhook receiver to respond", setDuration(&c.Webhooks.Timeout)},
+		{"webhooks.maxAttempts", "WEBHOOK_MAX_ATTEMPTS", "attempts before a webhook delivery is dead-lettered", setInt(&c.Webhooks.MaxAttempts)},
+		{"webhooks.retryBase", "WEBHOOK_RETRY_BASE", "delay before the first webhook retry; doubles on each retry", setDuration(&c.Webhooks.RetryBase)},
+		{"webhooks.retryMax", "WEBHOOK_RETRY_MAX", "longest delay between webhook retries", setDuration(&c.Webhooks.RetryMax)},
+		{"webhooks.pollInterval", "WEBHOOK_POLL_INTERVAL", "how often due webhook deliveries are looked up", setDuration(&c.Webhooks.PollInterval)},
+		{"mail.transport", "MAIL_TRANSPORT", "outgoing mail transport: smtp, file or memory; smtp when mail.smtpHost is set, memory otherwise", setString(&c.Mail.Transport)},
+		{"mail.from", "SMTP_FROM", "sender address of outgoing mail", setString(&c.Mail.From)},
+		{"mail.smtpHost", "SMTP_HOST", "SMTP server host", setString(&c.Mail.SMTPHost)},
+		{"mail.smtpPort", "SMTP_PORT", "SMTP server port", setString(&c.Mail.SMTPPort)},
+		{"mail.smtpUsername", "SMTP_USERNAME", "SMTP user; authentication is skipped when empty", setString(&c.Mail.SMTPUsername)},
+		{"mail.smtpPassword", "SMTP_PASSWORD", "SMTP password", setString(&c.Mail.SMTPPassword)},
+		{"mail.dropDir", "MAIL_DROP_DIR", "directory the file transport writes .eml files to", setString(&c.Mail.DropDir)},
+		{"notifications.defaultLocale", "NOTIFY_DEFAULT_LOCALE", "language of recipient emails when the certificate names none", setString(&c.Notifications.DefaultLocale)},
+		{"notifications.maxAttempts", "NOTIFY_MAX_ATTEMPTS", "attempts before a recipient email is marked failed", setInt(&c.Notifications.MaxAttempts)},
+		{"notifications.retryBase", "NOTIFY_RETRY_BASE", "delay before the first email retry; doubles on each retry", setDuration(&c.Notifications.RetryBase)},
+		{"notifications.retryMax", "NOTIFY_RETRY_MAX", "longest delay between email retries", setDuration(&c.Notifications.RetryMax)},
+		{"notifications.pollInterval", "NOTIFY_POLL_INTERVAL", "how often due emails are looked up", setDuration(&c.Notifications.PollInterval)},
+		{"integrity.interval", "INTEGRITY_SCAN_INTERVAL", "how often the chain and database are checked against each other", setDuration(&c.Integrity.Interval)},
+		{"integrity.alertEmails", "INTEGRITY_ALERT_EMAILS", "comma-separated addresses emailed about new integrity discrepancies", setList(&c.Integrity.AlertEmails)},
+		{"anchor.tsaUrl", "ANCHOR_TSA_URL", "RFC 3161 time-stamp authority the chain's head is anchored with, or local; anchoring is off when empty", setString(&c.Anchor.TSAURL)},
+		{"anchor.caFile", "ANCHOR_TSA_CA_FILE", "PEM certificates the authority's signing certificate must chain to; the system roots when empty, and none with anchoring off", setString(&c.Anchor.CAFile)},
+		{"anchor.localKeyFile", "ANCHOR_LOCAL_TSA_KEY_FILE", "file the local authority keeps its key and certificate in; a new key on every start when empty", setString(&c.Anchor.LocalKeyFile)},
+		{"anchor.interval", "ANCHOR_INTERVAL", "how often the chain's head is anchored", setDuration(&c.Anchor.Interval)},
+		{"anchor.timeout", "ANCHOR_TSA_TIMEOUT", "time allowed for the time-stamp authority to respond", setDuration(&c.Anchor.Timeout)},
+		{"checkpoints.origin", "CHECKPOINT_ORIGIN", "name of this ledger in the checkpoints witnesses sign", setString(&c.Checkpoints.Origin)},
+		{"checkpoints.signingKey", "CHECKPOINT_SIGNING_KEY", "hex Ed25519 seed this ledger signs its requests to witnesses with", setString(&c.Checkpoints.SigningKey)},
+		{"checkpoints.witnesses", "CHECKPOINT_WITNESSES", "comma-separated witnesses as name=publicKey@url; checkpointing is off when empty", setList(&c.Checkpoints.Witnesses)},
+		{"checkpoints.quorum", "CHECKPOINT_QUORUM", "witness signatures that make a checkpoint co-signed", setInt(&c.Checkpoints.Quorum)},
+		{"checkpoints.interval", "CHECKPOINT_INTERVAL", "how often the chain's head is checkpointed", setDuration(&c.Checkpoints.Interval)},
+		{"checkpoints.timeout", "CHECKPOINT_TIMEOUT", "time allowed for a witness to respond", setDuration(&c.Checkpoints.Timeout)},
+		{"witness.name", "WITNESS_NAME", "name this server gives as a witness", setString(&c.Witness.Name)},
+		{"witness.signingKey", "WITNESS_SIGNING_KEY", "hex Ed25519 seed this server co-signs other ledgers' checkpoints with; it is no witness when empty", setString(&c.Witness.SigningKey)},
+		{"witness.origins", "WITNESS_ORIGINS", "comma-separated ledgers this witness co-signs for, as origin=publicKey", setList(&c.Witness.Origins)},
+	}
+}
+
+// Load builds the configuration from defaults, the JSON file named by
+// -config or CONFIG_FILE, the environment and the flags in args. It returns
+// the arguments left after the flags. The result is not validated.
+func Load(args []string) (*Config, []string, error) {
+	cfg := Default()
+	settings := cfg.settings()
+
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "JSON configuration file (env CONFIG_FILE)")
+	flagValues := make(map[string]string)
+	for _, s := range settings {
+		key := s.key
+		fs.Func(key, fmt.Sprintf("%s (env %s)", s.usage, s.env), func(value string) error {
+			flagValues[key] = value
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if *configFile != "" {
+		values, err := readFile(*configFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := apply(settings, values, *configFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	envValues := make(map[string]string)
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env); ok {
+			envValues[s.key] = value
+		}
+	}
+	if err := apply(settings, envValues, "the environment"); err != nil {
+		return nil, nil, err
+	}
+	if err := apply(settings, flagValues, "the command line"); err != nil {
+		return nil, nil, err
+	}
+	return cfg, fs.Args(), nil
+}
+
+// apply sets each setting that has a value. source names where the values
+// came from in errors.
+func apply(settings []setting, values map[string]string, source string) error {
+	for _, s := range settings {
+		value, ok := values[s.key]
+		if !ok {
+			continue
+		}
+		if err := s.set(value); err != nil {
+			return fmt.Errorf("invalid %s (env %s) from %s: %v", s.key, s.env, source, err)
+		}
+	}
+	return nil
+}
+
+// readFile flattens a JSON file into dotted keys such as "server.addr".
+// Arrays become comma-separated lists.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var root map[string]interface{}
+	if err := dec.Decode(&root); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flatten("", root, values); err != nil {
+		return nil, fmt.Errorf("config file %s: %v", path, err)
+	}
+
+	known := make(map[string]bool)
+	for _, s := range Default().settings() {
+		known[s.key] = true
+	}
+	var unknown []string
+	for key := range values {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("config file %s: unknown settings %s", path, strings.Join(unknown, ", "))
+	}
+	return values, nil
+}
+
+func flatten(prefix string, node map[string]interface{}, values map[string]string) error {
+	for name, value := range node {
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if err := flatten(key, v, values); err != nil {
+				return err
+			}
+		case []interface{}:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			values[key] = strings.Join(items, ",")
+		case nil:
+			return fmt.Errorf("%s is null", key)
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+	return nil
+}
+
+func setString(p *string) func(string) error {
+	return func(s string) error {
+		*p = strings.TrimSpace(s)
+		return nil
+	}
+}
+
+func setInt(p *int) func(string) error {
+	return func(s string) error {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", s)
+		}
+		*p = n
+		return nil
+	}
+}
+
+func setDuration(p *time.Duration) func(string) error {
+	return func(s string) error {
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 30s or 5m", s)
+		}
+		*p = d
+		return nil
+	}
+}
+
+func setBool(p *bool) func(string) error {
+	return func(s string) error {
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", s)
+		}
+		*p = b
+		return nil
+	}
+}
+
+func setList(p *[]string) func(string) error {
+	return func(s string) error {
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*p = items
+		return nil
+	}
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	return errors.Join(
+		c.Server.Validate(),
+		c.Database.Validate(),
+		c.Search.Validate(),
+		c.JWT.Validate(),
+		c.TOTP.Validate(),
+		c.OIDC.Validate(),
+		c.Blockchain.Validate(),
+		c.Admin.Validate(),
+		c.Webhooks.Validate(),
+		c.Mail.Validate(),
+		c.Notifications.Validate(),
+		c.Integrity.Validate(),
+		c.Anchor.Validate(),
+		c.Checkpoints.Validate(),
+		c.Witness.Validate(),
+	)
+}
+
+func (s Server) Validate() error {
+	var errs []error
+	if s.Addr == "" {
+		errs = append(errs, errors.New("server.addr is required"))
+	}
+	for name, d := range map[string]time.Duration{
+		"server.readTimeout":     s.ReadTimeout,
+		"server.writeTimeout":    s.WriteTimeout,
+		"server.idleTimeout":     s.IdleTimeout,
+		"server.shutdownTimeout": s.ShutdownTimeout,
+	} {
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", name))
+		}
+	}
+	if (s.TLSCertFile == "") != (s.TLSKeyFile == "") {
+		errs = append(errs, errors.New("server.tlsCertFile and server.tlsKeyFile must be set together"))
+	}
+	if !isHTTPURL(s.AppBaseURL) {
+		errs = append(errs, errors.New("server.appBaseURL must be an http or https URL"))
+	}
+	if s.MetricsToken != "" && len(s.MetricsToken) < MinMetricsTokenLength {
+		errs = append(errs, fmt.Errorf("server.metricsToken must be at least %d characters", MinMetricsTokenLength))
+	}
+	return errors.Join(errs...)
+}
+
+func (d Database) Validate() error {
+	var errs []error
+	switch d.Backend {
+	case BackendMySQL:
+		if d.DSN == "" {
+			errs = append(errs, errors.New("database.dsn is required for the mysql backend"))
+		}
+	case BackendSQLite:
+		if d.SQLitePath == "" {
+			errs = append(errs, errors.New("database.sqlitePath is required for the sqlite backend"))
+		}
+	case BackendMemory:
+	default:
+		errs = append(errs, fmt.Errorf("database.backend %q must be mysql, sqlite or memory", d.Backend))
+	}
+	if d.MaxOpenConns < 0 || d.MaxIdleConns < 0 || d.ConnMaxLifetime < 0 {
+		errs = append(errs, errors.New("database pool settings must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+func (s Search) Validate() error {
+	if s.Backend != SearchDatabase && s.Backend != SearchMemory {
+		return fmt.Errorf("search.backend %q must be database or memory", s.Backend)
+	}
+	return nil
+}
+
+func (j JWT) Validate() error {
+	var errs []error
+	if len(j.Secret) < MinJWTSecretLength {
+		errs = append(errs, fmt.Errorf("jwt.secret must be at least %d characters", MinJWTSecretLength))
+	}
+	if j.TTL <= 0 {
+		errs = append(errs, errors.New("jwt.ttl must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+func (t TOTP) Validate() error {
+	if t.Issuer == "" {
+		return errors.New("totp.issuer is required")
+	}
+	return nil
+}
+
+func (o OIDC) Validate() error {
+	if !o.Enabled() {
+		return nil
+	}
+	var errs []error
+	if !isHTTPURL(o.IssuerURL) {
+		errs = append(errs, errors.New("oidc.issuerURL must be an http or https URL"))
+	}
+	if o.ClientID == "" {
+		errs = append(errs, errors.New("oidc.clientID is required when oidc.issuerURL is set"))
+	}
+	if !isHTTPURL(o.RedirectURL) {
+		errs = append(errs, errors.New("oidc.redirectURL must be an http or https URL"))
+	}
+	for _, mapping := range o.RoleMappings {
+		value, role, ok := strings.Cut(mapping, "=")
+		if !ok || strings.TrimSpace(value) == "" {
+			errs = append(errs, fmt.Errorf("oidc.roleMappings: %q is not value=role", mapping))
+		} else if !isRole(strings.TrimSpace(role)) {
+			errs = append(errs, fmt.Errorf("oidc.roleMappings: %q is not a role", strings.TrimSpace(role)))
+		}
+	}
+	if !isRole(o.DefaultRole) {
+		errs = append(errs, fmt.Errorf("oidc.defaultRole: %q is not a role", o.DefaultRole))
+	}
+	return errors.Join(errs...)
+}
+
+func (b Blockchain) Validate() error {
+	if b.Difficulty < 1 || b.Difficulty > MaxMiningDifficulty {
+		return fmt.Errorf("blockchain.difficulty must be between 1 and %d", MaxMiningDifficulty)
+	}
+	return nil
+}
+
+func (a Admin) Validate() error {
+	var errs []error
+	if !strings.Contains(a.Email, "@") {
+		errs = append(errs, errors.New("admin.email must be an email address"))
+	}
+	if a.Password != "" && len(a.Password) < validation.MinPasswordLength {
+		errs = append(errs, fmt.Errorf("admin.password must be at least %d characters", validation.MinPasswordLength))
+	}
+	if len(a.Password) > validation.MaxPasswordBytes {
+		errs = append(errs, fmt.Errorf("admin.password must be at most %d bytes", validation.MaxPasswordBytes))
+	}
+	return errors.Join(errs...)
+}
+
+func (w Webhooks) Validate() error {
+	var errs []error
+	for name, d := range map[string]time.Duration{
+		"webhooks.timeout":      w.Timeout,
+		"webhooks.retryBase":    w.RetryBase,
+		"webhooks.retryMax":     w.RetryMax,
+		"webhooks.pollInterval": w.PollInterval,
+	} {
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", name))
+		}
+	}
+	if w.MaxAttempts < 1 {
+		errs = append(errs, errors.New("webhooks.maxAttempts must be at least 1"))
+	}
+	if w.RetryMax < w.RetryBase {
+		errs = append(errs, errors.New("webhooks.retryMax must not be less than webhooks.retryBase"))
+	}
+	return errors.Join(errs...)
+}
+
+// EffectiveTransport resolves MailAuto to the transport actually used.
+func (m Mail) EffectiveTransport() string {
+	if m.Transport != MailAuto {
+		return m.Transport
+	}
+	if m.SMTPHost != "" {
+		return MailSMTP
+	}
+	return MailMemory
+}
+
+func (m Mail) Validate() error {
+	var errs []error
+	switch m.Transport {
+	case MailAuto, MailMemory:
+	case MailSMTP:
+		if m.SMTPHost == "" {
+			errs = append(errs, errors.New("mail.smtpHost is required for the smtp transport"))
+		}
+	case MailFile:
+		if m.DropDir == "" {
+			errs = append(errs, errors.New("mail.dropDir is required for the file transport"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("mail.transport %q must be smtp, file or memory", m.Transport))
+	}
+	if !strings.Contains(m.From, "@") {
+		errs = append(errs, errors.New("mail.from must be an email address"))
+	}
+	if m.EffectiveTransport() == MailSMTP && m.SMTPPort == "" {
+		errs = append(errs, errors.New("mail.smtpPort is required for the smtp transport"))
+	}
+	return errors.Join(errs...)
+}
+
+func (n Notifications) Validate() error {
+	var errs []error
+	if !containsString(domain.Locales, n.DefaultLocale) {
+		errs = append(errs, fmt.Errorf("notifications.defaultLocale must be one of %s", strings.Join(domain.Locales, ", ")))
+	}
+	for name, d := range map[string]time.Duration{
+		"notifications.retryBase":    n.RetryBase,
+		"notifications.retryMax":     n.RetryMax,
+		"notifications.pollInterval": n.PollInterval,
+	} {
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", name))
+		}
+	}
+	if n.MaxAttempts < 1 {
+		errs = append(errs, errors.New("notifications.maxAttempts must be at least 1"))
+	}
+	if n.RetryMax < n.RetryBase {
+		errs = append(errs, errors.New("notifications.retryMax must not be less than notifications.retryBase"))
+	}
+	return errors.Join(errs...)
+}
+
+func (i Integrity) Validate() error {
+	var errs []error
+	if i.Interval <= 0 {
+		errs = append(errs, errors.New("integrity.interval must be positive"))
+	}
+	for _, email := range i.AlertEmails {
+		if !strings.Contains(email, "@") {
+			errs = append(errs, fmt.Errorf("integrity.alertEmails: %q is not an email address", email))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (a Anchor) Validate() error {
+	var errs []error
+	if a.TSAURL != "" && a.TSAURL != LocalTSA {
+		if !isHTTPURL(a.TSAURL) {
+			errs = append(errs, fmt.Errorf("anchor.tsaUrl %q must be an http(s) URL or %s", a.TSAURL, LocalTSA))
+		}
+	}
+	for name, d := range map[string]time.Duration{
+		"anchor.interval": a.Interval,
+		"anchor.timeout":  a.Timeout,
+	} {
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c Checkpoints) Validate() error {
+	var errs []error
+	if !witness.ValidOrigin(c.Origin) {
+		errs = append(errs, fmt.Errorf("checkpoints.origin %q must be letters, digits, dots, dashes or underscores", c.Origin))
+	}
+	if len(c.Witnesses) > 0 {
+		if _, err := witness.ParsePrivateKey(c.SigningKey); err != nil {
+			errs = append(errs, fmt.Errorf("checkpoints.signingKey is required with witnesses: %v", err))
+		}
+	}
+	peers, err := c.Peers()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if len(c.Witnesses) > 0 && (c.Quorum < 1 || c.Quorum > len(c.Witnesses)) {
+		errs = append(errs, fmt.Errorf("checkpoints.quorum must be between 1 and the %d witnesses", len(c.Witnesses)))
+	}
+	names := make(map[string]bool)
+	for _, peer := range peers {
+		if names[peer.Name] {
+			errs = append(errs, fmt.Errorf("checkpoints.witnesses: %s is listed twice", peer.Name))
+		}
+		names[peer.Name] = true
+	}
+	for name, d := range map[string]time.Duration{
+		"checkpoints.interval": c.Interval,
+		"checkpoints.timeout":  c.Timeout,
+	} {
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (w Witness) Validate() error {
+	if !w.Enabled() {
+		return nil
+	}
+	var errs []error
+	if w.Name == "" {
+		errs = append(errs, errors.New("witness.name is required when witness.signingKey is set"))
+	}
+	if _, err := witness.ParsePrivateKey(w.SigningKey); err != nil {
+		errs = append(errs, fmt.Errorf("witness.signingKey: %v", err))
+	}
+	if len(w.Origins) == 0 {
+		errs = append(errs, errors.New("witness.origins is required when witness.signingKey is set"))
+	}
+	if _, err := w.OriginKeys(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func isRole(role string) bool {
+	return role == domain.RoleAdmin || role == domain.RoleIssuer || role == domain.RoleUser
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,337 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Driver names accepted by NewMigrator, as registered with database/sql.
+const (
+	MySQL  = "mysql"
+	SQLite = "sqlite3"
+)
+
+// Migrations live in migrations/<dialect>/ as NNNN_name.up.sql and
+// NNNN_name.down.sql. A file may hold several statements, each ending with a
+// semicolon at the end of a line.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+var migrationDirs = map[string]string{
+	MySQL:  "migrations/mysql",
+	SQLite: "migrations/sqlite",
+}
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// migrationLockName names the MySQL advisory lock held while migrating.
+const migrationLockName = "certificate_ledger.schema_migrations"
+
+// migrationLockTimeout bounds how long an instance waits for another one to finish migrating.
+const migrationLockTimeout = 5 * time.Minute
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus describes a migration and whether it was applied. A
+// migration recorded in the database but unknown to this build has an
+// empty Up and Down.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrator applies the embedded migrations and records them in the
+// schema_migrations table. Every operation holds a lock, so instances that
+// start together apply each migration once.
+//
+// SQLite runs a whole operation in one transaction. MySQL commits DDL
+// implicitly, so a migration that fails part-way leaves its earlier
+// statements applied and is not recorded; fix the cause and run it again.
+type Migrator struct {
+	db         *sql.DB
+	driver     string
+	migrations []Migration
+}
+
+func NewMigrator(db *sql.DB, driver string) (*Migrator, error) {
+	migrations, err := LoadMigrations(driver)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, driver: driver, migrations: migrations}, nil
+}
+
+// LoadMigrations returns the embedded migrations for a driver in version order.
+func LoadMigrations(driver string) ([]Migration, error) {
+	dir, ok := migrationDirs[driver]
+	if !ok {
+		return nil, fmt.Errorf("no migrations for driver %s", driver)
+	}
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %v", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %v", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up applies every pending migration in version order and returns the ones it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			if err := execStatements(ctx, conn, migration.Up); err != nil {
+				return fmt.Errorf("migration %d_%s failed: %v", migration.Version, migration.Name, err)
+			}
+			query := `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`
+			if _, err := conn.ExecContext(ctx, query, migration.Version, migration.Name, time.Now().UTC()); err != nil {
+				return fmt.Errorf("failed to record migration %d: %v", migration.Version, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the last steps applied migrations, newest first, and
+// returns the ones it rolled back.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	known := make(map[int]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+
+	var rolledBack []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		versions := make([]int, 0, len(done))
+		for version := range done {
+			versions = append(versions, version)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+		for _, version := range versions {
+			if len(rolledBack) == steps {
+				break
+			}
+			migration, ok := known[version]
+			if !ok {
+				return fmt.Errorf("migration %d is applied but unknown to this build", version)
+			}
+			if err := execStatements(ctx, conn, migration.Down); err != nil {
+				return fmt.Errorf("rollback of %d_%s failed: %v", migration.Version, migration.Name, err)
+			}
+			if _, err := conn.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, version); err != nil {
+				return fmt.Errorf("failed to unrecord migration %d: %v", version, err)
+			}
+			rolledBack = append(rolledBack, migration)
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// Status lists every known or applied migration in version order.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			status := MigrationStatus{Migration: migration}
+			if record, ok := done[migration.Version]; ok {
+				status.AppliedAt = &record.appliedAt
+				delete(done, migration.Version)
+			}
+			statuses = append(statuses, status)
+		}
+		for version, record := range done {
+			appliedAt := record.appliedAt
+			statuses = append(statuses, MigrationStatus{
+				Migration: Migration{Version: version, Name: record.name},
+				AppliedAt: &appliedAt,
+			})
+		}
+		return nil
+	})
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, err
+}
+
+// withLock runs fn on a dedicated connection while holding the migration
+// lock: a MySQL advisory lock, or for SQLite a write transaction that also
+// makes the whole operation atomic.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	switch m.driver {
+	case MySQL:
+		var locked sql.NullInt64
+		err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, migrationLockName, int(migrationLockTimeout.Seconds())).Scan(&locked)
+		if err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %v", err)
+		}
+		if locked.Int64 != 1 {
+			return fmt.Errorf("timed out waiting for migration lock")
+		}
+		defer conn.QueryRowContext(context.Background(), `SELECT RELEASE_LOCK(?)`, migrationLockName).Scan(&locked)
+
+		if err := createMigrationsTable(ctx, conn); err != nil {
+			return err
+		}
+		return fn(conn)
+	case SQLite:
+		if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %v", err)
+		}
+		err := createMigrationsTable(ctx, conn)
+		if err == nil {
+			err = fn(conn)
+		}
+		if err != nil {
+			conn.ExecContext(context.Background(), `ROLLBACK`)
+			return err
+		}
+		if _, err := conn.ExecContext(ctx, `COMMIT`); err != nil {
+			return fmt.Errorf("failed to commit migrations: %v", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("no migrations for driver %s", m.driver)
+}
+
+func createMigrationsTable(ctx context.Context, conn *sql.Conn) error {
+	query := `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at DATETIME NOT NULL
+	)`
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %v", err)
+	}
+	return nil
+}
+
+type migrationRecord struct {
+	name      string
+	appliedAt time.Time
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]migrationRecord, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	done := make(map[int]migrationRecord)
+	for rows.Next() {
+		var version int
+		var record migrationRecord
+		if err := rows.Scan(&version, &record.name, &record.appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations: %v", err)
+		}
+		done[version] = record
+	}
+	return done, rows.Err()
+}
+
+// execStatements runs each statement of a migration file in turn. The MySQL
+// driver does not accept several statements in one Exec.
+func execStatements(ctx context.Context, conn *sql.Conn, script string) error {
+	for _, stmt := range splitStatements(script) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitStatements splits a script on semicolons that end a line and drops
+// "--" comment lines.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" && current.Len() == 0 || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
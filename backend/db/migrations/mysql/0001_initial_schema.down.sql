@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS lockout_events;
+DROP TABLE IF EXISTS login_throttles;
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS user_tokens;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS share_links;
+DROP TABLE IF EXISTS bulk_issue_rows;
+DROP TABLE IF EXISTS bulk_issue_jobs;
+DROP TABLE IF EXISTS certificate_field_salts;
+DROP TABLE IF EXISTS certificates;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,166 @@
+-- Schema as last created by initTables. Deployments that ran initTables
+-- already have these tables; IF NOT EXISTS lets them adopt migrations.
+
+CREATE TABLE IF NOT EXISTS users (
+    id VARCHAR(36) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) UNIQUE NOT NULL,
+    password VARCHAR(255) NOT NULL,
+    role VARCHAR(50) NOT NULL DEFAULT 'user',
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    email_verified_at DATETIME NULL,
+    totp_secret VARCHAR(64) NOT NULL DEFAULT '',
+    totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    totp_last_step BIGINT NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS certificates (
+    id VARCHAR(50) PRIMARY KEY,
+    hash VARCHAR(64) NOT NULL,
+    recipient_name VARCHAR(255) NOT NULL,
+    recipient_email VARCHAR(255) NOT NULL,
+    certificate_title VARCHAR(255) NOT NULL,
+    issue_date DATETIME NOT NULL,
+    issuer_id VARCHAR(36) NOT NULL,
+    issuer_name VARCHAR(255) NOT NULL,
+    description TEXT,
+    block_number INT NOT NULL,
+    timestamp DATETIME NOT NULL,
+    recipient_user_id VARCHAR(36) NULL,
+    claim_code_hash VARCHAR(64) NOT NULL DEFAULT '',
+    claimed_at DATETIME NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active',
+    INDEX idx_certificates_recipient_user (recipient_user_id),
+    INDEX idx_certificates_recipient_email (recipient_email),
+    INDEX idx_certificates_issuer_issue_date (issuer_id, issue_date, id),
+    INDEX idx_certificates_issue_date (issue_date, id),
+    INDEX idx_certificates_timestamp (timestamp, id),
+    INDEX idx_certificates_title (certificate_title, id),
+    INDEX idx_certificates_status (status, issue_date),
+    FULLTEXT INDEX ft_certificates_text (recipient_name, certificate_title, issuer_name, description),
+    FOREIGN KEY (issuer_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS certificate_field_salts (
+    certificate_id VARCHAR(50) NOT NULL,
+    field VARCHAR(32) NOT NULL,
+    salt VARCHAR(64) NOT NULL,
+    PRIMARY KEY (certificate_id, field),
+    FOREIGN KEY (certificate_id) REFERENCES certificates(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS bulk_issue_jobs (
+    id VARCHAR(36) PRIMARY KEY,
+    issuer_id VARCHAR(36) NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    total_rows INT NOT NULL,
+    valid_rows INT NOT NULL,
+    processed INT NOT NULL DEFAULT 0,
+    succeeded INT NOT NULL DEFAULT 0,
+    failed INT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    started_at DATETIME NULL,
+    finished_at DATETIME NULL,
+    INDEX idx_bulk_issue_jobs_issuer (issuer_id, created_at),
+    INDEX idx_bulk_issue_jobs_status (status),
+    FOREIGN KEY (issuer_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS bulk_issue_rows (
+    job_id VARCHAR(36) NOT NULL,
+    line INT NOT NULL,
+    request TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    errors TEXT NOT NULL,
+    certificate_id VARCHAR(50) NOT NULL DEFAULT '',
+    hash VARCHAR(64) NOT NULL DEFAULT '',
+    PRIMARY KEY (job_id, line),
+    FOREIGN KEY (job_id) REFERENCES bulk_issue_jobs(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS share_links (
+    id VARCHAR(64) PRIMARY KEY,
+    certificate_id VARCHAR(50) NOT NULL,
+    owner_id VARCHAR(36) NOT NULL,
+    fields VARCHAR(255) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME NULL,
+    view_count INT NOT NULL DEFAULT 0,
+    last_viewed_at DATETIME NULL,
+    created_at DATETIME NOT NULL,
+    INDEX idx_share_links_certificate_owner (certificate_id, owner_id),
+    FOREIGN KEY (certificate_id) REFERENCES certificates(id) ON DELETE CASCADE,
+    FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    prefix VARCHAR(16) UNIQUE NOT NULL,
+    key_hash VARCHAR(64) NOT NULL,
+    scopes VARCHAR(255) NOT NULL,
+    expires_at DATETIME NULL,
+    last_used_at DATETIME NULL,
+    revoked_at DATETIME NULL,
+    created_at DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at DATETIME NULL,
+    created_at DATETIME NOT NULL,
+    INDEX idx_recovery_codes_user (user_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS user_tokens (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL,
+    purpose VARCHAR(32) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME NULL,
+    created_at DATETIME NOT NULL,
+    INDEX idx_user_tokens_user_purpose (user_id, purpose),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS user_identities (
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    user_id VARCHAR(36) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    created_at DATETIME NOT NULL,
+    last_login_at DATETIME NOT NULL,
+    PRIMARY KEY (issuer, subject),
+    INDEX idx_user_identities_user (user_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS login_throttles (
+    scope VARCHAR(16) NOT NULL,
+    throttle_key VARCHAR(255) NOT NULL,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at DATETIME NOT NULL,
+    locked_until DATETIME NULL,
+    PRIMARY KEY (scope, throttle_key),
+    INDEX idx_login_throttles_locked_until (locked_until)
+);
+
+CREATE TABLE IF NOT EXISTS lockout_events (
+    id VARCHAR(36) PRIMARY KEY,
+    scope VARCHAR(16) NOT NULL,
+    throttle_key VARCHAR(255) NOT NULL,
+    event VARCHAR(32) NOT NULL,
+    actor_id VARCHAR(36) NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    INDEX idx_lockout_events_created_at (created_at)
+);
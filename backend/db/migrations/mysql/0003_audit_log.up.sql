@@ -0,0 +1,21 @@
+-- Append-only, hash-chained audit log. seq is assigned by the writer, not
+-- AUTO_INCREMENT, so a concurrent append collides on the key and retries
+-- against the new head instead of forking the chain.
+CREATE TABLE audit_log (
+    seq BIGINT PRIMARY KEY,
+    created_at DATETIME(6) NOT NULL,
+    actor_id VARCHAR(64) NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    target_type VARCHAR(32) NOT NULL,
+    target_id VARCHAR(255) NOT NULL,
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    before_state MEDIUMTEXT,
+    after_state MEDIUMTEXT,
+    previous_hash CHAR(64) NOT NULL,
+    hash CHAR(64) NOT NULL,
+    INDEX idx_audit_log_actor (actor_id, seq),
+    INDEX idx_audit_log_action (action, seq),
+    INDEX idx_audit_log_target (target_type, target_id, seq),
+    INDEX idx_audit_log_created_at (created_at)
+);
@@ -0,0 +1,5 @@
+ALTER TABLE certificates
+    DROP INDEX idx_certificates_renewed_from,
+    DROP COLUMN renewed_from,
+    DROP COLUMN revoked_at,
+    DROP COLUMN revocation_reason;
@@ -0,0 +1,5 @@
+ALTER TABLE certificates
+    ADD COLUMN renewed_from VARCHAR(50) NULL,
+    ADD COLUMN revoked_at DATETIME NULL,
+    ADD COLUMN revocation_reason VARCHAR(500) NOT NULL DEFAULT '',
+    ADD INDEX idx_certificates_renewed_from (renewed_from);
@@ -0,0 +1,43 @@
+CREATE TABLE webhook_subscriptions (
+    id VARCHAR(36) PRIMARY KEY,
+    owner_id VARCHAR(36) NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    events VARCHAR(255) NOT NULL,
+    secret VARCHAR(128) NOT NULL,
+    created_at DATETIME NOT NULL,
+    INDEX idx_webhook_subscriptions_owner (owner_id),
+    FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- The payload is stored so retries and replays send the same body.
+CREATE TABLE webhook_deliveries (
+    id VARCHAR(36) PRIMARY KEY,
+    subscription_id VARCHAR(36) NOT NULL,
+    event_id VARCHAR(36) NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    payload MEDIUMTEXT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME NOT NULL,
+    last_status_code INT NOT NULL DEFAULT 0,
+    last_error VARCHAR(1024) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    delivered_at DATETIME NULL,
+    INDEX idx_webhook_deliveries_due (status, next_attempt_at),
+    INDEX idx_webhook_deliveries_subscription (subscription_id, created_at),
+    FOREIGN KEY (subscription_id) REFERENCES webhook_subscriptions(id) ON DELETE CASCADE
+);
+
+CREATE TABLE webhook_attempts (
+    id VARCHAR(36) PRIMARY KEY,
+    delivery_id VARCHAR(36) NOT NULL,
+    attempt INT NOT NULL,
+    attempted_at DATETIME NOT NULL,
+    status_code INT NOT NULL DEFAULT 0,
+    error VARCHAR(1024) NOT NULL DEFAULT '',
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    response_body VARCHAR(1024) NOT NULL DEFAULT '',
+    INDEX idx_webhook_attempts_delivery (delivery_id, attempted_at),
+    FOREIGN KEY (delivery_id) REFERENCES webhook_deliveries(id) ON DELETE CASCADE
+);
@@ -0,0 +1,22 @@
+ALTER TABLE certificates ADD COLUMN recipient_locale VARCHAR(10) NOT NULL DEFAULT '';
+
+-- Emails are rendered when queued; the bodies are cleared once sent.
+CREATE TABLE notifications (
+    id VARCHAR(36) PRIMARY KEY,
+    certificate_id VARCHAR(50) NOT NULL,
+    template VARCHAR(64) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    locale VARCHAR(10) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    text_body MEDIUMTEXT NOT NULL,
+    html_body MEDIUMTEXT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME NOT NULL,
+    last_error VARCHAR(1024) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    sent_at DATETIME NULL,
+    INDEX idx_notifications_due (status, next_attempt_at),
+    INDEX idx_notifications_certificate (certificate_id),
+    FOREIGN KEY (certificate_id) REFERENCES certificates(id) ON DELETE CASCADE
+);
@@ -0,0 +1,17 @@
+-- Results of the periodic integrity check. Discrepancies are kept as JSON;
+-- discrepancy_count covers those beyond the stored list too.
+CREATE TABLE integrity_scans (
+    id VARCHAR(36) PRIMARY KEY,
+    status VARCHAR(16) NOT NULL,
+    scan_trigger VARCHAR(16) NOT NULL,
+    started_at DATETIME(6) NOT NULL,
+    finished_at DATETIME(6) NULL,
+    chain_valid BOOLEAN NOT NULL,
+    chain_height INT NOT NULL,
+    certificates_checked INT NOT NULL,
+    discrepancy_count INT NOT NULL,
+    new_discrepancies INT NOT NULL,
+    discrepancies MEDIUMTEXT NOT NULL,
+    error VARCHAR(1024) NOT NULL DEFAULT '',
+    INDEX idx_integrity_scans_started_at (started_at)
+);
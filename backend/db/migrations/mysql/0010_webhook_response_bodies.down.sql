@@ -0,0 +1 @@
+ALTER TABLE webhook_attempts ADD COLUMN response_body VARCHAR(1024) NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- Receivers' responses are no longer logged: a subscriber could otherwise read
+-- what any address reachable from the server answers.
+ALTER TABLE webhook_attempts DROP COLUMN response_body;
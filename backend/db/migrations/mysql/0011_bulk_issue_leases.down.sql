@@ -0,0 +1,3 @@
+ALTER TABLE bulk_issue_jobs
+    DROP COLUMN lease_owner,
+    DROP COLUMN lease_until;
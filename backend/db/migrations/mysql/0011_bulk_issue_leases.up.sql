@@ -0,0 +1,5 @@
+-- The instance running a job holds a lease on it, so that only one instance
+-- issues its rows and another picks it up once the lease runs out.
+ALTER TABLE bulk_issue_jobs
+    ADD COLUMN lease_owner VARCHAR(36) NOT NULL DEFAULT '',
+    ADD COLUMN lease_until DATETIME NULL;
@@ -0,0 +1,22 @@
+-- A witness keeps one chain per origin, so a ledger cannot start over under
+-- its name with a new genesis block. Of the chains already kept for an
+-- origin, the one last co-signed stays.
+CREATE TABLE witnessed_chains_by_origin (
+    origin VARCHAR(255) PRIMARY KEY,
+    genesis_hash VARCHAR(64) NOT NULL,
+    height INT NOT NULL,
+    head_hash VARCHAR(64) NOT NULL,
+    signed_at DATETIME(6) NOT NULL
+);
+
+INSERT INTO witnessed_chains_by_origin (origin, genesis_hash, height, head_hash, signed_at)
+SELECT c.origin, c.genesis_hash, c.height, c.head_hash, c.signed_at
+FROM witnessed_chains c
+WHERE NOT EXISTS (
+    SELECT 1 FROM witnessed_chains later
+    WHERE later.origin = c.origin
+      AND (later.signed_at > c.signed_at OR (later.signed_at = c.signed_at AND later.genesis_hash > c.genesis_hash))
+);
+
+DROP TABLE witnessed_chains;
+RENAME TABLE witnessed_chains_by_origin TO witnessed_chains;
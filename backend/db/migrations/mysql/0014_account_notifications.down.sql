@@ -0,0 +1,2 @@
+DELETE FROM notifications WHERE certificate_id IS NULL;
+ALTER TABLE notifications MODIFY certificate_id VARCHAR(50) NOT NULL;
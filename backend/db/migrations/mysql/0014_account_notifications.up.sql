@@ -0,0 +1,3 @@
+-- Account emails (email verification, password reset) are queued with the
+-- certificate emails and have no certificate.
+ALTER TABLE notifications MODIFY certificate_id VARCHAR(50) NULL;
@@ -0,0 +1,3 @@
+-- Session tokens carry the version; raising it, as a password reset does,
+-- ends every session issued before.
+ALTER TABLE users ADD COLUMN token_version INT NOT NULL DEFAULT 0;
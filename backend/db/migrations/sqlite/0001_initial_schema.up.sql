@@ -0,0 +1,152 @@
+CREATE TABLE IF NOT EXISTS users (
+    id VARCHAR(36) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) UNIQUE NOT NULL,
+    password VARCHAR(255) NOT NULL,
+    role VARCHAR(50) NOT NULL DEFAULT 'user',
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    email_verified_at DATETIME NULL,
+    totp_secret VARCHAR(64) NOT NULL DEFAULT '',
+    totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    totp_last_step BIGINT NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS certificates (
+    id VARCHAR(50) PRIMARY KEY,
+    hash VARCHAR(64) NOT NULL,
+    recipient_name VARCHAR(255) NOT NULL,
+    recipient_email VARCHAR(255) NOT NULL,
+    certificate_title VARCHAR(255) NOT NULL,
+    issue_date DATETIME NOT NULL,
+    issuer_id VARCHAR(36) NOT NULL REFERENCES users(id),
+    issuer_name VARCHAR(255) NOT NULL,
+    description TEXT,
+    block_number INT NOT NULL,
+    timestamp DATETIME NOT NULL,
+    recipient_user_id VARCHAR(36) NULL,
+    claim_code_hash VARCHAR(64) NOT NULL DEFAULT '',
+    claimed_at DATETIME NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active'
+);
+CREATE INDEX IF NOT EXISTS idx_certificates_recipient_user ON certificates (recipient_user_id);
+CREATE INDEX IF NOT EXISTS idx_certificates_recipient_email ON certificates (recipient_email);
+CREATE INDEX IF NOT EXISTS idx_certificates_issuer_issue_date ON certificates (issuer_id, issue_date, id);
+CREATE INDEX IF NOT EXISTS idx_certificates_issue_date ON certificates (issue_date, id);
+CREATE INDEX IF NOT EXISTS idx_certificates_timestamp ON certificates (timestamp, id);
+CREATE INDEX IF NOT EXISTS idx_certificates_title ON certificates (certificate_title, id);
+CREATE INDEX IF NOT EXISTS idx_certificates_status ON certificates (status, issue_date);
+
+CREATE TABLE IF NOT EXISTS certificate_field_salts (
+    certificate_id VARCHAR(50) NOT NULL REFERENCES certificates(id) ON DELETE CASCADE,
+    field VARCHAR(32) NOT NULL,
+    salt VARCHAR(64) NOT NULL,
+    PRIMARY KEY (certificate_id, field)
+);
+
+CREATE TABLE IF NOT EXISTS bulk_issue_jobs (
+    id VARCHAR(36) PRIMARY KEY,
+    issuer_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    file_name VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    total_rows INT NOT NULL,
+    valid_rows INT NOT NULL,
+    processed INT NOT NULL DEFAULT 0,
+    succeeded INT NOT NULL DEFAULT 0,
+    failed INT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    started_at DATETIME NULL,
+    finished_at DATETIME NULL
+);
+CREATE INDEX IF NOT EXISTS idx_bulk_issue_jobs_issuer ON bulk_issue_jobs (issuer_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_bulk_issue_jobs_status ON bulk_issue_jobs (status);
+
+CREATE TABLE IF NOT EXISTS bulk_issue_rows (
+    job_id VARCHAR(36) NOT NULL REFERENCES bulk_issue_jobs(id) ON DELETE CASCADE,
+    line INT NOT NULL,
+    request TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    errors TEXT NOT NULL,
+    certificate_id VARCHAR(50) NOT NULL DEFAULT '',
+    hash VARCHAR(64) NOT NULL DEFAULT '',
+    PRIMARY KEY (job_id, line)
+);
+
+CREATE TABLE IF NOT EXISTS share_links (
+    id VARCHAR(64) PRIMARY KEY,
+    certificate_id VARCHAR(50) NOT NULL REFERENCES certificates(id) ON DELETE CASCADE,
+    owner_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    fields VARCHAR(255) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME NULL,
+    view_count INT NOT NULL DEFAULT 0,
+    last_viewed_at DATETIME NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_share_links_certificate_owner ON share_links (certificate_id, owner_id);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    prefix VARCHAR(16) UNIQUE NOT NULL,
+    key_hash VARCHAR(64) NOT NULL,
+    scopes VARCHAR(255) NOT NULL,
+    expires_at DATETIME NULL,
+    last_used_at DATETIME NULL,
+    revoked_at DATETIME NULL,
+    created_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at DATETIME NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user ON recovery_codes (user_id);
+
+CREATE TABLE IF NOT EXISTS user_tokens (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(32) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_user_tokens_user_purpose ON user_tokens (user_id, purpose);
+
+CREATE TABLE IF NOT EXISTS user_identities (
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    created_at DATETIME NOT NULL,
+    last_login_at DATETIME NOT NULL,
+    PRIMARY KEY (issuer, subject)
+);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user ON user_identities (user_id);
+
+CREATE TABLE IF NOT EXISTS login_throttles (
+    scope VARCHAR(16) NOT NULL,
+    throttle_key VARCHAR(255) NOT NULL,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at DATETIME NOT NULL,
+    locked_until DATETIME NULL,
+    PRIMARY KEY (scope, throttle_key)
+);
+CREATE INDEX IF NOT EXISTS idx_login_throttles_locked_until ON login_throttles (locked_until);
+
+CREATE TABLE IF NOT EXISTS lockout_events (
+    id VARCHAR(36) PRIMARY KEY,
+    scope VARCHAR(16) NOT NULL,
+    throttle_key VARCHAR(255) NOT NULL,
+    event VARCHAR(32) NOT NULL,
+    actor_id VARCHAR(36) NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_lockout_events_created_at ON lockout_events (created_at);
@@ -0,0 +1,20 @@
+-- Append-only, hash-chained audit log. seq is assigned by the writer, so a
+-- concurrent append collides on the key and retries against the new head.
+CREATE TABLE audit_log (
+    seq BIGINT PRIMARY KEY,
+    created_at DATETIME NOT NULL,
+    actor_id VARCHAR(64) NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    target_type VARCHAR(32) NOT NULL,
+    target_id VARCHAR(255) NOT NULL,
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    before_state TEXT,
+    after_state TEXT,
+    previous_hash CHAR(64) NOT NULL,
+    hash CHAR(64) NOT NULL
+);
+CREATE INDEX idx_audit_log_actor ON audit_log (actor_id, seq);
+CREATE INDEX idx_audit_log_action ON audit_log (action, seq);
+CREATE INDEX idx_audit_log_target ON audit_log (target_type, target_id, seq);
+CREATE INDEX idx_audit_log_created_at ON audit_log (created_at);
@@ -0,0 +1,4 @@
+DROP INDEX idx_certificates_renewed_from;
+ALTER TABLE certificates DROP COLUMN renewed_from;
+ALTER TABLE certificates DROP COLUMN revoked_at;
+ALTER TABLE certificates DROP COLUMN revocation_reason;
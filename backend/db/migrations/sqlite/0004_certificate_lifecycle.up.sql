@@ -0,0 +1,4 @@
+ALTER TABLE certificates ADD COLUMN renewed_from VARCHAR(50) NULL;
+ALTER TABLE certificates ADD COLUMN revoked_at DATETIME NULL;
+ALTER TABLE certificates ADD COLUMN revocation_reason VARCHAR(500) NOT NULL DEFAULT '';
+CREATE INDEX idx_certificates_renewed_from ON certificates (renewed_from);
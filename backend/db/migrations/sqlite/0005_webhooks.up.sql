@@ -0,0 +1,40 @@
+CREATE TABLE webhook_subscriptions (
+    id VARCHAR(36) PRIMARY KEY,
+    owner_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url VARCHAR(2048) NOT NULL,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    events VARCHAR(255) NOT NULL,
+    secret VARCHAR(128) NOT NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX idx_webhook_subscriptions_owner ON webhook_subscriptions (owner_id);
+
+-- The payload is stored so retries and replays send the same body.
+CREATE TABLE webhook_deliveries (
+    id VARCHAR(36) PRIMARY KEY,
+    subscription_id VARCHAR(36) NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+    event_id VARCHAR(36) NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    payload TEXT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME NOT NULL,
+    last_status_code INT NOT NULL DEFAULT 0,
+    last_error VARCHAR(1024) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    delivered_at DATETIME NULL
+);
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);
+CREATE INDEX idx_webhook_deliveries_subscription ON webhook_deliveries (subscription_id, created_at);
+
+CREATE TABLE webhook_attempts (
+    id VARCHAR(36) PRIMARY KEY,
+    delivery_id VARCHAR(36) NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+    attempt INT NOT NULL,
+    attempted_at DATETIME NOT NULL,
+    status_code INT NOT NULL DEFAULT 0,
+    error VARCHAR(1024) NOT NULL DEFAULT '',
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    response_body VARCHAR(1024) NOT NULL DEFAULT ''
+);
+CREATE INDEX idx_webhook_attempts_delivery ON webhook_attempts (delivery_id, attempted_at);
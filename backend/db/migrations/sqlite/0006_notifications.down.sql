@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS notifications;
+ALTER TABLE certificates DROP COLUMN recipient_locale;
@@ -0,0 +1,21 @@
+ALTER TABLE certificates ADD COLUMN recipient_locale VARCHAR(10) NOT NULL DEFAULT '';
+
+-- Emails are rendered when queued; the bodies are cleared once sent.
+CREATE TABLE notifications (
+    id VARCHAR(36) PRIMARY KEY,
+    certificate_id VARCHAR(50) NOT NULL REFERENCES certificates(id) ON DELETE CASCADE,
+    template VARCHAR(64) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    locale VARCHAR(10) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    text_body TEXT NOT NULL,
+    html_body TEXT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME NOT NULL,
+    last_error VARCHAR(1024) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    sent_at DATETIME NULL
+);
+CREATE INDEX idx_notifications_due ON notifications (status, next_attempt_at);
+CREATE INDEX idx_notifications_certificate ON notifications (certificate_id);
@@ -0,0 +1,16 @@
+-- Time-stamps of the chain's head from an RFC 3161 authority. receipt is the
+-- authority's DER reply, kept so the anchor can be checked independently.
+CREATE TABLE anchors (
+    id VARCHAR(36) PRIMARY KEY,
+    block_index INT NOT NULL,
+    block_hash VARCHAR(64) NOT NULL,
+    tsa_url VARCHAR(2048) NOT NULL,
+    serial_number VARCHAR(64) NOT NULL,
+    policy VARCHAR(255) NOT NULL,
+    gen_time DATETIME NOT NULL,
+    accuracy_micros BIGINT NOT NULL,
+    receipt BLOB NOT NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX idx_anchors_block_index ON anchors (block_index);
+CREATE INDEX idx_anchors_created_at ON anchors (created_at);
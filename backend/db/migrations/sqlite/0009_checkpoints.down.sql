@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS witnessed_chains;
+DROP TABLE IF EXISTS checkpoints;
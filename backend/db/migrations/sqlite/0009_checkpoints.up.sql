@@ -0,0 +1,22 @@
+-- Checkpoints of this ledger's chain, with the witnesses' signatures as JSON.
+CREATE TABLE checkpoints (
+    id VARCHAR(36) PRIMARY KEY,
+    origin VARCHAR(255) NOT NULL,
+    genesis_hash VARCHAR(64) NOT NULL,
+    height INT NOT NULL,
+    head_hash VARCHAR(64) NOT NULL,
+    created_at DATETIME NOT NULL,
+    signatures TEXT NOT NULL
+);
+CREATE INDEX idx_checkpoints_genesis ON checkpoints (genesis_hash, height);
+CREATE INDEX idx_checkpoints_created_at ON checkpoints (created_at);
+
+-- When this server is a witness: the last checkpoint it co-signed for each
+-- chain of another ledger.
+CREATE TABLE witnessed_chains (
+    genesis_hash VARCHAR(64) PRIMARY KEY,
+    origin VARCHAR(255) NOT NULL,
+    height INT NOT NULL,
+    head_hash VARCHAR(64) NOT NULL,
+    signed_at DATETIME NOT NULL
+);
@@ -0,0 +1,2 @@
+ALTER TABLE bulk_issue_jobs DROP COLUMN lease_until;
+ALTER TABLE bulk_issue_jobs DROP COLUMN lease_owner;
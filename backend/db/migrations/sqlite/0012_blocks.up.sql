@@ -0,0 +1,11 @@
+-- The blocks of the chain, loaded at startup. The timestamp is kept to the
+-- nanosecond since it is part of the block's hash.
+CREATE TABLE blocks (
+    height INT PRIMARY KEY,
+    timestamp_ns BIGINT NOT NULL,
+    data BLOB NOT NULL,
+    previous_hash VARCHAR(64) NOT NULL,
+    hash VARCHAR(64) NOT NULL,
+    nonce BIGINT NOT NULL
+);
+CREATE UNIQUE INDEX idx_blocks_hash ON blocks (hash);
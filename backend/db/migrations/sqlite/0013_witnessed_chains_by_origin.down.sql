@@ -0,0 +1,13 @@
+CREATE TABLE witnessed_chains_by_genesis (
+    genesis_hash VARCHAR(64) PRIMARY KEY,
+    origin VARCHAR(255) NOT NULL,
+    height INT NOT NULL,
+    head_hash VARCHAR(64) NOT NULL,
+    signed_at DATETIME NOT NULL
+);
+
+INSERT INTO witnessed_chains_by_genesis (genesis_hash, origin, height, head_hash, signed_at)
+SELECT genesis_hash, origin, height, head_hash, signed_at FROM witnessed_chains;
+
+DROP TABLE witnessed_chains;
+ALTER TABLE witnessed_chains_by_genesis RENAME TO witnessed_chains;
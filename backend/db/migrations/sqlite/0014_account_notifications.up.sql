@@ -0,0 +1,25 @@
+-- Account emails (email verification, password reset) are queued with the
+-- certificate emails and have no certificate.
+CREATE TABLE notifications_new (
+    id VARCHAR(36) PRIMARY KEY,
+    certificate_id VARCHAR(50) NULL REFERENCES certificates(id) ON DELETE CASCADE,
+    template VARCHAR(64) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    locale VARCHAR(10) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    text_body TEXT NOT NULL,
+    html_body TEXT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME NOT NULL,
+    last_error VARCHAR(1024) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    sent_at DATETIME NULL
+);
+
+INSERT INTO notifications_new SELECT id, certificate_id, template, recipient, locale, subject, text_body, html_body, status, attempts, next_attempt_at, last_error, created_at, sent_at FROM notifications;
+
+DROP TABLE notifications;
+ALTER TABLE notifications_new RENAME TO notifications;
+CREATE INDEX idx_notifications_due ON notifications (status, next_attempt_at);
+CREATE INDEX idx_notifications_certificate ON notifications (certificate_id);
@@ -0,0 +1,52 @@
+package domain
+
+import "time"
+
+const (
+	DefaultAnchorLimit = 20
+	MaxAnchorLimit     = 200
+)
+
+// Anchor is a time-stamp authority's signed statement that the chain's head
+// had BlockHash at BlockIndex no later than GenTime, give or take Accuracy.
+// Since every block links to the one before it, the anchor also covers every
+// earlier block. Receipt is the authority's RFC 3161 reply as received.
+type Anchor struct {
+	ID           string    `json:"id"`
+	BlockIndex   int       `json:"blockIndex"`
+	BlockHash    string    `json:"blockHash"`
+	TSAURL       string    `json:"tsaUrl"`
+	SerialNumber string    `json:"serialNumber"`
+	Policy       string    `json:"policy"`
+	GenTime      time.Time `json:"genTime"`
+	// AccuracyMicros is how far GenTime may be off, as the authority states.
+	AccuracyMicros int64     `json:"accuracyMicros"`
+	Receipt        []byte    `json:"-"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// AnchorVerification tells whether a certificate is covered by an anchor. If
+// it is, the certificate's block existed no later than ExistedBefore.
+type AnchorVerification struct {
+	CertificateID   string     `json:"certificateId"`
+	CertificateHash string     `json:"certificateHash"`
+	BlockIndex      int        `json:"blockIndex"`
+	Anchored        bool       `json:"anchored"`
+	ExistedBefore   *time.Time `json:"existedBefore,omitempty"`
+	Anchor          *Anchor    `json:"anchor,omitempty"`
+	// Reason says why the certificate is not anchored.
+	Reason string `json:"reason,omitempty"`
+}
+
+// AnchorCheck tells whether a stored anchor still holds: its receipt verifies
+// and the stored chain still has the anchored hash at the anchored height,
+// with every block up to it intact.
+type AnchorCheck struct {
+	AnchorID   string    `json:"anchorId"`
+	BlockIndex int       `json:"blockIndex"`
+	BlockHash  string    `json:"blockHash"`
+	Valid      bool      `json:"valid"`
+	GenTime    time.Time `json:"genTime"`
+	// Reason says why the anchor does not hold.
+	Reason string `json:"reason,omitempty"`
+}
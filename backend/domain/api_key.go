@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"time"
+)
+
+const (
+	ScopeCertificatesRead  = "certificates:read"
+	ScopeCertificatesIssue = "certificates:issue"
+)
+
+// APIKeyScopes lists every scope an API key may be granted.
+var APIKeyScopes = []string{
+	ScopeCertificatesRead,
+	ScopeCertificatesIssue,
+}
+
+// roleScopes lists the scopes each role's API keys may carry. Roles missing
+// here cannot have API keys.
+var roleScopes = map[string][]string{
+	RoleAdmin:  {ScopeCertificatesRead, ScopeCertificatesIssue},
+	RoleIssuer: {ScopeCertificatesRead, ScopeCertificatesIssue},
+}
+
+// RoleAllowsScope reports whether a user with the role may use the scope
+// through an API key.
+func RoleAllowsScope(role, scope string) bool {
+	for _, s := range roleScopes[role] {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"userId"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *APIKey) IsActive(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	if k.ExpiresAt != nil && !now.Before(*k.ExpiresAt) {
+		return false
+	}
+	return true
+}
+
+type APIKeyRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expiresInDays"`
+}
+
+// APIKeyResponse carries the plaintext key, which is only ever returned once at creation.
+type APIKeyResponse struct {
+	APIKey APIKey `json:"apiKey"`
+	Key    string `json:"key"`
+}
@@ -0,0 +1,177 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Audited actions.
+const (
+	AuditUserRegister   = "user.register"
+	AuditUserCreate     = "user.create"
+	AuditUserProvision  = "user.provision"
+	AuditUserDelete     = "user.delete"
+	AuditAdminBootstrap = "user.bootstrap_admin"
+
+	AuditCertificateIssue  = "certificate.issue"
+	AuditCertificateRevoke = "certificate.revoke"
+	AuditCertificateRenew  = "certificate.renew"
+	AuditBulkIssueUpload   = "certificate.bulk_upload"
+
+	AuditAPIKeyCreate = "api_key.create"
+	AuditAPIKeyRevoke = "api_key.revoke"
+
+	AuditLockoutUnlock = "lockout.unlock"
+
+	AuditWebhookCreate = "webhook.create"
+	AuditWebhookDelete = "webhook.delete"
+	AuditWebhookReplay = "webhook.replay"
+
+	AuditNotificationRetry = "notification.retry"
+
+	AuditIntegrityScan = "integrity.scan"
+
+	AuditAnchorCreate     = "anchor.create"
+	AuditCheckpointCreate = "checkpoint.create"
+)
+
+// Audit target types.
+const (
+	AuditTargetUser          = "user"
+	AuditTargetCertificate   = "certificate"
+	AuditTargetBulkJob       = "bulk_issue_job"
+	AuditTargetAPIKey        = "api_key"
+	AuditTargetLockout       = "lockout"
+	AuditTargetWebhook       = "webhook"
+	AuditTargetNotification  = "notification"
+	AuditTargetIntegrityScan = "integrity_scan"
+	AuditTargetAnchor        = "anchor"
+	AuditTargetCheckpoint    = "checkpoint"
+)
+
+// AuditActorSystem is the actor of entries the server writes on its own, such
+// as the bootstrap admin.
+const AuditActorSystem = "system"
+
+const (
+	DefaultAuditLimit = 50
+	MaxAuditLimit     = 500
+)
+
+// AuditEntry is one record of the append-only audit log. Entries are numbered
+// from 1 without gaps and each one's Hash covers its fields and the previous
+// entry's hash, so editing or deleting an entry breaks the chain after it.
+type AuditEntry struct {
+	Seq        int64     `json:"seq"`
+	CreatedAt  time.Time `json:"createdAt"`
+	ActorID    string    `json:"actorId"`
+	Action     string    `json:"action"`
+	TargetType string    `json:"targetType"`
+	TargetID   string    `json:"targetId"`
+	IP         string    `json:"ip,omitempty"`
+	UserAgent  string    `json:"userAgent,omitempty"`
+	// Before and After are JSON snapshots of the target, empty when the
+	// target did not exist before or after the action.
+	Before       json.RawMessage `json:"before,omitempty"`
+	After        json.RawMessage `json:"after,omitempty"`
+	PreviousHash string          `json:"previousHash"`
+	Hash         string          `json:"hash"`
+}
+
+// AuditGenesisHash is the PreviousHash of the first entry.
+const AuditGenesisHash = "0"
+
+// ComputeHash returns the hash the entry should carry. Times are hashed at
+// microsecond precision, which every storage backend keeps.
+func (e *AuditEntry) ComputeHash() string {
+	h := sha256.New()
+	for _, field := range []string{
+		strconv.FormatInt(e.Seq, 10),
+		strconv.FormatInt(e.CreatedAt.UnixMicro(), 10),
+		e.ActorID,
+		e.Action,
+		e.TargetType,
+		e.TargetID,
+		e.IP,
+		e.UserAgent,
+		string(e.Before),
+		string(e.After),
+		e.PreviousHash,
+	} {
+		// Length prefixes keep "ab"+"c" and "a"+"bc" apart.
+		h.Write([]byte(strconv.Itoa(len(field))))
+		h.Write([]byte{':'})
+		h.Write([]byte(field))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// AuditFilter narrows an audit query. Empty fields do not filter.
+type AuditFilter struct {
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	From       *time.Time
+	// To is exclusive.
+	To *time.Time
+}
+
+// AuditQuery pages through the log newest first. BeforeSeq, when set, only
+// returns entries older than that sequence number.
+type AuditQuery struct {
+	Filter    AuditFilter
+	BeforeSeq int64
+	Limit     int
+}
+
+type AuditPage struct {
+	Entries    []*AuditEntry `json:"entries"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+}
+
+// Matches reports whether e passes the filter, for backends that filter in memory.
+func (f AuditFilter) Matches(e *AuditEntry) bool {
+	if f.ActorID != "" && e.ActorID != f.ActorID {
+		return false
+	}
+	if f.Action != "" && e.Action != f.Action {
+		return false
+	}
+	if f.TargetType != "" && e.TargetType != f.TargetType {
+		return false
+	}
+	if f.TargetID != "" && e.TargetID != f.TargetID {
+		return false
+	}
+	if f.From != nil && e.CreatedAt.Before(*f.From) {
+		return false
+	}
+	if f.To != nil && !e.CreatedAt.Before(*f.To) {
+		return false
+	}
+	return true
+}
+
+// AuditProblem is an integrity failure found by verifying the log.
+type AuditProblem struct {
+	Seq     int64  `json:"seq"`
+	Problem string `json:"problem"`
+}
+
+// AuditVerification is the result of checking the whole chain. HeadSeq and
+// HeadHash identify the newest entry; recording them elsewhere lets a later
+// check detect entries removed from the end, which the chain alone cannot.
+type AuditVerification struct {
+	Entries  int64          `json:"entries"`
+	HeadSeq  int64          `json:"headSeq"`
+	HeadHash string         `json:"headHash"`
+	Problems []AuditProblem `json:"problems"`
+}
+
+func (v *AuditVerification) Valid() bool {
+	return len(v.Problems) == 0
+}
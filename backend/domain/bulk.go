@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"time"
+)
+
+const (
+	BulkJobPending   = "pending"
+	BulkJobRunning   = "running"
+	BulkJobCompleted = "completed"
+	BulkJobFailed    = "failed"
+
+	BulkRowPending = "pending"
+	BulkRowInvalid = "invalid"
+	// BulkRowIssuing marks a row whose certificate, under the row's
+	// CertificateID, may or may not have been issued when the job stopped.
+	BulkRowIssuing = "issuing"
+	BulkRowIssued  = "issued"
+	BulkRowFailed  = "failed"
+)
+
+// BulkIssueJob tracks the background issuance of an uploaded file.
+type BulkIssueJob struct {
+	ID         string     `json:"id"`
+	IssuerID   string     `json:"issuerId"`
+	FileName   string     `json:"fileName"`
+	Status     string     `json:"status"`
+	TotalRows  int        `json:"totalRows"`
+	ValidRows  int        `json:"validRows"`
+	Processed  int        `json:"processed"`
+	Succeeded  int        `json:"succeeded"`
+	Failed     int        `json:"failed"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// Progress is the share of valid rows processed, from 0 to 1.
+func (j *BulkIssueJob) Progress() float64 {
+	if j.ValidRows == 0 {
+		return 1
+	}
+	return float64(j.Processed) / float64(j.ValidRows)
+}
+
+// BulkIssueRow is one data row of an uploaded file. Row is the 1-based line
+// number in the file, counting the header row.
+type BulkIssueRow struct {
+	JobID         string             `json:"-"`
+	Row           int                `json:"row"`
+	Request       CertificateRequest `json:"request"`
+	Status        string             `json:"status"`
+	Errors        []string           `json:"errors,omitempty"`
+	CertificateID string             `json:"certificateId,omitempty"`
+	Hash          string             `json:"hash,omitempty"`
+}
+
+// BulkIssueReport describes an upload after validation. Job is nil for dry runs.
+type BulkIssueReport struct {
+	DryRun      bool            `json:"dryRun"`
+	TotalRows   int             `json:"totalRows"`
+	ValidRows   int             `json:"validRows"`
+	InvalidRows int             `json:"invalidRows"`
+	Errors      []*BulkIssueRow `json:"errors"`
+	Job         *BulkIssueJob   `json:"job,omitempty"`
+}
+
+type BulkIssueJobStatus struct {
+	*BulkIssueJob
+	Progress float64 `json:"progress"`
+}
@@ -4,25 +4,80 @@ import (
 	"time"
 )
 
+const (
+	CertificateStatusActive  = "active"
+	CertificateStatusRevoked = "revoked"
+	// CertificateStatusSuperseded marks a certificate replaced by a renewal.
+	// It still verifies; only revoked certificates do not.
+	CertificateStatusSuperseded = "superseded"
+)
+
+// CertificateStatuses lists every lifecycle status a certificate can have.
+var CertificateStatuses = []string{
+	CertificateStatusActive,
+	CertificateStatusRevoked,
+	CertificateStatusSuperseded,
+}
+
+// MaxRevocationReasonLength matches the revocation_reason column.
+const MaxRevocationReasonLength = 500
+
 type Certificate struct {
-	ID              string    `json:"id"`
-	Hash            string    `json:"hash"`
-	RecipientName   string    `json:"recipientName"`
-	RecipientEmail  string    `json:"recipientEmail"`
-	CertificateTitle string   `json:"certificateTitle"`
-	IssueDate       time.Time `json:"issueDate"`
-	IssuerID        string    `json:"issuerId"`
-	IssuerName      string    `json:"issuerName"`
-	Description     string    `json:"description"`
-	BlockNumber     int       `json:"blockNumber"`
-	Timestamp       time.Time `json:"timestamp"`
+	ID               string    `json:"id"`
+	Hash             string    `json:"hash"`
+	RecipientName    string    `json:"recipientName"`
+	RecipientEmail   string    `json:"recipientEmail"`
+	CertificateTitle string    `json:"certificateTitle"`
+	IssueDate        time.Time `json:"issueDate"`
+	IssuerID         string    `json:"issuerId"`
+	IssuerName       string    `json:"issuerName"`
+	Description      string    `json:"description"`
+	BlockNumber      int       `json:"blockNumber"`
+	Timestamp        time.Time `json:"timestamp"`
+	// Commitments holds a salted hash of every disclosable field. They are
+	// written into the block in place of the values, so selectively
+	// disclosed fields stay verifiable.
+	Commitments map[string]string `json:"commitments,omitempty"`
+	FieldSalts  map[string]string `json:"-"`
+	// RecipientUserID is set once the recipient claims the certificate into their wallet.
+	RecipientUserID string     `json:"recipientUserId,omitempty"`
+	ClaimedAt       *time.Time `json:"claimedAt,omitempty"`
+	ClaimCodeHash   string     `json:"-"`
+	// ClaimCode is only populated in the response to the issuer at creation time.
+	ClaimCode string `json:"claimCode,omitempty"`
+	// RenewedFrom is the ID of the certificate this one renews. Like the
+	// ID, issuer ID and timestamp, it is written into the block as it is.
+	RenewedFrom string `json:"renewedFrom,omitempty"`
+	// Status lives only in the database; it is set after the block is mined.
+	Status           string     `json:"status,omitempty"`
+	RevokedAt        *time.Time `json:"revokedAt,omitempty"`
+	RevocationReason string     `json:"revocationReason,omitempty"`
+	// RecipientLocale picks the language of emails to the recipient. Like
+	// Status it is kept out of the block.
+	RecipientLocale string `json:"recipientLocale,omitempty"`
 }
 
 type CertificateRequest struct {
-	RecipientName   string `json:"recipientName"`
-	RecipientEmail  string `json:"recipientEmail"`
+	RecipientName    string `json:"recipientName"`
+	RecipientEmail   string `json:"recipientEmail"`
 	CertificateTitle string `json:"certificateTitle"`
-	IssueDate       string `json:"issueDate"`
-	IssuerName      string `json:"issuerName"`
-	Description     string `json:"description"`
+	IssueDate        string `json:"issueDate"`
+	IssuerName       string `json:"issuerName"`
+	Description      string `json:"description"`
+	// RecipientLocale is optional and defaults to the server's default locale.
+	RecipientLocale string `json:"recipientLocale"`
+}
+
+type ClaimRequest struct {
+	ClaimCode string `json:"claimCode"`
+}
+
+type RevokeCertificateRequest struct {
+	Reason string `json:"reason"`
+}
+
+// RenewCertificateRequest reissues a certificate with the same contents. The
+// issue date defaults to today.
+type RenewCertificateRequest struct {
+	IssueDate string `json:"issueDate"`
 }
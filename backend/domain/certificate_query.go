@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	CertificateSortIssueDate     = "issueDate"
+	CertificateSortTimestamp     = "timestamp"
+	CertificateSortTitle         = "certificateTitle"
+	CertificateSortRecipientName = "recipientName"
+	CertificateSortBlockNumber   = "blockNumber"
+
+	SortAscending  = "asc"
+	SortDescending = "desc"
+
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// CertificateSortFields lists the fields certificate listings can be sorted by.
+var CertificateSortFields = []string{
+	CertificateSortIssueDate,
+	CertificateSortTimestamp,
+	CertificateSortTitle,
+	CertificateSortRecipientName,
+	CertificateSortBlockNumber,
+}
+
+// CertificateFilter narrows a certificate listing. Empty fields do not filter.
+type CertificateFilter struct {
+	IssuerID       string
+	RecipientEmail string
+	// Title matches certificate titles containing the value.
+	Title      string
+	IssuedFrom *time.Time
+	// IssuedTo is inclusive of the whole day.
+	IssuedTo *time.Time
+	Status   string
+	// WalletUserID restricts the listing to a recipient's wallet: certificates
+	// they claimed plus unclaimed ones issued to WalletEmail, if set.
+	WalletUserID string
+	WalletEmail  string
+}
+
+type CertificateQuery struct {
+	Filter CertificateFilter
+	Sort   string
+	Order  string
+	// Cursor is the opaque NextCursor of the previous page.
+	Cursor string
+	Limit  int
+}
+
+type CertificatePage struct {
+	Items      []*Certificate `json:"items"`
+	Total      int            `json:"total"`
+	Limit      int            `json:"limit"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
+
+// Matches reports whether cert passes the filter. It mirrors the SQL the
+// repository builds, for backends that filter in memory.
+func (f CertificateFilter) Matches(cert *Certificate) bool {
+	if f.IssuerID != "" && cert.IssuerID != f.IssuerID {
+		return false
+	}
+	if f.RecipientEmail != "" && cert.RecipientEmail != f.RecipientEmail {
+		return false
+	}
+	if f.Title != "" && !strings.Contains(strings.ToLower(cert.CertificateTitle), strings.ToLower(f.Title)) {
+		return false
+	}
+	if f.IssuedFrom != nil && cert.IssueDate.Before(*f.IssuedFrom) {
+		return false
+	}
+	if f.IssuedTo != nil && !cert.IssueDate.Before(f.IssuedTo.AddDate(0, 0, 1)) {
+		return false
+	}
+	if f.Status != "" && cert.Status != f.Status {
+		return false
+	}
+	if f.WalletUserID != "" {
+		claimedByUser := cert.RecipientUserID == f.WalletUserID
+		issuedToEmail := cert.RecipientUserID == "" && f.WalletEmail != "" && cert.RecipientEmail == f.WalletEmail
+		if !claimedByUser && !issuedToEmail {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package domain
+
+import "time"
+
+const (
+	DefaultCheckpointLimit = 20
+	MaxCheckpointLimit     = 200
+)
+
+// Checkpoint is the chain's height and head hash at one point, as sent to
+// witnesses. Once Quorum witnesses have co-signed it, a chain whose block at
+// Height has another hash is invalid.
+type Checkpoint struct {
+	ID          string                `json:"id"`
+	Origin      string                `json:"origin"`
+	GenesisHash string                `json:"genesisHash"`
+	Height      int                   `json:"height"`
+	HeadHash    string                `json:"headHash"`
+	CreatedAt   time.Time             `json:"createdAt"`
+	Signatures  []CheckpointSignature `json:"signatures"`
+	// Cosigned is set when enough witnesses signed; it is not stored.
+	Cosigned bool `json:"cosigned"`
+}
+
+// CheckpointSignature is one witness's signature of a checkpoint. PublicKey
+// is the hex key it verifies with.
+type CheckpointSignature struct {
+	Witness   string    `json:"witness"`
+	PublicKey string    `json:"publicKey"`
+	SignedAt  time.Time `json:"signedAt"`
+	Signature []byte    `json:"signature"`
+}
+
+// WitnessedChain is what a witness keeps of another ledger's chain: the last
+// checkpoint it co-signed for the ledger's origin.
+type WitnessedChain struct {
+	GenesisHash string    `json:"genesisHash"`
+	Origin      string    `json:"origin"`
+	Height      int       `json:"height"`
+	HeadHash    string    `json:"headHash"`
+	SignedAt    time.Time `json:"signedAt"`
+}
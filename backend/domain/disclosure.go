@@ -0,0 +1,169 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	FieldRecipientName    = "recipientName"
+	FieldRecipientEmail   = "recipientEmail"
+	FieldCertificateTitle = "certificateTitle"
+	FieldIssueDate        = "issueDate"
+	FieldIssuerName       = "issuerName"
+	FieldDescription      = "description"
+)
+
+// DisclosableFields lists the certificate fields a recipient can selectively reveal.
+var DisclosableFields = []string{
+	FieldRecipientName,
+	FieldRecipientEmail,
+	FieldCertificateTitle,
+	FieldIssueDate,
+	FieldIssuerName,
+	FieldDescription,
+}
+
+func IsDisclosableField(field string) bool {
+	for _, f := range DisclosableFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// FieldValue returns the canonical string form of a disclosable field that commitments are computed over.
+func FieldValue(cert *Certificate, field string) string {
+	switch field {
+	case FieldRecipientName:
+		return cert.RecipientName
+	case FieldRecipientEmail:
+		return cert.RecipientEmail
+	case FieldCertificateTitle:
+		return cert.CertificateTitle
+	case FieldIssueDate:
+		return cert.IssueDate.UTC().Format("2006-01-02")
+	case FieldIssuerName:
+		return cert.IssuerName
+	case FieldDescription:
+		return cert.Description
+	}
+	return ""
+}
+
+// FieldCommitment hashes a field value with its salt. Publishing the commitment
+// reveals nothing about the value until the salt and value are disclosed.
+func FieldCommitment(field, salt, value string) string {
+	sum := sha256.Sum256([]byte(field + "\x00" + salt + "\x00" + value))
+	return hex.EncodeToString(sum[:])
+}
+
+// CertificateBlock is what a certificate's block holds: the certificate's
+// ID, a commitment to every disclosable field and the metadata that is not
+// disclosable, including the issuing account. The field values stay off the
+// chain, since anyone who can read blocks would otherwise see every
+// recipient's details.
+type CertificateBlock struct {
+	ID          string            `json:"id"`
+	IssuerID    string            `json:"issuerId"`
+	Commitments map[string]string `json:"commitments"`
+	Timestamp   time.Time         `json:"timestamp"`
+	RenewedFrom string            `json:"renewedFrom,omitempty"`
+}
+
+// NewCertificateBlock returns the block contents of a certificate whose
+// fields have been committed to.
+func NewCertificateBlock(cert *Certificate) *CertificateBlock {
+	return &CertificateBlock{
+		ID:          cert.ID,
+		IssuerID:    cert.IssuerID,
+		Commitments: cert.Commitments,
+		Timestamp:   cert.Timestamp,
+		RenewedFrom: cert.RenewedFrom,
+	}
+}
+
+// ParseCertificateBlock decodes a block's data. It fails for blocks that do
+// not hold a certificate, such as the genesis block.
+func ParseCertificateBlock(data []byte) (*CertificateBlock, error) {
+	var block CertificateBlock
+	if err := json.Unmarshal(data, &block); err != nil {
+		return nil, err
+	}
+	if block.ID == "" || block.Commitments == nil {
+		return nil, errors.New("block does not hold a certificate")
+	}
+	return &block, nil
+}
+
+// Differences names what the block records that cert no longer matches: its
+// ID, the issuer, each field whose value and salt do not give the commitment,
+// the timestamp, compared to the second since databases may store it with
+// less precision, and renewedFrom.
+func (b *CertificateBlock) Differences(cert *Certificate, salts map[string]string) []string {
+	var fields []string
+	if cert.ID != b.ID {
+		fields = append(fields, "id")
+	}
+	if cert.IssuerID != b.IssuerID {
+		fields = append(fields, "issuerId")
+	}
+	for _, field := range DisclosableFields {
+		commitment, ok := b.Commitments[field]
+		if !ok || FieldCommitment(field, salts[field], FieldValue(cert, field)) != commitment {
+			fields = append(fields, field)
+		}
+	}
+	if d := cert.Timestamp.Sub(b.Timestamp); d <= -time.Second || d >= time.Second {
+		fields = append(fields, "timestamp")
+	}
+	if cert.RenewedFrom != b.RenewedFrom {
+		fields = append(fields, "renewedFrom")
+	}
+	return fields
+}
+
+type ShareLink struct {
+	ID            string     `json:"id"`
+	CertificateID string     `json:"certificateId"`
+	OwnerID       string     `json:"ownerId"`
+	Fields        []string   `json:"fields"`
+	ExpiresAt     time.Time  `json:"expiresAt"`
+	RevokedAt     *time.Time `json:"revokedAt,omitempty"`
+	ViewCount     int        `json:"viewCount"`
+	LastViewedAt  *time.Time `json:"lastViewedAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+type ShareLinkRequest struct {
+	Fields         []string `json:"fields"`
+	ExpiresInHours int      `json:"expiresInHours"`
+}
+
+// DisclosedField is a revealed value together with the salt needed to check it against its commitment.
+type DisclosedField struct {
+	Value      string `json:"value"`
+	Salt       string `json:"salt"`
+	Commitment string `json:"commitment"`
+	Verified   bool   `json:"verified"`
+}
+
+// SharedCertificate is what a share link shows to its viewer.
+type SharedCertificate struct {
+	CertificateID string                    `json:"certificateId"`
+	Hash          string                    `json:"hash"`
+	BlockNumber   int                       `json:"blockNumber"`
+	Timestamp     time.Time                 `json:"timestamp"`
+	Disclosed     map[string]DisclosedField `json:"disclosed"`
+	Commitments   map[string]string         `json:"commitments"`
+	// Status is the certificate's lifecycle status. A revoked certificate
+	// is never shown as verified.
+	Status    string     `json:"status"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+	Verified  bool       `json:"verified"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+}
@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error kinds. Test for them with errors.Is; handlers map each kind to an HTTP status.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrForbidden    = errors.New("forbidden")
+	ErrUnauthorized = errors.New("unauthorized")
+)
+
+// Error is an expected failure whose message is safe to show to API clients.
+// Code is a stable, machine-readable identifier such as "certificate_not_found".
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func newError(kind error, code, format string, args ...interface{}) *Error {
+	return &Error{Kind: kind, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func NotFound(code, format string, args ...interface{}) *Error {
+	return newError(ErrNotFound, code, format, args...)
+}
+
+func Conflict(code, format string, args ...interface{}) *Error {
+	return newError(ErrConflict, code, format, args...)
+}
+
+// Invalid reports a request that is well-formed but cannot be carried out as asked.
+func Invalid(code, format string, args ...interface{}) *Error {
+	return newError(ErrValidation, code, format, args...)
+}
+
+func Forbidden(code, format string, args ...interface{}) *Error {
+	return newError(ErrForbidden, code, format, args...)
+}
+
+func Unauthorized(code, format string, args ...interface{}) *Error {
+	return newError(ErrUnauthorized, code, format, args...)
+}
+
+// ErrorCode returns the code of a domain error, or "" for any other error.
+func ErrorCode(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return ""
+}
@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"strconv"
+	"time"
+)
+
+// Integrity scan outcomes.
+const (
+	IntegrityClean         = "clean"
+	IntegrityDiscrepancies = "discrepancies"
+	// IntegrityFailed means the scan could not finish, for example because
+	// the database was unreachable.
+	IntegrityFailed = "failed"
+)
+
+var IntegrityStatuses = []string{
+	IntegrityClean,
+	IntegrityDiscrepancies,
+	IntegrityFailed,
+}
+
+// Kinds of discrepancy an integrity scan can find.
+const (
+	// DiscrepancyChainInvalid: a block's hash does not match its contents or
+	// the block before it.
+	DiscrepancyChainInvalid = "chain_invalid"
+	// DiscrepancyBlockMissing: no block on the chain has the certificate's hash.
+	DiscrepancyBlockMissing = "block_missing"
+	// DiscrepancyBlockNumber: the block with the certificate's hash is not at
+	// the certificate's block number.
+	DiscrepancyBlockNumber = "block_number_mismatch"
+	// DiscrepancyBlockData: the certificate row differs from the certificate
+	// recorded in its block.
+	DiscrepancyBlockData = "block_data_mismatch"
+	// DiscrepancyUnrecordedBlock: a block holds a certificate that has no row.
+	DiscrepancyUnrecordedBlock = "unrecorded_block"
+	// DiscrepancyStoredBlock: the blocks table differs from the chain the
+	// server loaded, so the chain will not be the same after a restart.
+	DiscrepancyStoredBlock = "stored_block_mismatch"
+)
+
+const (
+	DefaultIntegrityScanLimit = 20
+	MaxIntegrityScanLimit     = 200
+	// MaxIntegrityDiscrepancies bounds the discrepancies kept per scan; the
+	// count covers them all.
+	MaxIntegrityDiscrepancies = 500
+)
+
+// IntegrityScan is one run of the integrity check: the chain is re-validated
+// and every certificate row compared with its block.
+type IntegrityScan struct {
+	ID                  string     `json:"id"`
+	Status              string     `json:"status"`
+	Trigger             string     `json:"trigger"`
+	StartedAt           time.Time  `json:"startedAt"`
+	FinishedAt          *time.Time `json:"finishedAt,omitempty"`
+	ChainValid          bool       `json:"chainValid"`
+	ChainHeight         int        `json:"chainHeight"`
+	CertificatesChecked int        `json:"certificatesChecked"`
+	DiscrepancyCount    int        `json:"discrepancyCount"`
+	// NewDiscrepancies counts the discrepancies the previous scan did not
+	// find; only those are alerted on.
+	NewDiscrepancies int                    `json:"newDiscrepancies"`
+	Discrepancies    []IntegrityDiscrepancy `json:"discrepancies"`
+	Error            string                 `json:"error,omitempty"`
+}
+
+// Scan triggers.
+const (
+	IntegrityTriggerSchedule = "schedule"
+	IntegrityTriggerManual   = "manual"
+)
+
+type IntegrityDiscrepancy struct {
+	Kind          string `json:"kind"`
+	CertificateID string `json:"certificateId,omitempty"`
+	BlockIndex    int    `json:"blockIndex"`
+	Detail        string `json:"detail"`
+}
+
+// Key identifies the discrepancy across scans.
+func (d IntegrityDiscrepancy) Key() string {
+	return d.Kind + "/" + d.CertificateID + "/" + strconv.Itoa(d.BlockIndex)
+}
+
+// IntegrityAlertEvent is the body of integrity.discrepancy webhook
+// deliveries, sent when a scan finds discrepancies the previous one did not.
+type IntegrityAlertEvent struct {
+	ID        string             `json:"id"`
+	Type      string             `json:"type"`
+	CreatedAt time.Time          `json:"createdAt"`
+	Data      IntegrityAlertData `json:"data"`
+}
+
+type IntegrityAlertData struct {
+	ScanID           string                 `json:"scanId"`
+	DiscrepancyCount int                    `json:"discrepancyCount"`
+	Discrepancies    []IntegrityDiscrepancy `json:"discrepancies"`
+}
@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"time"
+)
+
+const (
+	ThrottleScopeAccount = "account"
+	ThrottleScopeIP      = "ip"
+
+	LockoutEventLocked   = "locked"
+	LockoutEventUnlocked = "unlocked"
+)
+
+// LoginThrottle tracks recent failed logins for one account or client IP.
+type LoginThrottle struct {
+	Scope         string     `json:"scope"`
+	Key           string     `json:"key"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"lastFailureAt"`
+	LockedUntil   *time.Time `json:"lockedUntil,omitempty"`
+}
+
+// LockoutEvent is the audit record written whenever a lockout starts or is lifted by an admin.
+type LockoutEvent struct {
+	ID        string    `json:"id"`
+	Scope     string    `json:"scope"`
+	Key       string    `json:"key"`
+	Event     string    `json:"event"`
+	ActorID   string    `json:"actorId,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"time"
+)
+
+// Locales emails can be written in. DefaultLocale is used when a certificate
+// does not name one.
+const (
+	LocaleEnglish    = "en"
+	LocaleVietnamese = "vi"
+	DefaultLocale    = LocaleEnglish
+)
+
+var Locales = []string{LocaleEnglish, LocaleVietnamese}
+
+// Notification templates. Account emails are rendered by the account
+// service and carry no certificate.
+const (
+	NotificationCertificateIssued = "certificate_issued"
+	NotificationEmailVerification = "email_verification"
+	NotificationPasswordReset     = "password_reset"
+)
+
+// Notification statuses. A notification is retried while pending; once it
+// runs out of attempts it has failed and stays so until retried by an admin.
+const (
+	NotificationPending = "pending"
+	NotificationSent    = "sent"
+	NotificationFailed  = "failed"
+)
+
+var NotificationStatuses = []string{
+	NotificationPending,
+	NotificationSent,
+	NotificationFailed,
+}
+
+const (
+	DefaultNotificationLimit = 50
+	MaxNotificationLimit     = 500
+)
+
+// Notification is a queued email: to a certificate's recipient, or to an
+// account holder, in which case CertificateID is empty. It is rendered when
+// queued, so retries send exactly the same message. The bodies carry claim
+// codes and account tokens, so they are cleared once the email is sent.
+type Notification struct {
+	ID            string     `json:"id"`
+	CertificateID string     `json:"certificateId"`
+	Template      string     `json:"template"`
+	Recipient     string     `json:"recipient"`
+	Locale        string     `json:"locale"`
+	Subject       string     `json:"subject"`
+	TextBody      string     `json:"-"`
+	HTMLBody      string     `json:"-"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"nextAttemptAt"`
+	LastError     string     `json:"lastError,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	SentAt        *time.Time `json:"sentAt,omitempty"`
+}
@@ -0,0 +1,34 @@
+package domain
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// SearchableFields lists the certificate fields covered by full-text search.
+var SearchableFields = []string{
+	FieldRecipientName,
+	FieldCertificateTitle,
+	FieldIssuerName,
+	FieldDescription,
+}
+
+type CertificateSearchQuery struct {
+	Text string
+	// Filter restricts results to certificates the caller may see.
+	Filter CertificateFilter
+	Limit  int
+}
+
+type CertificateSearchHit struct {
+	Certificate *Certificate `json:"certificate"`
+	Score       float64      `json:"score"`
+	// Highlights holds HTML-escaped field values with matches wrapped in <mark>,
+	// keyed by field name. Only fields containing a match are present.
+	Highlights map[string]string `json:"highlights"`
+}
+
+type CertificateSearchResult struct {
+	Query string                  `json:"query"`
+	Hits  []*CertificateSearchHit `json:"hits"`
+}
@@ -4,14 +4,37 @@ import (
 	"time"
 )
 
+const (
+	RoleAdmin  = "admin"
+	RoleIssuer = "issuer"
+	RoleUser   = "user"
+)
+
+// RequiresTwoFactor reports whether accounts with role must complete a second
+// factor at login because they can issue certificates or administer the system.
+func RequiresTwoFactor(role string) bool {
+	return role == RoleAdmin || role == RoleIssuer
+}
+
 type User struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Password  string    `json:"-"`
-	Role      string    `json:"role"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID              string     `json:"id"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email"`
+	Password        string     `json:"-"`
+	Role            string     `json:"role"`
+	EmailVerified   bool       `json:"emailVerified"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty"`
+	TOTPSecret      string     `json:"-"`
+	TOTPEnabled     bool       `json:"totpEnabled"`
+	TOTPLastStep    int64      `json:"-"`
+	// TokenVersion is carried by session tokens; raising it ends every
+	// session issued before.
+	TokenVersion int `json:"-"`
+	// PasswordChangeRequired blocks everything but changing the password,
+	// e.g. for the bootstrap admin whose initial password was configured or logged.
+	PasswordChangeRequired bool      `json:"passwordChangeRequired"`
+	CreatedAt              time.Time `json:"createdAt"`
+	UpdatedAt              time.Time `json:"updatedAt"`
 }
 
 type UserRequest struct {
@@ -21,12 +44,41 @@ type UserRequest struct {
 	Role     string `json:"role"`
 }
 
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
 type LoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 }
 
+// AuthResponse is returned by login. When MFARequired is set, Token is empty and
+// MFAToken must be exchanged together with a one-time code for a session token.
 type AuthResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
-}
\ No newline at end of file
+	Token              string   `json:"token,omitempty"`
+	User               *User    `json:"user,omitempty"`
+	MFARequired        bool     `json:"mfaRequired,omitempty"`
+	MFAToken           string   `json:"mfaToken,omitempty"`
+	EnrollmentRequired bool     `json:"enrollmentRequired,omitempty"`
+	RecoveryCodes      []string `json:"recoveryCodes,omitempty"`
+}
+
+type TOTPLoginRequest struct {
+	MFAToken string `json:"mfaToken"`
+	Code     string `json:"code"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type TOTPEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauthUri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
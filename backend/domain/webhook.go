@@ -0,0 +1,141 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Certificate lifecycle events delivered to webhooks.
+const (
+	EventCertificateIssued  = "certificate.issued"
+	EventCertificateRevoked = "certificate.revoked"
+	EventCertificateRenewed = "certificate.renewed"
+)
+
+// EventIntegrityDiscrepancy reports discrepancies found by an integrity
+// scan. Only admins may subscribe to it.
+const EventIntegrityDiscrepancy = "integrity.discrepancy"
+
+var CertificateEventTypes = []string{
+	EventCertificateIssued,
+	EventCertificateRevoked,
+	EventCertificateRenewed,
+}
+
+// WebhookEventTypes lists every event a webhook can subscribe to.
+var WebhookEventTypes = []string{
+	EventCertificateIssued,
+	EventCertificateRevoked,
+	EventCertificateRenewed,
+	EventIntegrityDiscrepancy,
+}
+
+// CertificateEvent is emitted after a certificate changes. It is also the
+// body of webhook deliveries, so its JSON form is part of the public API.
+type CertificateEvent struct {
+	ID        string               `json:"id"`
+	Type      string               `json:"type"`
+	CreatedAt time.Time            `json:"createdAt"`
+	Data      CertificateEventData `json:"data"`
+	// ClaimCode is set on certificate.issued and certificate.renewed so the
+	// recipient can be told it. It is never marshalled.
+	ClaimCode string `json:"-"`
+}
+
+type CertificateEventData struct {
+	Certificate *Certificate `json:"certificate"`
+	// PreviousCertificateID is the renewed certificate on certificate.renewed.
+	PreviousCertificateID string `json:"previousCertificateId,omitempty"`
+}
+
+// Delivery statuses. A delivery is retried while pending; once it runs out of
+// attempts it is dead and stays in the dead-letter queue until replayed.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryDead      = "dead"
+)
+
+var WebhookDeliveryStatuses = []string{
+	WebhookDeliveryPending,
+	WebhookDeliverySucceeded,
+	WebhookDeliveryDead,
+}
+
+const (
+	DefaultWebhookDeliveryLimit = 50
+	MaxWebhookDeliveryLimit     = 500
+)
+
+// WebhookSubscription sends the selected events for the certificates its
+// owner issued; subscriptions owned by an admin receive every certificate's
+// events. The secret signs each delivery and is only shown at creation.
+type WebhookSubscription struct {
+	ID          string    `json:"id"`
+	OwnerID     string    `json:"ownerId"`
+	URL         string    `json:"url"`
+	Description string    `json:"description,omitempty"`
+	Events      []string  `json:"events"`
+	Secret      string    `json:"-"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func (s *WebhookSubscription) Wants(eventType string) bool {
+	for _, e := range s.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookSubscriptionRequest struct {
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	Events      []string `json:"events"`
+}
+
+// WebhookSubscriptionResponse carries the signing secret, which is only ever
+// returned once at creation.
+type WebhookSubscriptionResponse struct {
+	Subscription WebhookSubscription `json:"subscription"`
+	Secret       string              `json:"secret"`
+}
+
+// WebhookDelivery is one event sent to one subscription. Its payload is
+// stored so retries and replays send exactly the same body.
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	SubscriptionID string          `json:"subscriptionId"`
+	EventID        string          `json:"eventId"`
+	EventType      string          `json:"eventType"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	// Attempts counts the attempts since the delivery was created or last replayed.
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"nextAttemptAt"`
+	LastStatusCode int        `json:"lastStatusCode,omitempty"`
+	LastError      string     `json:"lastError,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty"`
+}
+
+// WebhookAttempt is an entry of a delivery's log.
+type WebhookAttempt struct {
+	ID          string    `json:"id"`
+	DeliveryID  string    `json:"-"`
+	Attempt     int       `json:"attempt"`
+	AttemptedAt time.Time `json:"attemptedAt"`
+	StatusCode  int       `json:"statusCode,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	DurationMS  int64     `json:"durationMs"`
+}
+
+type WebhookDeliveryDetail struct {
+	*WebhookDelivery
+	Log []*WebhookAttempt `json:"log"`
+}
+
+type WebhookReplayResult struct {
+	Replayed int64 `json:"replayed"`
+}
@@ -0,0 +1,56 @@
+package mail
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileMailer writes each message as an .eml file into a directory instead of
+// sending it, for staging servers and for handing mail to another process.
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{
+		Dir:  dir,
+		From: from,
+	}
+}
+
+// Send writes the message under a temporary name and renames it, so a
+// process watching the directory never reads a partial file.
+func (m *FileMailer) Send(msg Message) error {
+	if len(msg.To) == 0 {
+		return fmt.Errorf("message has no recipients")
+	}
+
+	body, err := buildMIME(m.From, msg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.Dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create mail directory: %v", err)
+	}
+
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Errorf("failed to generate mail file name: %v", err)
+	}
+	name := time.Now().UTC().Format("20060102T150405.000000000") + "-" + hex.EncodeToString(b) + ".eml"
+
+	tmp := filepath.Join(m.Dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, body, 0o640); err != nil {
+		return fmt.Errorf("failed to write mail file: %v", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(m.Dir, name)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write mail file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package mail
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveSMTP answers one connection as a minimal SMTP server and returns the
+// message data it received.
+func serveSMTP(t *testing.T, l net.Listener) <-chan string {
+	t.Helper()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+		reply("220 test ESMTP")
+		var data strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250 test")
+			case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+				reply("250 ok")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				received <- data.String()
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+	return received
+}
+
+func newTestMailer(t *testing.T, l net.Listener) *SMTPMailer {
+	host, port, err := net.SplitHostPort(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewSMTPMailer(host, port, "", "", "ledger@example.edu")
+}
+
+func TestSMTPMailerSends(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	received := serveSMTP(t, l)
+
+	err = newTestMailer(t, l).Send(Message{To: []string{"alice@example.com"}, Subject: "Hello", TextBody: "Hi Alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case data := <-received:
+		if !strings.Contains(data, "To: alice@example.com") || !strings.Contains(data, "Hi Alice") {
+			t.Errorf("server received %q", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server received nothing")
+	}
+}
+
+func TestSMTPMailerGivesUpOnSilentServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	// Accept the connection but never greet.
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	mailer := newTestMailer(t, l)
+	mailer.Timeout = 200 * time.Millisecond
+	start := time.Now()
+	if err := mailer.Send(Message{To: []string{"alice@example.com"}, Subject: "Hello", TextBody: "Hi"}); err == nil {
+		t.Fatal("send to a silent server succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("send gave up after %s, want about %s", elapsed, mailer.Timeout)
+	}
+}
@@ -0,0 +1,57 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Templates live in templates/<name>.<locale>.txt and .html. The text
+// template starts with a "Subject:" line and a blank line, like a message
+// header; the rest is the plain-text body. The HTML template is the HTML body.
+//
+//go:embed templates
+var templateFS embed.FS
+
+var (
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.txt"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/*.html"))
+)
+
+// HasTemplate reports whether the template exists in the locale.
+func HasTemplate(name, locale string) bool {
+	return textTemplates.Lookup(name+"."+locale+".txt") != nil &&
+		htmlTemplates.Lookup(name+"."+locale+".html") != nil
+}
+
+// Render fills in a template for a locale and returns the message without
+// recipients.
+func Render(name, locale string, data interface{}) (Message, error) {
+	textTmpl := textTemplates.Lookup(name + "." + locale + ".txt")
+	htmlTmpl := htmlTemplates.Lookup(name + "." + locale + ".html")
+	if textTmpl == nil || htmlTmpl == nil {
+		return Message{}, fmt.Errorf("no %s mail template for locale %s", name, locale)
+	}
+
+	var text, html bytes.Buffer
+	if err := textTmpl.Execute(&text, data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s mail: %v", name, err)
+	}
+	if err := htmlTmpl.Execute(&html, data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s mail: %v", name, err)
+	}
+
+	header, body, ok := strings.Cut(text.String(), "\n\n")
+	subject, hasSubject := strings.CutPrefix(header, "Subject:")
+	if !ok || !hasSubject || strings.Contains(header, "\n") {
+		return Message{}, fmt.Errorf("%s mail template must start with a Subject line and a blank line", name)
+	}
+	return Message{
+		Subject:  strings.TrimSpace(subject),
+		TextBody: body,
+		HTMLBody: html.String(),
+	}, nil
+}
@@ -0,0 +1,34 @@
+package metrics
+
+import "database/sql"
+
+// PublishDBStats publishes the connection pool statistics of db. It may be
+// called once per process.
+func PublishDBStats(db *sql.DB) {
+	gauges := map[string]struct {
+		help string
+		read func(sql.DBStats) float64
+	}{
+		"ledger_db_max_open_connections": {"Maximum number of open connections to the database.", func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }},
+		"ledger_db_open_connections":     {"Established connections, in use or idle.", func(s sql.DBStats) float64 { return float64(s.OpenConnections) }},
+		"ledger_db_in_use_connections":   {"Connections currently in use.", func(s sql.DBStats) float64 { return float64(s.InUse) }},
+		"ledger_db_idle_connections":     {"Idle connections.", func(s sql.DBStats) float64 { return float64(s.Idle) }},
+	}
+	for name, g := range gauges {
+		NewGaugeFunc(name, g.help, func() float64 { return g.read(db.Stats()) })
+	}
+
+	counters := map[string]struct {
+		help string
+		read func(sql.DBStats) float64
+	}{
+		"ledger_db_wait_count_total":            {"Connections waited for.", func(s sql.DBStats) float64 { return float64(s.WaitCount) }},
+		"ledger_db_wait_duration_seconds_total": {"Time spent waiting for a connection.", func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }},
+		"ledger_db_max_idle_closed_total":       {"Connections closed because of the idle connection limit.", func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }},
+		"ledger_db_max_idle_time_closed_total":  {"Connections closed because they were idle too long.", func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }},
+		"ledger_db_max_lifetime_closed_total":   {"Connections closed because they reached their maximum lifetime.", func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }},
+	}
+	for name, c := range counters {
+		NewCounterFunc(name, c.help, func() float64 { return c.read(db.Stats()) })
+	}
+}
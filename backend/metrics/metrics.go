@@ -0,0 +1,337 @@
+// Package metrics keeps the server's counters, gauges and histograms and
+// serves them in the Prometheus text exposition format. Like expvar, metrics
+// are package-level variables registered under a unique name when created:
+//
+//	var requests = metrics.NewCounterVec("ledger_http_requests_total", "HTTP requests served.", "route", "code")
+//
+//	requests.Inc("/api/chain", "200")
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContentType is the media type of the exposition format Write produces.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are upper bounds in seconds suited to request latencies.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// ExponentialBuckets returns count upper bounds, starting at start and each
+// factor times the previous one.
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+var registry = struct {
+	sync.Mutex
+	metrics map[string]metric
+}{metrics: make(map[string]metric)}
+
+// register adds a metric to the registry. As with expvar, reusing a name is
+// a programming error and panics.
+func register(name string, m metric) {
+	registry.Lock()
+	defer registry.Unlock()
+	if _, dup := registry.metrics[name]; dup {
+		panic("metrics: reuse of metric name " + name)
+	}
+	registry.metrics[name] = m
+}
+
+// Write writes every metric, sorted by name, in the exposition format.
+func Write(w io.Writer) error {
+	registry.Lock()
+	names := make([]string, 0, len(registry.metrics))
+	for name := range registry.metrics {
+		names = append(names, name)
+	}
+	metrics := make([]metric, len(names))
+	sort.Strings(names)
+	for i, name := range names {
+		metrics[i] = registry.metrics[name]
+	}
+	registry.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler serves the metrics for Prometheus to scrape.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		Write(w)
+	})
+}
+
+// desc is what every metric has: a name, help text and label names.
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (d *desc) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, d.kind)
+}
+
+// key joins label values into a map key, checking their number.
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// writeSample writes one line; extra is an additional label such as "le".
+func (d *desc) writeSample(w *bufio.Writer, suffix string, values []string, extra string, value float64) {
+	w.WriteString(d.name)
+	w.WriteString(suffix)
+	if len(values) > 0 || extra != "" {
+		w.WriteByte('{')
+		for i, v := range values {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", d.labels[i], escapeLabel(v))
+		}
+		if extra != "" {
+			if len(values) > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(extra)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+// series is the value of a metric for one combination of label values.
+type series struct {
+	values []string
+	value  float64
+}
+
+// vec holds the series of a counter or gauge.
+type vec struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+func newVec(name, help, kind string, labels []string) *vec {
+	v := &vec{desc: desc{name: name, help: help, kind: kind, labels: labels}, series: make(map[string]*series)}
+	register(name, v)
+	return v
+}
+
+func (v *vec) update(values []string, f func(*series)) {
+	key := v.key(values)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{values: append([]string(nil), values...)}
+		v.series[key] = s
+	}
+	f(s)
+}
+
+func (v *vec) write(w *bufio.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.writeHeader(w)
+	for _, s := range sortedSeries(v.series) {
+		v.writeSample(w, "", s.values, "", s.value)
+	}
+}
+
+// CounterVec is a counter partitioned by labels. A counter without labels is
+// a CounterVec called with no label values.
+type CounterVec struct {
+	*vec
+}
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{newVec(name, help, "counter", labels)}
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds delta, which must not be negative.
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic("metrics: counter " + c.name + " cannot decrease")
+	}
+	c.update(labelValues, func(s *series) { s.value += delta })
+}
+
+// GaugeVec is a value that goes up and down, partitioned by labels.
+type GaugeVec struct {
+	*vec
+}
+
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{newVec(name, help, "gauge", labels)}
+}
+
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.update(labelValues, func(s *series) { s.value = value })
+}
+
+// funcMetric reads its single value when the metrics are written.
+type funcMetric struct {
+	desc
+	read func() float64
+}
+
+func (f *funcMetric) write(w *bufio.Writer) {
+	f.writeHeader(w)
+	f.writeSample(w, "", nil, "", f.read())
+}
+
+// NewGaugeFunc publishes what read returns as a gauge, such as the length of
+// a queue kept elsewhere.
+func NewGaugeFunc(name, help string, read func() float64) {
+	register(name, &funcMetric{desc: desc{name: name, help: help, kind: "gauge"}, read: read})
+}
+
+// NewCounterFunc publishes what read returns as a counter; read must never
+// return less than it did before.
+func NewCounterFunc(name, help string, read func() float64) {
+	register(name, &funcMetric{desc: desc{name: name, help: help, kind: "counter"}, read: read})
+}
+
+// HistogramVec counts observations in buckets, partitioned by labels.
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogram
+}
+
+type histogram struct {
+	values []string
+	// counts[i] counts observations in bucket i alone; the last is +Inf.
+	counts []uint64
+	sum    float64
+}
+
+// NewHistogramVec creates a histogram with the given bucket upper bounds,
+// which must be increasing; the +Inf bucket is implied.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if !sort.Float64sAreSorted(buckets) {
+		panic("metrics: buckets of " + name + " are not increasing")
+	}
+	h := &HistogramVec{
+		desc:    desc{name: name, help: help, kind: "histogram", labels: labels},
+		buckets: buckets,
+		series:  make(map[string]*histogram),
+	}
+	register(name, h)
+	return h
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := h.key(labelValues)
+	bucket := sort.SearchFloat64s(h.buckets, value)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{values: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets)+1)}
+		h.series[key] = s
+	}
+	s.counts[bucket]++
+	s.sum += value
+}
+
+// ObserveSince observes the seconds elapsed since start.
+func (h *HistogramVec) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(w)
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := h.series[key]
+		var cumulative uint64
+		for i, count := range s.counts {
+			cumulative += count
+			le := math.Inf(1)
+			if i < len(h.buckets) {
+				le = h.buckets[i]
+			}
+			h.writeSample(w, "_bucket", s.values, `le="`+formatFloat(le)+`"`, float64(cumulative))
+		}
+		h.writeSample(w, "_sum", s.values, "", s.sum)
+		h.writeSample(w, "_count", s.values, "", float64(cumulative))
+	}
+}
+
+func sortedSeries(m map[string]*series) []*series {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	out := make([]*series, len(keys))
+	for i, key := range keys {
+		out[i] = m[key]
+	}
+	return out
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
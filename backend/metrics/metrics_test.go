@@ -0,0 +1,220 @@
+package metrics
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// scrape returns the exposition of every registered metric.
+func scrape(t *testing.T) string {
+	t.Helper()
+	var b strings.Builder
+	if err := Write(&b); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	return b.String()
+}
+
+// family returns the lines written for the metric name: its header and its
+// samples.
+func family(t *testing.T, name string) string {
+	t.Helper()
+	var lines []string
+	for _, line := range strings.SplitAfter(scrape(t), "\n") {
+		rest, ok := strings.CutPrefix(line, name)
+		if !ok {
+			rest, ok = strings.CutPrefix(line, "# HELP "+name+" ")
+		}
+		if !ok {
+			rest, ok = strings.CutPrefix(line, "# TYPE "+name+" ")
+		}
+		if ok && (strings.HasPrefix(line, "#") || strings.IndexAny(rest, "{ ") == 0 || strings.HasPrefix(rest, "_")) {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+func TestCounterVec(t *testing.T) {
+	c := NewCounterVec("test_counter_total", "Requests served.", "route", "code")
+	c.Inc("/b", "500")
+	c.Inc("/a", "200")
+	c.Add(2, "/a", "200")
+
+	want := `# HELP test_counter_total Requests served.
+# TYPE test_counter_total counter
+test_counter_total{route="/a",code="200"} 3
+test_counter_total{route="/b",code="500"} 1
+`
+	if got := family(t, "test_counter_total"); got != want {
+		t.Errorf("exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCounterWithoutLabels(t *testing.T) {
+	c := NewCounterVec("test_unlabelled_total", "Events.")
+	c.Add(1.5)
+
+	want := "# HELP test_unlabelled_total Events.\n# TYPE test_unlabelled_total counter\ntest_unlabelled_total 1.5\n"
+	if got := family(t, "test_unlabelled_total"); got != want {
+		t.Errorf("exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGaugeVec(t *testing.T) {
+	g := NewGaugeVec("test_gauge", "Queue length.", "queue")
+	g.Set(4, "mail")
+	g.Set(-2, "mail")
+
+	want := "# HELP test_gauge Queue length.\n# TYPE test_gauge gauge\ntest_gauge{queue=\"mail\"} -2\n"
+	if got := family(t, "test_gauge"); got != want {
+		t.Errorf("exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEscaping(t *testing.T) {
+	c := NewCounterVec("test_escaped_total", "Help with \\ and\na new line.", "path")
+	c.Inc("a\"b\\c\nd")
+
+	want := `# HELP test_escaped_total Help with \\ and\na new line.
+# TYPE test_escaped_total counter
+test_escaped_total{path="a\"b\\c\nd"} 1
+`
+	if got := family(t, "test_escaped_total"); got != want {
+		t.Errorf("exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	h := NewHistogramVec("test_duration_seconds", "Latency.", []float64{0.125, 1}, "route")
+	// 0.125 falls in its own bucket: bounds are inclusive.
+	for _, v := range []float64{0.0625, 0.125, 0.5, 2} {
+		h.Observe(v, "/a")
+	}
+
+	want := `# HELP test_duration_seconds Latency.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="/a",le="0.125"} 2
+test_duration_seconds_bucket{route="/a",le="1"} 3
+test_duration_seconds_bucket{route="/a",le="+Inf"} 4
+test_duration_seconds_sum{route="/a"} 2.6875
+test_duration_seconds_count{route="/a"} 4
+`
+	if got := family(t, "test_duration_seconds"); got != want {
+		t.Errorf("exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFuncMetrics(t *testing.T) {
+	n := 0.0
+	NewGaugeFunc("test_func_gauge", "Read on scrape.", func() float64 { return n })
+	NewCounterFunc("test_func_total", "Read on scrape.", func() float64 { return n * 2 })
+	n = 21
+
+	if got := family(t, "test_func_gauge"); !strings.HasSuffix(got, "# TYPE test_func_gauge gauge\ntest_func_gauge 21\n") {
+		t.Errorf("gauge exposition:\n%s", got)
+	}
+	if got := family(t, "test_func_total"); !strings.HasSuffix(got, "# TYPE test_func_total counter\ntest_func_total 42\n") {
+		t.Errorf("counter exposition:\n%s", got)
+	}
+}
+
+func TestWriteSortsByName(t *testing.T) {
+	NewGaugeFunc("test_sorted_b", "B.", func() float64 { return 0 })
+	NewGaugeFunc("test_sorted_a", "A.", func() float64 { return 0 })
+
+	out := scrape(t)
+	a, b := strings.Index(out, "# HELP test_sorted_a "), strings.Index(out, "# HELP test_sorted_b ")
+	if a < 0 || b < 0 || a > b {
+		t.Errorf("test_sorted_a at %d, test_sorted_b at %d; want a before b", a, b)
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	for v, want := range map[float64]string{
+		math.Inf(1):  "+Inf",
+		math.Inf(-1): "-Inf",
+		0.25:         "0.25",
+		1e21:         "1e+21",
+		3:            "3",
+	} {
+		if got := formatFloat(v); got != want {
+			t.Errorf("formatFloat(%v) = %q, want %q", v, got, want)
+		}
+	}
+	if got := formatFloat(math.NaN()); got != "NaN" {
+		t.Errorf("formatFloat(NaN) = %q, want NaN", got)
+	}
+}
+
+func TestMisusePanics(t *testing.T) {
+	NewCounterVec("test_misuse_total", "Misuse.", "label")
+	tests := map[string]func(){
+		"reused name":       func() { NewGaugeVec("test_misuse_total", "Again.") },
+		"wrong label count": func() { NewCounterVec("test_misuse_labels_total", "Labels.", "a").Inc("x", "y") },
+		"negative counter":  func() { NewCounterVec("test_misuse_negative_total", "Negative.").Add(-1) },
+		"unsorted buckets":  func() { NewHistogramVec("test_misuse_buckets", "Buckets.", []float64{1, 0.5}) },
+		"histogram label count": func() {
+			NewHistogramVec("test_misuse_histogram", "Labels.", DefaultBuckets, "a").Observe(1)
+		},
+	}
+	for name, f := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("did not panic")
+				}
+			}()
+			f()
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	NewCounterVec("test_handler_total", "Scraped.").Inc()
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if got := rec.Header().Get("Content-Type"); got != ContentType {
+		t.Errorf("Content-Type = %q, want %q", got, ContentType)
+	}
+	if !strings.Contains(rec.Body.String(), "\ntest_handler_total 1\n") {
+		t.Errorf("body does not hold test_handler_total:\n%s", rec.Body.String())
+	}
+}
+
+// sampleLine is a sample in the text exposition format: a metric name,
+// optional labels with quoted values and a value.
+var sampleLine = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*(\{[a-zA-Z_][a-zA-Z0-9_]*="(\\.|[^"\\])*"(,[a-zA-Z_][a-zA-Z0-9_]*="(\\.|[^"\\])*")*\})? (\+Inf|-Inf|NaN|-?[0-9.]+(e[+-]?[0-9]+)?)$`)
+
+func TestPublishRuntimeStats(t *testing.T) {
+	PublishRuntimeStats()
+	out := scrape(t)
+
+	names := []string{"go_info", "go_goroutines", "go_memstats_alloc_bytes", "go_gc_cycles_total", "process_start_time_seconds"}
+	if runtime.GOOS == "linux" {
+		names = append(names, "process_open_fds", "process_resident_memory_bytes", "process_cpu_seconds_total")
+	}
+	for _, name := range names {
+		if !strings.Contains(out, "# TYPE "+name+" ") {
+			t.Errorf("%s is not published", name)
+		}
+	}
+	if !strings.Contains(out, `go_info{version="`+runtime.Version()+`"} 1`) {
+		t.Errorf("go_info does not give the Go version")
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		if strings.HasPrefix(line, "# HELP ") || strings.HasPrefix(line, "# TYPE ") {
+			continue
+		}
+		if !sampleLine.MatchString(line) {
+			t.Errorf("malformed sample line %q", line)
+		}
+	}
+}
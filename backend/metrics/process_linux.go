@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// clockTicks is the kernel's USER_HZ, the unit of the CPU times in
+// /proc/self/stat. It is 100 on every Linux architecture Go supports.
+const clockTicks = 100
+
+// publishProcessStats publishes what /proc tells of the process.
+func publishProcessStats() {
+	pageSize := float64(os.Getpagesize())
+
+	NewGaugeFunc("process_open_fds", "Number of open file descriptors.", func() float64 {
+		entries, err := os.ReadDir("/proc/self/fd")
+		if err != nil {
+			return 0
+		}
+		return float64(len(entries))
+	})
+	NewGaugeFunc("process_max_fds", "Maximum number of open file descriptors.", func() float64 {
+		var limit syscall.Rlimit
+		if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+			return 0
+		}
+		return float64(limit.Cur)
+	})
+	NewGaugeFunc("process_virtual_memory_bytes", "Virtual memory size in bytes.", func() float64 {
+		return statm(0) * pageSize
+	})
+	NewGaugeFunc("process_resident_memory_bytes", "Resident memory size in bytes.", func() float64 {
+		return statm(1) * pageSize
+	})
+	NewCounterFunc("process_cpu_seconds_total", "User and system CPU time spent, in seconds.", func() float64 {
+		data, err := os.ReadFile("/proc/self/stat")
+		if err != nil {
+			return 0
+		}
+		// The command name in parentheses may hold spaces; the fields
+		// after it start with the state, the third field.
+		fields := bytes.Fields(data[bytes.LastIndexByte(data, ')')+1:])
+		if len(fields) < 13 {
+			return 0
+		}
+		utime, _ := strconv.ParseFloat(string(fields[11]), 64)
+		stime, _ := strconv.ParseFloat(string(fields[12]), 64)
+		return (utime + stime) / clockTicks
+	})
+}
+
+// statm returns the field'th number in /proc/self/statm, in pages.
+func statm(field int) float64 {
+	data, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0
+	}
+	fields := bytes.Fields(data)
+	if len(fields) <= field {
+		return 0
+	}
+	pages, _ := strconv.ParseFloat(string(fields[field]), 64)
+	return pages
+}
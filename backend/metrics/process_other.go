@@ -0,0 +1,7 @@
+//go:build !linux
+
+package metrics
+
+// publishProcessStats publishes nothing: the process statistics are read
+// from /proc, which only Linux has.
+func publishProcessStats() {}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// memStatsMaxAge bounds how stale the memory statistics written may be.
+// Reading them stops the world briefly, so one read serves every metric of a
+// scrape.
+const memStatsMaxAge = time.Second
+
+// processStart is when the package was initialized, near enough the
+// process's start.
+var processStart = time.Now()
+
+// PublishRuntimeStats publishes the Go runtime's and the process's
+// statistics under the names Prometheus's Go client uses, so existing
+// dashboards work. It may be called once per process.
+func PublishRuntimeStats() {
+	version := NewGaugeVec("go_info", "Information about the Go environment.", "version")
+	version.Set(1, runtime.Version())
+
+	NewGaugeFunc("go_goroutines", "Number of goroutines that currently exist.", func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+	NewGaugeFunc("go_gomaxprocs", "Number of operating system threads that can run Go code at once.", func() float64 {
+		return float64(runtime.GOMAXPROCS(0))
+	})
+
+	stats := &memStats{}
+	gauges := map[string]struct {
+		help string
+		read func(*runtime.MemStats) float64
+	}{
+		"go_memstats_alloc_bytes":       {"Bytes of allocated heap objects.", func(m *runtime.MemStats) float64 { return float64(m.Alloc) }},
+		"go_memstats_heap_inuse_bytes":  {"Bytes in in-use heap spans.", func(m *runtime.MemStats) float64 { return float64(m.HeapInuse) }},
+		"go_memstats_heap_idle_bytes":   {"Bytes in idle heap spans.", func(m *runtime.MemStats) float64 { return float64(m.HeapIdle) }},
+		"go_memstats_heap_objects":      {"Number of allocated heap objects.", func(m *runtime.MemStats) float64 { return float64(m.HeapObjects) }},
+		"go_memstats_stack_inuse_bytes": {"Bytes in stack spans.", func(m *runtime.MemStats) float64 { return float64(m.StackInuse) }},
+		"go_memstats_sys_bytes":         {"Bytes of memory obtained from the operating system.", func(m *runtime.MemStats) float64 { return float64(m.Sys) }},
+		"go_memstats_next_gc_bytes":     {"Heap size at which the next garbage collection will run.", func(m *runtime.MemStats) float64 { return float64(m.NextGC) }},
+		"go_memstats_last_gc_time_seconds": {"Time of the last garbage collection, in seconds since the epoch.", func(m *runtime.MemStats) float64 {
+			return float64(m.LastGC) / 1e9
+		}},
+	}
+	for name, g := range gauges {
+		NewGaugeFunc(name, g.help, func() float64 { return g.read(stats.get()) })
+	}
+
+	counters := map[string]struct {
+		help string
+		read func(*runtime.MemStats) float64
+	}{
+		"go_memstats_alloc_bytes_total": {"Bytes allocated for heap objects, even if freed.", func(m *runtime.MemStats) float64 { return float64(m.TotalAlloc) }},
+		"go_memstats_mallocs_total":     {"Heap objects allocated.", func(m *runtime.MemStats) float64 { return float64(m.Mallocs) }},
+		"go_memstats_frees_total":       {"Heap objects freed.", func(m *runtime.MemStats) float64 { return float64(m.Frees) }},
+		"go_gc_cycles_total":            {"Completed garbage collection cycles.", func(m *runtime.MemStats) float64 { return float64(m.NumGC) }},
+		"go_gc_pause_seconds_total":     {"Time the world was stopped for garbage collection.", func(m *runtime.MemStats) float64 { return float64(m.PauseTotalNs) / 1e9 }},
+	}
+	for name, c := range counters {
+		NewCounterFunc(name, c.help, func() float64 { return c.read(stats.get()) })
+	}
+
+	NewGaugeFunc("process_start_time_seconds", "Start time of the process, in seconds since the epoch.", func() float64 {
+		return float64(processStart.UnixNano()) / 1e9
+	})
+	publishProcessStats()
+}
+
+// memStats caches runtime.MemStats for memStatsMaxAge.
+type memStats struct {
+	mu     sync.Mutex
+	stats  runtime.MemStats
+	readAt time.Time
+}
+
+func (s *memStats) get() *runtime.MemStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Since(s.readAt) > memStatsMaxAge {
+		runtime.ReadMemStats(&s.stats)
+		s.readAt = time.Now()
+	}
+	stats := s.stats
+	return &stats
+}
@@ -0,0 +1,121 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+)
+
+type jwksDocument struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JSONWebKey is a public key in JWK form (RFC 7517).
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type keySet struct {
+	keys map[string]crypto.PublicKey
+	// only is set when the set holds a single key, which is then used for tokens without a kid.
+	only crypto.PublicKey
+}
+
+func (s *keySet) find(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && s.only != nil {
+		return s.only, true
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+func parseJWKS(doc jwksDocument) (*keySet, error) {
+	set := &keySet{keys: make(map[string]crypto.PublicKey)}
+	for _, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		set.keys[jwk.Kid] = key
+	}
+	if len(set.keys) == 0 {
+		return nil, fmt.Errorf("jwks contains no usable signing keys")
+	}
+	if len(set.keys) == 1 {
+		for _, key := range set.keys {
+			set.only = key
+		}
+	}
+	return set, nil
+}
+
+// PublicKey decodes the JWK into an *rsa.PublicKey or *ecdsa.PublicKey.
+func (k JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+// NewRSAJSONWebKey encodes an RSA public key as a JWK.
+func NewRSAJSONWebKey(kid string, key *rsa.PublicKey) JSONWebKey {
+	return JSONWebKey{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwk value: %v", err)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,321 @@
+// Package oidc implements the relying-party side of OpenID Connect: discovery,
+// the authorization code flow with PKCE and ID token verification.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type Config struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Discovery is the subset of the provider metadata document the client uses.
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Claims are the verified claims of an ID token.
+type Claims map[string]interface{}
+
+func (c Claims) String(name string) string {
+	v, _ := c[name].(string)
+	return v
+}
+
+func (c Claims) Bool(name string) bool {
+	switch v := c[name].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+// Strings returns a claim that may be a single string or a list of strings.
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// AuthRequest holds the per-login secrets that must survive the redirect to the provider.
+type AuthRequest struct {
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"codeVerifier"`
+}
+
+type Client struct {
+	config     Config
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	discovery *Discovery
+	keys      *keySet
+}
+
+func NewClient(config Config) *Client {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &Client{
+		config:     config,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// NewAuthRequest generates a fresh state, nonce and PKCE code verifier.
+func NewAuthRequest() (*AuthRequest, error) {
+	state, err := randomString(24)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomString(24)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	return &AuthRequest{State: state, Nonce: nonce, CodeVerifier: verifier}, nil
+}
+
+// AuthCodeURL returns the provider URL the browser is redirected to.
+func (c *Client) AuthCodeURL(ctx context.Context, req *AuthRequest) (string, error) {
+	d, err := c.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", c.config.ClientID)
+	params.Set("redirect_uri", c.config.RedirectURL)
+	params.Set("scope", strings.Join(c.config.Scopes, " "))
+	params.Set("state", req.State)
+	params.Set("nonce", req.Nonce)
+	params.Set("code_challenge", CodeChallenge(req.CodeVerifier))
+	params.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + params.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the verified ID token claims.
+func (c *Client) Exchange(ctx context.Context, code string, req *AuthRequest) (Claims, error) {
+	d, err := c.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.config.RedirectURL)
+	form.Set("client_id", c.config.ClientID)
+	form.Set("code_verifier", req.CodeVerifier)
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build token request: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Accept", "application/json")
+	if c.config.ClientSecret != "" {
+		httpReq.SetBasicAuth(url.QueryEscape(c.config.ClientID), url.QueryEscape(c.config.ClientSecret))
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call token endpoint: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var tokenResp struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %v", err)
+	}
+	if tokenResp.IDToken == "" {
+		return nil, fmt.Errorf("token response has no id_token")
+	}
+
+	return c.VerifyIDToken(ctx, tokenResp.IDToken, req.Nonce)
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of an ID token.
+func (c *Client) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (Claims, error) {
+	d, err := c.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, claims, c.keyFunc(ctx),
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384"}),
+		jwt.WithIssuer(d.Issuer),
+		jwt.WithAudience(c.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token: %v", err)
+	}
+
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, fmt.Errorf("invalid id token: nonce mismatch")
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, fmt.Errorf("invalid id token: missing subject")
+	}
+	return Claims(claims), nil
+}
+
+// Discover fetches and caches the provider metadata document.
+func (c *Client) Discover(ctx context.Context) (*Discovery, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.discovery != nil {
+		return c.discovery, nil
+	}
+
+	wellKnown := strings.TrimRight(c.config.IssuerURL, "/") + "/.well-known/openid-configuration"
+	var d Discovery
+	if err := c.getJSON(ctx, wellKnown, &d); err != nil {
+		return nil, fmt.Errorf("failed to discover provider: %v", err)
+	}
+	if strings.TrimRight(d.Issuer, "/") != strings.TrimRight(c.config.IssuerURL, "/") {
+		return nil, fmt.Errorf("provider issuer %q does not match configured issuer %q", d.Issuer, c.config.IssuerURL)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, fmt.Errorf("provider metadata is incomplete")
+	}
+
+	c.discovery = &d
+	return c.discovery, nil
+}
+
+// keyFunc returns the jwt.Keyfunc that looks up signing keys, fetching them
+// with ctx when needed.
+func (c *Client) keyFunc(ctx context.Context) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		c.mu.Lock()
+		keys := c.keys
+		c.mu.Unlock()
+		if keys != nil {
+			if key, ok := keys.find(kid); ok {
+				return key, nil
+			}
+		}
+
+		// Unknown key ID: the provider may have rotated keys, so refetch once.
+		keys, err := c.fetchKeys(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if key, ok := keys.find(kid); ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("no signing key found for kid %q", kid)
+	}
+}
+
+func (c *Client) fetchKeys(ctx context.Context) (*keySet, error) {
+	d, err := c.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc jwksDocument
+	if err := c.getJSON(ctx, d.JWKSURI, &doc); err != nil {
+		return nil, fmt.Errorf("failed to fetch jwks: %v", err)
+	}
+	keys, err := parseJWKS(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.mu.Unlock()
+	return keys, nil
+}
+
+func (c *Client) getJSON(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %d", u, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// CodeChallenge derives the S256 PKCE challenge for a code verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random value: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"certificate-ledger/domain"
+)
+
+const anchorColumns = `id, block_index, block_hash, tsa_url, serial_number, policy, gen_time, accuracy_micros, receipt, created_at`
+
+type SQLAnchorRepository struct {
+	db *sql.DB
+}
+
+func NewSQLAnchorRepository(db *sql.DB) *SQLAnchorRepository {
+	return &SQLAnchorRepository{db: db}
+}
+
+func (r *SQLAnchorRepository) Save(ctx context.Context, anchor *domain.Anchor) error {
+	query := `INSERT INTO anchors (` + anchorColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query,
+		anchor.ID,
+		anchor.BlockIndex,
+		anchor.BlockHash,
+		anchor.TSAURL,
+		anchor.SerialNumber,
+		anchor.Policy,
+		anchor.GenTime,
+		anchor.AccuracyMicros,
+		anchor.Receipt,
+		anchor.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save anchor: %v", err)
+	}
+	return nil
+}
+
+func (r *SQLAnchorRepository) FindByID(ctx context.Context, id string) (*domain.Anchor, error) {
+	query := `SELECT ` + anchorColumns + ` FROM anchors WHERE id = ?`
+	anchor, err := scanAnchor(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, domain.NotFound("anchor_not_found", "anchor with ID %s not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find anchor: %v", err)
+	}
+	return anchor, nil
+}
+
+func (r *SQLAnchorRepository) FindRecent(ctx context.Context, limit int) ([]*domain.Anchor, error) {
+	query := `SELECT ` + anchorColumns + ` FROM anchors ORDER BY created_at DESC, id DESC LIMIT ?`
+	return r.query(ctx, query, limit)
+}
+
+func (r *SQLAnchorRepository) FindCovering(ctx context.Context, blockIndex int, since time.Time, limit int) ([]*domain.Anchor, error) {
+	query := `SELECT ` + anchorColumns + ` FROM anchors WHERE block_index >= ? AND created_at >= ? ORDER BY created_at, id LIMIT ?`
+	return r.query(ctx, query, blockIndex, since, limit)
+}
+
+func (r *SQLAnchorRepository) query(ctx context.Context, query string, args ...interface{}) ([]*domain.Anchor, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query anchors: %v", err)
+	}
+	defer rows.Close()
+
+	anchors := []*domain.Anchor{}
+	for rows.Next() {
+		anchor, err := scanAnchor(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan anchor: %v", err)
+		}
+		anchors = append(anchors, anchor)
+	}
+	return anchors, rows.Err()
+}
+
+func scanAnchor(row rowScanner) (*domain.Anchor, error) {
+	var anchor domain.Anchor
+	err := row.Scan(
+		&anchor.ID,
+		&anchor.BlockIndex,
+		&anchor.BlockHash,
+		&anchor.TSAURL,
+		&anchor.SerialNumber,
+		&anchor.Policy,
+		&anchor.GenTime,
+		&anchor.AccuracyMicros,
+		&anchor.Receipt,
+		&anchor.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &anchor, nil
+}
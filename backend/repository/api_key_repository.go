@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"certificate-ledger/domain"
+)
+
+type SQLAPIKeyRepository struct {
+	db *sql.DB
+}
+
+func NewSQLAPIKeyRepository(db *sql.DB) *SQLAPIKeyRepository {
+	return &SQLAPIKeyRepository{db: db}
+}
+
+func (r *SQLAPIKeyRepository) Save(ctx context.Context, key *domain.APIKey) error {
+	query := `
+		INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, revoked_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query,
+		key.ID,
+		key.UserID,
+		key.Name,
+		key.Prefix,
+		key.KeyHash,
+		strings.Join(key.Scopes, ","),
+		key.ExpiresAt,
+		key.LastUsedAt,
+		key.RevokedAt,
+		key.CreatedAt,
+	)
+	if isDuplicateKey(err) {
+		return domain.Conflict("api_key_exists", "api key with prefix %s already exists", key.Prefix)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save api key: %v", err)
+	}
+	return nil
+}
+
+func (r *SQLAPIKeyRepository) FindByID(ctx context.Context, id string) (*domain.APIKey, error) {
+	query := `SELECT id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, revoked_at, created_at
+	          FROM api_keys WHERE id = ?`
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, domain.NotFound("api_key_not_found", "api key with ID %s not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find api key: %v", err)
+	}
+	return key, nil
+}
+
+func (r *SQLAPIKeyRepository) FindByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	query := `SELECT id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, revoked_at, created_at
+	          FROM api_keys WHERE prefix = ?`
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, prefix))
+	if err == sql.ErrNoRows {
+		return nil, domain.NotFound("api_key_not_found", "api key with prefix %s not found", prefix)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find api key: %v", err)
+	}
+	return key, nil
+}
+
+func (r *SQLAPIKeyRepository) FindByUserID(ctx context.Context, userID string) ([]*domain.APIKey, error) {
+	query := `SELECT id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, revoked_at, created_at
+	          FROM api_keys WHERE user_id = ? ORDER BY created_at DESC`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api keys: %v", err)
+	}
+	defer rows.Close()
+
+	var keys []*domain.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %v", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (r *SQLAPIKeyRepository) Revoke(ctx context.Context, id string, at time.Time) error {
+	query := `UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, at, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return domain.NotFound("api_key_not_found", "api key with ID %s not found or already revoked", id)
+	}
+	return nil
+}
+
+func (r *SQLAPIKeyRepository) RevokeForUser(ctx context.Context, userID string, at time.Time) error {
+	query := `UPDATE api_keys SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL`
+	if _, err := r.db.ExecContext(ctx, query, at, userID); err != nil {
+		return fmt.Errorf("failed to revoke api keys: %v", err)
+	}
+	return nil
+}
+
+func (r *SQLAPIKeyRepository) UpdateLastUsed(ctx context.Context, id string, at time.Time) error {
+	query := `UPDATE api_keys SET last_used_at = ? WHERE id = ?`
+	if _, err := r.db.ExecContext(ctx, query, at, id); err != nil {
+		return fmt.Errorf("failed to update api key last used: %v", err)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row rowScanner) (*domain.APIKey, error) {
+	var key domain.APIKey
+	var scopes string
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	if err := row.Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		&key.KeyHash,
+		&scopes,
+		&expiresAt,
+		&lastUsedAt,
+		&revokedAt,
+		&key.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	key.ExpiresAt = nullTimePtr(expiresAt)
+	key.LastUsedAt = nullTimePtr(lastUsedAt)
+	key.RevokedAt = nullTimePtr(revokedAt)
+	return &key, nil
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
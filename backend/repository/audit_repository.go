@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"certificate-ledger/domain"
+)
+
+const auditColumns = `seq, created_at, actor_id, action, target_type, target_id, ip, user_agent, before_state, after_state, previous_hash, hash`
+
+type SQLAuditRepository struct {
+	db *sql.DB
+}
+
+func NewSQLAuditRepository(db *sql.DB) *SQLAuditRepository {
+	return &SQLAuditRepository{db: db}
+}
+
+func (r *SQLAuditRepository) Append(ctx context.Context, entry *domain.AuditEntry) error {
+	query := `INSERT INTO audit_log (` + auditColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query,
+		entry.Seq,
+		entry.CreatedAt,
+		entry.ActorID,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetID,
+		entry.IP,
+		entry.UserAgent,
+		nullString(string(entry.Before)),
+		nullString(string(entry.After)),
+		entry.PreviousHash,
+		entry.Hash,
+	)
+	if isDuplicateKey(err) {
+		return domain.Conflict("audit_seq_taken", "audit entry %d already exists", entry.Seq)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to append audit entry: %v", err)
+	}
+	return nil
+}
+
+func (r *SQLAuditRepository) Last(ctx context.Context) (*domain.AuditEntry, error) {
+	query := `SELECT ` + auditColumns + ` FROM audit_log ORDER BY seq DESC LIMIT 1`
+	entry, err := scanAuditEntry(r.db.QueryRowContext(ctx, query))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find last audit entry: %v", err)
+	}
+	return entry, nil
+}
+
+func (r *SQLAuditRepository) FindPage(ctx context.Context, q domain.AuditQuery) (*domain.AuditPage, error) {
+	var where []string
+	var args []interface{}
+	for column, value := range map[string]string{
+		"actor_id":    q.Filter.ActorID,
+		"action":      q.Filter.Action,
+		"target_type": q.Filter.TargetType,
+		"target_id":   q.Filter.TargetID,
+	} {
+		if value != "" {
+			where = append(where, column+" = ?")
+			args = append(args, value)
+		}
+	}
+	if q.Filter.From != nil {
+		where = append(where, "created_at >= ?")
+		args = append(args, *q.Filter.From)
+	}
+	if q.Filter.To != nil {
+		where = append(where, "created_at < ?")
+		args = append(args, *q.Filter.To)
+	}
+	if q.BeforeSeq > 0 {
+		where = append(where, "seq < ?")
+		args = append(args, q.BeforeSeq)
+	}
+
+	query := `SELECT ` + auditColumns + ` FROM audit_log`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY seq DESC LIMIT ?"
+	rows, err := r.db.QueryContext(ctx, query, append(args, q.Limit+1)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %v", err)
+	}
+	defer rows.Close()
+
+	page := &domain.AuditPage{Entries: []*domain.AuditEntry{}}
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %v", err)
+		}
+		page.Entries = append(page.Entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return trimAuditPage(page, q.Limit), nil
+}
+
+func (r *SQLAuditRepository) Walk(ctx context.Context, fn func(*domain.AuditEntry) error) error {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+auditColumns+` FROM audit_log ORDER BY seq`)
+	if err != nil {
+		return fmt.Errorf("failed to query audit log: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan audit entry: %v", err)
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// trimAuditPage cuts a page fetched with one extra entry down to limit and
+// sets the cursor when there are more.
+func trimAuditPage(page *domain.AuditPage, limit int) *domain.AuditPage {
+	if len(page.Entries) > limit {
+		page.Entries = page.Entries[:limit]
+		page.NextCursor = strconv.FormatInt(page.Entries[limit-1].Seq, 10)
+	}
+	return page
+}
+
+// PageAuditEntries pages entries that are already filtered and ordered newest
+// first, the same way FindPage does, for backends that query in memory.
+func PageAuditEntries(entries []*domain.AuditEntry, limit int) *domain.AuditPage {
+	if entries == nil {
+		entries = []*domain.AuditEntry{}
+	}
+	if len(entries) > limit+1 {
+		entries = entries[:limit+1]
+	}
+	return trimAuditPage(&domain.AuditPage{Entries: entries}, limit)
+}
+
+func scanAuditEntry(row rowScanner) (*domain.AuditEntry, error) {
+	var e domain.AuditEntry
+	var before, after sql.NullString
+	err := row.Scan(
+		&e.Seq,
+		&e.CreatedAt,
+		&e.ActorID,
+		&e.Action,
+		&e.TargetType,
+		&e.TargetID,
+		&e.IP,
+		&e.UserAgent,
+		&before,
+		&after,
+		&e.PreviousHash,
+		&e.Hash,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if before.String != "" {
+		e.Before = json.RawMessage(before.String)
+	}
+	if after.String != "" {
+		e.After = json.RawMessage(after.String)
+	}
+	return &e, nil
+}
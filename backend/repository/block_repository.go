@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"certificate-ledger/blockchain"
+	"certificate-ledger/domain"
+)
+
+type SQLBlockRepository struct {
+	db *sql.DB
+}
+
+func NewSQLBlockRepository(db *sql.DB) *SQLBlockRepository {
+	return &SQLBlockRepository{db: db}
+}
+
+func (r *SQLBlockRepository) FindAll(ctx context.Context) ([]*blockchain.Block, error) {
+	query := `SELECT height, timestamp_ns, data, previous_hash, hash, nonce FROM blocks ORDER BY height`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocks: %v", err)
+	}
+	defer rows.Close()
+
+	var blocks []*blockchain.Block
+	for rows.Next() {
+		var block blockchain.Block
+		var timestamp int64
+		if err := rows.Scan(&block.Index, &timestamp, &block.Data, &block.PreviousHash, &block.Hash, &block.Nonce); err != nil {
+			return nil, fmt.Errorf("failed to scan block: %v", err)
+		}
+		block.Timestamp = time.Unix(0, timestamp).UTC()
+		blocks = append(blocks, &block)
+	}
+	return blocks, rows.Err()
+}
+
+func (r *SQLBlockRepository) Append(ctx context.Context, block *blockchain.Block) error {
+	query := `INSERT INTO blocks (height, timestamp_ns, data, previous_hash, hash, nonce) VALUES (?, ?, ?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query, block.Index, block.Timestamp.UnixNano(), block.Data, block.PreviousHash, block.Hash, block.Nonce)
+	if isDuplicateKey(err) {
+		return domain.Conflict("block_exists", "block %d is already stored", block.Index)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save block: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"certificate-ledger/domain"
+)
+
+const bulkIssueJobColumns = `id, issuer_id, file_name, status, total_rows, valid_rows, processed, succeeded, failed, error, created_at, started_at, finished_at`
+
+type SQLBulkIssueRepository struct {
+	db *sql.DB
+}
+
+func NewSQLBulkIssueRepository(db *sql.DB) *SQLBulkIssueRepository {
+	return &SQLBulkIssueRepository{db: db}
+}
+
+// SaveJob inserts a job together with all of its rows.
+func (r *SQLBulkIssueRepository) SaveJob(ctx context.Context, job *domain.BulkIssueJob, rows []*domain.BulkIssueRow) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO bulk_issue_jobs (` + bulkIssueJobColumns + `)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, query,
+		job.ID,
+		job.IssuerID,
+		job.FileName,
+		job.Status,
+		job.TotalRows,
+		job.ValidRows,
+		job.Processed,
+		job.Succeeded,
+		job.Failed,
+		job.Error,
+		job.CreatedAt,
+		job.StartedAt,
+		job.FinishedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save bulk issue job: %v", err)
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO bulk_issue_rows (job_id, line, request, status, errors, certificate_id, hash)
+	                         VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare bulk issue rows: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		request, err := json.Marshal(row.Request)
+		if err != nil {
+			return fmt.Errorf("failed to encode row %d: %v", row.Row, err)
+		}
+		if _, err := stmt.Exec(job.ID, row.Row, request, row.Status, strings.Join(row.Errors, "\n"), row.CertificateID, row.Hash); err != nil {
+			return fmt.Errorf("failed to save row %d: %v", row.Row, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit bulk issue job: %v", err)
+	}
+	return nil
+}
+
+// UpdateJob stores the job's status and counters.
+func (r *SQLBulkIssueRepository) UpdateJob(ctx context.Context, job *domain.BulkIssueJob) error {
+	query := `UPDATE bulk_issue_jobs
+	          SET status = ?, processed = ?, succeeded = ?, failed = ?, error = ?, started_at = ?, finished_at = ?
+	          WHERE id = ?`
+	_, err := r.db.ExecContext(ctx, query,
+		job.Status,
+		job.Processed,
+		job.Succeeded,
+		job.Failed,
+		job.Error,
+		job.StartedAt,
+		job.FinishedAt,
+		job.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update bulk issue job: %v", err)
+	}
+	return nil
+}
+
+// UpdateRow stores the outcome of issuing a row.
+func (r *SQLBulkIssueRepository) UpdateRow(ctx context.Context, row *domain.BulkIssueRow) error {
+	query := `UPDATE bulk_issue_rows SET status = ?, errors = ?, certificate_id = ?, hash = ? WHERE job_id = ? AND line = ?`
+	_, err := r.db.ExecContext(ctx, query, row.Status, strings.Join(row.Errors, "\n"), row.CertificateID, row.Hash, row.JobID, row.Row)
+	if err != nil {
+		return fmt.Errorf("failed to update row %d: %v", row.Row, err)
+	}
+	return nil
+}
+
+func (r *SQLBulkIssueRepository) FindJob(ctx context.Context, id string) (*domain.BulkIssueJob, error) {
+	query := `SELECT ` + bulkIssueJobColumns + ` FROM bulk_issue_jobs WHERE id = ?`
+	job, err := scanBulkIssueJob(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, domain.NotFound("bulk_issue_job_not_found", "bulk issue job %s not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find bulk issue job: %v", err)
+	}
+	return job, nil
+}
+
+func (r *SQLBulkIssueRepository) FindJobsByIssuer(ctx context.Context, issuerID string) ([]*domain.BulkIssueJob, error) {
+	query := `SELECT ` + bulkIssueJobColumns + ` FROM bulk_issue_jobs WHERE issuer_id = ? ORDER BY created_at DESC`
+	return r.queryJobs(ctx, query, issuerID)
+}
+
+// FindUnfinishedJobs returns jobs that were pending or running, e.g. when the server stopped.
+func (r *SQLBulkIssueRepository) FindUnfinishedJobs(ctx context.Context) ([]*domain.BulkIssueJob, error) {
+	query := `SELECT ` + bulkIssueJobColumns + ` FROM bulk_issue_jobs WHERE status IN (?, ?) ORDER BY created_at`
+	return r.queryJobs(ctx, query, domain.BulkJobPending, domain.BulkJobRunning)
+}
+
+// FindRows returns the job's rows in file order. With status set, only rows in that status are returned.
+func (r *SQLBulkIssueRepository) FindRows(ctx context.Context, jobID, status string) ([]*domain.BulkIssueRow, error) {
+	query := `SELECT job_id, line, request, status, errors, certificate_id, hash
+	          FROM bulk_issue_rows WHERE job_id = ? AND (? = '' OR status = ?) ORDER BY line`
+	rows, err := r.db.QueryContext(ctx, query, jobID, status, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bulk issue rows: %v", err)
+	}
+	defer rows.Close()
+
+	var result []*domain.BulkIssueRow
+	for rows.Next() {
+		var row domain.BulkIssueRow
+		var request []byte
+		var errs string
+		if err := rows.Scan(&row.JobID, &row.Row, &request, &row.Status, &errs, &row.CertificateID, &row.Hash); err != nil {
+			return nil, fmt.Errorf("failed to scan bulk issue row: %v", err)
+		}
+		if err := json.Unmarshal(request, &row.Request); err != nil {
+			return nil, fmt.Errorf("failed to decode row %d: %v", row.Row, err)
+		}
+		if errs != "" {
+			row.Errors = strings.Split(errs, "\n")
+		}
+		result = append(result, &row)
+	}
+	return result, nil
+}
+
+func (r *SQLBulkIssueRepository) ClaimJob(ctx context.Context, id, owner string, now, leaseUntil time.Time) (bool, error) {
+	query := `UPDATE bulk_issue_jobs SET lease_owner = ?, lease_until = ?
+	          WHERE id = ? AND status IN (?, ?) AND (lease_owner = ? OR lease_until IS NULL OR lease_until <= ?)`
+	result, err := r.db.ExecContext(ctx, query, owner, leaseUntil, id, domain.BulkJobPending, domain.BulkJobRunning, owner, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim bulk issue job: %v", err)
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (r *SQLBulkIssueRepository) queryJobs(ctx context.Context, query string, args ...interface{}) ([]*domain.BulkIssueJob, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bulk issue jobs: %v", err)
+	}
+	defer rows.Close()
+
+	var jobs []*domain.BulkIssueJob
+	for rows.Next() {
+		job, err := scanBulkIssueJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan bulk issue job: %v", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+func scanBulkIssueJob(row rowScanner) (*domain.BulkIssueJob, error) {
+	var job domain.BulkIssueJob
+	var startedAt, finishedAt sql.NullTime
+	if err := row.Scan(
+		&job.ID,
+		&job.IssuerID,
+		&job.FileName,
+		&job.Status,
+		&job.TotalRows,
+		&job.ValidRows,
+		&job.Processed,
+		&job.Succeeded,
+		&job.Failed,
+		&job.Error,
+		&job.CreatedAt,
+		&startedAt,
+		&finishedAt,
+	); err != nil {
+		return nil, err
+	}
+	job.StartedAt = nullTimePtr(startedAt)
+	job.FinishedAt = nullTimePtr(finishedAt)
+	return &job, nil
+}
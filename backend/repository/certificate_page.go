@@ -0,0 +1,287 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"certificate-ledger/domain"
+)
+
+// certificateSortColumns maps sort fields to columns. Every listing is
+// ordered by the sort column and then id, which makes the order total and
+// lets a cursor resume exactly after the last row of a page.
+var certificateSortColumns = map[string]string{
+	domain.CertificateSortIssueDate:     "issue_date",
+	domain.CertificateSortTimestamp:     "timestamp",
+	domain.CertificateSortTitle:         "certificate_title",
+	domain.CertificateSortRecipientName: "recipient_name",
+	domain.CertificateSortBlockNumber:   "block_number",
+}
+
+// certificateCursor is the decoded form of CertificatePage.NextCursor.
+type certificateCursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// FindPage returns one page of certificates matching the query, plus the
+// number of matching certificates across all pages. q must be normalized:
+// a known sort field, an order and a positive limit.
+func (r *SQLCertificateRepository) FindPage(ctx context.Context, q domain.CertificateQuery) (*domain.CertificatePage, error) {
+	column, ok := certificateSortColumns[q.Sort]
+	if !ok {
+		return nil, domain.Invalid("invalid_sort", "unknown sort field %s", q.Sort)
+	}
+
+	where, args := certificateFilterClause(q.Filter)
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM certificates` + whereSQL(where)
+	if err := r.db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("failed to count certificates: %v", err)
+	}
+
+	direction, comparison := "ASC", ">"
+	if q.Order == domain.SortDescending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if q.Cursor != "" {
+		cursor, err := decodeCertificateCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Sort != q.Sort || cursor.Order != q.Order {
+			return nil, domain.Invalid("invalid_cursor", "cursor does not match the requested sort order")
+		}
+		value, err := cursorValue(q.Sort, cursor.Value)
+		if err != nil {
+			return nil, err
+		}
+		where = append(where, fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", column, comparison, column, comparison))
+		args = append(args, value, value, cursor.ID)
+	}
+
+	query := `SELECT ` + certificateColumns + `
+	          FROM certificates` + whereSQL(where) +
+		fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT ?", column, direction, direction)
+	certs, err := r.query(ctx, query, append(args, q.Limit+1)...)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &domain.CertificatePage{Items: certs, Total: total, Limit: q.Limit}
+	if len(certs) > q.Limit {
+		page.Items = certs[:q.Limit]
+		last := page.Items[len(page.Items)-1]
+		page.NextCursor = encodeCertificateCursor(certificateCursor{
+			Sort:  q.Sort,
+			Order: q.Order,
+			Value: sortValue(last, q.Sort),
+			ID:    last.ID,
+		})
+	}
+	if page.Items == nil {
+		page.Items = []*domain.Certificate{}
+	}
+	return page, nil
+}
+
+// PageCertificates applies a normalized query to certificates held in memory.
+// Ordering, totals and cursors match FindPage, so cursors are interchangeable
+// between backends.
+func PageCertificates(certs []*domain.Certificate, q domain.CertificateQuery) (*domain.CertificatePage, error) {
+	if _, ok := certificateSortColumns[q.Sort]; !ok {
+		return nil, domain.Invalid("invalid_sort", "unknown sort field %s", q.Sort)
+	}
+
+	var matched []*domain.Certificate
+	for _, cert := range certs {
+		if q.Filter.Matches(cert) {
+			matched = append(matched, cert)
+		}
+	}
+
+	// compare orders a certificate against a sort key and id in the requested direction.
+	compare := func(cert *domain.Certificate, key interface{}, id string) int {
+		c := compareSortKeys(sortKey(cert, q.Sort), key)
+		if c == 0 {
+			c = strings.Compare(cert.ID, id)
+		}
+		if q.Order == domain.SortDescending {
+			c = -c
+		}
+		return c
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return compare(matched[i], sortKey(matched[j], q.Sort), matched[j].ID) < 0
+	})
+
+	page := &domain.CertificatePage{Total: len(matched), Limit: q.Limit}
+	if q.Cursor != "" {
+		cursor, err := decodeCertificateCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Sort != q.Sort || cursor.Order != q.Order {
+			return nil, domain.Invalid("invalid_cursor", "cursor does not match the requested sort order")
+		}
+		value, err := cursorValue(q.Sort, cursor.Value)
+		if err != nil {
+			return nil, err
+		}
+		start := sort.Search(len(matched), func(i int) bool {
+			return compare(matched[i], value, cursor.ID) > 0
+		})
+		matched = matched[start:]
+	}
+
+	page.Items = matched
+	if len(matched) > q.Limit {
+		page.Items = matched[:q.Limit]
+		last := page.Items[len(page.Items)-1]
+		page.NextCursor = encodeCertificateCursor(certificateCursor{
+			Sort:  q.Sort,
+			Order: q.Order,
+			Value: sortValue(last, q.Sort),
+			ID:    last.ID,
+		})
+	}
+	if page.Items == nil {
+		page.Items = []*domain.Certificate{}
+	}
+	return page, nil
+}
+
+func certificateFilterClause(f domain.CertificateFilter) ([]string, []interface{}) {
+	var where []string
+	var args []interface{}
+	if f.IssuerID != "" {
+		where = append(where, "issuer_id = ?")
+		args = append(args, f.IssuerID)
+	}
+	if f.RecipientEmail != "" {
+		where = append(where, "recipient_email = ?")
+		args = append(args, f.RecipientEmail)
+	}
+	if f.Title != "" {
+		where = append(where, "certificate_title LIKE ? ESCAPE '!'")
+		args = append(args, "%"+escapeLike(f.Title)+"%")
+	}
+	if f.IssuedFrom != nil {
+		where = append(where, "issue_date >= ?")
+		args = append(args, *f.IssuedFrom)
+	}
+	if f.IssuedTo != nil {
+		where = append(where, "issue_date < ?")
+		args = append(args, f.IssuedTo.AddDate(0, 0, 1))
+	}
+	if f.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, f.Status)
+	}
+	if f.WalletUserID != "" {
+		where = append(where, "(recipient_user_id = ? OR (recipient_user_id IS NULL AND ? <> '' AND recipient_email = ?))")
+		args = append(args, f.WalletUserID, f.WalletEmail, f.WalletEmail)
+	}
+	return where, args
+}
+
+func whereSQL(where []string) string {
+	if len(where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(where, " AND ")
+}
+
+// escapeLike escapes LIKE wildcards with '!'. MySQL and SQLite disagree on the
+// default escape character, so queries name it with ESCAPE '!'.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`).Replace(s)
+}
+
+func sortValue(cert *domain.Certificate, sort string) string {
+	switch sort {
+	case domain.CertificateSortIssueDate:
+		return cert.IssueDate.UTC().Format(time.RFC3339Nano)
+	case domain.CertificateSortTimestamp:
+		return cert.Timestamp.UTC().Format(time.RFC3339Nano)
+	case domain.CertificateSortTitle:
+		return cert.CertificateTitle
+	case domain.CertificateSortRecipientName:
+		return cert.RecipientName
+	case domain.CertificateSortBlockNumber:
+		return strconv.Itoa(cert.BlockNumber)
+	}
+	return ""
+}
+
+// sortKey returns the value a certificate is sorted by, in the same type
+// cursorValue produces.
+func sortKey(cert *domain.Certificate, sort string) interface{} {
+	switch sort {
+	case domain.CertificateSortIssueDate:
+		return cert.IssueDate
+	case domain.CertificateSortTimestamp:
+		return cert.Timestamp
+	case domain.CertificateSortBlockNumber:
+		return cert.BlockNumber
+	}
+	return sortValue(cert, sort)
+}
+
+func compareSortKeys(a, b interface{}) int {
+	switch a := a.(type) {
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case int:
+		return cmp.Compare(a, b.(int))
+	case string:
+		return strings.Compare(a, b.(string))
+	}
+	return 0
+}
+
+func cursorValue(sort, value string) (interface{}, error) {
+	switch sort {
+	case domain.CertificateSortIssueDate, domain.CertificateSortTimestamp:
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, domain.Invalid("invalid_cursor", "invalid cursor")
+		}
+		return t, nil
+	case domain.CertificateSortBlockNumber:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, domain.Invalid("invalid_cursor", "invalid cursor")
+		}
+		return n, nil
+	}
+	return value, nil
+}
+
+func encodeCertificateCursor(c certificateCursor) string {
+	payload, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+func decodeCertificateCursor(s string) (*certificateCursor, error) {
+	payload, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, domain.Invalid("invalid_cursor", "invalid cursor")
+	}
+	var c certificateCursor
+	if err := json.Unmarshal(payload, &c); err != nil || c.ID == "" {
+		return nil, domain.Invalid("invalid_cursor", "invalid cursor")
+	}
+	return &c, nil
+}
@@ -1,25 +1,38 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	"certificate-ledger/domain"
 )
 
-type CertificateRepository struct {
+const certificateColumns = `id, hash, recipient_name, recipient_email, certificate_title, issue_date, issuer_id, issuer_name, description, block_number, timestamp,
+	          recipient_user_id, claim_code_hash, claimed_at, status, renewed_from, revoked_at, revocation_reason, recipient_locale`
+
+type SQLCertificateRepository struct {
 	db *sql.DB
 }
 
-func NewCertificateRepository(db *sql.DB) *CertificateRepository {
-	return &CertificateRepository{db: db}
+func NewSQLCertificateRepository(db *sql.DB) *SQLCertificateRepository {
+	return &SQLCertificateRepository{db: db}
 }
 
-func (r *CertificateRepository) Save(cert *domain.Certificate) error {
+// Save inserts the certificate together with its disclosure salts.
+func (r *SQLCertificateRepository) Save(ctx context.Context, cert *domain.Certificate) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
 	query := `
-		INSERT INTO certificates (id, hash, recipient_name, recipient_email, certificate_title, issue_date, issuer_id, issuer_name, description, block_number, timestamp)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	_, err := r.db.Exec(query,
+		INSERT INTO certificates (id, hash, recipient_name, recipient_email, certificate_title, issue_date, issuer_id, issuer_name, description, block_number, timestamp,
+			recipient_user_id, claim_code_hash, claimed_at, status, renewed_from, revoked_at, revocation_reason, recipient_locale)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, query,
 		cert.ID,
 		cert.Hash,
 		cert.RecipientName,
@@ -31,137 +44,156 @@ func (r *CertificateRepository) Save(cert *domain.Certificate) error {
 		cert.Description,
 		cert.BlockNumber,
 		cert.Timestamp,
+		nullString(cert.RecipientUserID),
+		cert.ClaimCodeHash,
+		cert.ClaimedAt,
+		cert.Status,
+		nullString(cert.RenewedFrom),
+		cert.RevokedAt,
+		cert.RevocationReason,
+		cert.RecipientLocale,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to save certificate: %v", err)
 	}
+
+	for field, salt := range cert.FieldSalts {
+		query := `INSERT INTO certificate_field_salts (certificate_id, field, salt) VALUES (?, ?, ?)`
+		if _, err := tx.ExecContext(ctx, query, cert.ID, field, salt); err != nil {
+			return fmt.Errorf("failed to save certificate field salt: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit certificate: %v", err)
+	}
 	return nil
 }
 
-func (r *CertificateRepository) FindByID(id string) (*domain.Certificate, error) {
-	query := `SELECT id, hash, recipient_name, recipient_email, certificate_title, issue_date, issuer_id, issuer_name, description, block_number, timestamp
-	          FROM certificates WHERE id = ?`
-	row := r.db.QueryRow(query, id)
+// FindFieldSalts returns the disclosure salts of a certificate keyed by field name.
+func (r *SQLCertificateRepository) FindFieldSalts(ctx context.Context, id string) (map[string]string, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT field, salt FROM certificate_field_salts WHERE certificate_id = ?`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query certificate field salts: %v", err)
+	}
+	defer rows.Close()
 
-	var cert domain.Certificate
-	err := row.Scan(
-		&cert.ID,
-		&cert.Hash,
-		&cert.RecipientName,
-		&cert.RecipientEmail,
-		&cert.CertificateTitle,
-		&cert.IssueDate,
-		&cert.IssuerID,
-		&cert.IssuerName,
-		&cert.Description,
-		&cert.BlockNumber,
-		&cert.Timestamp,
-	)
+	salts := make(map[string]string)
+	for rows.Next() {
+		var field, salt string
+		if err := rows.Scan(&field, &salt); err != nil {
+			return nil, fmt.Errorf("failed to scan certificate field salt: %v", err)
+		}
+		salts[field] = salt
+	}
+	return salts, nil
+}
+
+func (r *SQLCertificateRepository) FindByID(ctx context.Context, id string) (*domain.Certificate, error) {
+	query := `SELECT ` + certificateColumns + `
+	          FROM certificates WHERE id = ?`
+	cert, err := scanCertificate(r.db.QueryRowContext(ctx, query, id))
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("certificate with ID %s not found", id)
+		return nil, domain.NotFound("certificate_not_found", "certificate with ID %s not found", id)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to find certificate: %v", err)
 	}
-	return &cert, nil
+	return cert, nil
 }
 
-func (r *CertificateRepository) FindByHash(hash string) (*domain.Certificate, error) {
-	query := `SELECT id, hash, recipient_name, recipient_email, certificate_title, issue_date, issuer_id, issuer_name, description, block_number, timestamp
+func (r *SQLCertificateRepository) FindByHash(ctx context.Context, hash string) (*domain.Certificate, error) {
+	query := `SELECT ` + certificateColumns + `
 	          FROM certificates WHERE hash = ?`
-	row := r.db.QueryRow(query, hash)
-
-	var cert domain.Certificate
-	err := row.Scan(
-		&cert.ID,
-		&cert.Hash,
-		&cert.RecipientName,
-		&cert.RecipientEmail,
-		&cert.CertificateTitle,
-		&cert.IssueDate,
-		&cert.IssuerID,
-		&cert.IssuerName,
-		&cert.Description,
-		&cert.BlockNumber,
-		&cert.Timestamp,
-	)
+	cert, err := scanCertificate(r.db.QueryRowContext(ctx, query, hash))
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("certificate with hash %s not found", hash)
+		return nil, domain.NotFound("certificate_not_found", "certificate with hash %s not found", hash)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to find certificate: %v", err)
 	}
-	return &cert, nil
+	return cert, nil
 }
 
-func (r *CertificateRepository) FindAll() ([]*domain.Certificate, error) {
-	query := `SELECT id, hash, recipient_name, recipient_email, certificate_title, issue_date, issuer_id, issuer_name, description, block_number, timestamp
+func (r *SQLCertificateRepository) FindAll(ctx context.Context) ([]*domain.Certificate, error) {
+	query := `SELECT ` + certificateColumns + `
 	          FROM certificates`
-	rows, err := r.db.Query(query)
+	return r.query(ctx, query)
+}
+
+func (r *SQLCertificateRepository) FindByIssuerID(ctx context.Context, userID string) ([]*domain.Certificate, error) {
+	query := `SELECT ` + certificateColumns + `
+	          FROM certificates WHERE issuer_id = ?`
+	return r.query(ctx, query, userID)
+}
+
+func (r *SQLCertificateRepository) FindByRecipientEmail(ctx context.Context, email string) ([]*domain.Certificate, error) {
+	query := `SELECT ` + certificateColumns + `
+	          FROM certificates WHERE recipient_email = ?`
+	return r.query(ctx, query, email)
+}
+
+// FindByRecipient returns the certificates claimed by the user plus, when
+// email is not empty, unclaimed certificates issued to that email address.
+func (r *SQLCertificateRepository) FindByRecipient(ctx context.Context, userID, email string) ([]*domain.Certificate, error) {
+	query := `SELECT ` + certificateColumns + `
+	          FROM certificates
+	          WHERE recipient_user_id = ? OR (recipient_user_id IS NULL AND ? <> '' AND recipient_email = ?)
+	          ORDER BY issue_date DESC`
+	return r.query(ctx, query, userID, email, email)
+}
+
+// Claim links an unclaimed certificate to a user. It reports false if the
+// certificate was already claimed.
+func (r *SQLCertificateRepository) Claim(ctx context.Context, id, userID string, at time.Time) (bool, error) {
+	query := `UPDATE certificates SET recipient_user_id = ?, claimed_at = ? WHERE id = ? AND recipient_user_id IS NULL`
+	result, err := r.db.ExecContext(ctx, query, userID, at, id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query certificates: %v", err)
+		return false, fmt.Errorf("failed to claim certificate: %v", err)
 	}
-	defer rows.Close()
 
-	var certs []*domain.Certificate
-	for rows.Next() {
-		var cert domain.Certificate
-		if err := rows.Scan(
-			&cert.ID,
-			&cert.Hash,
-			&cert.RecipientName,
-			&cert.RecipientEmail,
-			&cert.CertificateTitle,
-			&cert.IssueDate,
-			&cert.IssuerID,
-			&cert.IssuerName,
-			&cert.Description,
-			&cert.BlockNumber,
-			&cert.Timestamp,
-		); err != nil {
-			return nil, fmt.Errorf("failed to scan certificate: %v", err)
-		}
-		certs = append(certs, &cert)
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check rows affected: %v", err)
 	}
-	return certs, nil
+	return rowsAffected == 1, nil
 }
 
-func (r *CertificateRepository) FindByIssuerID(userID string) ([]*domain.Certificate, error) {
-	query := `SELECT id, hash, recipient_name, recipient_email, certificate_title, issue_date, issuer_id, issuer_name, description, block_number, timestamp
-	          FROM certificates WHERE issuer_id = ?`
-	rows, err := r.db.Query(query, userID)
+// ClaimByEmail links every unclaimed certificate issued to email to the user.
+func (r *SQLCertificateRepository) ClaimByEmail(ctx context.Context, userID, email string, at time.Time) (int64, error) {
+	query := `UPDATE certificates SET recipient_user_id = ?, claimed_at = ? WHERE recipient_email = ? AND recipient_user_id IS NULL`
+	result, err := r.db.ExecContext(ctx, query, userID, at, email)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query certificates: %v", err)
+		return 0, fmt.Errorf("failed to claim certificates: %v", err)
 	}
-	defer rows.Close()
+	return result.RowsAffected()
+}
 
-	var certs []*domain.Certificate
-	for rows.Next() {
-		var cert domain.Certificate
-		if err := rows.Scan(
-			&cert.ID,
-			&cert.Hash,
-			&cert.RecipientName,
-			&cert.RecipientEmail,
-			&cert.CertificateTitle,
-			&cert.IssueDate,
-			&cert.IssuerID,
-			&cert.IssuerName,
-			&cert.Description,
-			&cert.BlockNumber,
-			&cert.Timestamp,
-		); err != nil {
-			return nil, fmt.Errorf("failed to scan certificate: %v", err)
-		}
-		certs = append(certs, &cert)
+// Revoke marks a certificate revoked unless it already is, and reports
+// whether it did.
+func (r *SQLCertificateRepository) Revoke(ctx context.Context, id, reason string, at time.Time) (bool, error) {
+	query := `UPDATE certificates SET status = ?, revoked_at = ?, revocation_reason = ? WHERE id = ? AND status <> ?`
+	result, err := r.db.ExecContext(ctx, query, domain.CertificateStatusRevoked, at, reason, id, domain.CertificateStatusRevoked)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke certificate: %v", err)
 	}
-	return certs, nil
+	n, err := result.RowsAffected()
+	return n > 0, err
 }
 
-func (r *CertificateRepository) FindByRecipientEmail(email string) ([]*domain.Certificate, error) {
-	query := `SELECT id, hash, recipient_name, recipient_email, certificate_title, issue_date, issuer_id, issuer_name, description, block_number, timestamp
-	          FROM certificates WHERE recipient_email = ?`
-	rows, err := r.db.Query(query, email)
+// UpdateStatus moves a certificate from one status to another and reports
+// whether it was in the from status.
+func (r *SQLCertificateRepository) UpdateStatus(ctx context.Context, id, from, to string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `UPDATE certificates SET status = ? WHERE id = ? AND status = ?`, to, id, from)
+	if err != nil {
+		return false, fmt.Errorf("failed to update certificate status: %v", err)
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (r *SQLCertificateRepository) query(ctx context.Context, query string, args ...interface{}) ([]*domain.Certificate, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query certificates: %v", err)
 	}
@@ -169,23 +201,49 @@ func (r *CertificateRepository) FindByRecipientEmail(email string) ([]*domain.Ce
 
 	var certs []*domain.Certificate
 	for rows.Next() {
-		var cert domain.Certificate
-		if err := rows.Scan(
-			&cert.ID,
-			&cert.Hash,
-			&cert.RecipientName,
-			&cert.RecipientEmail,
-			&cert.CertificateTitle,
-			&cert.IssueDate,
-			&cert.IssuerID,
-			&cert.IssuerName,
-			&cert.Description,
-			&cert.BlockNumber,
-			&cert.Timestamp,
-		); err != nil {
+		cert, err := scanCertificate(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan certificate: %v", err)
 		}
-		certs = append(certs, &cert)
+		certs = append(certs, cert)
 	}
 	return certs, nil
-}
\ No newline at end of file
+}
+
+func scanCertificate(row rowScanner) (*domain.Certificate, error) {
+	var cert domain.Certificate
+	var recipientUserID, renewedFrom sql.NullString
+	var claimedAt, revokedAt sql.NullTime
+	if err := row.Scan(
+		&cert.ID,
+		&cert.Hash,
+		&cert.RecipientName,
+		&cert.RecipientEmail,
+		&cert.CertificateTitle,
+		&cert.IssueDate,
+		&cert.IssuerID,
+		&cert.IssuerName,
+		&cert.Description,
+		&cert.BlockNumber,
+		&cert.Timestamp,
+		&recipientUserID,
+		&cert.ClaimCodeHash,
+		&claimedAt,
+		&cert.Status,
+		&renewedFrom,
+		&revokedAt,
+		&cert.RevocationReason,
+		&cert.RecipientLocale,
+	); err != nil {
+		return nil, err
+	}
+	cert.RecipientUserID = recipientUserID.String
+	cert.ClaimedAt = nullTimePtr(claimedAt)
+	cert.RenewedFrom = renewedFrom.String
+	cert.RevokedAt = nullTimePtr(revokedAt)
+	return &cert, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"certificate-ledger/domain"
+)
+
+const certificateFullText = `MATCH (recipient_name, certificate_title, issuer_name, description) AGAINST (? IN BOOLEAN MODE)`
+
+// Search ranks certificates against the FULLTEXT index. Each term matches as
+// a word prefix; a certificate needs to match at least one term. Terms must
+// only contain letters and digits, as produced by search.Terms.
+func (r *SQLCertificateRepository) Search(ctx context.Context, terms []string, filter domain.CertificateFilter, limit int) ([]*domain.CertificateSearchHit, error) {
+	against := make([]string, len(terms))
+	for i, t := range terms {
+		against[i] = t + "*"
+	}
+	expr := strings.Join(against, " ")
+
+	where, args := certificateFilterClause(filter)
+	where = append([]string{certificateFullText}, where...)
+	args = append([]interface{}{expr, expr}, args...)
+
+	query := `SELECT ` + certificateColumns + `, ` + certificateFullText + ` AS score
+	          FROM certificates` + whereSQL(where) + `
+	          ORDER BY score DESC, id LIMIT ?`
+	rows, err := r.db.QueryContext(ctx, query, append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search certificates: %v", err)
+	}
+	defer rows.Close()
+
+	var hits []*domain.CertificateSearchHit
+	for rows.Next() {
+		hit := &domain.CertificateSearchHit{}
+		cert, err := scanCertificate(scoredRow{rows: rows, score: &hit.Score})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan certificate: %v", err)
+		}
+		hit.Certificate = cert
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// scoredRow scans the trailing score column after the certificate columns.
+type scoredRow struct {
+	rows  *sql.Rows
+	score *float64
+}
+
+func (s scoredRow) Scan(dest ...interface{}) error {
+	return s.rows.Scan(append(dest, s.score)...)
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"certificate-ledger/domain"
+)
+
+const checkpointColumns = `id, origin, genesis_hash, height, head_hash, created_at, signatures`
+
+type SQLCheckpointRepository struct {
+	db *sql.DB
+}
+
+func NewSQLCheckpointRepository(db *sql.DB) *SQLCheckpointRepository {
+	return &SQLCheckpointRepository{db: db}
+}
+
+func (r *SQLCheckpointRepository) Save(ctx context.Context, checkpoint *domain.Checkpoint) error {
+	signatures, err := json.Marshal(checkpoint.Signatures)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint signatures: %v", err)
+	}
+	query := `INSERT INTO checkpoints (` + checkpointColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err = r.db.ExecContext(ctx, query,
+		checkpoint.ID,
+		checkpoint.Origin,
+		checkpoint.GenesisHash,
+		checkpoint.Height,
+		checkpoint.HeadHash,
+		checkpoint.CreatedAt,
+		string(signatures),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint: %v", err)
+	}
+	return nil
+}
+
+func (r *SQLCheckpointRepository) FindByID(ctx context.Context, id string) (*domain.Checkpoint, error) {
+	query := `SELECT ` + checkpointColumns + ` FROM checkpoints WHERE id = ?`
+	checkpoint, err := scanCheckpoint(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, domain.NotFound("checkpoint_not_found", "checkpoint with ID %s not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find checkpoint: %v", err)
+	}
+	return checkpoint, nil
+}
+
+func (r *SQLCheckpointRepository) FindRecent(ctx context.Context, limit int) ([]*domain.Checkpoint, error) {
+	query := `SELECT ` + checkpointColumns + ` FROM checkpoints ORDER BY created_at DESC, id DESC LIMIT ?`
+	return r.query(ctx, query, limit)
+}
+
+func (r *SQLCheckpointRepository) FindByOrigin(ctx context.Context, origin string) ([]*domain.Checkpoint, error) {
+	query := `SELECT ` + checkpointColumns + ` FROM checkpoints WHERE origin = ? ORDER BY height, created_at`
+	return r.query(ctx, query, origin)
+}
+
+func (r *SQLCheckpointRepository) query(ctx context.Context, query string, args ...interface{}) ([]*domain.Checkpoint, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query checkpoints: %v", err)
+	}
+	defer rows.Close()
+
+	checkpoints := []*domain.Checkpoint{}
+	for rows.Next() {
+		checkpoint, err := scanCheckpoint(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan checkpoint: %v", err)
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	return checkpoints, rows.Err()
+}
+
+func scanCheckpoint(row rowScanner) (*domain.Checkpoint, error) {
+	var checkpoint domain.Checkpoint
+	var signatures string
+	err := row.Scan(
+		&checkpoint.ID,
+		&checkpoint.Origin,
+		&checkpoint.GenesisHash,
+		&checkpoint.Height,
+		&checkpoint.HeadHash,
+		&checkpoint.CreatedAt,
+		&signatures,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(signatures), &checkpoint.Signatures); err != nil {
+		return nil, fmt.Errorf("invalid signatures of checkpoint %s: %v", checkpoint.ID, err)
+	}
+	return &checkpoint, nil
+}
+
+type SQLWitnessedChainRepository struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+func NewSQLWitnessedChainRepository(db *sql.DB, dialect Dialect) *SQLWitnessedChainRepository {
+	return &SQLWitnessedChainRepository{db: db, dialect: dialect}
+}
+
+func (r *SQLWitnessedChainRepository) Find(ctx context.Context, origin string) (*domain.WitnessedChain, error) {
+	query := `SELECT genesis_hash, origin, height, head_hash, signed_at FROM witnessed_chains WHERE origin = ?`
+	var chain domain.WitnessedChain
+	err := r.db.QueryRowContext(ctx, query, origin).Scan(
+		&chain.GenesisHash,
+		&chain.Origin,
+		&chain.Height,
+		&chain.HeadHash,
+		&chain.SignedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find witnessed chain: %v", err)
+	}
+	return &chain, nil
+}
+
+func (r *SQLWitnessedChainRepository) Save(ctx context.Context, chain *domain.WitnessedChain) error {
+	query := `
+		INSERT INTO witnessed_chains (origin, genesis_hash, height, head_hash, signed_at)
+		VALUES (?, ?, ?, ?, ?)
+		` + r.dialect.upsert("origin") + `
+			genesis_hash = ?, height = ?, head_hash = ?, signed_at = ?`
+	_, err := r.db.ExecContext(ctx, query,
+		chain.Origin, chain.GenesisHash, chain.Height, chain.HeadHash, chain.SignedAt,
+		chain.GenesisHash, chain.Height, chain.HeadHash, chain.SignedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save witnessed chain: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"certificate-ledger/domain"
+)
+
+type SQLIdentityRepository struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+func NewSQLIdentityRepository(db *sql.DB, dialect Dialect) *SQLIdentityRepository {
+	return &SQLIdentityRepository{db: db, dialect: dialect}
+}
+
+func (r *SQLIdentityRepository) Save(ctx context.Context, identity *domain.UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (issuer, subject, user_id, email, created_at, last_login_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		` + r.dialect.upsert("issuer, subject") + `
+			user_id = ?, email = ?, last_login_at = ?`
+	_, err := r.db.ExecContext(ctx, query,
+		identity.Issuer, identity.Subject, identity.UserID, identity.Email, identity.CreatedAt, identity.LastLoginAt,
+		identity.UserID, identity.Email, identity.LastLoginAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save identity: %v", err)
+	}
+	return nil
+}
+
+func (r *SQLIdentityRepository) FindBySubject(ctx context.Context, issuer, subject string) (*domain.UserIdentity, error) {
+	query := `SELECT issuer, subject, user_id, email, created_at, last_login_at
+	          FROM user_identities WHERE issuer = ? AND subject = ?`
+	row := r.db.QueryRowContext(ctx, query, issuer, subject)
+
+	var identity domain.UserIdentity
+	err := row.Scan(
+		&identity.Issuer,
+		&identity.Subject,
+		&identity.UserID,
+		&identity.Email,
+		&identity.CreatedAt,
+		&identity.LastLoginAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, domain.NotFound("identity_not_found", "identity %s at %s not found", subject, issuer)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find identity: %v", err)
+	}
+	return &identity, nil
+}
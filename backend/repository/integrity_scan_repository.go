@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"certificate-ledger/domain"
+)
+
+const integrityScanColumns = `id, status, scan_trigger, started_at, finished_at, chain_valid, chain_height, certificates_checked, discrepancy_count, new_discrepancies, discrepancies, error`
+
+type SQLIntegrityScanRepository struct {
+	db *sql.DB
+}
+
+func NewSQLIntegrityScanRepository(db *sql.DB) *SQLIntegrityScanRepository {
+	return &SQLIntegrityScanRepository{db: db}
+}
+
+func (r *SQLIntegrityScanRepository) Save(ctx context.Context, scan *domain.IntegrityScan) error {
+	discrepancies, err := json.Marshal(scan.Discrepancies)
+	if err != nil {
+		return fmt.Errorf("failed to marshal integrity discrepancies: %v", err)
+	}
+	query := `INSERT INTO integrity_scans (` + integrityScanColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = r.db.ExecContext(ctx, query,
+		scan.ID,
+		scan.Status,
+		scan.Trigger,
+		scan.StartedAt,
+		scan.FinishedAt,
+		scan.ChainValid,
+		scan.ChainHeight,
+		scan.CertificatesChecked,
+		scan.DiscrepancyCount,
+		scan.NewDiscrepancies,
+		string(discrepancies),
+		scan.Error,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save integrity scan: %v", err)
+	}
+	return nil
+}
+
+func (r *SQLIntegrityScanRepository) FindByID(ctx context.Context, id string) (*domain.IntegrityScan, error) {
+	query := `SELECT ` + integrityScanColumns + ` FROM integrity_scans WHERE id = ?`
+	scan, err := scanIntegrityScan(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, domain.NotFound("integrity_scan_not_found", "integrity scan with ID %s not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find integrity scan: %v", err)
+	}
+	return scan, nil
+}
+
+func (r *SQLIntegrityScanRepository) FindRecent(ctx context.Context, limit int) ([]*domain.IntegrityScan, error) {
+	query := `SELECT ` + integrityScanColumns + ` FROM integrity_scans ORDER BY started_at DESC, id DESC LIMIT ?`
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query integrity scans: %v", err)
+	}
+	defer rows.Close()
+
+	scans := []*domain.IntegrityScan{}
+	for rows.Next() {
+		scan, err := scanIntegrityScan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan integrity scan: %v", err)
+		}
+		scans = append(scans, scan)
+	}
+	return scans, rows.Err()
+}
+
+func scanIntegrityScan(row rowScanner) (*domain.IntegrityScan, error) {
+	var scan domain.IntegrityScan
+	var finishedAt sql.NullTime
+	var discrepancies string
+	err := row.Scan(
+		&scan.ID,
+		&scan.Status,
+		&scan.Trigger,
+		&scan.StartedAt,
+		&finishedAt,
+		&scan.ChainValid,
+		&scan.ChainHeight,
+		&scan.CertificatesChecked,
+		&scan.DiscrepancyCount,
+		&scan.NewDiscrepancies,
+		&discrepancies,
+		&scan.Error,
+	)
+	if err != nil {
+		return nil, err
+	}
+	scan.FinishedAt = nullTimePtr(finishedAt)
+	if err := json.Unmarshal([]byte(discrepancies), &scan.Discrepancies); err != nil {
+		return nil, fmt.Errorf("invalid discrepancies of integrity scan %s: %v", scan.ID, err)
+	}
+	return &scan, nil
+}
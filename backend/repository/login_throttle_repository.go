@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"certificate-ledger/domain"
+	"github.com/google/uuid"
+)
+
+// SQLLoginThrottleRepository keeps failed-login counters in the database so every
+// backend instance sees the same counts and lockouts.
+type SQLLoginThrottleRepository struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+func NewSQLLoginThrottleRepository(db *sql.DB, dialect Dialect) *SQLLoginThrottleRepository {
+	return &SQLLoginThrottleRepository{db: db, dialect: dialect}
+}
+
+func (r *SQLLoginThrottleRepository) Find(ctx context.Context, scope, key string) (*domain.LoginThrottle, error) {
+	query := `SELECT scope, throttle_key, failures, last_failure_at, locked_until
+	          FROM login_throttles WHERE scope = ? AND throttle_key = ?`
+	throttle, err := scanLoginThrottle(r.db.QueryRowContext(ctx, query, scope, key))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find login throttle: %v", err)
+	}
+	return throttle, nil
+}
+
+// RecordFailure atomically increments the failure counter. Counters whose last
+// failure is older than windowStart start again from one.
+func (r *SQLLoginThrottleRepository) RecordFailure(ctx context.Context, scope, key string, now, windowStart time.Time) (*domain.LoginThrottle, error) {
+	query := `
+		INSERT INTO login_throttles (scope, throttle_key, failures, last_failure_at, locked_until)
+		VALUES (?, ?, 1, ?, NULL)
+		` + r.dialect.upsert("scope, throttle_key") + `
+			failures = CASE WHEN last_failure_at < ? THEN 1 ELSE failures + 1 END,
+			last_failure_at = ?`
+	if _, err := r.db.ExecContext(ctx, query, scope, key, now, windowStart, now); err != nil {
+		return nil, fmt.Errorf("failed to record login failure: %v", err)
+	}
+	return r.Find(ctx, scope, key)
+}
+
+// Lock starts a lockout if the counter reached threshold and no lockout is
+// active. It reports whether this call started the lockout, so concurrent
+// instances record the event only once.
+func (r *SQLLoginThrottleRepository) Lock(ctx context.Context, scope, key string, threshold int, now, until time.Time) (bool, error) {
+	query := `
+		UPDATE login_throttles SET locked_until = ?, failures = 0
+		WHERE scope = ? AND throttle_key = ? AND failures >= ? AND (locked_until IS NULL OR locked_until <= ?)`
+	result, err := r.db.ExecContext(ctx, query, until, scope, key, threshold, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to lock login throttle: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check rows affected: %v", err)
+	}
+	return rowsAffected == 1, nil
+}
+
+func (r *SQLLoginThrottleRepository) Forgive(ctx context.Context, scope, key string) error {
+	query := `UPDATE login_throttles SET failures = failures - 1 WHERE scope = ? AND throttle_key = ? AND failures > 0`
+	if _, err := r.db.ExecContext(ctx, query, scope, key); err != nil {
+		return fmt.Errorf("failed to forgive login failure: %v", err)
+	}
+	return nil
+}
+
+func (r *SQLLoginThrottleRepository) Delete(ctx context.Context, scope, key string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM login_throttles WHERE scope = ? AND throttle_key = ?`, scope, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete login throttle: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check rows affected: %v", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+func (r *SQLLoginThrottleRepository) FindLocked(ctx context.Context, now time.Time) ([]*domain.LoginThrottle, error) {
+	query := `SELECT scope, throttle_key, failures, last_failure_at, locked_until
+	          FROM login_throttles WHERE locked_until > ? ORDER BY locked_until DESC`
+	rows, err := r.db.QueryContext(ctx, query, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query login throttles: %v", err)
+	}
+	defer rows.Close()
+
+	var throttles []*domain.LoginThrottle
+	for rows.Next() {
+		throttle, err := scanLoginThrottle(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan login throttle: %v", err)
+		}
+		throttles = append(throttles, throttle)
+	}
+	return throttles, nil
+}
+
+func (r *SQLLoginThrottleRepository) SaveEvent(ctx context.Context, event *domain.LockoutEvent) error {
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	query := `
+		INSERT INTO lockout_events (id, scope, throttle_key, event, actor_id, ip, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query,
+		event.ID,
+		event.Scope,
+		event.Key,
+		event.Event,
+		event.ActorID,
+		event.IP,
+		event.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save lockout event: %v", err)
+	}
+	return nil
+}
+
+func (r *SQLLoginThrottleRepository) FindEvents(ctx context.Context, limit int) ([]*domain.LockoutEvent, error) {
+	query := `SELECT id, scope, throttle_key, event, actor_id, ip, created_at
+	          FROM lockout_events ORDER BY created_at DESC LIMIT ?`
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lockout events: %v", err)
+	}
+	defer rows.Close()
+
+	var events []*domain.LockoutEvent
+	for rows.Next() {
+		var event domain.LockoutEvent
+		if err := rows.Scan(
+			&event.ID,
+			&event.Scope,
+			&event.Key,
+			&event.Event,
+			&event.ActorID,
+			&event.IP,
+			&event.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan lockout event: %v", err)
+		}
+		events = append(events, &event)
+	}
+	return events, nil
+}
+
+func scanLoginThrottle(row rowScanner) (*domain.LoginThrottle, error) {
+	var throttle domain.LoginThrottle
+	var lockedUntil sql.NullTime
+	if err := row.Scan(
+		&throttle.Scope,
+		&throttle.Key,
+		&throttle.Failures,
+		&throttle.LastFailureAt,
+		&lockedUntil,
+	); err != nil {
+		return nil, err
+	}
+	throttle.LockedUntil = nullTimePtr(lockedUntil)
+	return &throttle, nil
+}
@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"certificate-ledger/domain"
+)
+
+type AnchorRepository struct {
+	s *store
+}
+
+func (r *AnchorRepository) Save(ctx context.Context, anchor *domain.Anchor) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.anchors[anchor.ID]; ok {
+		return domain.Conflict("anchor_exists", "anchor with ID %s already exists", anchor.ID)
+	}
+	r.s.anchors[anchor.ID] = cloneAnchor(anchor)
+	return nil
+}
+
+func (r *AnchorRepository) FindByID(ctx context.Context, id string) (*domain.Anchor, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	anchor, ok := r.s.anchors[id]
+	if !ok {
+		return nil, domain.NotFound("anchor_not_found", "anchor with ID %s not found", id)
+	}
+	return cloneAnchor(anchor), nil
+}
+
+func (r *AnchorRepository) FindRecent(ctx context.Context, limit int) ([]*domain.Anchor, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	newestFirst := func(a, b *domain.Anchor) bool { return anchorBefore(b, a) }
+	anchors := []*domain.Anchor{}
+	for _, anchor := range values(r.s.anchors, newestFirst) {
+		if len(anchors) == limit {
+			break
+		}
+		anchors = append(anchors, cloneAnchor(anchor))
+	}
+	return anchors, nil
+}
+
+func (r *AnchorRepository) FindCovering(ctx context.Context, blockIndex int, since time.Time, limit int) ([]*domain.Anchor, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	anchors := []*domain.Anchor{}
+	for _, anchor := range values(r.s.anchors, anchorBefore) {
+		if len(anchors) == limit {
+			break
+		}
+		if anchor.BlockIndex >= blockIndex && !anchor.CreatedAt.Before(since) {
+			anchors = append(anchors, cloneAnchor(anchor))
+		}
+	}
+	return anchors, nil
+}
+
+func anchorBefore(a, b *domain.Anchor) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+func cloneAnchor(anchor *domain.Anchor) *domain.Anchor {
+	clone := *anchor
+	clone.Receipt = append([]byte(nil), anchor.Receipt...)
+	return &clone
+}
@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"certificate-ledger/domain"
+)
+
+type APIKeyRepository struct {
+	s *store
+}
+
+func (r *APIKeyRepository) Save(ctx context.Context, key *domain.APIKey) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, other := range r.s.apiKeys {
+		if other.ID == key.ID || other.Prefix == key.Prefix {
+			return domain.Conflict("api_key_exists", "api key with prefix %s already exists", key.Prefix)
+		}
+	}
+	r.s.apiKeys[key.ID] = copyAPIKey(key)
+	return nil
+}
+
+func (r *APIKeyRepository) FindByID(ctx context.Context, id string) (*domain.APIKey, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	key, ok := r.s.apiKeys[id]
+	if !ok {
+		return nil, domain.NotFound("api_key_not_found", "api key with ID %s not found", id)
+	}
+	return copyAPIKey(key), nil
+}
+
+func (r *APIKeyRepository) FindByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, key := range r.s.apiKeys {
+		if key.Prefix == prefix {
+			return copyAPIKey(key), nil
+		}
+	}
+	return nil, domain.NotFound("api_key_not_found", "api key with prefix %s not found", prefix)
+}
+
+func (r *APIKeyRepository) FindByUserID(ctx context.Context, userID string) ([]*domain.APIKey, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var keys []*domain.APIKey
+	for _, key := range values(r.s.apiKeys, func(a, b *domain.APIKey) bool { return a.CreatedAt.After(b.CreatedAt) }) {
+		if key.UserID == userID {
+			keys = append(keys, copyAPIKey(key))
+		}
+	}
+	return keys, nil
+}
+
+func (r *APIKeyRepository) Revoke(ctx context.Context, id string, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	key, ok := r.s.apiKeys[id]
+	if !ok || key.RevokedAt != nil {
+		return domain.NotFound("api_key_not_found", "api key with ID %s not found or already revoked", id)
+	}
+	key.RevokedAt = &at
+	return nil
+}
+
+func (r *APIKeyRepository) RevokeForUser(ctx context.Context, userID string, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, key := range r.s.apiKeys {
+		if key.UserID == userID && key.RevokedAt == nil {
+			revokedAt := at
+			key.RevokedAt = &revokedAt
+		}
+	}
+	return nil
+}
+
+func (r *APIKeyRepository) UpdateLastUsed(ctx context.Context, id string, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if key, ok := r.s.apiKeys[id]; ok {
+		key.LastUsedAt = &at
+	}
+	return nil
+}
+
+func copyAPIKey(key *domain.APIKey) *domain.APIKey {
+	k := *key
+	k.Scopes = cloneStrings(key.Scopes)
+	return &k
+}
@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"context"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/repository"
+)
+
+// AuditRepository keeps the log as a slice ordered by Seq.
+type AuditRepository struct {
+	s *store
+}
+
+func (r *AuditRepository) Append(ctx context.Context, entry *domain.AuditEntry) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if n := len(r.s.auditLog); n > 0 && r.s.auditLog[n-1].Seq >= entry.Seq {
+		return domain.Conflict("audit_seq_taken", "audit entry %d already exists", entry.Seq)
+	}
+	e := *entry
+	r.s.auditLog = append(r.s.auditLog, &e)
+	return nil
+}
+
+func (r *AuditRepository) Last(ctx context.Context) (*domain.AuditEntry, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if len(r.s.auditLog) == 0 {
+		return nil, nil
+	}
+	e := *r.s.auditLog[len(r.s.auditLog)-1]
+	return &e, nil
+}
+
+func (r *AuditRepository) FindPage(ctx context.Context, q domain.AuditQuery) (*domain.AuditPage, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var entries []*domain.AuditEntry
+	for i := len(r.s.auditLog) - 1; i >= 0 && len(entries) <= q.Limit; i-- {
+		entry := r.s.auditLog[i]
+		if q.BeforeSeq > 0 && entry.Seq >= q.BeforeSeq {
+			continue
+		}
+		if q.Filter.Matches(entry) {
+			e := *entry
+			entries = append(entries, &e)
+		}
+	}
+	return repository.PageAuditEntries(entries, q.Limit), nil
+}
+
+func (r *AuditRepository) Walk(ctx context.Context, fn func(*domain.AuditEntry) error) error {
+	r.s.mu.Lock()
+	entries := make([]domain.AuditEntry, len(r.s.auditLog))
+	for i, entry := range r.s.auditLog {
+		entries[i] = *entry
+	}
+	r.s.mu.Unlock()
+
+	for i := range entries {
+		if err := fn(&entries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"context"
+
+	"certificate-ledger/blockchain"
+	"certificate-ledger/domain"
+)
+
+type BlockRepository struct {
+	s *store
+}
+
+func (r *BlockRepository) FindAll(ctx context.Context) ([]*blockchain.Block, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	blocks := make([]*blockchain.Block, len(r.s.blocks))
+	for i, block := range r.s.blocks {
+		blocks[i] = cloneBlock(block)
+	}
+	return blocks, nil
+}
+
+func (r *BlockRepository) Append(ctx context.Context, block *blockchain.Block) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if block.Index != len(r.s.blocks) {
+		return domain.Conflict("block_exists", "block %d is already stored", block.Index)
+	}
+	r.s.blocks = append(r.s.blocks, cloneBlock(block))
+	return nil
+}
+
+func cloneBlock(block *blockchain.Block) *blockchain.Block {
+	b := *block
+	b.Data = append([]byte(nil), block.Data...)
+	return &b
+}
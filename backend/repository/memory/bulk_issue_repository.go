@@ -0,0 +1,152 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"certificate-ledger/domain"
+)
+
+type BulkIssueRepository struct {
+	s *store
+}
+
+// bulkIssueLease is the lease on a job, kept apart since jobs do not show it.
+type bulkIssueLease struct {
+	owner string
+	until time.Time
+}
+
+// SaveJob stores a job together with all of its rows.
+func (r *BulkIssueRepository) SaveJob(ctx context.Context, job *domain.BulkIssueJob, rows []*domain.BulkIssueRow) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.bulkIssueJobs[job.ID]; ok {
+		return domain.Conflict("bulk_issue_job_exists", "bulk issue job %s already exists", job.ID)
+	}
+	j := *job
+	r.s.bulkIssueJobs[job.ID] = &j
+
+	stored := make([]*domain.BulkIssueRow, len(rows))
+	for i, row := range rows {
+		stored[i] = copyBulkIssueRow(row)
+		stored[i].JobID = job.ID
+	}
+	r.s.bulkIssueRows[job.ID] = stored
+	return nil
+}
+
+// UpdateJob stores the job's status and counters.
+func (r *BulkIssueRepository) UpdateJob(ctx context.Context, job *domain.BulkIssueJob) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.bulkIssueJobs[job.ID]
+	if !ok {
+		return nil
+	}
+	stored.Status = job.Status
+	stored.Processed = job.Processed
+	stored.Succeeded = job.Succeeded
+	stored.Failed = job.Failed
+	stored.Error = job.Error
+	stored.StartedAt = job.StartedAt
+	stored.FinishedAt = job.FinishedAt
+	return nil
+}
+
+// UpdateRow stores the outcome of issuing a row.
+func (r *BulkIssueRepository) UpdateRow(ctx context.Context, row *domain.BulkIssueRow) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, stored := range r.s.bulkIssueRows[row.JobID] {
+		if stored.Row == row.Row {
+			stored.Status = row.Status
+			stored.Errors = cloneStrings(row.Errors)
+			stored.CertificateID = row.CertificateID
+			stored.Hash = row.Hash
+		}
+	}
+	return nil
+}
+
+func (r *BulkIssueRepository) FindJob(ctx context.Context, id string) (*domain.BulkIssueJob, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	job, ok := r.s.bulkIssueJobs[id]
+	if !ok {
+		return nil, domain.NotFound("bulk_issue_job_not_found", "bulk issue job %s not found", id)
+	}
+	j := *job
+	return &j, nil
+}
+
+func (r *BulkIssueRepository) FindJobsByIssuer(ctx context.Context, issuerID string) ([]*domain.BulkIssueJob, error) {
+	return r.findJobs(func(job *domain.BulkIssueJob) bool { return job.IssuerID == issuerID }, true), nil
+}
+
+// FindUnfinishedJobs returns jobs that were pending or running, e.g. when the server stopped.
+func (r *BulkIssueRepository) FindUnfinishedJobs(ctx context.Context) ([]*domain.BulkIssueJob, error) {
+	return r.findJobs(func(job *domain.BulkIssueJob) bool {
+		return job.Status == domain.BulkJobPending || job.Status == domain.BulkJobRunning
+	}, false), nil
+}
+
+// FindRows returns the job's rows in file order. With status set, only rows in that status are returned.
+func (r *BulkIssueRepository) FindRows(ctx context.Context, jobID, status string) ([]*domain.BulkIssueRow, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var rows []*domain.BulkIssueRow
+	for _, row := range r.s.bulkIssueRows[jobID] {
+		if status == "" || row.Status == status {
+			rows = append(rows, copyBulkIssueRow(row))
+		}
+	}
+	return rows, nil
+}
+
+func (r *BulkIssueRepository) ClaimJob(ctx context.Context, id, owner string, now, leaseUntil time.Time) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	job, ok := r.s.bulkIssueJobs[id]
+	if !ok || (job.Status != domain.BulkJobPending && job.Status != domain.BulkJobRunning) {
+		return false, nil
+	}
+	lease := r.s.bulkIssueLeases[id]
+	if lease.owner != owner && lease.until.After(now) {
+		return false, nil
+	}
+	r.s.bulkIssueLeases[id] = bulkIssueLease{owner: owner, until: leaseUntil}
+	return true, nil
+}
+
+// findJobs returns matching jobs ordered by creation time.
+func (r *BulkIssueRepository) findJobs(match func(*domain.BulkIssueJob) bool, newestFirst bool) []*domain.BulkIssueJob {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var jobs []*domain.BulkIssueJob
+	for _, job := range values(r.s.bulkIssueJobs, func(a, b *domain.BulkIssueJob) bool {
+		if newestFirst {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	}) {
+		if match(job) {
+			j := *job
+			jobs = append(jobs, &j)
+		}
+	}
+	return jobs
+}
+
+func copyBulkIssueRow(row *domain.BulkIssueRow) *domain.BulkIssueRow {
+	r := *row
+	r.Errors = cloneStrings(row.Errors)
+	return &r
+}
@@ -0,0 +1,165 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/repository"
+)
+
+type CertificateRepository struct {
+	s *store
+}
+
+// Save stores the columns the SQL repository persists: commitments and the
+// one-time claim code are not kept, and salts are kept apart.
+func (r *CertificateRepository) Save(ctx context.Context, cert *domain.Certificate) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.certificates[cert.ID]; ok {
+		return domain.Conflict("certificate_exists", "certificate with ID %s already exists", cert.ID)
+	}
+	stored := *cert
+	stored.Commitments = nil
+	stored.FieldSalts = nil
+	stored.ClaimCode = ""
+	r.s.certificates[cert.ID] = &stored
+
+	salts := make(map[string]string, len(cert.FieldSalts))
+	for field, salt := range cert.FieldSalts {
+		salts[field] = salt
+	}
+	r.s.fieldSalts[cert.ID] = salts
+	return nil
+}
+
+func (r *CertificateRepository) FindFieldSalts(ctx context.Context, id string) (map[string]string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	salts := make(map[string]string)
+	for field, salt := range r.s.fieldSalts[id] {
+		salts[field] = salt
+	}
+	return salts, nil
+}
+
+func (r *CertificateRepository) FindByID(ctx context.Context, id string) (*domain.Certificate, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	cert, ok := r.s.certificates[id]
+	if !ok {
+		return nil, domain.NotFound("certificate_not_found", "certificate with ID %s not found", id)
+	}
+	return copyCertificate(cert), nil
+}
+
+func (r *CertificateRepository) FindByHash(ctx context.Context, hash string) (*domain.Certificate, error) {
+	certs := r.find(func(c *domain.Certificate) bool { return c.Hash == hash })
+	if len(certs) == 0 {
+		return nil, domain.NotFound("certificate_not_found", "certificate with hash %s not found", hash)
+	}
+	return certs[0], nil
+}
+
+func (r *CertificateRepository) FindAll(ctx context.Context) ([]*domain.Certificate, error) {
+	return r.find(func(*domain.Certificate) bool { return true }), nil
+}
+
+func (r *CertificateRepository) FindByIssuerID(ctx context.Context, userID string) ([]*domain.Certificate, error) {
+	return r.find(func(c *domain.Certificate) bool { return c.IssuerID == userID }), nil
+}
+
+func (r *CertificateRepository) FindByRecipientEmail(ctx context.Context, email string) ([]*domain.Certificate, error) {
+	return r.find(func(c *domain.Certificate) bool { return c.RecipientEmail == email }), nil
+}
+
+// FindByRecipient returns the certificates claimed by the user plus, when
+// email is not empty, unclaimed certificates issued to that email address.
+func (r *CertificateRepository) FindByRecipient(ctx context.Context, userID, email string) ([]*domain.Certificate, error) {
+	filter := domain.CertificateFilter{WalletUserID: userID, WalletEmail: email}
+	certs := r.find(filter.Matches)
+	sort.SliceStable(certs, func(i, j int) bool { return certs[i].IssueDate.After(certs[j].IssueDate) })
+	return certs, nil
+}
+
+func (r *CertificateRepository) Claim(ctx context.Context, id, userID string, at time.Time) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	cert, ok := r.s.certificates[id]
+	if !ok || cert.RecipientUserID != "" {
+		return false, nil
+	}
+	cert.RecipientUserID = userID
+	cert.ClaimedAt = &at
+	return true, nil
+}
+
+func (r *CertificateRepository) ClaimByEmail(ctx context.Context, userID, email string, at time.Time) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var claimed int64
+	for _, cert := range r.s.certificates {
+		if cert.RecipientEmail == email && cert.RecipientUserID == "" {
+			cert.RecipientUserID = userID
+			cert.ClaimedAt = &at
+			claimed++
+		}
+	}
+	return claimed, nil
+}
+
+func (r *CertificateRepository) Revoke(ctx context.Context, id, reason string, at time.Time) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	cert, ok := r.s.certificates[id]
+	if !ok || cert.Status == domain.CertificateStatusRevoked {
+		return false, nil
+	}
+	cert.Status = domain.CertificateStatusRevoked
+	cert.RevokedAt = &at
+	cert.RevocationReason = reason
+	return true, nil
+}
+
+func (r *CertificateRepository) UpdateStatus(ctx context.Context, id, from, to string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	cert, ok := r.s.certificates[id]
+	if !ok || cert.Status != from {
+		return false, nil
+	}
+	cert.Status = to
+	return true, nil
+}
+
+func (r *CertificateRepository) FindPage(ctx context.Context, q domain.CertificateQuery) (*domain.CertificatePage, error) {
+	return repository.PageCertificates(r.find(func(*domain.Certificate) bool { return true }), q)
+}
+
+// find returns copies of the matching certificates ordered by ID.
+func (r *CertificateRepository) find(match func(*domain.Certificate) bool) []*domain.Certificate {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var certs []*domain.Certificate
+	for _, cert := range values(r.s.certificates, func(a, b *domain.Certificate) bool { return a.ID < b.ID }) {
+		if match(cert) {
+			certs = append(certs, copyCertificate(cert))
+		}
+	}
+	return certs
+}
+
+func copyCertificate(cert *domain.Certificate) *domain.Certificate {
+	c := *cert
+	return &c
+}
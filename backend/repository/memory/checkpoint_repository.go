@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"context"
+
+	"certificate-ledger/domain"
+)
+
+type CheckpointRepository struct {
+	s *store
+}
+
+func (r *CheckpointRepository) Save(ctx context.Context, checkpoint *domain.Checkpoint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.checkpoints[checkpoint.ID]; ok {
+		return domain.Conflict("checkpoint_exists", "checkpoint with ID %s already exists", checkpoint.ID)
+	}
+	r.s.checkpoints[checkpoint.ID] = cloneCheckpoint(checkpoint)
+	return nil
+}
+
+func (r *CheckpointRepository) FindByID(ctx context.Context, id string) (*domain.Checkpoint, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	checkpoint, ok := r.s.checkpoints[id]
+	if !ok {
+		return nil, domain.NotFound("checkpoint_not_found", "checkpoint with ID %s not found", id)
+	}
+	return cloneCheckpoint(checkpoint), nil
+}
+
+func (r *CheckpointRepository) FindRecent(ctx context.Context, limit int) ([]*domain.Checkpoint, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	newestFirst := func(a, b *domain.Checkpoint) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID > b.ID
+	}
+	checkpoints := []*domain.Checkpoint{}
+	for _, checkpoint := range values(r.s.checkpoints, newestFirst) {
+		if len(checkpoints) == limit {
+			break
+		}
+		checkpoints = append(checkpoints, cloneCheckpoint(checkpoint))
+	}
+	return checkpoints, nil
+}
+
+func (r *CheckpointRepository) FindByOrigin(ctx context.Context, origin string) ([]*domain.Checkpoint, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	byHeight := func(a, b *domain.Checkpoint) bool {
+		if a.Height != b.Height {
+			return a.Height < b.Height
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	checkpoints := []*domain.Checkpoint{}
+	for _, checkpoint := range values(r.s.checkpoints, byHeight) {
+		if checkpoint.Origin == origin {
+			checkpoints = append(checkpoints, cloneCheckpoint(checkpoint))
+		}
+	}
+	return checkpoints, nil
+}
+
+func cloneCheckpoint(checkpoint *domain.Checkpoint) *domain.Checkpoint {
+	clone := *checkpoint
+	clone.Signatures = append([]domain.CheckpointSignature{}, checkpoint.Signatures...)
+	return &clone
+}
+
+type WitnessedChainRepository struct {
+	s *store
+}
+
+func (r *WitnessedChainRepository) Find(ctx context.Context, origin string) (*domain.WitnessedChain, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	chain, ok := r.s.witnessedChains[origin]
+	if !ok {
+		return nil, nil
+	}
+	clone := *chain
+	return &clone, nil
+}
+
+func (r *WitnessedChainRepository) Save(ctx context.Context, chain *domain.WitnessedChain) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	clone := *chain
+	r.s.witnessedChains[chain.Origin] = &clone
+	return nil
+}
@@ -0,0 +1,45 @@
+package memory
+
+import (
+	"context"
+
+	"certificate-ledger/domain"
+)
+
+type identityKey struct {
+	issuer, subject string
+}
+
+type IdentityRepository struct {
+	s *store
+}
+
+// Save inserts the identity or, for a known issuer and subject, updates its
+// user, email and last login.
+func (r *IdentityRepository) Save(ctx context.Context, identity *domain.UserIdentity) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	k := identityKey{identity.Issuer, identity.Subject}
+	if existing, ok := r.s.identities[k]; ok {
+		existing.UserID = identity.UserID
+		existing.Email = identity.Email
+		existing.LastLoginAt = identity.LastLoginAt
+		return nil
+	}
+	i := *identity
+	r.s.identities[k] = &i
+	return nil
+}
+
+func (r *IdentityRepository) FindBySubject(ctx context.Context, issuer, subject string) (*domain.UserIdentity, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	identity, ok := r.s.identities[identityKey{issuer, subject}]
+	if !ok {
+		return nil, domain.NotFound("identity_not_found", "identity %s at %s not found", subject, issuer)
+	}
+	i := *identity
+	return &i, nil
+}
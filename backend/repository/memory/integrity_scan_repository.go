@@ -0,0 +1,59 @@
+package memory
+
+import (
+	"context"
+
+	"certificate-ledger/domain"
+)
+
+type IntegrityScanRepository struct {
+	s *store
+}
+
+func (r *IntegrityScanRepository) Save(ctx context.Context, scan *domain.IntegrityScan) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.integrityScans[scan.ID]; ok {
+		return domain.Conflict("integrity_scan_exists", "integrity scan with ID %s already exists", scan.ID)
+	}
+	r.s.integrityScans[scan.ID] = cloneIntegrityScan(scan)
+	return nil
+}
+
+func (r *IntegrityScanRepository) FindByID(ctx context.Context, id string) (*domain.IntegrityScan, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	scan, ok := r.s.integrityScans[id]
+	if !ok {
+		return nil, domain.NotFound("integrity_scan_not_found", "integrity scan with ID %s not found", id)
+	}
+	return cloneIntegrityScan(scan), nil
+}
+
+func (r *IntegrityScanRepository) FindRecent(ctx context.Context, limit int) ([]*domain.IntegrityScan, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	newestFirst := func(a, b *domain.IntegrityScan) bool {
+		if !a.StartedAt.Equal(b.StartedAt) {
+			return a.StartedAt.After(b.StartedAt)
+		}
+		return a.ID > b.ID
+	}
+	scans := []*domain.IntegrityScan{}
+	for _, scan := range values(r.s.integrityScans, newestFirst) {
+		if len(scans) == limit {
+			break
+		}
+		scans = append(scans, cloneIntegrityScan(scan))
+	}
+	return scans, nil
+}
+
+func cloneIntegrityScan(scan *domain.IntegrityScan) *domain.IntegrityScan {
+	clone := *scan
+	clone.Discrepancies = append([]domain.IntegrityDiscrepancy{}, scan.Discrepancies...)
+	return &clone
+}
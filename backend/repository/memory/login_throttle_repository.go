@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"certificate-ledger/domain"
+	"github.com/google/uuid"
+)
+
+type throttleKey struct {
+	scope, key string
+}
+
+type LoginThrottleRepository struct {
+	s *store
+}
+
+func (r *LoginThrottleRepository) Find(ctx context.Context, scope, key string) (*domain.LoginThrottle, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	throttle, ok := r.s.loginThrottles[throttleKey{scope, key}]
+	if !ok {
+		return nil, nil
+	}
+	t := *throttle
+	return &t, nil
+}
+
+// RecordFailure increments the failure counter. Counters whose last failure
+// is older than windowStart start again from one.
+func (r *LoginThrottleRepository) RecordFailure(ctx context.Context, scope, key string, now, windowStart time.Time) (*domain.LoginThrottle, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	k := throttleKey{scope, key}
+	throttle, ok := r.s.loginThrottles[k]
+	switch {
+	case !ok:
+		throttle = &domain.LoginThrottle{Scope: scope, Key: key, Failures: 1}
+		r.s.loginThrottles[k] = throttle
+	case throttle.LastFailureAt.Before(windowStart):
+		throttle.Failures = 1
+	default:
+		throttle.Failures++
+	}
+	throttle.LastFailureAt = now
+
+	t := *throttle
+	return &t, nil
+}
+
+// Lock starts a lockout if the counter reached threshold and no lockout is
+// active. It reports whether this call started the lockout.
+func (r *LoginThrottleRepository) Lock(ctx context.Context, scope, key string, threshold int, now, until time.Time) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	throttle, ok := r.s.loginThrottles[throttleKey{scope, key}]
+	if !ok || throttle.Failures < threshold || (throttle.LockedUntil != nil && throttle.LockedUntil.After(now)) {
+		return false, nil
+	}
+	throttle.LockedUntil = &until
+	throttle.Failures = 0
+	return true, nil
+}
+
+func (r *LoginThrottleRepository) Forgive(ctx context.Context, scope, key string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if throttle, ok := r.s.loginThrottles[throttleKey{scope, key}]; ok && throttle.Failures > 0 {
+		throttle.Failures--
+	}
+	return nil
+}
+
+func (r *LoginThrottleRepository) Delete(ctx context.Context, scope, key string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	k := throttleKey{scope, key}
+	_, ok := r.s.loginThrottles[k]
+	delete(r.s.loginThrottles, k)
+	return ok, nil
+}
+
+func (r *LoginThrottleRepository) FindLocked(ctx context.Context, now time.Time) ([]*domain.LoginThrottle, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var throttles []*domain.LoginThrottle
+	for _, throttle := range r.s.loginThrottles {
+		if throttle.LockedUntil != nil && throttle.LockedUntil.After(now) {
+			t := *throttle
+			throttles = append(throttles, &t)
+		}
+	}
+	sort.Slice(throttles, func(i, j int) bool { return throttles[i].LockedUntil.After(*throttles[j].LockedUntil) })
+	return throttles, nil
+}
+
+func (r *LoginThrottleRepository) SaveEvent(ctx context.Context, event *domain.LockoutEvent) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	e := *event
+	r.s.lockoutEvents = append(r.s.lockoutEvents, &e)
+	return nil
+}
+
+func (r *LoginThrottleRepository) FindEvents(ctx context.Context, limit int) ([]*domain.LockoutEvent, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	events := make([]*domain.LockoutEvent, 0, len(r.s.lockoutEvents))
+	for _, event := range r.s.lockoutEvents {
+		e := *event
+		events = append(events, &e)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].CreatedAt.After(events[j].CreatedAt) })
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
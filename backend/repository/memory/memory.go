@@ -0,0 +1,109 @@
+// Package memory implements the repository interfaces with maps guarded by a
+// single mutex. Nothing survives a restart; it exists for tests, demos and
+// local development without a database. Operations never wait on I/O, so
+// they ignore their context.
+package memory
+
+import (
+	"sort"
+	"sync"
+
+	"certificate-ledger/blockchain"
+	"certificate-ledger/domain"
+	"certificate-ledger/repository"
+)
+
+// store holds every table. Repositories share it so cascading deletes can
+// see related records, as foreign keys do in the SQL schema.
+type store struct {
+	mu              sync.Mutex
+	users           map[string]*domain.User
+	certificates    map[string]*domain.Certificate
+	fieldSalts      map[string]map[string]string
+	apiKeys         map[string]*domain.APIKey
+	recoveryCodes   map[string][]*recoveryCode
+	userTokens      map[string]*domain.UserToken
+	loginThrottles  map[throttleKey]*domain.LoginThrottle
+	lockoutEvents   []*domain.LockoutEvent
+	identities      map[identityKey]*domain.UserIdentity
+	shareLinks      map[string]*domain.ShareLink
+	bulkIssueJobs   map[string]*domain.BulkIssueJob
+	bulkIssueRows   map[string][]*domain.BulkIssueRow
+	bulkIssueLeases map[string]bulkIssueLease
+	auditLog        []*domain.AuditEntry
+
+	webhookSubscriptions map[string]*domain.WebhookSubscription
+	webhookDeliveries    map[string]*domain.WebhookDelivery
+	webhookAttempts      map[string][]*domain.WebhookAttempt
+	notifications        map[string]*domain.Notification
+	integrityScans       map[string]*domain.IntegrityScan
+	anchors              map[string]*domain.Anchor
+	checkpoints          map[string]*domain.Checkpoint
+	witnessedChains      map[string]*domain.WitnessedChain
+	blocks               []*blockchain.Block
+}
+
+// NewRepositories returns in-memory implementations of every repository,
+// backed by one empty store.
+func NewRepositories() *repository.Repositories {
+	s := &store{
+		users:           make(map[string]*domain.User),
+		certificates:    make(map[string]*domain.Certificate),
+		fieldSalts:      make(map[string]map[string]string),
+		apiKeys:         make(map[string]*domain.APIKey),
+		recoveryCodes:   make(map[string][]*recoveryCode),
+		userTokens:      make(map[string]*domain.UserToken),
+		loginThrottles:  make(map[throttleKey]*domain.LoginThrottle),
+		identities:      make(map[identityKey]*domain.UserIdentity),
+		shareLinks:      make(map[string]*domain.ShareLink),
+		bulkIssueJobs:   make(map[string]*domain.BulkIssueJob),
+		bulkIssueRows:   make(map[string][]*domain.BulkIssueRow),
+		bulkIssueLeases: make(map[string]bulkIssueLease),
+
+		webhookSubscriptions: make(map[string]*domain.WebhookSubscription),
+		webhookDeliveries:    make(map[string]*domain.WebhookDelivery),
+		webhookAttempts:      make(map[string][]*domain.WebhookAttempt),
+		notifications:        make(map[string]*domain.Notification),
+		integrityScans:       make(map[string]*domain.IntegrityScan),
+		anchors:              make(map[string]*domain.Anchor),
+		checkpoints:          make(map[string]*domain.Checkpoint),
+		witnessedChains:      make(map[string]*domain.WitnessedChain),
+	}
+	return &repository.Repositories{
+		Certificates:   &CertificateRepository{s},
+		Users:          &UserRepository{s},
+		APIKeys:        &APIKeyRepository{s},
+		RecoveryCodes:  &RecoveryCodeRepository{s},
+		UserTokens:     &UserTokenRepository{s},
+		LoginThrottles: &LoginThrottleRepository{s},
+		Identities:     &IdentityRepository{s},
+		ShareLinks:     &ShareLinkRepository{s},
+		BulkIssues:     &BulkIssueRepository{s},
+		Audit:          &AuditRepository{s},
+		Webhooks:       &WebhookRepository{s},
+		Notifications:  &NotificationRepository{s},
+		IntegrityScans: &IntegrityScanRepository{s},
+		Anchors:        &AnchorRepository{s},
+		Checkpoints:    &CheckpointRepository{s},
+		Witnessed:      &WitnessedChainRepository{s},
+		Blocks:         &BlockRepository{s},
+	}
+}
+
+// values returns the map's values ordered by less, since map iteration order
+// is random and callers expect stable listings.
+func values[K comparable, V any](m map[K]V, less func(a, b V) bool) []V {
+	result := make([]V, 0, len(m))
+	for _, v := range m {
+		result = append(result, v)
+	}
+	sort.Slice(result, func(i, j int) bool { return less(result[i], result[j]) })
+	return result
+}
+
+func cloneStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string(nil), s...)
+}
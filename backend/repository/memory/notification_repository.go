@@ -0,0 +1,124 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"certificate-ledger/domain"
+)
+
+type NotificationRepository struct {
+	s *store
+}
+
+func (r *NotificationRepository) Save(ctx context.Context, n *domain.Notification) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.certificates[n.CertificateID]; n.CertificateID != "" && !ok {
+		return domain.NotFound("certificate_not_found", "certificate with ID %s not found", n.CertificateID)
+	}
+	if _, ok := r.s.notifications[n.ID]; ok {
+		return domain.Conflict("notification_exists", "notification with ID %s already exists", n.ID)
+	}
+	stored := *n
+	r.s.notifications[n.ID] = &stored
+	return nil
+}
+
+func (r *NotificationRepository) FindByID(ctx context.Context, id string) (*domain.Notification, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	n, ok := r.s.notifications[id]
+	if !ok {
+		return nil, domain.NotFound("notification_not_found", "notification with ID %s not found", id)
+	}
+	found := *n
+	return &found, nil
+}
+
+func (r *NotificationRepository) FindByStatus(ctx context.Context, status string, limit int) ([]*domain.Notification, error) {
+	newestFirst := func(a, b *domain.Notification) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID > b.ID
+	}
+	return r.find(newestFirst, limit, func(n *domain.Notification) bool {
+		return status == "" || n.Status == status
+	}), nil
+}
+
+func (r *NotificationRepository) FindDue(ctx context.Context, now time.Time, limit int) ([]*domain.Notification, error) {
+	oldestFirst := func(a, b *domain.Notification) bool {
+		if !a.NextAttemptAt.Equal(b.NextAttemptAt) {
+			return a.NextAttemptAt.Before(b.NextAttemptAt)
+		}
+		return a.ID < b.ID
+	}
+	return r.find(oldestFirst, limit, func(n *domain.Notification) bool {
+		return n.Status == domain.NotificationPending && !n.NextAttemptAt.After(now)
+	}), nil
+}
+
+func (r *NotificationRepository) Claim(ctx context.Context, id string, now, leaseUntil time.Time) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	n, ok := r.s.notifications[id]
+	if !ok || n.Status != domain.NotificationPending || n.NextAttemptAt.After(now) {
+		return false, nil
+	}
+	n.NextAttemptAt = leaseUntil
+	return true, nil
+}
+
+func (r *NotificationRepository) Update(ctx context.Context, n *domain.Notification) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.notifications[n.ID]
+	if !ok {
+		return domain.NotFound("notification_not_found", "notification with ID %s not found", n.ID)
+	}
+	stored.TextBody = n.TextBody
+	stored.HTMLBody = n.HTMLBody
+	stored.Status = n.Status
+	stored.Attempts = n.Attempts
+	stored.NextAttemptAt = n.NextAttemptAt
+	stored.LastError = n.LastError
+	stored.SentAt = n.SentAt
+	return nil
+}
+
+func (r *NotificationRepository) Retry(ctx context.Context, id string, now time.Time) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	n, ok := r.s.notifications[id]
+	if !ok || n.Status != domain.NotificationFailed {
+		return false, nil
+	}
+	n.Status = domain.NotificationPending
+	n.Attempts = 0
+	n.NextAttemptAt = now
+	return true, nil
+}
+
+func (r *NotificationRepository) find(less func(a, b *domain.Notification) bool, limit int, match func(*domain.Notification) bool) []*domain.Notification {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	notifications := []*domain.Notification{}
+	for _, n := range values(r.s.notifications, less) {
+		if len(notifications) == limit {
+			break
+		}
+		if match(n) {
+			found := *n
+			notifications = append(notifications, &found)
+		}
+	}
+	return notifications
+}
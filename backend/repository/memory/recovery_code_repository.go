@@ -0,0 +1,51 @@
+package memory
+
+import (
+	"context"
+	"time"
+)
+
+type recoveryCode struct {
+	hash   string
+	usedAt *time.Time
+}
+
+type RecoveryCodeRepository struct {
+	s *store
+}
+
+// ReplaceForUser discards every existing recovery code of the user and stores the given hashes.
+func (r *RecoveryCodeRepository) ReplaceForUser(ctx context.Context, userID string, codeHashes []string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	codes := make([]*recoveryCode, len(codeHashes))
+	for i, hash := range codeHashes {
+		codes[i] = &recoveryCode{hash: hash}
+	}
+	r.s.recoveryCodes[userID] = codes
+	return nil
+}
+
+// Consume marks an unused recovery code as used. It reports false if no such code exists.
+func (r *RecoveryCodeRepository) Consume(ctx context.Context, userID, codeHash string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, code := range r.s.recoveryCodes[userID] {
+		if code.hash == codeHash && code.usedAt == nil {
+			now := time.Now()
+			code.usedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *RecoveryCodeRepository) DeleteForUser(ctx context.Context, userID string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	delete(r.s.recoveryCodes, userID)
+	return nil
+}
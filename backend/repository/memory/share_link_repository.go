@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"certificate-ledger/domain"
+)
+
+type ShareLinkRepository struct {
+	s *store
+}
+
+func (r *ShareLinkRepository) Save(ctx context.Context, link *domain.ShareLink) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.shareLinks[link.ID]; ok {
+		return domain.Conflict("share_link_exists", "share link already exists")
+	}
+	r.s.shareLinks[link.ID] = copyShareLink(link)
+	return nil
+}
+
+func (r *ShareLinkRepository) FindByID(ctx context.Context, id string) (*domain.ShareLink, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	link, ok := r.s.shareLinks[id]
+	if !ok {
+		return nil, domain.NotFound("share_link_not_found", "share link not found")
+	}
+	return copyShareLink(link), nil
+}
+
+func (r *ShareLinkRepository) FindByCertificate(ctx context.Context, certificateID, ownerID string) ([]*domain.ShareLink, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var links []*domain.ShareLink
+	for _, link := range values(r.s.shareLinks, func(a, b *domain.ShareLink) bool { return a.CreatedAt.After(b.CreatedAt) }) {
+		if link.CertificateID == certificateID && link.OwnerID == ownerID {
+			links = append(links, copyShareLink(link))
+		}
+	}
+	return links, nil
+}
+
+// RecordView counts a view of an active link. It reports false if the link is revoked or expired.
+func (r *ShareLinkRepository) RecordView(ctx context.Context, id string, now time.Time) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	link, ok := r.s.shareLinks[id]
+	if !ok || link.RevokedAt != nil || !link.ExpiresAt.After(now) {
+		return false, nil
+	}
+	link.ViewCount++
+	link.LastViewedAt = &now
+	return true, nil
+}
+
+func (r *ShareLinkRepository) Revoke(ctx context.Context, id string, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	link, ok := r.s.shareLinks[id]
+	if !ok || link.RevokedAt != nil {
+		return domain.NotFound("share_link_not_found", "share link not found or already revoked")
+	}
+	link.RevokedAt = &at
+	return nil
+}
+
+func copyShareLink(link *domain.ShareLink) *domain.ShareLink {
+	l := *link
+	l.Fields = cloneStrings(link.Fields)
+	return &l
+}
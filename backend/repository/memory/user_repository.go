@@ -0,0 +1,129 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"certificate-ledger/domain"
+	"github.com/google/uuid"
+)
+
+type UserRepository struct {
+	s *store
+}
+
+// Save inserts the user or updates the user with the same ID. Emails are
+// unique, as in the SQL schema.
+func (r *UserRepository) Save(ctx context.Context, user *domain.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if user.ID == "" {
+		user.ID = uuid.New().String()
+	}
+	for id, other := range r.s.users {
+		if id != user.ID && other.Email == user.Email {
+			return domain.Conflict("email_taken", "user with email %s already exists", user.Email)
+		}
+	}
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now()
+	}
+	user.UpdatedAt = time.Now()
+
+	stored := *user
+	if existing, ok := r.s.users[user.ID]; ok {
+		stored.CreatedAt = existing.CreatedAt
+	}
+	r.s.users[user.ID] = &stored
+	return nil
+}
+
+func (r *UserRepository) FindByID(ctx context.Context, id string) (*domain.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.users[id]
+	if !ok {
+		return nil, domain.NotFound("user_not_found", "user with ID %s not found", id)
+	}
+	u := *user
+	return &u, nil
+}
+
+func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*domain.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, user := range r.s.users {
+		if user.Email == email {
+			u := *user
+			return &u, nil
+		}
+	}
+	return nil, domain.NotFound("user_not_found", "user with email %s not found", email)
+}
+
+func (r *UserRepository) FindAll(ctx context.Context) ([]*domain.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var users []*domain.User
+	for _, user := range values(r.s.users, func(a, b *domain.User) bool { return a.CreatedAt.Before(b.CreatedAt) }) {
+		u := *user
+		users = append(users, &u)
+	}
+	return users, nil
+}
+
+// Delete removes the user and everything the SQL schema deletes with it. Like
+// the issuer foreign key, it refuses to delete a user who issued certificates.
+func (r *UserRepository) Delete(ctx context.Context, id string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.users[id]; !ok {
+		return domain.NotFound("user_not_found", "user with ID %s not found", id)
+	}
+	for _, cert := range r.s.certificates {
+		if cert.IssuerID == id {
+			return domain.Conflict("user_has_certificates", "user %s has issued certificates", id)
+		}
+	}
+
+	delete(r.s.users, id)
+	delete(r.s.recoveryCodes, id)
+	for keyID, key := range r.s.apiKeys {
+		if key.UserID == id {
+			delete(r.s.apiKeys, keyID)
+		}
+	}
+	for tokenID, token := range r.s.userTokens {
+		if token.UserID == id {
+			delete(r.s.userTokens, tokenID)
+		}
+	}
+	for k, identity := range r.s.identities {
+		if identity.UserID == id {
+			delete(r.s.identities, k)
+		}
+	}
+	for linkID, link := range r.s.shareLinks {
+		if link.OwnerID == id {
+			delete(r.s.shareLinks, linkID)
+		}
+	}
+	for jobID, job := range r.s.bulkIssueJobs {
+		if job.IssuerID == id {
+			delete(r.s.bulkIssueJobs, jobID)
+			delete(r.s.bulkIssueRows, jobID)
+			delete(r.s.bulkIssueLeases, jobID)
+		}
+	}
+	for subID, sub := range r.s.webhookSubscriptions {
+		if sub.OwnerID == id {
+			r.s.deleteWebhookSubscription(subID)
+		}
+	}
+	return nil
+}
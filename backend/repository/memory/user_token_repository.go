@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"certificate-ledger/domain"
+)
+
+type UserTokenRepository struct {
+	s *store
+}
+
+func (r *UserTokenRepository) Save(ctx context.Context, token *domain.UserToken) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.userTokens[token.ID]; ok {
+		return domain.Conflict("token_exists", "token with ID %s already exists", token.ID)
+	}
+	t := *token
+	r.s.userTokens[token.ID] = &t
+	return nil
+}
+
+func (r *UserTokenRepository) FindByID(ctx context.Context, id string) (*domain.UserToken, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	token, ok := r.s.userTokens[id]
+	if !ok {
+		return nil, domain.NotFound("token_not_found", "token with ID %s not found", id)
+	}
+	t := *token
+	return &t, nil
+}
+
+// Consume marks the token used if it is still unused and unexpired.
+func (r *UserTokenRepository) Consume(ctx context.Context, id string, now time.Time) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	token, ok := r.s.userTokens[id]
+	if !ok || token.UsedAt != nil || !token.ExpiresAt.After(now) {
+		return false, nil
+	}
+	token.UsedAt = &now
+	return true, nil
+}
+
+// InvalidateForUser consumes every outstanding token of the given purpose for a user.
+func (r *UserTokenRepository) InvalidateForUser(ctx context.Context, userID, purpose string, now time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, token := range r.s.userTokens {
+		if token.UserID == userID && token.Purpose == purpose && token.UsedAt == nil {
+			token.UsedAt = &now
+		}
+	}
+	return nil
+}
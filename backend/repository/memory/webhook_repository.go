@@ -0,0 +1,246 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"certificate-ledger/domain"
+)
+
+type WebhookRepository struct {
+	s *store
+}
+
+func (r *WebhookRepository) SaveSubscription(ctx context.Context, sub *domain.WebhookSubscription) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.webhookSubscriptions[sub.ID]; ok {
+		return domain.Conflict("webhook_exists", "webhook with ID %s already exists", sub.ID)
+	}
+	r.s.webhookSubscriptions[sub.ID] = copyWebhookSubscription(sub)
+	return nil
+}
+
+func (r *WebhookRepository) FindSubscription(ctx context.Context, id string) (*domain.WebhookSubscription, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	sub, ok := r.s.webhookSubscriptions[id]
+	if !ok {
+		return nil, domain.NotFound("webhook_not_found", "webhook with ID %s not found", id)
+	}
+	return copyWebhookSubscription(sub), nil
+}
+
+func (r *WebhookRepository) FindSubscriptionsByOwner(ctx context.Context, ownerID string) ([]*domain.WebhookSubscription, error) {
+	return r.findSubscriptions(func(sub *domain.WebhookSubscription) bool { return sub.OwnerID == ownerID }), nil
+}
+
+func (r *WebhookRepository) FindSubscriptionsForIssuer(ctx context.Context, issuerID string) ([]*domain.WebhookSubscription, error) {
+	return r.findSubscriptions(func(sub *domain.WebhookSubscription) bool {
+		owner, ok := r.s.users[sub.OwnerID]
+		return sub.OwnerID == issuerID || (ok && owner.Role == domain.RoleAdmin)
+	}), nil
+}
+
+func (r *WebhookRepository) FindAdminSubscriptions(ctx context.Context) ([]*domain.WebhookSubscription, error) {
+	return r.findSubscriptions(func(sub *domain.WebhookSubscription) bool {
+		owner, ok := r.s.users[sub.OwnerID]
+		return ok && owner.Role == domain.RoleAdmin
+	}), nil
+}
+
+func (r *WebhookRepository) DeleteSubscription(ctx context.Context, id string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.webhookSubscriptions[id]; !ok {
+		return domain.NotFound("webhook_not_found", "webhook with ID %s not found", id)
+	}
+	r.s.deleteWebhookSubscription(id)
+	return nil
+}
+
+func (r *WebhookRepository) SaveDelivery(ctx context.Context, d *domain.WebhookDelivery) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.webhookSubscriptions[d.SubscriptionID]; !ok {
+		return domain.NotFound("webhook_not_found", "webhook with ID %s not found", d.SubscriptionID)
+	}
+	stored := *d
+	r.s.webhookDeliveries[d.ID] = &stored
+	return nil
+}
+
+func (r *WebhookRepository) FindDelivery(ctx context.Context, id string) (*domain.WebhookDelivery, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	d, ok := r.s.webhookDeliveries[id]
+	if !ok {
+		return nil, domain.NotFound("webhook_delivery_not_found", "webhook delivery with ID %s not found", id)
+	}
+	found := *d
+	return &found, nil
+}
+
+func (r *WebhookRepository) FindDeliveries(ctx context.Context, subscriptionID, status string, limit int) ([]*domain.WebhookDelivery, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	deliveries := []*domain.WebhookDelivery{}
+	newestFirst := func(a, b *domain.WebhookDelivery) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID > b.ID
+	}
+	for _, d := range values(r.s.webhookDeliveries, newestFirst) {
+		if len(deliveries) == limit {
+			break
+		}
+		if d.SubscriptionID == subscriptionID && (status == "" || d.Status == status) {
+			found := *d
+			deliveries = append(deliveries, &found)
+		}
+	}
+	return deliveries, nil
+}
+
+func (r *WebhookRepository) FindDueDeliveries(ctx context.Context, now time.Time, limit int) ([]*domain.WebhookDelivery, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	deliveries := []*domain.WebhookDelivery{}
+	oldestFirst := func(a, b *domain.WebhookDelivery) bool {
+		if !a.NextAttemptAt.Equal(b.NextAttemptAt) {
+			return a.NextAttemptAt.Before(b.NextAttemptAt)
+		}
+		return a.ID < b.ID
+	}
+	for _, d := range values(r.s.webhookDeliveries, oldestFirst) {
+		if len(deliveries) == limit {
+			break
+		}
+		if d.Status == domain.WebhookDeliveryPending && !d.NextAttemptAt.After(now) {
+			found := *d
+			deliveries = append(deliveries, &found)
+		}
+	}
+	return deliveries, nil
+}
+
+func (r *WebhookRepository) ClaimDelivery(ctx context.Context, id string, now, leaseUntil time.Time) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	d, ok := r.s.webhookDeliveries[id]
+	if !ok || d.Status != domain.WebhookDeliveryPending || d.NextAttemptAt.After(now) {
+		return false, nil
+	}
+	d.NextAttemptAt = leaseUntil
+	return true, nil
+}
+
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, d *domain.WebhookDelivery, a *domain.WebhookAttempt) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.webhookDeliveries[d.ID]
+	if !ok {
+		return domain.NotFound("webhook_delivery_not_found", "webhook delivery with ID %s not found", d.ID)
+	}
+	stored.Status = d.Status
+	stored.Attempts = d.Attempts
+	stored.NextAttemptAt = d.NextAttemptAt
+	stored.LastStatusCode = d.LastStatusCode
+	stored.LastError = d.LastError
+	stored.DeliveredAt = d.DeliveredAt
+
+	attempt := *a
+	r.s.webhookAttempts[d.ID] = append(r.s.webhookAttempts[d.ID], &attempt)
+	return nil
+}
+
+func (r *WebhookRepository) FindAttempts(ctx context.Context, deliveryID string) ([]*domain.WebhookAttempt, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	attempts := []*domain.WebhookAttempt{}
+	for _, a := range r.s.webhookAttempts[deliveryID] {
+		found := *a
+		attempts = append(attempts, &found)
+	}
+	return attempts, nil
+}
+
+func (r *WebhookRepository) Replay(ctx context.Context, id string, now time.Time) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	d, ok := r.s.webhookDeliveries[id]
+	if !ok || d.Status == domain.WebhookDeliveryPending {
+		return false, nil
+	}
+	replay(d, now)
+	return true, nil
+}
+
+func (r *WebhookRepository) ReplayDead(ctx context.Context, subscriptionID string, now time.Time) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var replayed int64
+	for _, d := range r.s.webhookDeliveries {
+		if d.SubscriptionID == subscriptionID && d.Status == domain.WebhookDeliveryDead {
+			replay(d, now)
+			replayed++
+		}
+	}
+	return replayed, nil
+}
+
+func (r *WebhookRepository) findSubscriptions(match func(*domain.WebhookSubscription) bool) []*domain.WebhookSubscription {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	subs := []*domain.WebhookSubscription{}
+	oldestFirst := func(a, b *domain.WebhookSubscription) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	}
+	for _, sub := range values(r.s.webhookSubscriptions, oldestFirst) {
+		if match(sub) {
+			subs = append(subs, copyWebhookSubscription(sub))
+		}
+	}
+	return subs
+}
+
+// deleteWebhookSubscription removes a subscription with its deliveries and
+// their logs. The caller holds the lock.
+func (s *store) deleteWebhookSubscription(id string) {
+	delete(s.webhookSubscriptions, id)
+	for deliveryID, d := range s.webhookDeliveries {
+		if d.SubscriptionID == id {
+			delete(s.webhookDeliveries, deliveryID)
+			delete(s.webhookAttempts, deliveryID)
+		}
+	}
+}
+
+func replay(d *domain.WebhookDelivery, now time.Time) {
+	d.Status = domain.WebhookDeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = now
+}
+
+func copyWebhookSubscription(sub *domain.WebhookSubscription) *domain.WebhookSubscription {
+	c := *sub
+	c.Events = cloneStrings(sub.Events)
+	return &c
+}
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"certificate-ledger/domain"
+)
+
+const notificationColumns = `id, certificate_id, template, recipient, locale, subject, text_body, html_body, status, attempts, next_attempt_at, last_error, created_at, sent_at`
+
+type SQLNotificationRepository struct {
+	db *sql.DB
+}
+
+func NewSQLNotificationRepository(db *sql.DB) *SQLNotificationRepository {
+	return &SQLNotificationRepository{db: db}
+}
+
+func (r *SQLNotificationRepository) Save(ctx context.Context, n *domain.Notification) error {
+	query := `INSERT INTO notifications (` + notificationColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query,
+		n.ID,
+		nullString(n.CertificateID),
+		n.Template,
+		n.Recipient,
+		n.Locale,
+		n.Subject,
+		n.TextBody,
+		n.HTMLBody,
+		n.Status,
+		n.Attempts,
+		n.NextAttemptAt,
+		n.LastError,
+		n.CreatedAt,
+		n.SentAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save notification: %v", err)
+	}
+	return nil
+}
+
+func (r *SQLNotificationRepository) FindByID(ctx context.Context, id string) (*domain.Notification, error) {
+	query := `SELECT ` + notificationColumns + ` FROM notifications WHERE id = ?`
+	n, err := scanNotification(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, domain.NotFound("notification_not_found", "notification with ID %s not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find notification: %v", err)
+	}
+	return n, nil
+}
+
+func (r *SQLNotificationRepository) FindByStatus(ctx context.Context, status string, limit int) ([]*domain.Notification, error) {
+	query := `SELECT ` + notificationColumns + ` FROM notifications`
+	var args []interface{}
+	if status != "" {
+		query += ` WHERE status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	return r.query(ctx, query, append(args, limit)...)
+}
+
+func (r *SQLNotificationRepository) FindDue(ctx context.Context, now time.Time, limit int) ([]*domain.Notification, error) {
+	query := `SELECT ` + notificationColumns + ` FROM notifications
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY next_attempt_at, id LIMIT ?`
+	return r.query(ctx, query, domain.NotificationPending, now, limit)
+}
+
+func (r *SQLNotificationRepository) Claim(ctx context.Context, id string, now, leaseUntil time.Time) (bool, error) {
+	query := `UPDATE notifications SET next_attempt_at = ? WHERE id = ? AND status = ? AND next_attempt_at <= ?`
+	result, err := r.db.ExecContext(ctx, query, leaseUntil, id, domain.NotificationPending, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim notification: %v", err)
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (r *SQLNotificationRepository) Update(ctx context.Context, n *domain.Notification) error {
+	query := `UPDATE notifications SET text_body = ?, html_body = ?, status = ?, attempts = ?, next_attempt_at = ?, last_error = ?, sent_at = ? WHERE id = ?`
+	if _, err := r.db.ExecContext(ctx, query, n.TextBody, n.HTMLBody, n.Status, n.Attempts, n.NextAttemptAt, n.LastError, n.SentAt, n.ID); err != nil {
+		return fmt.Errorf("failed to update notification: %v", err)
+	}
+	return nil
+}
+
+func (r *SQLNotificationRepository) Retry(ctx context.Context, id string, now time.Time) (bool, error) {
+	query := `UPDATE notifications SET status = ?, attempts = 0, next_attempt_at = ? WHERE id = ? AND status = ?`
+	result, err := r.db.ExecContext(ctx, query, domain.NotificationPending, now, id, domain.NotificationFailed)
+	if err != nil {
+		return false, fmt.Errorf("failed to retry notification: %v", err)
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (r *SQLNotificationRepository) query(ctx context.Context, query string, args ...interface{}) ([]*domain.Notification, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notifications: %v", err)
+	}
+	defer rows.Close()
+
+	notifications := []*domain.Notification{}
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %v", err)
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+func scanNotification(row rowScanner) (*domain.Notification, error) {
+	var n domain.Notification
+	var certificateID sql.NullString
+	var sentAt sql.NullTime
+	err := row.Scan(
+		&n.ID,
+		&certificateID,
+		&n.Template,
+		&n.Recipient,
+		&n.Locale,
+		&n.Subject,
+		&n.TextBody,
+		&n.HTMLBody,
+		&n.Status,
+		&n.Attempts,
+		&n.NextAttemptAt,
+		&n.LastError,
+		&n.CreatedAt,
+		&sentAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	n.CertificateID = certificateID.String
+	n.SentAt = nullTimePtr(sentAt)
+	return &n, nil
+}
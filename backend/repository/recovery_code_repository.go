@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SQLRecoveryCodeRepository struct {
+	db *sql.DB
+}
+
+func NewSQLRecoveryCodeRepository(db *sql.DB) *SQLRecoveryCodeRepository {
+	return &SQLRecoveryCodeRepository{db: db}
+}
+
+// ReplaceForUser discards every existing recovery code of the user and stores the given hashes.
+func (r *SQLRecoveryCodeRepository) ReplaceForUser(ctx context.Context, userID string, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %v", err)
+	}
+
+	now := time.Now()
+	for _, hash := range codeHashes {
+		query := `INSERT INTO recovery_codes (id, user_id, code_hash, used_at, created_at) VALUES (?, ?, ?, NULL, ?)`
+		if _, err := tx.ExecContext(ctx, query, uuid.New().String(), userID, hash, now); err != nil {
+			return fmt.Errorf("failed to save recovery code: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit recovery codes: %v", err)
+	}
+	return nil
+}
+
+// Consume marks an unused recovery code as used. It reports false if no such code exists.
+func (r *SQLRecoveryCodeRepository) Consume(ctx context.Context, userID, codeHash string) (bool, error) {
+	query := `UPDATE recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, time.Now(), userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to consume recovery code: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check rows affected: %v", err)
+	}
+	return rowsAffected == 1, nil
+}
+
+func (r *SQLRecoveryCodeRepository) DeleteForUser(ctx context.Context, userID string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"certificate-ledger/blockchain"
+	"certificate-ledger/domain"
+)
+
+// The interfaces below are what services depend on. The SQL types in this
+// package implement them for MySQL and SQLite; package memory implements them
+// without a database. Every method takes the caller's context, which the SQL
+// types hand to the driver so a cancelled request stops its queries.
+
+type CertificateRepository interface {
+	Save(ctx context.Context, cert *domain.Certificate) error
+	FindFieldSalts(ctx context.Context, id string) (map[string]string, error)
+	FindByID(ctx context.Context, id string) (*domain.Certificate, error)
+	FindByHash(ctx context.Context, hash string) (*domain.Certificate, error)
+	FindAll(ctx context.Context) ([]*domain.Certificate, error)
+	FindByIssuerID(ctx context.Context, userID string) ([]*domain.Certificate, error)
+	FindByRecipientEmail(ctx context.Context, email string) ([]*domain.Certificate, error)
+	FindByRecipient(ctx context.Context, userID, email string) ([]*domain.Certificate, error)
+	Claim(ctx context.Context, id, userID string, at time.Time) (bool, error)
+	ClaimByEmail(ctx context.Context, userID, email string, at time.Time) (int64, error)
+	Revoke(ctx context.Context, id, reason string, at time.Time) (bool, error)
+	UpdateStatus(ctx context.Context, id, from, to string) (bool, error)
+	FindPage(ctx context.Context, q domain.CertificateQuery) (*domain.CertificatePage, error)
+}
+
+type UserRepository interface {
+	Save(ctx context.Context, user *domain.User) error
+	FindByID(ctx context.Context, id string) (*domain.User, error)
+	FindByEmail(ctx context.Context, email string) (*domain.User, error)
+	FindAll(ctx context.Context) ([]*domain.User, error)
+	Delete(ctx context.Context, id string) error
+}
+
+type APIKeyRepository interface {
+	Save(ctx context.Context, key *domain.APIKey) error
+	FindByID(ctx context.Context, id string) (*domain.APIKey, error)
+	FindByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error)
+	FindByUserID(ctx context.Context, userID string) ([]*domain.APIKey, error)
+	Revoke(ctx context.Context, id string, at time.Time) error
+	// RevokeForUser revokes every active key of the user.
+	RevokeForUser(ctx context.Context, userID string, at time.Time) error
+	UpdateLastUsed(ctx context.Context, id string, at time.Time) error
+}
+
+type RecoveryCodeRepository interface {
+	ReplaceForUser(ctx context.Context, userID string, codeHashes []string) error
+	Consume(ctx context.Context, userID, codeHash string) (bool, error)
+	DeleteForUser(ctx context.Context, userID string) error
+}
+
+type UserTokenRepository interface {
+	Save(ctx context.Context, token *domain.UserToken) error
+	FindByID(ctx context.Context, id string) (*domain.UserToken, error)
+	Consume(ctx context.Context, id string, now time.Time) (bool, error)
+	InvalidateForUser(ctx context.Context, userID, purpose string, now time.Time) error
+}
+
+// LoginThrottleRepository keeps failed-login counters. Find returns nil
+// without an error when there is no counter for the key.
+type LoginThrottleRepository interface {
+	Find(ctx context.Context, scope, key string) (*domain.LoginThrottle, error)
+	RecordFailure(ctx context.Context, scope, key string, now, windowStart time.Time) (*domain.LoginThrottle, error)
+	Lock(ctx context.Context, scope, key string, threshold int, now, until time.Time) (bool, error)
+	// Forgive takes one failure back from a counter.
+	Forgive(ctx context.Context, scope, key string) error
+	Delete(ctx context.Context, scope, key string) (bool, error)
+	FindLocked(ctx context.Context, now time.Time) ([]*domain.LoginThrottle, error)
+	SaveEvent(ctx context.Context, event *domain.LockoutEvent) error
+	FindEvents(ctx context.Context, limit int) ([]*domain.LockoutEvent, error)
+}
+
+type IdentityRepository interface {
+	Save(ctx context.Context, identity *domain.UserIdentity) error
+	FindBySubject(ctx context.Context, issuer, subject string) (*domain.UserIdentity, error)
+}
+
+type ShareLinkRepository interface {
+	Save(ctx context.Context, link *domain.ShareLink) error
+	FindByID(ctx context.Context, id string) (*domain.ShareLink, error)
+	FindByCertificate(ctx context.Context, certificateID, ownerID string) ([]*domain.ShareLink, error)
+	RecordView(ctx context.Context, id string, now time.Time) (bool, error)
+	Revoke(ctx context.Context, id string, at time.Time) error
+}
+
+type BulkIssueRepository interface {
+	SaveJob(ctx context.Context, job *domain.BulkIssueJob, rows []*domain.BulkIssueRow) error
+	UpdateJob(ctx context.Context, job *domain.BulkIssueJob) error
+	UpdateRow(ctx context.Context, row *domain.BulkIssueRow) error
+	FindJob(ctx context.Context, id string) (*domain.BulkIssueJob, error)
+	FindJobsByIssuer(ctx context.Context, issuerID string) ([]*domain.BulkIssueJob, error)
+	FindUnfinishedJobs(ctx context.Context) ([]*domain.BulkIssueJob, error)
+	FindRows(ctx context.Context, jobID, status string) ([]*domain.BulkIssueRow, error)
+	// ClaimJob takes or renews the lease on an unfinished job for owner
+	// until leaseUntil. It reports false when another owner's lease has not
+	// run out yet.
+	ClaimJob(ctx context.Context, id, owner string, now, leaseUntil time.Time) (bool, error)
+}
+
+// AuditRepository stores the append-only audit log; there is no way to change
+// or remove an entry. Append fails with a Conflict when the entry's Seq is
+// taken, so a writer that lost a race can retry on the new head. Last
+// returns nil without an error when the log is empty, and Walk visits every
+// entry in Seq order.
+type AuditRepository interface {
+	Append(ctx context.Context, entry *domain.AuditEntry) error
+	Last(ctx context.Context) (*domain.AuditEntry, error)
+	FindPage(ctx context.Context, q domain.AuditQuery) (*domain.AuditPage, error)
+	Walk(ctx context.Context, fn func(*domain.AuditEntry) error) error
+}
+
+// WebhookRepository stores subscriptions and their deliveries. Deleting a
+// subscription deletes its deliveries, and deleting a delivery its log.
+type WebhookRepository interface {
+	SaveSubscription(ctx context.Context, sub *domain.WebhookSubscription) error
+	FindSubscription(ctx context.Context, id string) (*domain.WebhookSubscription, error)
+	FindSubscriptionsByOwner(ctx context.Context, ownerID string) ([]*domain.WebhookSubscription, error)
+	// FindSubscriptionsForIssuer returns the subscriptions owned by the
+	// issuer or by an admin.
+	FindSubscriptionsForIssuer(ctx context.Context, issuerID string) ([]*domain.WebhookSubscription, error)
+	// FindAdminSubscriptions returns the subscriptions owned by an admin.
+	FindAdminSubscriptions(ctx context.Context) ([]*domain.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id string) error
+
+	SaveDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error
+	FindDelivery(ctx context.Context, id string) (*domain.WebhookDelivery, error)
+	// FindDeliveries lists a subscription's deliveries newest first,
+	// optionally only those with the given status.
+	FindDeliveries(ctx context.Context, subscriptionID, status string, limit int) ([]*domain.WebhookDelivery, error)
+	// FindDueDeliveries returns pending deliveries whose next attempt is due, oldest first.
+	FindDueDeliveries(ctx context.Context, now time.Time, limit int) ([]*domain.WebhookDelivery, error)
+	// ClaimDelivery pushes a due delivery's next attempt to leaseUntil and
+	// reports whether it did, so only one worker sends it. If the worker dies
+	// the delivery becomes due again when the lease runs out.
+	ClaimDelivery(ctx context.Context, id string, now, leaseUntil time.Time) (bool, error)
+	// RecordAttempt stores the outcome of an attempt: the delivery's new
+	// state and an entry in its log.
+	RecordAttempt(ctx context.Context, delivery *domain.WebhookDelivery, attempt *domain.WebhookAttempt) error
+	FindAttempts(ctx context.Context, deliveryID string) ([]*domain.WebhookAttempt, error)
+	// Replay makes a delivery that is not pending due again with a fresh
+	// attempt budget and reports whether it did. ReplayDead does the same for
+	// every dead delivery of a subscription and returns how many there were.
+	Replay(ctx context.Context, id string, now time.Time) (bool, error)
+	ReplayDead(ctx context.Context, subscriptionID string, now time.Time) (int64, error)
+}
+
+// NotificationRepository stores the emails queued for certificate recipients.
+type NotificationRepository interface {
+	Save(ctx context.Context, n *domain.Notification) error
+	FindByID(ctx context.Context, id string) (*domain.Notification, error)
+	// FindByStatus lists notifications newest first, optionally only those
+	// with the given status.
+	FindByStatus(ctx context.Context, status string, limit int) ([]*domain.Notification, error)
+	// FindDue returns pending notifications whose next attempt is due, oldest first.
+	FindDue(ctx context.Context, now time.Time, limit int) ([]*domain.Notification, error)
+	// Claim pushes a due notification's next attempt to leaseUntil and
+	// reports whether it did, so only one worker sends it.
+	Claim(ctx context.Context, id string, now, leaseUntil time.Time) (bool, error)
+	// Update stores the outcome of an attempt.
+	Update(ctx context.Context, n *domain.Notification) error
+	// Retry makes a failed notification due again with a fresh attempt
+	// budget and reports whether it did.
+	Retry(ctx context.Context, id string, now time.Time) (bool, error)
+}
+
+// IntegrityScanRepository stores the results of integrity scans.
+type IntegrityScanRepository interface {
+	Save(ctx context.Context, scan *domain.IntegrityScan) error
+	FindByID(ctx context.Context, id string) (*domain.IntegrityScan, error)
+	// FindRecent lists scans newest first.
+	FindRecent(ctx context.Context, limit int) ([]*domain.IntegrityScan, error)
+}
+
+// AnchorRepository stores the time-stamps of the chain's head.
+type AnchorRepository interface {
+	Save(ctx context.Context, anchor *domain.Anchor) error
+	FindByID(ctx context.Context, id string) (*domain.Anchor, error)
+	// FindRecent lists anchors newest first.
+	FindRecent(ctx context.Context, limit int) ([]*domain.Anchor, error)
+	// FindCovering lists the anchors of blocks at or above blockIndex that
+	// were made at or after since, oldest first.
+	FindCovering(ctx context.Context, blockIndex int, since time.Time, limit int) ([]*domain.Anchor, error)
+}
+
+// CheckpointRepository stores checkpoints of the chain with their witness
+// signatures.
+type CheckpointRepository interface {
+	Save(ctx context.Context, checkpoint *domain.Checkpoint) error
+	FindByID(ctx context.Context, id string) (*domain.Checkpoint, error)
+	// FindRecent lists checkpoints newest first.
+	FindRecent(ctx context.Context, limit int) ([]*domain.Checkpoint, error)
+	// FindByOrigin lists the checkpoints made under origin by height,
+	// whatever genesis block they start from.
+	FindByOrigin(ctx context.Context, origin string) ([]*domain.Checkpoint, error)
+}
+
+// WitnessedChainRepository stores, when this server is a witness, the last
+// checkpoint it co-signed for each origin. Find returns nil without an error
+// for an origin it never co-signed.
+type WitnessedChainRepository interface {
+	Find(ctx context.Context, origin string) (*domain.WitnessedChain, error)
+	Save(ctx context.Context, chain *domain.WitnessedChain) error
+}
+
+// BlockRepository stores the blocks of the chain; it is the chain's
+// blockchain.Store.
+type BlockRepository interface {
+	FindAll(ctx context.Context) ([]*blockchain.Block, error)
+	Append(ctx context.Context, block *blockchain.Block) error
+}
+
+// Repositories bundles one implementation of every repository, so a storage
+// backend can be chosen in one place.
+type Repositories struct {
+	Certificates   CertificateRepository
+	Users          UserRepository
+	APIKeys        APIKeyRepository
+	RecoveryCodes  RecoveryCodeRepository
+	UserTokens     UserTokenRepository
+	LoginThrottles LoginThrottleRepository
+	Identities     IdentityRepository
+	ShareLinks     ShareLinkRepository
+	BulkIssues     BulkIssueRepository
+	Audit          AuditRepository
+	Webhooks       WebhookRepository
+	Notifications  NotificationRepository
+	IntegrityScans IntegrityScanRepository
+	Anchors        AnchorRepository
+	Checkpoints    CheckpointRepository
+	Witnessed      WitnessedChainRepository
+	Blocks         BlockRepository
+}
+
+// Dialect selects the SQL flavour for the few statements that differ between
+// MySQL and SQLite.
+type Dialect string
+
+const (
+	DialectMySQL  Dialect = "mysql"
+	DialectSQLite Dialect = "sqlite3"
+)
+
+// upsert returns the clause that turns an INSERT into an update of the row
+// that conflicts on key. It is followed by "column = ?" assignments.
+func (d Dialect) upsert(key string) string {
+	if d == DialectSQLite {
+		return "ON CONFLICT (" + key + ") DO UPDATE SET"
+	}
+	return "ON DUPLICATE KEY UPDATE"
+}
+
+// NewSQLRepositories returns the SQL implementations of every repository.
+func NewSQLRepositories(db *sql.DB, dialect Dialect) *Repositories {
+	return &Repositories{
+		Certificates:   NewSQLCertificateRepository(db),
+		Users:          NewSQLUserRepository(db, dialect),
+		APIKeys:        NewSQLAPIKeyRepository(db),
+		RecoveryCodes:  NewSQLRecoveryCodeRepository(db),
+		UserTokens:     NewSQLUserTokenRepository(db),
+		LoginThrottles: NewSQLLoginThrottleRepository(db, dialect),
+		Identities:     NewSQLIdentityRepository(db, dialect),
+		ShareLinks:     NewSQLShareLinkRepository(db),
+		BulkIssues:     NewSQLBulkIssueRepository(db),
+		Audit:          NewSQLAuditRepository(db),
+		Webhooks:       NewSQLWebhookRepository(db),
+		Notifications:  NewSQLNotificationRepository(db),
+		IntegrityScans: NewSQLIntegrityScanRepository(db),
+		Anchors:        NewSQLAnchorRepository(db),
+		Checkpoints:    NewSQLCheckpointRepository(db),
+		Witnessed:      NewSQLWitnessedChainRepository(db, dialect),
+		Blocks:         NewSQLBlockRepository(db),
+	}
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"certificate-ledger/domain"
+)
+
+type SQLShareLinkRepository struct {
+	db *sql.DB
+}
+
+func NewSQLShareLinkRepository(db *sql.DB) *SQLShareLinkRepository {
+	return &SQLShareLinkRepository{db: db}
+}
+
+func (r *SQLShareLinkRepository) Save(ctx context.Context, link *domain.ShareLink) error {
+	query := `
+		INSERT INTO share_links (id, certificate_id, owner_id, fields, expires_at, revoked_at, view_count, last_viewed_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query,
+		link.ID,
+		link.CertificateID,
+		link.OwnerID,
+		strings.Join(link.Fields, ","),
+		link.ExpiresAt,
+		link.RevokedAt,
+		link.ViewCount,
+		link.LastViewedAt,
+		link.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save share link: %v", err)
+	}
+	return nil
+}
+
+func (r *SQLShareLinkRepository) FindByID(ctx context.Context, id string) (*domain.ShareLink, error) {
+	query := `SELECT id, certificate_id, owner_id, fields, expires_at, revoked_at, view_count, last_viewed_at, created_at
+	          FROM share_links WHERE id = ?`
+	link, err := scanShareLink(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, domain.NotFound("share_link_not_found", "share link not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find share link: %v", err)
+	}
+	return link, nil
+}
+
+func (r *SQLShareLinkRepository) FindByCertificate(ctx context.Context, certificateID, ownerID string) ([]*domain.ShareLink, error) {
+	query := `SELECT id, certificate_id, owner_id, fields, expires_at, revoked_at, view_count, last_viewed_at, created_at
+	          FROM share_links WHERE certificate_id = ? AND owner_id = ? ORDER BY created_at DESC`
+	rows, err := r.db.QueryContext(ctx, query, certificateID, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query share links: %v", err)
+	}
+	defer rows.Close()
+
+	var links []*domain.ShareLink
+	for rows.Next() {
+		link, err := scanShareLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan share link: %v", err)
+		}
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+// RecordView counts a view of an active link. It reports false if the link is revoked or expired.
+func (r *SQLShareLinkRepository) RecordView(ctx context.Context, id string, now time.Time) (bool, error) {
+	query := `
+		UPDATE share_links SET view_count = view_count + 1, last_viewed_at = ?
+		WHERE id = ? AND revoked_at IS NULL AND expires_at > ?`
+	result, err := r.db.ExecContext(ctx, query, now, id, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to record share link view: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check rows affected: %v", err)
+	}
+	return rowsAffected == 1, nil
+}
+
+func (r *SQLShareLinkRepository) Revoke(ctx context.Context, id string, at time.Time) error {
+	query := `UPDATE share_links SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, at, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke share link: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return domain.NotFound("share_link_not_found", "share link not found or already revoked")
+	}
+	return nil
+}
+
+func scanShareLink(row rowScanner) (*domain.ShareLink, error) {
+	var link domain.ShareLink
+	var fields string
+	var revokedAt, lastViewedAt sql.NullTime
+	if err := row.Scan(
+		&link.ID,
+		&link.CertificateID,
+		&link.OwnerID,
+		&fields,
+		&link.ExpiresAt,
+		&revokedAt,
+		&link.ViewCount,
+		&lastViewedAt,
+		&link.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if fields != "" {
+		link.Fields = strings.Split(fields, ",")
+	}
+	link.RevokedAt = nullTimePtr(revokedAt)
+	link.LastViewedAt = nullTimePtr(lastViewedAt)
+	return &link, nil
+}
@@ -0,0 +1,316 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"certificate-ledger/blockchain"
+	"certificate-ledger/db"
+	"certificate-ledger/domain"
+)
+
+// newSQLiteRepositories returns the SQL repositories over a fresh, migrated
+// SQLite database.
+func newSQLiteRepositories(t *testing.T) *Repositories {
+	t.Helper()
+	conn, err := db.NewSQLiteDB(filepath.Join(t.TempDir(), "ledger.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	migrator, err := db.NewMigrator(conn, db.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("migrations: %v", err)
+	}
+	return NewSQLRepositories(conn, DialectSQLite)
+}
+
+func saveUser(t *testing.T, repos *Repositories, id, email string) *domain.User {
+	t.Helper()
+	user := &domain.User{ID: id, Name: "User " + id, Email: email, Password: "hash", Role: domain.RoleIssuer}
+	if err := repos.Users.Save(context.Background(), user); err != nil {
+		t.Fatalf("save user %s: %v", id, err)
+	}
+	return user
+}
+
+func TestSQLiteUserSaveUpdatesExistingRow(t *testing.T) {
+	ctx := context.Background()
+	repos := newSQLiteRepositories(t)
+	user := saveUser(t, repos, "u1", "a@example.com")
+
+	user.Name = "Renamed"
+	user.TOTPEnabled = true
+	if err := repos.Users.Save(ctx, user); err != nil {
+		t.Fatalf("second save: %v", err)
+	}
+
+	got, err := repos.Users.FindByID(ctx, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Renamed" || !got.TOTPEnabled {
+		t.Errorf("got name %q, totp %v; want the second save's values", got.Name, got.TOTPEnabled)
+	}
+	all, err := repos.Users.FindAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 {
+		t.Errorf("%d users stored, want 1", len(all))
+	}
+}
+
+func TestSQLiteUserDuplicateEmailConflicts(t *testing.T) {
+	repos := newSQLiteRepositories(t)
+	saveUser(t, repos, "u1", "a@example.com")
+
+	err := repos.Users.Save(context.Background(), &domain.User{ID: "u2", Name: "Other", Email: "a@example.com", Password: "hash", Role: domain.RoleUser})
+	if !errors.Is(err, domain.ErrConflict) {
+		t.Fatalf("got %v, want a conflict", err)
+	}
+}
+
+func TestSQLiteBlockAppendDuplicateConflicts(t *testing.T) {
+	ctx := context.Background()
+	repos := newSQLiteRepositories(t)
+	block := &blockchain.Block{Index: 0, Timestamp: time.Unix(0, 1234567891).UTC(), Data: []byte("genesis"), PreviousHash: "0", Hash: "h0"}
+	if err := repos.Blocks.Append(ctx, block); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repos.Blocks.Append(ctx, block); !errors.Is(err, domain.ErrConflict) {
+		t.Fatalf("got %v, want a conflict", err)
+	}
+	blocks, err := repos.Blocks.FindAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 1 || !blocks[0].Timestamp.Equal(block.Timestamp) {
+		t.Errorf("got %+v, want the block once with its timestamp to the nanosecond", blocks)
+	}
+}
+
+func TestSQLiteLoginThrottleCountsAndRestarts(t *testing.T) {
+	ctx := context.Background()
+	repos := newSQLiteRepositories(t)
+	now := time.Now().UTC()
+
+	for i, want := range []int{1, 2, 3} {
+		throttle, err := repos.LoginThrottles.RecordFailure(ctx, "login", "a@example.com", now.Add(time.Duration(i)*time.Second), now.Add(-time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if throttle.Failures != want {
+			t.Fatalf("failure %d: counter is %d, want %d", i+1, throttle.Failures, want)
+		}
+	}
+	if err := repos.LoginThrottles.Forgive(ctx, "login", "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if throttle, _ := repos.LoginThrottles.Find(ctx, "login", "a@example.com"); throttle.Failures != 2 {
+		t.Errorf("counter is %d after forgiving one failure, want 2", throttle.Failures)
+	}
+
+	// A failure after the window restarts the count.
+	later := now.Add(time.Hour)
+	throttle, err := repos.LoginThrottles.RecordFailure(ctx, "login", "a@example.com", later, later.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if throttle.Failures != 1 {
+		t.Errorf("counter is %d after the window, want 1", throttle.Failures)
+	}
+}
+
+func TestSQLiteLoginThrottleLocksOnce(t *testing.T) {
+	ctx := context.Background()
+	repos := newSQLiteRepositories(t)
+	now := time.Now().UTC()
+	for i := 0; i < 3; i++ {
+		if _, err := repos.LoginThrottles.RecordFailure(ctx, "ip", "192.0.2.1", now, now.Add(-time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if started, err := repos.LoginThrottles.Lock(ctx, "ip", "192.0.2.1", 4, now, now.Add(time.Minute)); err != nil || started {
+		t.Fatalf("below the threshold: started %v, %v", started, err)
+	}
+	if started, err := repos.LoginThrottles.Lock(ctx, "ip", "192.0.2.1", 3, now, now.Add(time.Minute)); err != nil || !started {
+		t.Fatalf("at the threshold: started %v, %v", started, err)
+	}
+	if started, _ := repos.LoginThrottles.Lock(ctx, "ip", "192.0.2.1", 0, now, now.Add(time.Minute)); started {
+		t.Error("a second lockout started while the first is active")
+	}
+	locked, err := repos.LoginThrottles.FindLocked(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locked) != 1 || locked[0].Key != "192.0.2.1" || locked[0].Failures != 0 {
+		t.Errorf("locked %+v, want the IP with its counter reset", locked)
+	}
+}
+
+// saveCertificate stores the i-th certificate, issued by "issuer" under title.
+func saveCertificate(t *testing.T, repos *Repositories, i int, title string) *domain.Certificate {
+	t.Helper()
+	cert := &domain.Certificate{
+		ID:               fmt.Sprintf("CERT-%03d", i),
+		Hash:             fmt.Sprintf("hash-%03d", i),
+		RecipientName:    "Recipient",
+		RecipientEmail:   "r@example.com",
+		CertificateTitle: title,
+		IssueDate:        time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		IssuerID:         "issuer",
+		IssuerName:       "Issuer",
+		BlockNumber:      i + 1,
+		Timestamp:        time.Now().UTC(),
+		Status:           domain.CertificateStatusActive,
+	}
+	if err := repos.Certificates.Save(context.Background(), cert); err != nil {
+		t.Fatalf("save %q: %v", title, err)
+	}
+	return cert
+}
+
+func TestSQLiteWitnessedChainSaveUpdatesOrigin(t *testing.T) {
+	ctx := context.Background()
+	repos := newSQLiteRepositories(t)
+	chain := &domain.WitnessedChain{Origin: "ledger", GenesisHash: "g", Height: 1, HeadHash: "h1", SignedAt: time.Now().UTC()}
+	if err := repos.Witnessed.Save(ctx, chain); err != nil {
+		t.Fatal(err)
+	}
+	chain.Height, chain.HeadHash = 5, "h5"
+	if err := repos.Witnessed.Save(ctx, chain); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := repos.Witnessed.Find(ctx, "ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Height != 5 || got.HeadHash != "h5" {
+		t.Errorf("got %+v, want height 5 with head h5", got)
+	}
+	if missing, err := repos.Witnessed.Find(ctx, "other"); err != nil || missing != nil {
+		t.Errorf("unknown origin: got %+v, %v; want nil, nil", missing, err)
+	}
+}
+
+func TestSQLiteCertificateTitleFilterMatchesWildcardsLiterally(t *testing.T) {
+	ctx := context.Background()
+	repos := newSQLiteRepositories(t)
+	saveUser(t, repos, "issuer", "issuer@example.com")
+
+	titles := []string{"100% Attendance", "1000 Attendance", "Data_Science", "DataXScience", "Wow! Award", "Wow Award"}
+	for i, title := range titles {
+		saveCertificate(t, repos, i, title)
+	}
+
+	for filter, want := range map[string]string{
+		"100%":  "100% Attendance",
+		"a_s":   "Data_Science",
+		"wow! ": "Wow! Award",
+	} {
+		page, err := repos.Certificates.FindPage(ctx, domain.CertificateQuery{
+			Filter: domain.CertificateFilter{Title: filter},
+			Sort:   domain.CertificateSortTitle,
+			Order:  domain.SortAscending,
+			Limit:  10,
+		})
+		if err != nil {
+			t.Fatalf("filter %q: %v", filter, err)
+		}
+		if page.Total != 1 || len(page.Items) != 1 || page.Items[0].CertificateTitle != want {
+			var got []string
+			for _, cert := range page.Items {
+				got = append(got, cert.CertificateTitle)
+			}
+			t.Errorf("filter %q matched %v, want only %q", filter, got, want)
+		}
+	}
+}
+
+func TestSQLiteCertificatePagesWalkTiesOnce(t *testing.T) {
+	ctx := context.Background()
+	repos := newSQLiteRepositories(t)
+	saveUser(t, repos, "issuer", "issuer@example.com")
+	// Sorting by title puts the three "Same" certificates on a tie that the
+	// cursor has to break by ID.
+	titles := []string{"Same", "Alpha", "Same", "Zulu", "Same", "Beta", "Gamma"}
+	for i, title := range titles {
+		saveCertificate(t, repos, i, title)
+	}
+
+	var got []string
+	q := domain.CertificateQuery{Sort: domain.CertificateSortTitle, Order: domain.SortDescending, Limit: 2}
+	for pages := 0; ; pages++ {
+		if pages > len(titles) {
+			t.Fatal("the cursor does not advance")
+		}
+		page, err := repos.Certificates.FindPage(ctx, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != len(titles) {
+			t.Errorf("total is %d, want %d", page.Total, len(titles))
+		}
+		for _, cert := range page.Items {
+			got = append(got, cert.ID+" "+cert.CertificateTitle)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+
+	want := []string{"CERT-003 Zulu", "CERT-004 Same", "CERT-002 Same", "CERT-000 Same", "CERT-006 Gamma", "CERT-005 Beta", "CERT-001 Alpha"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("pages gave %v, want %v", got, want)
+	}
+}
+
+func TestSQLiteWebhookSubscriptionsByRole(t *testing.T) {
+	ctx := context.Background()
+	repos := newSQLiteRepositories(t)
+	saveUser(t, repos, "issuer", "issuer@example.com")
+	saveUser(t, repos, "other", "other@example.com")
+	admin := saveUser(t, repos, "admin", "admin@example.com")
+	admin.Role = domain.RoleAdmin
+	if err := repos.Users.Save(ctx, admin); err != nil {
+		t.Fatal(err)
+	}
+	for _, owner := range []string{"issuer", "other", "admin"} {
+		sub := &domain.WebhookSubscription{ID: "sub-" + owner, OwnerID: owner, URL: "https://example.com/" + owner, Events: []string{domain.EventCertificateIssued}, Secret: "whsec_x", CreatedAt: time.Now().UTC()}
+		if err := repos.Webhooks.SaveSubscription(ctx, sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := func(subs []*domain.WebhookSubscription, err error) string {
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, sub := range subs {
+			got = append(got, sub.ID)
+		}
+		sort.Strings(got)
+		return strings.Join(got, ",")
+	}
+	if got := ids(repos.Webhooks.FindSubscriptionsForIssuer(ctx, "issuer")); got != "sub-admin,sub-issuer" {
+		t.Errorf("issuer's certificates reach %s, want the issuer's and the admin's", got)
+	}
+	if got := ids(repos.Webhooks.FindAdminSubscriptions(ctx)); got != "sub-admin" {
+		t.Errorf("admin subscriptions are %s, want only the admin's", got)
+	}
+}
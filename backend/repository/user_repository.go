@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
@@ -9,15 +10,16 @@ import (
 	"github.com/google/uuid"
 )
 
-type UserRepository struct {
-	db *sql.DB
+type SQLUserRepository struct {
+	db      *sql.DB
+	dialect Dialect
 }
 
-func NewUserRepository(db *sql.DB) *UserRepository {
-	return &UserRepository{db: db}
+func NewSQLUserRepository(db *sql.DB, dialect Dialect) *SQLUserRepository {
+	return &SQLUserRepository{db: db, dialect: dialect}
 }
 
-func (r *UserRepository) Save(user *domain.User) error {
+func (r *SQLUserRepository) Save(ctx context.Context, user *domain.User) error {
 	if user.ID == "" {
 		user.ID = uuid.New().String()
 	}
@@ -27,72 +29,57 @@ func (r *UserRepository) Save(user *domain.User) error {
 	user.UpdatedAt = time.Now()
 
 	query := `
-		INSERT INTO users (id, name, email, password, role, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-		ON DUPLICATE KEY UPDATE
-			name = ?, email = ?, password = ?, role = ?, updated_at = ?`
-	_, err := r.db.Exec(query,
-		user.ID, user.Name, user.Email, user.Password, user.Role, user.CreatedAt, user.UpdatedAt,
-		user.Name, user.Email, user.Password, user.Role, user.UpdatedAt,
+		INSERT INTO users (id, name, email, password, role, email_verified, email_verified_at, totp_secret, totp_enabled, totp_last_step, token_version, password_change_required, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		` + r.dialect.upsert("id") + `
+			name = ?, email = ?, password = ?, role = ?, email_verified = ?, email_verified_at = ?, totp_secret = ?, totp_enabled = ?, totp_last_step = ?, token_version = ?, password_change_required = ?, updated_at = ?`
+	_, err := r.db.ExecContext(ctx, query,
+		user.ID, user.Name, user.Email, user.Password, user.Role, user.EmailVerified, user.EmailVerifiedAt, user.TOTPSecret, user.TOTPEnabled, user.TOTPLastStep, user.TokenVersion, user.PasswordChangeRequired, user.CreatedAt, user.UpdatedAt,
+		user.Name, user.Email, user.Password, user.Role, user.EmailVerified, user.EmailVerifiedAt, user.TOTPSecret, user.TOTPEnabled, user.TOTPLastStep, user.TokenVersion, user.PasswordChangeRequired, user.UpdatedAt,
 	)
+	if isDuplicateKey(err) {
+		return domain.Conflict("email_taken", "user with email %s already exists", user.Email)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to save user: %v", err)
 	}
 	return nil
 }
 
-func (r *UserRepository) FindByID(id string) (*domain.User, error) {
-	query := `SELECT id, name, email, password, role, created_at, updated_at
+func (r *SQLUserRepository) FindByID(ctx context.Context, id string) (*domain.User, error) {
+	query := `SELECT id, name, email, password, role, email_verified, email_verified_at, totp_secret, totp_enabled, totp_last_step, token_version, password_change_required, created_at, updated_at
 	          FROM users WHERE id = ?`
-	row := r.db.QueryRow(query, id)
+	row := r.db.QueryRowContext(ctx, query, id)
 
-	var user domain.User
-	err := row.Scan(
-		&user.ID,
-		&user.Name,
-		&user.Email,
-		&user.Password,
-		&user.Role,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	user, err := scanUser(row)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("user with ID %s not found", id)
+		return nil, domain.NotFound("user_not_found", "user with ID %s not found", id)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to find user: %v", err)
 	}
-	return &user, nil
+	return user, nil
 }
 
-func (r *UserRepository) FindByEmail(email string) (*domain.User, error) {
-	query := `SELECT id, name, email, password, role, created_at, updated_at
+func (r *SQLUserRepository) FindByEmail(ctx context.Context, email string) (*domain.User, error) {
+	query := `SELECT id, name, email, password, role, email_verified, email_verified_at, totp_secret, totp_enabled, totp_last_step, token_version, password_change_required, created_at, updated_at
 	          FROM users WHERE email = ?`
-	row := r.db.QueryRow(query, email)
+	row := r.db.QueryRowContext(ctx, query, email)
 
-	var user domain.User
-	err := row.Scan(
-		&user.ID,
-		&user.Name,
-		&user.Email,
-		&user.Password,
-		&user.Role,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	user, err := scanUser(row)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("user with email %s not found", email)
+		return nil, domain.NotFound("user_not_found", "user with email %s not found", email)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to find user: %v", err)
 	}
-	return &user, nil
+	return user, nil
 }
 
-func (r *UserRepository) FindAll() ([]*domain.User, error) {
-	query := `SELECT id, name, email, password, role, created_at, updated_at
+func (r *SQLUserRepository) FindAll(ctx context.Context) ([]*domain.User, error) {
+	query := `SELECT id, name, email, password, role, email_verified, email_verified_at, totp_secret, totp_enabled, totp_last_step, token_version, password_change_required, created_at, updated_at
 	          FROM users`
-	rows, err := r.db.Query(query)
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query users: %v", err)
 	}
@@ -100,26 +87,18 @@ func (r *UserRepository) FindAll() ([]*domain.User, error) {
 
 	var users []*domain.User
 	for rows.Next() {
-		var user domain.User
-		if err := rows.Scan(
-			&user.ID,
-			&user.Name,
-			&user.Email,
-			&user.Password,
-			&user.Role,
-			&user.CreatedAt,
-			&user.UpdatedAt,
-		); err != nil {
+		user, err := scanUser(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan user: %v", err)
 		}
-		users = append(users, &user)
+		users = append(users, user)
 	}
 	return users, nil
 }
 
-func (r *UserRepository) Delete(id string) error {
+func (r *SQLUserRepository) Delete(ctx context.Context, id string) error {
 	query := `DELETE FROM users WHERE id = ?`
-	result, err := r.db.Exec(query, id)
+	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete user: %v", err)
 	}
@@ -129,8 +108,33 @@ func (r *UserRepository) Delete(id string) error {
 		return fmt.Errorf("failed to check rows affected: %v", err)
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("user with ID %s not found", id)
+		return domain.NotFound("user_not_found", "user with ID %s not found", id)
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+func scanUser(row rowScanner) (*domain.User, error) {
+	var user domain.User
+	var emailVerifiedAt sql.NullTime
+	if err := row.Scan(
+		&user.ID,
+		&user.Name,
+		&user.Email,
+		&user.Password,
+		&user.Role,
+		&user.EmailVerified,
+		&emailVerifiedAt,
+		&user.TOTPSecret,
+		&user.TOTPEnabled,
+		&user.TOTPLastStep,
+		&user.TokenVersion,
+		&user.PasswordChangeRequired,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	user.EmailVerifiedAt = nullTimePtr(emailVerifiedAt)
+	return &user, nil
+}
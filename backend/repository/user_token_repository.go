@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"certificate-ledger/domain"
+)
+
+type SQLUserTokenRepository struct {
+	db *sql.DB
+}
+
+func NewSQLUserTokenRepository(db *sql.DB) *SQLUserTokenRepository {
+	return &SQLUserTokenRepository{db: db}
+}
+
+func (r *SQLUserTokenRepository) Save(ctx context.Context, token *domain.UserToken) error {
+	query := `
+		INSERT INTO user_tokens (id, user_id, purpose, expires_at, used_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query,
+		token.ID,
+		token.UserID,
+		token.Purpose,
+		token.ExpiresAt,
+		token.UsedAt,
+		token.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save token: %v", err)
+	}
+	return nil
+}
+
+func (r *SQLUserTokenRepository) FindByID(ctx context.Context, id string) (*domain.UserToken, error) {
+	query := `SELECT id, user_id, purpose, expires_at, used_at, created_at
+	          FROM user_tokens WHERE id = ?`
+	row := r.db.QueryRowContext(ctx, query, id)
+
+	var token domain.UserToken
+	var usedAt sql.NullTime
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Purpose,
+		&token.ExpiresAt,
+		&usedAt,
+		&token.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, domain.NotFound("token_not_found", "token with ID %s not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find token: %v", err)
+	}
+	token.UsedAt = nullTimePtr(usedAt)
+	return &token, nil
+}
+
+// Consume marks the token used if it is still unused and unexpired. It reports
+// false when the token was already consumed, so each token works exactly once
+// even when several instances race on it.
+func (r *SQLUserTokenRepository) Consume(ctx context.Context, id string, now time.Time) (bool, error) {
+	query := `UPDATE user_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL AND expires_at > ?`
+	result, err := r.db.ExecContext(ctx, query, now, id, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to consume token: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check rows affected: %v", err)
+	}
+	return rowsAffected == 1, nil
+}
+
+// InvalidateForUser consumes every outstanding token of the given purpose for a user.
+func (r *SQLUserTokenRepository) InvalidateForUser(ctx context.Context, userID, purpose string, now time.Time) error {
+	query := `UPDATE user_tokens SET used_at = ? WHERE user_id = ? AND purpose = ? AND used_at IS NULL`
+	if _, err := r.db.ExecContext(ctx, query, now, userID, purpose); err != nil {
+		return fmt.Errorf("failed to invalidate tokens: %v", err)
+	}
+	return nil
+}
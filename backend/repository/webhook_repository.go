@@ -0,0 +1,284 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"certificate-ledger/domain"
+)
+
+const (
+	webhookSubscriptionColumns = `id, owner_id, url, description, events, secret, created_at`
+	webhookDeliveryColumns     = `id, subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at`
+)
+
+type SQLWebhookRepository struct {
+	db *sql.DB
+}
+
+func NewSQLWebhookRepository(db *sql.DB) *SQLWebhookRepository {
+	return &SQLWebhookRepository{db: db}
+}
+
+func (r *SQLWebhookRepository) SaveSubscription(ctx context.Context, sub *domain.WebhookSubscription) error {
+	query := `INSERT INTO webhook_subscriptions (` + webhookSubscriptionColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query,
+		sub.ID,
+		sub.OwnerID,
+		sub.URL,
+		sub.Description,
+		strings.Join(sub.Events, ","),
+		sub.Secret,
+		sub.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save webhook subscription: %v", err)
+	}
+	return nil
+}
+
+func (r *SQLWebhookRepository) FindSubscription(ctx context.Context, id string) (*domain.WebhookSubscription, error) {
+	query := `SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions WHERE id = ?`
+	sub, err := scanWebhookSubscription(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, domain.NotFound("webhook_not_found", "webhook with ID %s not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find webhook subscription: %v", err)
+	}
+	return sub, nil
+}
+
+func (r *SQLWebhookRepository) FindSubscriptionsByOwner(ctx context.Context, ownerID string) ([]*domain.WebhookSubscription, error) {
+	query := `SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions WHERE owner_id = ? ORDER BY created_at, id`
+	return r.querySubscriptions(ctx, query, ownerID)
+}
+
+func (r *SQLWebhookRepository) FindSubscriptionsForIssuer(ctx context.Context, issuerID string) ([]*domain.WebhookSubscription, error) {
+	query := `SELECT s.id, s.owner_id, s.url, s.description, s.events, s.secret, s.created_at
+		FROM webhook_subscriptions s JOIN users u ON u.id = s.owner_id
+		WHERE s.owner_id = ? OR u.role = ?
+		ORDER BY s.created_at, s.id`
+	return r.querySubscriptions(ctx, query, issuerID, domain.RoleAdmin)
+}
+
+func (r *SQLWebhookRepository) FindAdminSubscriptions(ctx context.Context) ([]*domain.WebhookSubscription, error) {
+	query := `SELECT s.id, s.owner_id, s.url, s.description, s.events, s.secret, s.created_at
+		FROM webhook_subscriptions s JOIN users u ON u.id = s.owner_id
+		WHERE u.role = ?
+		ORDER BY s.created_at, s.id`
+	return r.querySubscriptions(ctx, query, domain.RoleAdmin)
+}
+
+func (r *SQLWebhookRepository) DeleteSubscription(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %v", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return domain.NotFound("webhook_not_found", "webhook with ID %s not found", id)
+	}
+	return nil
+}
+
+func (r *SQLWebhookRepository) SaveDelivery(ctx context.Context, d *domain.WebhookDelivery) error {
+	query := `INSERT INTO webhook_deliveries (` + webhookDeliveryColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query,
+		d.ID,
+		d.SubscriptionID,
+		d.EventID,
+		d.EventType,
+		string(d.Payload),
+		d.Status,
+		d.Attempts,
+		d.NextAttemptAt,
+		d.LastStatusCode,
+		d.LastError,
+		d.CreatedAt,
+		d.DeliveredAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save webhook delivery: %v", err)
+	}
+	return nil
+}
+
+func (r *SQLWebhookRepository) FindDelivery(ctx context.Context, id string) (*domain.WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE id = ?`
+	d, err := scanWebhookDelivery(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, domain.NotFound("webhook_delivery_not_found", "webhook delivery with ID %s not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find webhook delivery: %v", err)
+	}
+	return d, nil
+}
+
+func (r *SQLWebhookRepository) FindDeliveries(ctx context.Context, subscriptionID, status string, limit int) ([]*domain.WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE subscription_id = ?`
+	args := []interface{}{subscriptionID}
+	if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	return r.queryDeliveries(ctx, query, append(args, limit)...)
+}
+
+func (r *SQLWebhookRepository) FindDueDeliveries(ctx context.Context, now time.Time, limit int) ([]*domain.WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY next_attempt_at, id LIMIT ?`
+	return r.queryDeliveries(ctx, query, domain.WebhookDeliveryPending, now, limit)
+}
+
+func (r *SQLWebhookRepository) ClaimDelivery(ctx context.Context, id string, now, leaseUntil time.Time) (bool, error) {
+	query := `UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ? AND status = ? AND next_attempt_at <= ?`
+	result, err := r.db.ExecContext(ctx, query, leaseUntil, id, domain.WebhookDeliveryPending, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim webhook delivery: %v", err)
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (r *SQLWebhookRepository) RecordAttempt(ctx context.Context, d *domain.WebhookDelivery, a *domain.WebhookAttempt) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = ?, last_status_code = ?, last_error = ?, delivered_at = ? WHERE id = ?`
+	if _, err := tx.ExecContext(ctx, query, d.Status, d.Attempts, d.NextAttemptAt, d.LastStatusCode, d.LastError, d.DeliveredAt, d.ID); err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %v", err)
+	}
+
+	query = `INSERT INTO webhook_attempts (id, delivery_id, attempt, attempted_at, status_code, error, duration_ms) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	if _, err := tx.ExecContext(ctx, query, a.ID, a.DeliveryID, a.Attempt, a.AttemptedAt, a.StatusCode, a.Error, a.DurationMS); err != nil {
+		return fmt.Errorf("failed to save webhook attempt: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit webhook attempt: %v", err)
+	}
+	return nil
+}
+
+func (r *SQLWebhookRepository) FindAttempts(ctx context.Context, deliveryID string) ([]*domain.WebhookAttempt, error) {
+	query := `SELECT id, delivery_id, attempt, attempted_at, status_code, error, duration_ms
+		FROM webhook_attempts WHERE delivery_id = ? ORDER BY attempted_at, id`
+	rows, err := r.db.QueryContext(ctx, query, deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook attempts: %v", err)
+	}
+	defer rows.Close()
+
+	attempts := []*domain.WebhookAttempt{}
+	for rows.Next() {
+		var a domain.WebhookAttempt
+		if err := rows.Scan(&a.ID, &a.DeliveryID, &a.Attempt, &a.AttemptedAt, &a.StatusCode, &a.Error, &a.DurationMS); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook attempt: %v", err)
+		}
+		attempts = append(attempts, &a)
+	}
+	return attempts, rows.Err()
+}
+
+func (r *SQLWebhookRepository) Replay(ctx context.Context, id string, now time.Time) (bool, error) {
+	query := `UPDATE webhook_deliveries SET status = ?, attempts = 0, next_attempt_at = ? WHERE id = ? AND status <> ?`
+	result, err := r.db.ExecContext(ctx, query, domain.WebhookDeliveryPending, now, id, domain.WebhookDeliveryPending)
+	if err != nil {
+		return false, fmt.Errorf("failed to replay webhook delivery: %v", err)
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (r *SQLWebhookRepository) ReplayDead(ctx context.Context, subscriptionID string, now time.Time) (int64, error) {
+	query := `UPDATE webhook_deliveries SET status = ?, attempts = 0, next_attempt_at = ? WHERE subscription_id = ? AND status = ?`
+	result, err := r.db.ExecContext(ctx, query, domain.WebhookDeliveryPending, now, subscriptionID, domain.WebhookDeliveryDead)
+	if err != nil {
+		return 0, fmt.Errorf("failed to replay webhook deliveries: %v", err)
+	}
+	return result.RowsAffected()
+}
+
+func (r *SQLWebhookRepository) querySubscriptions(ctx context.Context, query string, args ...interface{}) ([]*domain.WebhookSubscription, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook subscriptions: %v", err)
+	}
+	defer rows.Close()
+
+	subs := []*domain.WebhookSubscription{}
+	for rows.Next() {
+		sub, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook subscription: %v", err)
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+func (r *SQLWebhookRepository) queryDeliveries(ctx context.Context, query string, args ...interface{}) ([]*domain.WebhookDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %v", err)
+	}
+	defer rows.Close()
+
+	deliveries := []*domain.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %v", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func scanWebhookSubscription(row rowScanner) (*domain.WebhookSubscription, error) {
+	var sub domain.WebhookSubscription
+	var events string
+	if err := row.Scan(&sub.ID, &sub.OwnerID, &sub.URL, &sub.Description, &events, &sub.Secret, &sub.CreatedAt); err != nil {
+		return nil, err
+	}
+	if events != "" {
+		sub.Events = strings.Split(events, ",")
+	}
+	return &sub, nil
+}
+
+func scanWebhookDelivery(row rowScanner) (*domain.WebhookDelivery, error) {
+	var d domain.WebhookDelivery
+	var payload string
+	var deliveredAt sql.NullTime
+	err := row.Scan(
+		&d.ID,
+		&d.SubscriptionID,
+		&d.EventID,
+		&d.EventType,
+		&payload,
+		&d.Status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&d.LastStatusCode,
+		&d.LastError,
+		&d.CreatedAt,
+		&deliveredAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	d.Payload = json.RawMessage(payload)
+	d.DeliveredAt = nullTimePtr(deliveredAt)
+	return &d, nil
+}
@@ -0,0 +1,134 @@
+package search
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"certificate-ledger/domain"
+)
+
+// fieldWeights favours matches in names and titles over descriptions.
+var fieldWeights = map[string]float64{
+	domain.FieldRecipientName:    3,
+	domain.FieldCertificateTitle: 3,
+	domain.FieldIssuerName:       2,
+	domain.FieldDescription:      1,
+}
+
+// MemoryIndex is an in-process inverted index. It holds only the searchable
+// text; candidates are loaded through load so filters see current data such
+// as claims and revocations.
+type MemoryIndex struct {
+	load func(ctx context.Context, id string) (*domain.Certificate, error)
+
+	mu sync.RWMutex
+	// postings maps a token to the weight it carries in each certificate.
+	postings map[string]map[string]float64
+	docs     map[string][]string
+}
+
+func NewMemoryIndex(load func(ctx context.Context, id string) (*domain.Certificate, error)) *MemoryIndex {
+	return &MemoryIndex{
+		load:     load,
+		postings: make(map[string]map[string]float64),
+		docs:     make(map[string][]string),
+	}
+}
+
+func (idx *MemoryIndex) Add(cert *domain.Certificate) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(cert.ID)
+	var tokens []string
+	for _, field := range domain.SearchableFields {
+		for _, token := range Terms(domain.FieldValue(cert, field)) {
+			if idx.postings[token] == nil {
+				idx.postings[token] = make(map[string]float64)
+			}
+			if idx.postings[token][cert.ID] == 0 {
+				tokens = append(tokens, token)
+			}
+			idx.postings[token][cert.ID] += fieldWeights[field]
+		}
+	}
+	idx.docs[cert.ID] = tokens
+}
+
+func (idx *MemoryIndex) Remove(id string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(id)
+}
+
+func (idx *MemoryIndex) remove(id string) {
+	for _, token := range idx.docs[id] {
+		delete(idx.postings[token], id)
+		if len(idx.postings[token]) == 0 {
+			delete(idx.postings, token)
+		}
+	}
+	delete(idx.docs, id)
+}
+
+// Search scores each certificate by the weighted, IDF-scaled tokens that
+// start with a query term. Exact token matches count double.
+func (idx *MemoryIndex) Search(ctx context.Context, q domain.CertificateSearchQuery) ([]*domain.CertificateSearchHit, error) {
+	terms := Terms(q.Text)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	idx.mu.RLock()
+	scores := make(map[string]float64)
+	total := float64(len(idx.docs))
+	for _, term := range terms {
+		for token, docs := range idx.postings {
+			if !strings.HasPrefix(token, term) {
+				continue
+			}
+			idf := math.Log(1 + total/float64(len(docs)))
+			exact := 0.5
+			if token == term {
+				exact = 1
+			}
+			for id, weight := range docs {
+				scores[id] += weight * idf * exact
+			}
+		}
+	}
+	idx.mu.RUnlock()
+
+	ids := make([]string, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+
+	var hits []*domain.CertificateSearchHit
+	for _, id := range ids {
+		if len(hits) >= q.Limit {
+			break
+		}
+		cert, err := idx.load(ctx, id)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		if !q.Filter.Matches(cert) {
+			continue
+		}
+		hits = append(hits, &domain.CertificateSearchHit{Certificate: cert, Score: scores[id]})
+	}
+	return hits, nil
+}
@@ -0,0 +1,33 @@
+package search
+
+import (
+	"context"
+
+	"certificate-ledger/domain"
+)
+
+// FullTextSearcher ranks certificates with a database full-text index.
+// repository.SQLCertificateRepository implements it for MySQL.
+type FullTextSearcher interface {
+	Search(ctx context.Context, terms []string, filter domain.CertificateFilter, limit int) ([]*domain.CertificateSearchHit, error)
+}
+
+// MySQLIndex searches through the FULLTEXT index MySQL maintains on the
+// certificates table, so Add has nothing to do.
+type MySQLIndex struct {
+	repo FullTextSearcher
+}
+
+func NewMySQLIndex(repo FullTextSearcher) *MySQLIndex {
+	return &MySQLIndex{repo: repo}
+}
+
+func (idx *MySQLIndex) Add(cert *domain.Certificate) {}
+
+func (idx *MySQLIndex) Search(ctx context.Context, q domain.CertificateSearchQuery) ([]*domain.CertificateSearchHit, error) {
+	terms := Terms(q.Text)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	return idx.repo.Search(ctx, terms, q.Filter, q.Limit)
+}
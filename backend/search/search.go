@@ -0,0 +1,95 @@
+// Package search finds certificates by free text. MySQLIndex relies on the
+// FULLTEXT index of the certificates table; MemoryIndex keeps its own inverted
+// index and is meant for tests and deployments without MySQL full-text support.
+package search
+
+import (
+	"context"
+	"html"
+	"strings"
+	"unicode"
+
+	"certificate-ledger/domain"
+)
+
+// Index is a full-text index over certificates.
+type Index interface {
+	// Add indexes a newly saved certificate. Backends that index on write may ignore it.
+	Add(cert *domain.Certificate)
+	// Search returns hits ordered by descending score. Highlights are left to the caller.
+	Search(ctx context.Context, q domain.CertificateSearchQuery) ([]*domain.CertificateSearchHit, error)
+}
+
+// Terms splits text into lowercase search terms made of letters and digits.
+func Terms(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	seen := make(map[string]bool, len(fields))
+	terms := fields[:0]
+	for _, f := range fields {
+		if !seen[f] {
+			seen[f] = true
+			terms = append(terms, f)
+		}
+	}
+	return terms
+}
+
+// Highlight HTML-escapes text and wraps every word starting with one of the
+// terms in <mark>. It reports whether anything was marked.
+func Highlight(text string, terms []string) (string, bool) {
+	var b strings.Builder
+	marked := false
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			j := i
+			for j < len(runes) && !isWordRune(runes[j]) {
+				j++
+			}
+			b.WriteString(html.EscapeString(string(runes[i:j])))
+			i = j
+			continue
+		}
+
+		j := i
+		for j < len(runes) && isWordRune(runes[j]) {
+			j++
+		}
+		word := string(runes[i:j])
+		if matchesAny(strings.ToLower(word), terms) {
+			b.WriteString("<mark>" + html.EscapeString(word) + "</mark>")
+			marked = true
+		} else {
+			b.WriteString(html.EscapeString(word))
+		}
+		i = j
+	}
+	return b.String(), marked
+}
+
+// Highlights builds the highlight map of a hit over the searchable fields.
+func Highlights(cert *domain.Certificate, terms []string) map[string]string {
+	highlights := make(map[string]string)
+	for _, field := range domain.SearchableFields {
+		if h, ok := Highlight(domain.FieldValue(cert, field), terms); ok {
+			highlights[field] = h
+		}
+	}
+	return highlights
+}
+
+func matchesAny(word string, terms []string) bool {
+	for _, t := range terms {
+		if strings.HasPrefix(word, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,92 @@
+package search
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"certificate-ledger/domain"
+)
+
+func TestTerms(t *testing.T) {
+	got := Terms("  Data-Science, data & ĐẠI học 2024! ")
+	want := []string{"data", "science", "đại", "học", "2024"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Terms = %q, want %q", got, want)
+	}
+	if got := Terms("-- !! --"); len(got) != 0 {
+		t.Errorf("Terms of punctuation = %q, want none", got)
+	}
+}
+
+func TestHighlightMarksPrefixesAndEscapes(t *testing.T) {
+	got, marked := Highlight("<b>Data</b> Science & Databases", []string{"data"})
+	want := "&lt;b&gt;<mark>Data</mark>&lt;/b&gt; Science &amp; <mark>Databases</mark>"
+	if !marked || got != want {
+		t.Errorf("Highlight = %q, %v; want %q, true", got, marked, want)
+	}
+	if _, marked := Highlight("Mathematics", []string{"data"}); marked {
+		t.Error("Highlight marked a field without a match")
+	}
+}
+
+// certificates is a stand-in repository for the index to load from.
+type certificates map[string]*domain.Certificate
+
+func (c certificates) load(_ context.Context, id string) (*domain.Certificate, error) {
+	cert, ok := c[id]
+	if !ok {
+		return nil, domain.NotFound("certificate_not_found", "certificate %s not found", id)
+	}
+	return cert, nil
+}
+
+func TestMemoryIndexRanksAndFilters(t *testing.T) {
+	certs := certificates{
+		"title":       {ID: "title", CertificateTitle: "Data Science", RecipientName: "Alice", IssuerID: "i1"},
+		"description": {ID: "description", CertificateTitle: "Mathematics", Description: "Includes data analysis", RecipientName: "Bob", IssuerID: "i1"},
+		"prefix":      {ID: "prefix", CertificateTitle: "Databases", RecipientName: "Carol", IssuerID: "i2"},
+		"unrelated":   {ID: "unrelated", CertificateTitle: "Chemistry", RecipientName: "Dave", IssuerID: "i1"},
+	}
+	idx := NewMemoryIndex(certs.load)
+	for _, cert := range certs {
+		idx.Add(cert)
+	}
+
+	hits, err := idx.Search(context.Background(), domain.CertificateSearchQuery{Text: "data", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, hit := range hits {
+		got = append(got, hit.Certificate.ID)
+	}
+	// Titles weigh three times as much as descriptions, so even a prefix match
+	// in a title ranks above an exact match in a description.
+	if want := []string{"title", "prefix", "description"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("hits %v, want %v", got, want)
+	}
+
+	hits, err = idx.Search(context.Background(), domain.CertificateSearchQuery{
+		Text:   "data",
+		Filter: domain.CertificateFilter{IssuerID: "i2"},
+		Limit:  10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].Certificate.ID != "prefix" {
+		t.Errorf("filtered search returned %d hits, want only the i2 certificate", len(hits))
+	}
+
+	idx.Remove("title")
+	certs["prefix"].CertificateTitle = "Renamed"
+	idx.Add(certs["prefix"])
+	hits, err = idx.Search(context.Background(), domain.CertificateSearchQuery{Text: "data", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].Certificate.ID != "description" {
+		t.Errorf("after removal and reindex got %d hits, want only the description match", len(hits))
+	}
+}
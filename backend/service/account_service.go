@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/mail"
+	"certificate-ledger/repository"
+	"certificate-ledger/validation"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	emailVerificationTTL = 48 * time.Hour
+	passwordResetTTL     = time.Hour
+)
+
+// AccountService handles the emailed, single-use token flows: email
+// verification and password reset. The emails go through the notification
+// queue.
+type AccountService struct {
+	userRepo      repository.UserRepository
+	tokenRepo     repository.UserTokenRepository
+	apiKeyRepo    repository.APIKeyRepository
+	certificates  *CertificateService
+	notifications *NotificationService
+	throttle      *LoginThrottleService
+	// secret keys the signatures of emailed tokens.
+	secret string
+	// baseURL is the frontend origin the emailed links point to.
+	baseURL string
+}
+
+func NewAccountService(userRepo repository.UserRepository, tokenRepo repository.UserTokenRepository, apiKeyRepo repository.APIKeyRepository, certificates *CertificateService, notifications *NotificationService, throttle *LoginThrottleService, secret, baseURL string) *AccountService {
+	return &AccountService{
+		userRepo:      userRepo,
+		tokenRepo:     tokenRepo,
+		apiKeyRepo:    apiKeyRepo,
+		certificates:  certificates,
+		notifications: notifications,
+		throttle:      throttle,
+		secret:        secret,
+		baseURL:       strings.TrimRight(baseURL, "/"),
+	}
+}
+
+func (s *AccountService) RequestEmailVerification(ctx context.Context, userID string) error {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return domain.Conflict("email_already_verified", "email is already verified")
+	}
+
+	token, err := s.issueToken(ctx, user.ID, domain.TokenPurposeEmailVerification, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := s.appURL("/verify-email", token)
+	return s.notifications.QueueAccountMail(ctx, domain.NotificationEmailVerification, user.Email, mail.Message{
+		To:      []string{user.Email},
+		Subject: "Verify your email address",
+		TextBody: fmt.Sprintf("Hello %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in %d hours.\n",
+			user.Name, link, int(emailVerificationTTL.Hours())),
+		HTMLBody: fmt.Sprintf("<p>Hello %s,</p><p>Please confirm your email address by opening the link below:</p><p><a href=\"%s\">Verify email</a></p><p>The link expires in %d hours.</p>",
+			html.EscapeString(user.Name), html.EscapeString(link), int(emailVerificationTTL.Hours())),
+	})
+}
+
+func (s *AccountService) VerifyEmail(ctx context.Context, token string) (*domain.User, error) {
+	userID, err := s.consumeToken(ctx, token, domain.TokenPurposeEmailVerification)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.EmailVerified {
+		now := time.Now()
+		user.EmailVerified = true
+		user.EmailVerifiedAt = &now
+		if err := s.userRepo.Save(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	// Certificates issued to this address before the account existed now belong to it.
+	if claimed, err := s.certificates.ClaimByVerifiedEmail(ctx, user); err != nil {
+		log.Printf("Failed to claim certificates for %s: %v", user.ID, err)
+	} else if claimed > 0 {
+		log.Printf("Claimed %d certificates for %s", claimed, user.ID)
+	}
+	return user, nil
+}
+
+// RequestPasswordReset mails a reset link if the email belongs to an account.
+// It never reports whether the account exists: the mail is queued rather than
+// sent, so neither the answer nor its timing depends on it.
+func (s *AccountService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.userRepo.FindByEmail(ctx, strings.TrimSpace(email))
+	if err != nil {
+		log.Printf("Password reset requested for unknown email")
+		return nil
+	}
+
+	token, err := s.issueToken(ctx, user.ID, domain.TokenPurposePasswordReset, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	link := s.appURL("/reset-password", token)
+	return s.notifications.QueueAccountMail(ctx, domain.NotificationPasswordReset, user.Email, mail.Message{
+		To:      []string{user.Email},
+		Subject: "Reset your password",
+		TextBody: fmt.Sprintf("Hello %s,\n\nSomeone asked to reset the password for your account. If it was you, open the link below:\n\n%s\n\nThe link expires in %d minutes. If you did not ask for this, you can ignore this email.\n",
+			user.Name, link, int(passwordResetTTL.Minutes())),
+		HTMLBody: fmt.Sprintf("<p>Hello %s,</p><p>Someone asked to reset the password for your account. If it was you, open the link below:</p><p><a href=\"%s\">Reset password</a></p><p>The link expires in %d minutes. If you did not ask for this, you can ignore this email.</p>",
+			html.EscapeString(user.Name), html.EscapeString(link), int(passwordResetTTL.Minutes())),
+	})
+}
+
+// ResetPassword sets a new password from a reset link. Whoever may have
+// learnt the old one is shut out: sessions issued before end, the user's API
+// keys are revoked, and a lockout the owner ran into is lifted.
+func (s *AccountService) ResetPassword(ctx context.Context, req domain.ResetPasswordRequest) error {
+	if err := validation.ResetPasswordRequest(req); err != nil {
+		return err
+	}
+
+	userID, err := s.consumeToken(ctx, req.Token, domain.TokenPurposePasswordReset)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %v", err)
+	}
+	user.Password = string(hashedPassword)
+	user.PasswordChangeRequired = false
+	user.TokenVersion++
+
+	// Receiving the reset link proves control of the mailbox.
+	now := time.Now()
+	if !user.EmailVerified {
+		user.EmailVerified = true
+		user.EmailVerifiedAt = &now
+	}
+	if err := s.userRepo.Save(ctx, user); err != nil {
+		return err
+	}
+	if err := s.apiKeyRepo.RevokeForUser(ctx, user.ID, now); err != nil {
+		return err
+	}
+	return s.throttle.ResetAccount(ctx, user.Email)
+}
+
+// issueToken stores a new token, invalidating older ones of the same purpose,
+// and returns its signed form "<id>.<expiry>.<signature>".
+func (s *AccountService) issueToken(ctx context.Context, userID, purpose string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	if err := s.tokenRepo.InvalidateForUser(ctx, userID, purpose, now); err != nil {
+		return "", err
+	}
+
+	token := &domain.UserToken{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Purpose:   purpose,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	if err := s.tokenRepo.Save(ctx, token); err != nil {
+		return "", err
+	}
+
+	expires := strconv.FormatInt(token.ExpiresAt.Unix(), 10)
+	return token.ID + "." + expires + "." + s.signUserToken(token.ID, purpose, expires), nil
+}
+
+func (s *AccountService) consumeToken(ctx context.Context, raw, purpose string) (string, error) {
+	invalid := domain.Invalid("invalid_token", "invalid or expired token")
+
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return "", invalid
+	}
+	id, expires, signature := parts[0], parts[1], parts[2]
+
+	if !hmac.Equal([]byte(signature), []byte(s.signUserToken(id, purpose, expires))) {
+		return "", invalid
+	}
+	expiresUnix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() >= expiresUnix {
+		return "", invalid
+	}
+
+	token, err := s.tokenRepo.FindByID(ctx, id)
+	if err != nil || token.Purpose != purpose {
+		return "", invalid
+	}
+	ok, err := s.tokenRepo.Consume(ctx, token.ID, time.Now())
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", invalid
+	}
+	return token.UserID, nil
+}
+
+func (s *AccountService) signUserToken(id, purpose, expires string) string {
+	mac := hmac.New(sha256.New, []byte(s.secret))
+	mac.Write([]byte(id + "|" + purpose + "|" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// appURL builds a frontend link carrying a token.
+func (s *AccountService) appURL(path, token string) string {
+	return s.baseURL + path + "?token=" + url.QueryEscape(token)
+}
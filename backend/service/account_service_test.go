@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/mail"
+)
+
+func newTestAccounts(env *testEnv) (*AccountService, *mail.MemoryMailer) {
+	mailer := mail.NewMemoryMailer()
+	notifications := NewNotificationService(env.repos.Notifications, mailer, env.audit, NotificationConfig{
+		BaseURL:       "https://ledger.example.edu",
+		DefaultLocale: domain.DefaultLocale,
+		MaxAttempts:   3,
+		RetryBase:     time.Minute,
+		RetryMax:      time.Hour,
+		PollInterval:  time.Hour,
+	})
+	accounts := NewAccountService(env.repos.Users, env.repos.UserTokens, env.repos.APIKeys, env.certs, notifications, env.throttle, "0123456789abcdef0123456789abcdef", "https://ledger.example.edu")
+	return accounts, mailer
+}
+
+var resetLink = regexp.MustCompile(`/reset-password\?token=(\S+)`)
+
+// queuedResetToken returns the token of the last queued password reset email.
+func queuedResetToken(t *testing.T, env *testEnv) string {
+	t.Helper()
+	queued, err := env.repos.Notifications.FindByStatus(context.Background(), domain.NotificationPending, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range queued {
+		if n.Template != domain.NotificationPasswordReset {
+			continue
+		}
+		m := resetLink.FindStringSubmatch(n.TextBody)
+		if m == nil {
+			t.Fatalf("reset email without a link: %q", n.TextBody)
+		}
+		token, err := url.QueryUnescape(m[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	t.Fatal("no password reset email queued")
+	return ""
+}
+
+func TestRequestPasswordResetQueuesTheEmail(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	accounts, mailer := newTestAccounts(env)
+	env.saveUser(t, "alice@example.com", domain.RoleUser)
+
+	if err := accounts.RequestPasswordReset(ctx, "nobody@example.com"); err != nil {
+		t.Fatalf("unknown email: %v", err)
+	}
+	if queued, _ := env.repos.Notifications.FindByStatus(ctx, "", 10); len(queued) != 0 {
+		t.Fatalf("unknown email queued %d emails", len(queued))
+	}
+
+	if err := accounts.RequestPasswordReset(ctx, "alice@example.com"); err != nil {
+		t.Fatalf("known email: %v", err)
+	}
+	queued, err := env.repos.Notifications.FindByStatus(ctx, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queued) != 1 || queued[0].Recipient != "alice@example.com" || queued[0].CertificateID != "" {
+		t.Fatalf("queued %+v, want one account email to alice", queued)
+	}
+	if _, sent := mailer.Last(); sent {
+		t.Error("the reset email was sent during the request")
+	}
+}
+
+func TestResetPasswordEndsSessionsKeysAndLockout(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	accounts, _ := newTestAccounts(env)
+	user := env.saveUser(t, "alice@example.com", domain.RoleUser)
+
+	session, err := env.auth.Login(ctx, domain.LoginRequest{Email: user.Email, Password: testPassword}, "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Alice is then made an issuer and creates an API key.
+	user.Role = domain.RoleIssuer
+	if err := env.repos.Users.Save(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	keys := NewAPIKeyService(env.repos.APIKeys, env.repos.Users, env.audit)
+	key, err := keys.CreateKey(ctx, domain.APIKeyRequest{Name: "sis", Scopes: []string{domain.ScopeCertificatesRead}}, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.lockAccount(t, user.Email)
+
+	if err := accounts.RequestPasswordReset(ctx, user.Email); err != nil {
+		t.Fatal(err)
+	}
+	token := queuedResetToken(t, env)
+	const newPassword = "a brand new passphrase"
+	if err := accounts.ResetPassword(ctx, domain.ResetPasswordRequest{Token: token, Password: newPassword}); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+
+	if _, err := env.auth.AuthenticateSession(ctx, session.Token); !errors.Is(err, domain.ErrUnauthorized) {
+		t.Errorf("session from before the reset: got %v, want unauthorized", err)
+	}
+	if _, _, err := keys.Authenticate(ctx, key.Key); !errors.Is(err, domain.ErrUnauthorized) {
+		t.Errorf("api key from before the reset: got %v, want unauthorized", err)
+	}
+	// The lockout is lifted; as an issuer Alice is now asked for a second factor.
+	if resp, err := env.auth.Login(ctx, domain.LoginRequest{Email: user.Email, Password: newPassword}, "192.0.2.1"); err != nil || !resp.MFARequired {
+		t.Errorf("login with the new password: got %+v, %v; want an MFA challenge", resp, err)
+	}
+
+	if err := accounts.ResetPassword(ctx, domain.ResetPasswordRequest{Token: token, Password: "yet another passphrase"}); !errors.Is(err, domain.ErrValidation) {
+		t.Errorf("reused reset token: got %v, want a validation error", err)
+	}
+}
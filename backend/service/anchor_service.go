@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"certificate-ledger/blockchain"
+	"certificate-ledger/domain"
+	"certificate-ledger/repository"
+	"certificate-ledger/tsa"
+	"github.com/google/uuid"
+)
+
+// anchorCandidates bounds the anchors tried when verifying a certificate.
+// The oldest covering anchor is almost always the one that verifies.
+const anchorCandidates = 20
+
+// AnchorConfig sets how often the chain's head is time-stamped and which
+// certificates an authority's signing certificate must chain to. Roots are
+// configured apart from the authority, so stored anchors can be verified
+// with anchoring off; without roots no anchor verifies.
+type AnchorConfig struct {
+	Interval time.Duration
+	Roots    *x509.CertPool
+}
+
+// AnchorService has a time-stamp authority sign the chain's head hash, so the
+// operator cannot rewrite history and re-mine the chain without the
+// rewritten blocks post-dating the authority's receipts.
+type AnchorService struct {
+	anchors    repository.AnchorRepository
+	certs      repository.CertificateRepository
+	blockchain *blockchain.Blockchain
+	// tsa is nil when no authority is configured; stored anchors can still
+	// be verified, against config.Roots.
+	tsa    *tsa.Client
+	audit  *AuditService
+	config AnchorConfig
+	// anchoring keeps two anchors of the same head from being made at once.
+	anchoring sync.Mutex
+	running   sync.WaitGroup
+}
+
+func NewAnchorService(anchors repository.AnchorRepository, certs repository.CertificateRepository, bc *blockchain.Blockchain, client *tsa.Client, audit *AuditService, config AnchorConfig) *AnchorService {
+	return &AnchorService{
+		anchors:    anchors,
+		certs:      certs,
+		blockchain: bc,
+		tsa:        client,
+		audit:      audit,
+		config:     config,
+	}
+}
+
+// Enabled reports whether a time-stamp authority is configured.
+func (s *AnchorService) Enabled() bool {
+	return s.tsa != nil
+}
+
+// ListAnchors returns the latest anchors, newest first.
+func (s *AnchorService) ListAnchors(ctx context.Context, limit int) ([]*domain.Anchor, error) {
+	if limit <= 0 {
+		limit = domain.DefaultAnchorLimit
+	}
+	if limit > domain.MaxAnchorLimit {
+		limit = domain.MaxAnchorLimit
+	}
+	return s.anchors.FindRecent(ctx, limit)
+}
+
+func (s *AnchorService) GetAnchor(ctx context.Context, id string) (*domain.Anchor, error) {
+	return s.anchors.FindByID(ctx, id)
+}
+
+// AnchorNow time-stamps the current head on behalf of an admin, even if it
+// is already anchored.
+func (s *AnchorService) AnchorNow(ctx context.Context, actorID string) (*domain.Anchor, error) {
+	if s.tsa == nil {
+		return nil, domain.Conflict("anchoring_disabled", "no time-stamp authority is configured")
+	}
+	anchor, err := s.anchor(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	s.audit.Record(ctx, AuditEvent{
+		ActorID:    actorID,
+		Action:     domain.AuditAnchorCreate,
+		TargetType: domain.AuditTargetAnchor,
+		TargetID:   anchor.ID,
+		After: map[string]interface{}{
+			"blockIndex": anchor.BlockIndex,
+			"blockHash":  anchor.BlockHash,
+			"genTime":    anchor.GenTime,
+		},
+	})
+	return anchor, nil
+}
+
+// Start anchors the head at once and then every interval until ctx is
+// cancelled. A head that is already anchored is not anchored again.
+func (s *AnchorService) Start(ctx context.Context) {
+	if s.tsa == nil {
+		return
+	}
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+		for {
+			if anchor, err := s.anchor(ctx, false); err != nil && ctx.Err() == nil {
+				log.Printf("Failed to anchor the chain: %v", err)
+			} else if anchor != nil {
+				log.Printf("Anchored block %d (%s) at %s", anchor.BlockIndex, anchor.BlockHash, anchor.GenTime.Format(time.RFC3339))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Wait blocks until the anchoring loop has stopped.
+func (s *AnchorService) Wait() {
+	s.running.Wait()
+}
+
+// anchor time-stamps the head and stores the receipt. Unless force is set it
+// returns nil if the latest anchor already covers the head. A chain that
+// fails validation is never anchored.
+func (s *AnchorService) anchor(ctx context.Context, force bool) (*domain.Anchor, error) {
+	s.anchoring.Lock()
+	defer s.anchoring.Unlock()
+
+	if health := s.blockchain.Health(); !health.Valid {
+		return nil, domain.Conflict("chain_invalid", "the chain is broken at block %d: %s", health.FirstInvalid.Index, health.FirstInvalid.Reason)
+	}
+	head := s.blockchain.GetLatestBlock()
+	latest, err := s.anchors.FindRecent(ctx, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(latest) > 0 {
+		// A chain that lost an anchored head must not be anchored again, or
+		// the new receipts would vouch for the rewritten history.
+		if block, ok := s.blockchain.BlockByIndex(latest[0].BlockIndex); !ok || block.Hash != latest[0].BlockHash {
+			return nil, domain.Conflict("anchor_lost", "the chain no longer holds block %d (%s) anchored by %s", latest[0].BlockIndex, latest[0].BlockHash, latest[0].ID)
+		}
+		if !force && latest[0].BlockHash == head.Hash {
+			return nil, nil
+		}
+	}
+
+	digest, err := hex.DecodeString(head.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("head hash %q is not hex: %v", head.Hash, err)
+	}
+	receipt, token, err := s.tsa.Timestamp(ctx, digest)
+	if err != nil {
+		return nil, err
+	}
+	anchor := &domain.Anchor{
+		ID:             uuid.New().String(),
+		BlockIndex:     head.Index,
+		BlockHash:      head.Hash,
+		TSAURL:         s.tsa.URL,
+		SerialNumber:   token.SerialNumber,
+		Policy:         token.Policy,
+		GenTime:        token.GenTime,
+		AccuracyMicros: token.Accuracy.Microseconds(),
+		Receipt:        receipt,
+		CreatedAt:      time.Now().UTC(),
+	}
+	if err := s.anchors.Save(context.WithoutCancel(ctx), anchor); err != nil {
+		return nil, err
+	}
+	return anchor, nil
+}
+
+// VerifyCertificate looks for the earliest anchor of a head the certificate's
+// block leads up to. The anchor's receipt is checked again rather than
+// trusting the stored row, and so is every block up to the anchored one, since
+// the anchor only covers the certificate through the hashes linking them.
+func (s *AnchorService) VerifyCertificate(ctx context.Context, hash string) (*domain.AnchorVerification, error) {
+	cert, err := s.certs.FindByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	result := &domain.AnchorVerification{
+		CertificateID:   cert.ID,
+		CertificateHash: cert.Hash,
+		BlockIndex:      cert.BlockNumber,
+	}
+
+	block, err := s.blockchain.GetBlock(cert.Hash)
+	if err != nil {
+		result.Reason = "the certificate's block is not on the chain"
+		return result, nil
+	}
+	result.BlockIndex = block.Index
+	salts, err := s.certs.FindFieldSalts(ctx, cert.ID)
+	if err != nil {
+		return nil, err
+	}
+	record, err := domain.ParseCertificateBlock(block.Data)
+	if err != nil || len(record.Differences(cert, salts)) > 0 {
+		result.Reason = "the certificate differs from the one recorded in its block"
+		return result, nil
+	}
+
+	anchors, err := s.anchors.FindCovering(ctx, block.Index, block.Timestamp.UTC(), anchorCandidates)
+	if err != nil {
+		return nil, err
+	}
+	health := s.blockchain.Health()
+	result.Reason = "no anchor covers the certificate's block yet"
+	for _, anchor := range anchors {
+		token, reason := s.checkAnchor(anchor, health)
+		if token == nil {
+			result.Reason = reason
+			continue
+		}
+		existedBefore := token.GenTime.Add(token.Accuracy)
+		result.Anchored = true
+		result.ExistedBefore = &existedBefore
+		result.Anchor = anchor
+		result.Reason = ""
+		return result, nil
+	}
+	return result, nil
+}
+
+// VerifyAnchor checks a stored anchor against the stored chain: the receipt
+// must verify for the anchored hash, the chain must still hold that hash at
+// the anchored height and no block up to it may be broken. After a restart
+// this shows whether the chain loaded from storage is the one that was
+// anchored.
+func (s *AnchorService) VerifyAnchor(ctx context.Context, id string) (*domain.AnchorCheck, error) {
+	anchor, err := s.anchors.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	check := &domain.AnchorCheck{
+		AnchorID:   anchor.ID,
+		BlockIndex: anchor.BlockIndex,
+		BlockHash:  anchor.BlockHash,
+		GenTime:    anchor.GenTime,
+	}
+	token, reason := s.checkAnchor(anchor, s.blockchain.Health())
+	check.Valid = token != nil
+	check.Reason = reason
+	return check, nil
+}
+
+// checkAnchor verifies an anchor's receipt against the chain as stored and
+// returns the receipt's token, or nil and why the anchor does not hold.
+func (s *AnchorService) checkAnchor(anchor *domain.Anchor, health blockchain.Health) (*tsa.Token, string) {
+	head, ok := s.blockchain.BlockByIndex(anchor.BlockIndex)
+	if !ok {
+		return nil, fmt.Sprintf("the chain no longer reaches the anchored block %d", anchor.BlockIndex)
+	}
+	if head.Hash != anchor.BlockHash {
+		return nil, fmt.Sprintf("block %d is no longer the anchored block %s", anchor.BlockIndex, anchor.BlockHash)
+	}
+	if invalid := health.FirstInvalid; invalid != nil && invalid.Index <= anchor.BlockIndex {
+		return nil, fmt.Sprintf("the chain is broken at block %d, below the anchored block %d", invalid.Index, anchor.BlockIndex)
+	}
+	if s.config.Roots == nil {
+		return nil, "no time-stamp authority roots are configured to verify the receipt against"
+	}
+	digest, err := hex.DecodeString(anchor.BlockHash)
+	if err != nil {
+		return nil, fmt.Sprintf("the anchored hash %q is not hex", anchor.BlockHash)
+	}
+	token, err := tsa.Verify(anchor.Receipt, digest, s.config.Roots)
+	if err != nil {
+		return nil, fmt.Sprintf("the receipt of anchor %s does not verify: %v", anchor.ID, err)
+	}
+	return token, ""
+}
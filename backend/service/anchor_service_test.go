@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"certificate-ledger/blockchain"
+	"certificate-ledger/domain"
+	"certificate-ledger/tsa"
+	"certificate-ledger/tsa/tsatest"
+)
+
+// newTestTSA starts a local time-stamp authority and returns a client trusting it.
+func newTestTSA(t *testing.T) *tsa.Client {
+	t.Helper()
+	server, err := tsatest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	return tsa.NewClient(server.URL, server.Roots(), 5*time.Second)
+}
+
+// newTestAnchors anchors chain with client and trusts the client's roots.
+func newTestAnchors(env *testEnv, chain *blockchain.Blockchain, client *tsa.Client) *AnchorService {
+	return NewAnchorService(env.repos.Anchors, env.repos.Certificates, chain, client, env.audit, AnchorConfig{Interval: time.Hour, Roots: client.Roots})
+}
+
+func TestAnchorCoversIssuedCertificate(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	issuer := env.saveUser(t, "registrar@example.edu", domain.RoleIssuer)
+	cert := env.issue(t, issuer, "Bachelor of Science")
+	anchors := newTestAnchors(env, env.chain, newTestTSA(t))
+
+	before, err := anchors.VerifyCertificate(ctx, cert.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.Anchored {
+		t.Fatalf("certificate anchored before any anchor was made: %+v", before)
+	}
+
+	anchor, err := anchors.AnchorNow(ctx, issuer.ID)
+	if err != nil {
+		t.Fatalf("anchor: %v", err)
+	}
+	if head := env.chain.GetLatestBlock(); anchor.BlockIndex != head.Index || anchor.BlockHash != head.Hash {
+		t.Errorf("anchored block %d (%s), want the head %d (%s)", anchor.BlockIndex, anchor.BlockHash, head.Index, head.Hash)
+	}
+
+	result, err := anchors.VerifyCertificate(ctx, cert.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Anchored || result.Anchor == nil || result.Anchor.ID != anchor.ID || result.ExistedBefore == nil {
+		t.Errorf("verification = %+v, want the certificate covered by %s", result, anchor.ID)
+	}
+	check, err := anchors.VerifyAnchor(ctx, anchor.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !check.Valid {
+		t.Errorf("fresh anchor does not hold: %s", check.Reason)
+	}
+}
+
+func TestAnchorHoldsForReloadedChainOnly(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	issuer := env.saveUser(t, "registrar@example.edu", domain.RoleIssuer)
+	env.issue(t, issuer, "Bachelor of Science")
+	client := newTestTSA(t)
+	anchor, err := newTestAnchors(env, env.chain, client).AnchorNow(ctx, issuer.ID)
+	if err != nil {
+		t.Fatalf("anchor: %v", err)
+	}
+
+	// A restart loads the chain from the same storage; the anchor still holds.
+	reloaded, err := blockchain.NewBlockchain(ctx, 1, env.repos.Blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check, err := newTestAnchors(env, reloaded, client).VerifyAnchor(ctx, anchor.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !check.Valid {
+		t.Errorf("anchor does not hold for the reloaded chain: %s", check.Reason)
+	}
+
+	// A chain mined again from scratch has other hashes at the anchored height.
+	other := newTestEnv(t)
+	other.issue(t, other.saveUser(t, "registrar@example.edu", domain.RoleIssuer), "Bachelor of Arts")
+	rewritten := newTestAnchors(env, other.chain, client)
+	check, err = rewritten.VerifyAnchor(ctx, anchor.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.Valid || check.Reason == "" {
+		t.Errorf("anchor holds for a rewritten chain: %+v", check)
+	}
+
+	// Nor may the rewritten chain be anchored on top of the old receipts.
+	_, err = rewritten.AnchorNow(ctx, issuer.ID)
+	var domainErr *domain.Error
+	if !errors.Is(err, domain.ErrConflict) || !errors.As(err, &domainErr) || domainErr.Code != "anchor_lost" {
+		t.Errorf("anchoring a rewritten chain: got %v, want anchor_lost", err)
+	}
+}
+
+func TestAnchorsDoNotVerifyWithoutRoots(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	issuer := env.saveUser(t, "registrar@example.edu", domain.RoleIssuer)
+	cert := env.issue(t, issuer, "Bachelor of Science")
+	anchor, err := newTestAnchors(env, env.chain, newTestTSA(t)).AnchorNow(ctx, issuer.ID)
+	if err != nil {
+		t.Fatalf("anchor: %v", err)
+	}
+
+	// With anchoring off and no roots configured nothing vouches for the
+	// authority that signed the stored receipts.
+	unconfigured := NewAnchorService(env.repos.Anchors, env.repos.Certificates, env.chain, nil, env.audit, AnchorConfig{Interval: time.Hour})
+	check, err := unconfigured.VerifyAnchor(ctx, anchor.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.Valid || check.Reason == "" {
+		t.Errorf("anchor holds without roots: %+v", check)
+	}
+	result, err := unconfigured.VerifyCertificate(ctx, cert.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Anchored {
+		t.Errorf("certificate anchored without roots: %+v", result)
+	}
+
+	// Nor does a receipt verify against roots of another authority.
+	untrusted := newTestAnchors(env, env.chain, newTestTSA(t))
+	if check, err := untrusted.VerifyAnchor(ctx, anchor.ID); err != nil || check.Valid {
+		t.Errorf("anchor holds for another authority's roots: %+v, %v", check, err)
+	}
+}
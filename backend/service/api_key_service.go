@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// apiKeyPrefix marks a credential as a certificate-ledger API key: clk_<prefix>_<secret>.
+	apiKeyPrefix = "clk"
+
+	// apiKeyTouchInterval limits how often last_used_at is written for busy keys.
+	apiKeyTouchInterval = time.Minute
+
+	// apiKeyPrefixBytes is the length of the random lookup prefix. Prefixes
+	// are unique; a key that draws a taken one is generated again, up to
+	// apiKeyAttempts times.
+	apiKeyPrefixBytes = 8
+	apiKeyAttempts    = 3
+)
+
+type APIKeyService struct {
+	repo     repository.APIKeyRepository
+	userRepo repository.UserRepository
+	audit    *AuditService
+}
+
+func NewAPIKeyService(repo repository.APIKeyRepository, userRepo repository.UserRepository, audit *AuditService) *APIKeyService {
+	return &APIKeyService{
+		repo:     repo,
+		userRepo: userRepo,
+		audit:    audit,
+	}
+}
+
+// CreateKey creates a key for the user. Only issuers and admins have keys,
+// and a key carries no scope its owner's role lacks.
+func (s *APIKeyService) CreateKey(ctx context.Context, req domain.APIKeyRequest, user *domain.User) (*domain.APIKeyResponse, error) {
+	if user.Role != domain.RoleIssuer && user.Role != domain.RoleAdmin {
+		return nil, domain.Forbidden("api_keys_not_allowed", "only issuers and admins can create api keys")
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, domain.Invalid("name_required", "api key name is required")
+	}
+	if len(req.Scopes) == 0 {
+		return nil, domain.Invalid("scope_required", "at least one scope is required")
+	}
+	for _, scope := range req.Scopes {
+		if !isKnownScope(scope) {
+			return nil, domain.Invalid("unknown_scope", "unknown scope %s", scope)
+		}
+		if !domain.RoleAllowsScope(user.Role, scope) {
+			return nil, domain.Forbidden("scope_not_allowed", "role %s cannot grant scope %s", user.Role, scope)
+		}
+	}
+	if req.ExpiresInDays < 0 {
+		return nil, domain.Invalid("invalid_expiry", "expiresInDays must not be negative")
+	}
+
+	now := time.Now()
+	var key *domain.APIKey
+	var rawKey string
+	for attempt := 1; ; attempt++ {
+		var err error
+		key, rawKey, err = newAPIKey(user.ID, strings.TrimSpace(req.Name), req.Scopes, now)
+		if err != nil {
+			return nil, err
+		}
+		if req.ExpiresInDays > 0 {
+			expiresAt := now.AddDate(0, 0, req.ExpiresInDays)
+			key.ExpiresAt = &expiresAt
+		}
+		err = s.repo.Save(ctx, key)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, domain.ErrConflict) || attempt == apiKeyAttempts {
+			return nil, err
+		}
+	}
+
+	s.audit.Record(ctx, AuditEvent{
+		ActorID:    user.ID,
+		Action:     domain.AuditAPIKeyCreate,
+		TargetType: domain.AuditTargetAPIKey,
+		TargetID:   key.ID,
+		After:      key,
+	})
+
+	return &domain.APIKeyResponse{
+		APIKey: *key,
+		Key:    rawKey,
+	}, nil
+}
+
+func (s *APIKeyService) ListKeys(ctx context.Context, userID string) ([]*domain.APIKey, error) {
+	return s.repo.FindByUserID(ctx, userID)
+}
+
+// RevokeKey revokes one of the caller's keys; admins may revoke any key.
+func (s *APIKeyService) RevokeKey(ctx context.Context, keyID string, user *domain.User) error {
+	key, err := s.repo.FindByID(ctx, keyID)
+	if err != nil {
+		return err
+	}
+	if key.UserID != user.ID && user.Role != domain.RoleAdmin {
+		return domain.NotFound("api_key_not_found", "api key with ID %s not found", keyID)
+	}
+	now := time.Now()
+	if err := s.repo.Revoke(ctx, key.ID, now); err != nil {
+		return err
+	}
+	revoked := *key
+	revoked.RevokedAt = &now
+	s.audit.Record(ctx, AuditEvent{
+		ActorID:    user.ID,
+		Action:     domain.AuditAPIKeyRevoke,
+		TargetType: domain.AuditTargetAPIKey,
+		TargetID:   key.ID,
+		Before:     key,
+		After:      &revoked,
+	})
+	return nil
+}
+
+// Authenticate resolves a raw API key to its key record and owning user.
+func (s *APIKeyService) Authenticate(ctx context.Context, rawKey string) (*domain.APIKey, *domain.User, error) {
+	parts := strings.SplitN(rawKey, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyPrefix {
+		return nil, nil, domain.Unauthorized("invalid_api_key", "malformed api key")
+	}
+
+	key, err := s.repo.FindByPrefix(ctx, parts[1])
+	if err != nil {
+		return nil, nil, domain.Unauthorized("invalid_api_key", "invalid api key")
+	}
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(hashAPIKey(rawKey))) != 1 {
+		return nil, nil, domain.Unauthorized("invalid_api_key", "invalid api key")
+	}
+
+	now := time.Now()
+	if !key.IsActive(now) {
+		return nil, nil, domain.Unauthorized("api_key_inactive", "api key is revoked or expired")
+	}
+
+	user, err := s.userRepo.FindByID(ctx, key.UserID)
+	if err != nil {
+		return nil, nil, domain.Unauthorized("invalid_api_key", "api key owner not found")
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.repo.UpdateLastUsed(ctx, key.ID, now); err == nil {
+			key.LastUsedAt = &now
+		}
+	}
+
+	return key, user, nil
+}
+
+// newAPIKey generates a key with a random prefix and secret and returns its
+// record together with the raw key.
+func newAPIKey(userID, name string, scopes []string, now time.Time) (*domain.APIKey, string, error) {
+	prefix, err := randomHex(apiKeyPrefixBytes)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate api key: %v", err)
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate api key: %v", err)
+	}
+	rawKey := fmt.Sprintf("%s_%s_%s", apiKeyPrefix, prefix, base64.RawURLEncoding.EncodeToString(secret))
+	return &domain.APIKey{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   hashAPIKey(rawKey),
+		Scopes:    scopes,
+		CreatedAt: now,
+	}, rawKey, nil
+}
+
+func hashAPIKey(rawKey string) string {
+	sum := sha256.Sum256([]byte(rawKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func isKnownScope(scope string) bool {
+	for _, s := range domain.APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
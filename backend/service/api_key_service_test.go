@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/repository"
+)
+
+func TestAPIKeyAuthenticatesItsOwner(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	issuer := env.saveUser(t, "registrar@example.edu", domain.RoleIssuer)
+	keys := NewAPIKeyService(env.repos.APIKeys, env.repos.Users, env.audit)
+
+	created, err := keys.CreateKey(ctx, domain.APIKeyRequest{Name: "sis", Scopes: []string{domain.ScopeCertificatesIssue}}, issuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created.APIKey.Prefix) != 2*apiKeyPrefixBytes {
+		t.Errorf("prefix %q, want %d hex digits", created.APIKey.Prefix, 2*apiKeyPrefixBytes)
+	}
+
+	key, user, err := keys.Authenticate(ctx, created.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != issuer.ID || !key.HasScope(domain.ScopeCertificatesIssue) || key.HasScope(domain.ScopeCertificatesRead) {
+		t.Errorf("authenticated %s with scopes %v", user.ID, key.Scopes)
+	}
+
+	if _, _, err := keys.Authenticate(ctx, created.Key+"x"); !errors.Is(err, domain.ErrUnauthorized) {
+		t.Errorf("altered key: got %v, want unauthorized", err)
+	}
+	if err := keys.RevokeKey(ctx, key.ID, issuer); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := keys.Authenticate(ctx, created.Key); !errors.Is(err, domain.ErrUnauthorized) {
+		t.Errorf("revoked key: got %v, want unauthorized", err)
+	}
+}
+
+func TestAPIKeyRefusesUsersAndForeignScopes(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	keys := NewAPIKeyService(env.repos.APIKeys, env.repos.Users, env.audit)
+
+	user := env.saveUser(t, "alice@example.com", domain.RoleUser)
+	if _, err := keys.CreateKey(ctx, domain.APIKeyRequest{Name: "mine", Scopes: []string{domain.ScopeCertificatesRead}}, user); !errors.Is(err, domain.ErrForbidden) {
+		t.Errorf("user: got %v, want forbidden", err)
+	}
+	issuer := env.saveUser(t, "registrar@example.edu", domain.RoleIssuer)
+	if _, err := keys.CreateKey(ctx, domain.APIKeyRequest{Name: "all", Scopes: []string{"users:admin"}}, issuer); !errors.Is(err, domain.ErrValidation) {
+		t.Errorf("unknown scope: got %v, want a validation error", err)
+	}
+}
+
+// collidingAPIKeys reports the first saves as prefix conflicts.
+type collidingAPIKeys struct {
+	repository.APIKeyRepository
+	collisions int
+}
+
+func (r *collidingAPIKeys) Save(ctx context.Context, key *domain.APIKey) error {
+	if r.collisions > 0 {
+		r.collisions--
+		return domain.Conflict("api_key_exists", "api key with prefix %s already exists", key.Prefix)
+	}
+	return r.APIKeyRepository.Save(ctx, key)
+}
+
+func TestAPIKeyRetriesTakenPrefixes(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	issuer := env.saveUser(t, "registrar@example.edu", domain.RoleIssuer)
+	req := domain.APIKeyRequest{Name: "sis", Scopes: []string{domain.ScopeCertificatesRead}}
+
+	repo := &collidingAPIKeys{APIKeyRepository: env.repos.APIKeys, collisions: apiKeyAttempts - 1}
+	created, err := NewAPIKeyService(repo, env.repos.Users, env.audit).CreateKey(ctx, req, issuer)
+	if err != nil {
+		t.Fatalf("after %d collisions: %v", apiKeyAttempts-1, err)
+	}
+	if _, _, err := NewAPIKeyService(env.repos.APIKeys, env.repos.Users, env.audit).Authenticate(ctx, created.Key); err != nil {
+		t.Errorf("retried key does not authenticate: %v", err)
+	}
+
+	repo.collisions = apiKeyAttempts
+	if _, err := NewAPIKeyService(repo, env.repos.Users, env.audit).CreateKey(ctx, req, issuer); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("after %d collisions: got %v, want conflict", apiKeyAttempts, err)
+	}
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/repository"
+)
+
+// maxAuditAppendAttempts bounds the retries when another instance appends
+// the same Seq first.
+const maxAuditAppendAttempts = 5
+
+// maxUserAgentLength matches the user_agent column.
+const maxUserAgentLength = 512
+
+// RequestMeta describes the HTTP request an action came from. Handlers put
+// it in the context so audit entries can name the client without every
+// service method taking it as a parameter.
+type RequestMeta struct {
+	IP        string
+	UserAgent string
+}
+
+type requestMetaKey struct{}
+
+func WithRequestMeta(ctx context.Context, meta RequestMeta) context.Context {
+	return context.WithValue(ctx, requestMetaKey{}, meta)
+}
+
+// RequestMetaFrom returns the RequestMeta put in ctx by WithRequestMeta.
+func RequestMetaFrom(ctx context.Context) RequestMeta {
+	meta, _ := ctx.Value(requestMetaKey{}).(RequestMeta)
+	return meta
+}
+
+// AuditEvent is an action to record. Before and After are snapshots of the
+// target, marshalled to JSON; leave them nil when there is no such state.
+type AuditEvent struct {
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	Before     interface{}
+	After      interface{}
+}
+
+// AuditService appends to the hash-chained audit log and checks its integrity.
+type AuditService struct {
+	repo repository.AuditRepository
+	// mu serializes appends from this process; other instances are handled
+	// by retrying on a Seq conflict.
+	mu sync.Mutex
+}
+
+func NewAuditService(repo repository.AuditRepository) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+// Record appends an entry for an action that has already happened. A failure
+// is logged rather than returned, and the entry is written even if ctx is
+// cancelled, since the action itself cannot be undone.
+func (s *AuditService) Record(ctx context.Context, event AuditEvent) {
+	meta := RequestMetaFrom(ctx)
+	entry := &domain.AuditEntry{
+		CreatedAt:  time.Now().UTC().Truncate(time.Microsecond),
+		ActorID:    event.ActorID,
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		IP:         meta.IP,
+		UserAgent:  truncateRunes(meta.UserAgent, maxUserAgentLength),
+	}
+
+	var err error
+	if entry.Before, err = auditSnapshot(event.Before); err == nil {
+		entry.After, err = auditSnapshot(event.After)
+	}
+	if err == nil {
+		err = s.append(context.WithoutCancel(ctx), entry)
+	}
+	if err != nil {
+		log.Printf("Failed to record audit entry %s %s/%s: %v", event.Action, event.TargetType, event.TargetID, err)
+	}
+}
+
+func (s *AuditService) append(ctx context.Context, entry *domain.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for attempt := 1; ; attempt++ {
+		last, err := s.repo.Last(ctx)
+		if err != nil {
+			return err
+		}
+		entry.Seq = 1
+		entry.PreviousHash = domain.AuditGenesisHash
+		if last != nil {
+			entry.Seq = last.Seq + 1
+			entry.PreviousHash = last.Hash
+		}
+		entry.Hash = entry.ComputeHash()
+
+		err = s.repo.Append(ctx, entry)
+		if errors.Is(err, domain.ErrConflict) && attempt < maxAuditAppendAttempts {
+			continue
+		}
+		return err
+	}
+}
+
+// List returns a page of entries, newest first.
+func (s *AuditService) List(ctx context.Context, q domain.AuditQuery) (*domain.AuditPage, error) {
+	if q.Limit <= 0 {
+		q.Limit = domain.DefaultAuditLimit
+	}
+	if q.Limit > domain.MaxAuditLimit {
+		q.Limit = domain.MaxAuditLimit
+	}
+	if q.Filter.From != nil && q.Filter.To != nil && !q.Filter.To.After(*q.Filter.From) {
+		return nil, domain.Invalid("invalid_date_range", "to must be after from")
+	}
+	return s.repo.FindPage(ctx, q)
+}
+
+// Verify walks the whole log and reports every entry that was edited, every
+// gap left by deleted entries and every broken link. When headSeq is
+// positive, the entry with that Seq must still carry headHash; this catches
+// entries removed from the end since the head was recorded.
+func (s *AuditService) Verify(ctx context.Context, headSeq int64, headHash string) (*domain.AuditVerification, error) {
+	result := &domain.AuditVerification{Problems: []domain.AuditProblem{}}
+	report := func(seq int64, format string, args ...interface{}) {
+		result.Problems = append(result.Problems, domain.AuditProblem{Seq: seq, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	expected := int64(1)
+	previousHash := domain.AuditGenesisHash
+	headFound := false
+	err := s.repo.Walk(ctx, func(entry *domain.AuditEntry) error {
+		result.Entries++
+		switch {
+		case entry.Seq == expected+1:
+			report(entry.Seq, "entry %d is missing", expected)
+		case entry.Seq > expected:
+			report(entry.Seq, "entries %d to %d are missing", expected, entry.Seq-1)
+		case entry.Seq < expected:
+			report(entry.Seq, "entry is out of order")
+		case entry.PreviousHash != previousHash:
+			report(entry.Seq, "previous hash does not match entry %d", entry.Seq-1)
+		}
+		if entry.ComputeHash() != entry.Hash {
+			report(entry.Seq, "contents do not match the entry's hash")
+		}
+		if entry.Seq == headSeq {
+			headFound = true
+			if entry.Hash != headHash {
+				report(entry.Seq, "hash differs from the recorded head %s", headHash)
+			}
+		}
+
+		expected = entry.Seq + 1
+		previousHash = entry.Hash
+		result.HeadSeq = entry.Seq
+		result.HeadHash = entry.Hash
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if headSeq > 0 && !headFound {
+		report(headSeq, "recorded head is missing; the log ends at entry %d", result.HeadSeq)
+	}
+	return result, nil
+}
+
+// auditSnapshot marshals a target's state, returning nil for no state.
+func auditSnapshot(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal audit snapshot: %v", err)
+	}
+	if string(data) == "null" {
+		return nil, nil
+	}
+	return data, nil
+}
+
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
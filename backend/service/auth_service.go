@@ -1,31 +1,76 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"os"
+	"strings"
 	"time"
 
 	"certificate-ledger/domain"
 	"certificate-ledger/repository"
+	"certificate-ledger/totp"
+	"certificate-ledger/validation"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	// mfaTokenPurpose marks the short-lived token handed out between the password and TOTP steps.
+	mfaTokenPurpose = "mfa"
+	mfaTokenTTL     = 5 * time.Minute
+
+	recoveryCodeCount = 10
+	totpSkew          = 1
+)
+
+// recoveryCodeAlphabet omits characters that are easily confused when typed by hand.
+const recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// JWTConfig controls the tokens AuthService signs. Secret also keys the
+// signatures of emailed links and SSO state.
+type JWTConfig struct {
+	Secret string
+	TTL    time.Duration
+	// Issuer, when set, is written to the iss claim and required on parse.
+	Issuer string
+}
+
 type AuthService struct {
-	repo *repository.UserRepository
+	repo         repository.UserRepository
+	recoveryRepo repository.RecoveryCodeRepository
+	throttle     *LoginThrottleService
+	tokens       JWTConfig
+	audit        *AuditService
+	// totpIssuer names the service in authenticator apps.
+	totpIssuer string
 }
 
-func NewAuthService(repo *repository.UserRepository) *AuthService {
+func NewAuthService(repo repository.UserRepository, recoveryRepo repository.RecoveryCodeRepository, throttle *LoginThrottleService, tokens JWTConfig, audit *AuditService, totpIssuer string) *AuthService {
 	return &AuthService{
-		repo: repo,
+		repo:         repo,
+		recoveryRepo: recoveryRepo,
+		throttle:     throttle,
+		tokens:       tokens,
+		audit:        audit,
+		totpIssuer:   totpIssuer,
 	}
 }
 
-func (s *AuthService) Register(req domain.UserRequest) (*domain.User, error) {
-	_, err := s.repo.FindByEmail(req.Email)
+// Register creates an account with the user role; only admins give other
+// roles, through UserService.CreateUser.
+func (s *AuthService) Register(ctx context.Context, req domain.UserRequest) (*domain.User, error) {
+	if err := validation.RegisterRequest(req); err != nil {
+		return nil, err
+	}
+
+	_, err := s.repo.FindByEmail(ctx, req.Email)
 	if err == nil {
-		return nil, fmt.Errorf("user with email %s already exists", req.Email)
+		return nil, domain.Conflict("email_taken", "user with email %s already exists", req.Email)
 	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
@@ -33,49 +78,399 @@ func (s *AuthService) Register(req domain.UserRequest) (*domain.User, error) {
 		return nil, fmt.Errorf("failed to hash password: %v", err)
 	}
 
-	role := req.Role
-	if role == "" {
-		role = "user"
-	}
-
 	user := &domain.User{
 		Name:      req.Name,
 		Email:     req.Email,
 		Password:  string(hashedPassword),
-		Role:      role,
+		Role:      domain.RoleUser,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 
-	if err := s.repo.Save(user); err != nil {
-		return nil, fmt.Errorf("failed to save user: %v", err)
+	if err := s.repo.Save(ctx, user); err != nil {
+		return nil, err
 	}
+	s.audit.Record(ctx, AuditEvent{
+		ActorID:    user.ID,
+		Action:     domain.AuditUserRegister,
+		TargetType: domain.AuditTargetUser,
+		TargetID:   user.ID,
+		After:      user,
+	})
 
 	return user, nil
 }
 
-func (s *AuthService) Login(req domain.LoginRequest) (*domain.AuthResponse, error) {
-	user, err := s.repo.FindByEmail(req.Email)
+// Login checks the password. Accounts with TOTP enabled, or whose role requires
+// a second factor, receive an MFA challenge instead of a session token.
+// Failed attempts are throttled per account and per client IP.
+func (s *AuthService) Login(ctx context.Context, req domain.LoginRequest, ip string) (*domain.AuthResponse, error) {
+	if err := validation.LoginRequest(req); err != nil {
+		return nil, err
+	}
+
+	if err := s.throttle.Begin(ctx, req.Email, ip); err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.FindByEmail(ctx, req.Email)
 	if err != nil {
-		return nil, fmt.Errorf("invalid email or password")
+		s.throttle.Fail(ctx, req.Email, ip)
+		return nil, domain.Unauthorized("invalid_credentials", "invalid email or password")
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return nil, fmt.Errorf("invalid email or password")
+		s.throttle.Fail(ctx, req.Email, ip)
+		return nil, domain.Unauthorized("invalid_credentials", "invalid email or password")
+	}
+
+	if user.TOTPEnabled || domain.RequiresTwoFactor(user.Role) {
+		s.throttle.Release(ctx, req.Email, ip)
+		return s.challenge(user)
+	}
+
+	s.throttle.Succeed(ctx, req.Email, ip)
+	return s.issueSession(user)
+}
+
+// CompleteExternalLogin finishes a login authenticated by an external identity
+// provider. When the provider already performed multi-factor authentication
+// the local second factor is skipped; otherwise the same rules as Login apply.
+func (s *AuthService) CompleteExternalLogin(ctx context.Context, user *domain.User, secondFactorDone bool) (*domain.AuthResponse, error) {
+	if !secondFactorDone && (user.TOTPEnabled || domain.RequiresTwoFactor(user.Role)) {
+		return s.challenge(user)
+	}
+	return s.issueSession(user)
+}
+
+// LoginTOTP completes a login started by Login. For enrolled accounts the code
+// may be a TOTP code or an unused recovery code; for accounts still enrolling it
+// must be a TOTP code for the pending secret, which activates two-factor auth.
+func (s *AuthService) LoginTOTP(ctx context.Context, req domain.TOTPLoginRequest, ip string) (*domain.AuthResponse, error) {
+	user, err := s.userFromMFAToken(ctx, req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.throttle.Begin(ctx, user.Email, ip); err != nil {
+		return nil, err
+	}
+
+	if !user.TOTPEnabled {
+		codes, err := s.activateTOTP(ctx, user, req.Code)
+		if err != nil {
+			s.throttle.Fail(ctx, user.Email, ip)
+			return nil, err
+		}
+		s.throttle.Succeed(ctx, user.Email, ip)
+		resp, err := s.issueSession(user)
+		if err != nil {
+			return nil, err
+		}
+		resp.RecoveryCodes = codes
+		return resp, nil
+	}
+
+	if err := s.checkSecondFactor(ctx, user, req.Code); err != nil {
+		s.throttle.Fail(ctx, user.Email, ip)
+		return nil, err
+	}
+	s.throttle.Succeed(ctx, user.Email, ip)
+	return s.issueSession(user)
+}
+
+// AuthenticateSession returns the user a session token was issued to.
+func (s *AuthService) AuthenticateSession(ctx context.Context, tokenString string) (*domain.User, error) {
+	invalid := domain.Unauthorized("invalid_token", "invalid token")
+	claims, err := s.parseToken(tokenString)
+	if err != nil {
+		return nil, invalid
+	}
+	if _, pending := claims["purpose"]; pending {
+		return nil, invalid
+	}
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return nil, invalid
+	}
+	// Tokens from before versions were introduced carry none and count as 0.
+	version, _ := claims["ver"].(float64)
+
+	user, err := s.repo.FindByID(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, invalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if int(version) != user.TokenVersion {
+		return nil, invalid
+	}
+	return user, nil
+}
+
+// ChangePassword replaces the password of a signed-in user who knows the
+// current one, and lifts a required password change.
+func (s *AuthService) ChangePassword(ctx context.Context, userID string, req domain.ChangePasswordRequest) error {
+	if err := validation.ChangePasswordRequest(req); err != nil {
+		return err
+	}
+
+	user, err := s.repo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+		return domain.Forbidden("invalid_current_password", "current password is incorrect")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %v", err)
+	}
+	user.Password = string(hashedPassword)
+	user.PasswordChangeRequired = false
+	return s.repo.Save(ctx, user)
+}
+
+// BeginLoginEnrollment starts TOTP enrollment for an account that must enroll before it can log in.
+func (s *AuthService) BeginLoginEnrollment(ctx context.Context, mfaToken string) (*domain.TOTPEnrollment, error) {
+	user, err := s.userFromMFAToken(ctx, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	return s.beginEnrollment(ctx, user)
+}
+
+func (s *AuthService) BeginEnrollment(ctx context.Context, userID string) (*domain.TOTPEnrollment, error) {
+	user, err := s.repo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.beginEnrollment(ctx, user)
+}
+
+func (s *AuthService) ConfirmEnrollment(ctx context.Context, userID, code string) ([]string, error) {
+	user, err := s.repo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.activateTOTP(ctx, user, code)
+}
+
+func (s *AuthService) DisableTOTP(ctx context.Context, userID, code string) error {
+	user, err := s.repo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return domain.Conflict("totp_not_enabled", "two-factor authentication is not enabled")
+	}
+	if domain.RequiresTwoFactor(user.Role) {
+		return domain.Forbidden("totp_required", "two-factor authentication is mandatory for role %s", user.Role)
+	}
+	if err := s.checkSecondFactor(ctx, user, code); err != nil {
+		return err
+	}
+
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPLastStep = 0
+	if err := s.repo.Save(ctx, user); err != nil {
+		return err
+	}
+	return s.recoveryRepo.DeleteForUser(ctx, user.ID)
+}
+
+func (s *AuthService) RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error) {
+	user, err := s.repo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, domain.Conflict("totp_not_enabled", "two-factor authentication is not enabled")
+	}
+	if err := s.checkTOTP(ctx, user, code); err != nil {
+		return nil, err
+	}
+	return s.replaceRecoveryCodes(ctx, user.ID)
+}
+
+func (s *AuthService) beginEnrollment(ctx context.Context, user *domain.User) (*domain.TOTPEnrollment, error) {
+	if user.TOTPEnabled {
+		return nil, domain.Conflict("totp_already_enabled", "two-factor authentication is already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	user.TOTPSecret = secret
+	user.TOTPLastStep = 0
+	if err := s.repo.Save(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return &domain.TOTPEnrollment{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(s.totpIssuer, user.Email, secret),
+	}, nil
+}
+
+func (s *AuthService) activateTOTP(ctx context.Context, user *domain.User, code string) ([]string, error) {
+	if user.TOTPEnabled {
+		return nil, domain.Conflict("totp_already_enabled", "two-factor authentication is already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return nil, domain.Conflict("totp_enrollment_not_started", "two-factor enrollment has not been started")
+	}
+	if err := s.checkTOTP(ctx, user, code); err != nil {
+		return nil, err
+	}
+
+	user.TOTPEnabled = true
+	if err := s.repo.Save(ctx, user); err != nil {
+		return nil, err
+	}
+	return s.replaceRecoveryCodes(ctx, user.ID)
+}
+
+// checkSecondFactor accepts either a TOTP code or a single-use recovery code.
+func (s *AuthService) checkSecondFactor(ctx context.Context, user *domain.User, code string) error {
+	if err := s.checkTOTP(ctx, user, code); err == nil {
+		return nil
+	}
+
+	ok, err := s.recoveryRepo.Consume(ctx, user.ID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return domain.Unauthorized("invalid_totp_code", "invalid two-factor code")
+	}
+	return nil
+}
+
+// checkTOTP validates a TOTP code and records its time step so it cannot be replayed.
+func (s *AuthService) checkTOTP(ctx context.Context, user *domain.User, code string) error {
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now(), totpSkew)
+	if !ok || step <= user.TOTPLastStep {
+		return domain.Unauthorized("invalid_totp_code", "invalid two-factor code")
+	}
+
+	user.TOTPLastStep = step
+	if err := s.repo.Save(ctx, user); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *AuthService) replaceRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = hashRecoveryCode(code)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	if err := s.recoveryRepo.ReplaceForUser(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *AuthService) userFromMFAToken(ctx context.Context, tokenString string) (*domain.User, error) {
+	claims, err := s.parseToken(tokenString)
+	if err != nil || claims["purpose"] != mfaTokenPurpose {
+		return nil, domain.Unauthorized("invalid_mfa_token", "invalid or expired mfa token")
+	}
+
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return nil, domain.Unauthorized("invalid_mfa_token", "invalid or expired mfa token")
+	}
+	user, err := s.repo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, domain.Unauthorized("invalid_mfa_token", "invalid or expired mfa token")
+	}
+	return user, nil
+}
+
+func (s *AuthService) challenge(user *domain.User) (*domain.AuthResponse, error) {
+	mfaToken, err := s.signToken(jwt.MapClaims{
+		"user_id": user.ID,
+		"purpose": mfaTokenPurpose,
+		"exp":     time.Now().Add(mfaTokenTTL).Unix(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %v", err)
+	}
+	return &domain.AuthResponse{
+		MFARequired:        true,
+		MFAToken:           mfaToken,
+		EnrollmentRequired: !user.TOTPEnabled,
+	}, nil
+}
+
+func (s *AuthService) issueSession(user *domain.User) (*domain.AuthResponse, error) {
+	tokenString, err := s.signToken(jwt.MapClaims{
 		"user_id": user.ID,
 		"role":    user.Role,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
+		"ver":     user.TokenVersion,
+		"exp":     time.Now().Add(s.tokens.TTL).Unix(),
 	})
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %v", err)
 	}
 
 	return &domain.AuthResponse{
 		Token: tokenString,
-		User:  *user,
+		User:  user,
 	}, nil
-}
\ No newline at end of file
+}
+
+func (s *AuthService) signToken(claims jwt.MapClaims) (string, error) {
+	if s.tokens.Issuer != "" {
+		claims["iss"] = s.tokens.Issuer
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(s.tokens.Secret))
+}
+
+func (s *AuthService) parseToken(tokenString string) (jwt.MapClaims, error) {
+	options := []jwt.ParserOption{jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()})}
+	if s.tokens.Issuer != "" {
+		options = append(options, jwt.WithIssuer(s.tokens.Issuer))
+	}
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte(s.tokens.Secret), nil
+	}, options...)
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, domain.Unauthorized("invalid_token", "invalid token")
+	}
+	return claims, nil
+}
+
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate recovery code: %v", err)
+	}
+	for i := range b {
+		b[i] = recoveryCodeAlphabet[int(b[i])%len(recoveryCodeAlphabet)]
+	}
+	return string(b[:5]) + "-" + string(b[5:]), nil
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
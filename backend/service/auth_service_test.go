@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/totp"
+)
+
+func TestRegisterAndLogin(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+
+	user, err := env.auth.Register(ctx, domain.UserRequest{Name: "Alice", Email: "alice@example.com", Password: testPassword})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if user.Role != domain.RoleUser {
+		t.Errorf("registered with role %q, want %q", user.Role, domain.RoleUser)
+	}
+	if _, err := env.auth.Register(ctx, domain.UserRequest{Name: "Alice", Email: "alice@example.com", Password: testPassword}); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("second registration: got %v, want a conflict", err)
+	}
+
+	resp, err := env.auth.Login(ctx, domain.LoginRequest{Email: "alice@example.com", Password: testPassword}, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if resp.Token == "" || resp.MFARequired {
+		t.Fatalf("login gave %+v, want a session token", resp)
+	}
+	session, err := env.auth.AuthenticateSession(ctx, resp.Token)
+	if err != nil || session.ID != user.ID {
+		t.Errorf("AuthenticateSession = %v, %v; want %s", session, err, user.ID)
+	}
+
+	if _, err := env.auth.Login(ctx, domain.LoginRequest{Email: "alice@example.com", Password: "wrong password!"}, "192.0.2.1"); !errors.Is(err, domain.ErrUnauthorized) {
+		t.Errorf("wrong password: got %v, want unauthorized", err)
+	}
+	if _, err := env.auth.Login(ctx, domain.LoginRequest{Email: "nobody@example.com", Password: testPassword}, "192.0.2.1"); !errors.Is(err, domain.ErrUnauthorized) {
+		t.Errorf("unknown email: got %v, want unauthorized", err)
+	}
+}
+
+func TestLoginChallengesRolesThatRequireTwoFactor(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	env.saveUser(t, "issuer@example.com", domain.RoleIssuer)
+
+	resp, err := env.auth.Login(ctx, domain.LoginRequest{Email: "issuer@example.com", Password: testPassword}, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if resp.Token != "" || !resp.MFARequired || !resp.EnrollmentRequired || resp.MFAToken == "" {
+		t.Errorf("login gave %+v, want an enrollment challenge without a session", resp)
+	}
+	if _, err := env.auth.AuthenticateSession(ctx, resp.MFAToken); err == nil {
+		t.Error("the MFA token was accepted as a session")
+	}
+}
+
+func TestTOTPEnrollmentAtLoginAndRecoveryCodes(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	env.saveUser(t, "issuer@example.com", domain.RoleIssuer)
+	login := domain.LoginRequest{Email: "issuer@example.com", Password: testPassword}
+
+	challenge, err := env.auth.Login(ctx, login, "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	enrollment, err := env.auth.BeginLoginEnrollment(ctx, challenge.MFAToken)
+	if err != nil {
+		t.Fatalf("begin enrollment: %v", err)
+	}
+	code, err := totp.Code(enrollment.Secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := env.auth.LoginTOTP(ctx, domain.TOTPLoginRequest{MFAToken: challenge.MFAToken, Code: code}, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("confirm enrollment: %v", err)
+	}
+	if resp.Token == "" || len(resp.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("enrollment gave %+v, want a session and %d recovery codes", resp, recoveryCodeCount)
+	}
+
+	// The next login needs the second factor, and the code just used is spent.
+	challenge, err = env.auth.Login(ctx, login, "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if challenge.Token != "" || challenge.EnrollmentRequired {
+		t.Fatalf("login after enrollment gave %+v, want a TOTP challenge", challenge)
+	}
+	if _, err := env.auth.LoginTOTP(ctx, domain.TOTPLoginRequest{MFAToken: challenge.MFAToken, Code: code}, "192.0.2.1"); !errors.Is(err, domain.ErrUnauthorized) {
+		t.Errorf("replayed code: got %v, want unauthorized", err)
+	}
+
+	recovery := domain.TOTPLoginRequest{MFAToken: challenge.MFAToken, Code: resp.RecoveryCodes[0]}
+	if resp, err := env.auth.LoginTOTP(ctx, recovery, "192.0.2.1"); err != nil || resp.Token == "" {
+		t.Errorf("recovery code: got %+v, %v; want a session", resp, err)
+	}
+	if _, err := env.auth.LoginTOTP(ctx, recovery, "192.0.2.1"); !errors.Is(err, domain.ErrUnauthorized) {
+		t.Errorf("reused recovery code: got %v, want unauthorized", err)
+	}
+}
+
+func TestDisableTOTPIsRefusedForRolesThatRequireIt(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+
+	for _, tc := range []struct {
+		role string
+		want error
+	}{
+		{domain.RoleUser, nil},
+		{domain.RoleIssuer, domain.ErrForbidden},
+	} {
+		user := env.saveUser(t, tc.role+"@example.com", tc.role)
+		enrollment, err := env.auth.BeginEnrollment(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, _ := totp.Code(enrollment.Secret, time.Now())
+		if _, err := env.auth.ConfirmEnrollment(ctx, user.ID, code); err != nil {
+			t.Fatalf("%s: confirm enrollment: %v", tc.role, err)
+		}
+		// A fresh code is needed; the enrollment one has been used.
+		next, _ := totp.Code(enrollment.Secret, time.Now().Add(totp.Period*time.Second))
+		err = env.auth.DisableTOTP(ctx, user.ID, next)
+		if tc.want == nil && err != nil || tc.want != nil && !errors.Is(err, tc.want) {
+			t.Errorf("%s: disable gave %v, want %v", tc.role, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,494 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/repository"
+	"certificate-ledger/spreadsheet"
+	"certificate-ledger/validation"
+
+	"github.com/google/uuid"
+)
+
+// MaxBulkIssueRows caps the data rows accepted in one upload.
+const MaxBulkIssueRows = 5000
+
+// bulkJobLease is how long a job stays claimed by the instance running it.
+// The lease is renewed before every row, so another instance only takes the
+// job over once its runner has stopped for that long.
+const bulkJobLease = 2 * time.Minute
+
+// errBulkJobLeaseLost stops a job whose lease another instance has taken.
+var errBulkJobLeaseLost = errors.New("lease taken over by another instance")
+
+// bulkColumns maps normalized header names to the request field they fill.
+var bulkColumns = map[string]func(*domain.CertificateRequest, string){
+	"recipientname":    func(r *domain.CertificateRequest, v string) { r.RecipientName = v },
+	"recipientemail":   func(r *domain.CertificateRequest, v string) { r.RecipientEmail = v },
+	"certificatetitle": func(r *domain.CertificateRequest, v string) { r.CertificateTitle = v },
+	"issuedate":        func(r *domain.CertificateRequest, v string) { r.IssueDate = v },
+	"issuername":       func(r *domain.CertificateRequest, v string) { r.IssuerName = v },
+	"description":      func(r *domain.CertificateRequest, v string) { r.Description = v },
+	"recipientlocale":  func(r *domain.CertificateRequest, v string) { r.RecipientLocale = v },
+}
+
+var requiredBulkColumns = []string{"recipientName", "recipientEmail", "certificateTitle", "issueDate", "issuerName"}
+
+// BulkIssueService issues certificates from uploaded CSV or XLSX files. Valid
+// rows are issued by a background job whose rows are stored, so a job cut
+// short by a restart resumes where it stopped. An instance runs a job only
+// while it holds the job's lease, so instances sharing a database never run
+// the same job at once.
+type BulkIssueService struct {
+	repo         repository.BulkIssueRepository
+	certificates *CertificateService
+	audit        *AuditService
+	// jobs is the context jobs run under, since they outlive the upload
+	// request. Cancelling it stops them; they resume at the next start.
+	jobs    context.Context
+	running sync.WaitGroup
+	// instanceID owns the leases this instance takes.
+	instanceID string
+	mu         sync.Mutex
+	// active holds the IDs of the jobs running in this instance.
+	active map[string]bool
+}
+
+func NewBulkIssueService(jobs context.Context, repo repository.BulkIssueRepository, certificates *CertificateService, audit *AuditService) *BulkIssueService {
+	return &BulkIssueService{
+		repo:         repo,
+		certificates: certificates,
+		audit:        audit,
+		jobs:         jobs,
+		instanceID:   uuid.New().String(),
+		active:       make(map[string]bool),
+	}
+}
+
+// Upload validates every row of the file. A dry run only reports the errors;
+// otherwise the valid rows are queued for issuance and the report carries the job.
+func (s *BulkIssueService) Upload(ctx context.Context, fileName string, file io.Reader, dryRun bool, user *domain.User) (*domain.BulkIssueReport, error) {
+	records, err := spreadsheet.Read(fileName, file)
+	if err != nil {
+		return nil, domain.Invalid("invalid_file", "%v", err)
+	}
+	rows, err := parseBulkRows(records)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &domain.BulkIssueReport{DryRun: dryRun, TotalRows: len(rows), Errors: []*domain.BulkIssueRow{}}
+	for _, row := range rows {
+		if row.Status == domain.BulkRowInvalid {
+			report.InvalidRows++
+			report.Errors = append(report.Errors, row)
+		} else {
+			report.ValidRows++
+		}
+	}
+	if dryRun {
+		return report, nil
+	}
+	if report.ValidRows == 0 {
+		return nil, domain.Invalid("no_valid_rows", "the file has no valid rows to issue")
+	}
+
+	job := &domain.BulkIssueJob{
+		ID:        uuid.New().String(),
+		IssuerID:  user.ID,
+		FileName:  fileName,
+		Status:    domain.BulkJobPending,
+		TotalRows: report.TotalRows,
+		ValidRows: report.ValidRows,
+		CreatedAt: time.Now(),
+	}
+	for _, row := range rows {
+		row.JobID = job.ID
+	}
+	if err := s.repo.SaveJob(ctx, job, rows); err != nil {
+		return nil, err
+	}
+	// Recorded before the job starts updating its counters.
+	s.audit.Record(ctx, AuditEvent{
+		ActorID:    user.ID,
+		Action:     domain.AuditBulkIssueUpload,
+		TargetType: domain.AuditTargetBulkJob,
+		TargetID:   job.ID,
+		After:      job,
+	})
+
+	// Should the claim fail, the job is picked up by the next resume.
+	if ok, err := s.claim(ctx, job); err != nil {
+		log.Printf("Failed to claim bulk issue job %s: %v", job.ID, err)
+	} else if ok {
+		s.start(job)
+	}
+
+	report.Job = job
+	return report, nil
+}
+
+// Start resumes unfinished jobs whose lease has run out, now and then every
+// lease period until ctx is cancelled: jobs interrupted by a shutdown, and
+// jobs left behind by another instance that stopped.
+func (s *BulkIssueService) Start(ctx context.Context) {
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		ticker := time.NewTicker(bulkJobLease)
+		defer ticker.Stop()
+		for {
+			if err := s.resumeUnfinished(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Failed to resume bulk issue jobs: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *BulkIssueService) resumeUnfinished(ctx context.Context) error {
+	jobs, err := s.repo.FindUnfinishedJobs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if s.isActive(job.ID) {
+			continue
+		}
+		ok, err := s.claim(ctx, job)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		log.Printf("Resuming bulk issue job %s (%d/%d rows done)", job.ID, job.Processed, job.ValidRows)
+		s.start(job)
+	}
+	return nil
+}
+
+// claim takes or renews this instance's lease on the job.
+func (s *BulkIssueService) claim(ctx context.Context, job *domain.BulkIssueJob) (bool, error) {
+	now := time.Now().UTC()
+	return s.repo.ClaimJob(ctx, job.ID, s.instanceID, now, now.Add(bulkJobLease))
+}
+
+func (s *BulkIssueService) isActive(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active[id]
+}
+
+func (s *BulkIssueService) GetJob(ctx context.Context, id string, user *domain.User) (*domain.BulkIssueJobStatus, error) {
+	job, err := s.findJob(ctx, id, user)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.BulkIssueJobStatus{BulkIssueJob: job, Progress: job.Progress()}, nil
+}
+
+func (s *BulkIssueService) ListJobs(ctx context.Context, user *domain.User) ([]*domain.BulkIssueJob, error) {
+	return s.repo.FindJobsByIssuer(ctx, user.ID)
+}
+
+func (s *BulkIssueService) GetRows(ctx context.Context, id string, user *domain.User) ([]*domain.BulkIssueRow, error) {
+	job, err := s.findJob(ctx, id, user)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindRows(ctx, job.ID, "")
+}
+
+// WriteResultCSV writes every row of the job with its outcome, certificate ID and hash.
+func (s *BulkIssueService) WriteResultCSV(ctx context.Context, id string, user *domain.User, w io.Writer) error {
+	rows, err := s.GetRows(ctx, id, user)
+	if err != nil {
+		return err
+	}
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"row", "status", "certificateId", "hash", "recipientName", "recipientEmail", "certificateTitle", "issueDate", "issuerName", "errors"})
+	for _, row := range rows {
+		out.Write([]string{
+			strconv.Itoa(row.Row),
+			row.Status,
+			row.CertificateID,
+			row.Hash,
+			row.Request.RecipientName,
+			row.Request.RecipientEmail,
+			row.Request.CertificateTitle,
+			row.Request.IssueDate,
+			row.Request.IssuerName,
+			strings.Join(row.Errors, "; "),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func (s *BulkIssueService) findJob(ctx context.Context, id string, user *domain.User) (*domain.BulkIssueJob, error) {
+	job, err := s.repo.FindJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.IssuerID != user.ID && user.Role != domain.RoleAdmin {
+		return nil, domain.NotFound("bulk_issue_job_not_found", "bulk issue job %s not found", id)
+	}
+	return job, nil
+}
+
+// Wait blocks until every running job has stopped, which after the jobs
+// context is cancelled happens at the next row.
+func (s *BulkIssueService) Wait() {
+	s.running.Wait()
+}
+
+func (s *BulkIssueService) start(job *domain.BulkIssueJob) {
+	s.mu.Lock()
+	s.active[job.ID] = true
+	s.mu.Unlock()
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.active, job.ID)
+			s.mu.Unlock()
+		}()
+		s.run(job)
+	}()
+}
+
+func (s *BulkIssueService) run(job *domain.BulkIssueJob) {
+	ctx := s.jobs
+	err := s.process(ctx, job)
+	if err != nil && ctx.Err() != nil {
+		log.Printf("Bulk issue job %s interrupted after %d/%d rows", job.ID, job.Processed, job.ValidRows)
+		// Hand the lease back so that the next instance to start resumes
+		// the job at once.
+		now := time.Now().UTC()
+		if _, err := s.repo.ClaimJob(context.WithoutCancel(ctx), job.ID, s.instanceID, now, now); err != nil {
+			log.Printf("Failed to release bulk issue job %s: %v", job.ID, err)
+		}
+		return
+	}
+	if errors.Is(err, errBulkJobLeaseLost) {
+		log.Printf("Bulk issue job %s stopped after %d/%d rows: %v", job.ID, job.Processed, job.ValidRows, err)
+		return
+	}
+	if err != nil {
+		log.Printf("Bulk issue job %s failed: %v", job.ID, err)
+		now := time.Now()
+		job.Status = domain.BulkJobFailed
+		job.Error = err.Error()
+		job.FinishedAt = &now
+		if err := s.repo.UpdateJob(ctx, job); err != nil {
+			log.Printf("Failed to record failure of bulk issue job %s: %v", job.ID, err)
+		}
+	}
+}
+
+func (s *BulkIssueService) process(ctx context.Context, job *domain.BulkIssueJob) error {
+	now := time.Now()
+	job.Status = domain.BulkJobRunning
+	if job.StartedAt == nil {
+		job.StartedAt = &now
+	}
+	if err := s.repo.UpdateJob(ctx, job); err != nil {
+		return err
+	}
+
+	rows, err := s.repo.FindRows(ctx, job.ID, "")
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if row.Status != domain.BulkRowPending && row.Status != domain.BulkRowIssuing {
+			continue
+		}
+		ok, err := s.claim(ctx, job)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errBulkJobLeaseLost
+		}
+
+		// The certificate ID is stored before the certificate is issued,
+		// so a row interrupted meanwhile is looked up on resume rather than
+		// issued a second time.
+		resumed := row.Status == domain.BulkRowIssuing
+		if !resumed {
+			row.Status = domain.BulkRowIssuing
+			row.CertificateID = newCertificateID()
+			if err := s.repo.UpdateRow(ctx, row); err != nil {
+				return err
+			}
+		}
+		cert, err := s.issueRow(ctx, job, row, resumed)
+		if err != nil && ctx.Err() != nil {
+			// Interrupted: the row stays issuing and is settled on resume.
+			return ctx.Err()
+		}
+		if err != nil {
+			row.Status = domain.BulkRowFailed
+			row.Errors = []string{err.Error()}
+			row.CertificateID = ""
+			job.Failed++
+		} else {
+			row.Status = domain.BulkRowIssued
+			row.CertificateID = cert.ID
+			row.Hash = cert.Hash
+			job.Succeeded++
+		}
+		job.Processed++
+
+		// Record the outcome even if ctx is cancelled meanwhile, so an
+		// issued row is not issued again on resume.
+		record := context.WithoutCancel(ctx)
+		if err := s.repo.UpdateRow(record, row); err != nil {
+			return err
+		}
+		if err := s.repo.UpdateJob(record, job); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	finished := time.Now()
+	job.Status = domain.BulkJobCompleted
+	job.FinishedAt = &finished
+	return s.repo.UpdateJob(ctx, job)
+}
+
+// issueRow issues the certificate of a row marked issuing. A resumed row
+// may have been issued before the job was interrupted; its certificate is
+// then returned.
+func (s *BulkIssueService) issueRow(ctx context.Context, job *domain.BulkIssueJob, row *domain.BulkIssueRow, resumed bool) (*domain.Certificate, error) {
+	if resumed {
+		cert, err := s.certificates.GetCertificate(ctx, row.CertificateID)
+		if err != nil && !errors.Is(err, domain.ErrNotFound) {
+			return nil, err
+		}
+		if err == nil && cert.IssuerID == job.IssuerID && cert.RecipientEmail == row.Request.RecipientEmail && cert.CertificateTitle == row.Request.CertificateTitle {
+			return cert, nil
+		}
+	}
+	return s.certificates.CreateCertificateWithID(ctx, row.CertificateID, row.Request, job.IssuerID)
+}
+
+// parseBulkRows turns spreadsheet records into validated rows. The first
+// record must be a header naming the columns; column names are matched
+// ignoring case, spaces and punctuation, so "Recipient Email" works too.
+func parseBulkRows(records [][]string) ([]*domain.BulkIssueRow, error) {
+	if len(records) == 0 {
+		return nil, domain.Invalid("empty_file", "the file is empty")
+	}
+	if len(records)-1 > MaxBulkIssueRows {
+		return nil, domain.Invalid("too_many_rows", "the file has %d rows; at most %d can be issued at once", len(records)-1, MaxBulkIssueRows)
+	}
+
+	setters := make([]func(*domain.CertificateRequest, string), len(records[0]))
+	present := make(map[string]bool)
+	for i, header := range records[0] {
+		name := normalizeHeader(header)
+		if name == "title" {
+			name = "certificatetitle"
+		}
+		setters[i] = bulkColumns[name]
+		present[name] = setters[i] != nil
+	}
+	var missing []string
+	for _, column := range requiredBulkColumns {
+		if !present[strings.ToLower(column)] {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, domain.Invalid("missing_columns", "the header row is missing columns: %s", strings.Join(missing, ", "))
+	}
+
+	seen := make(map[string]int)
+	var rows []*domain.BulkIssueRow
+	for i, record := range records[1:] {
+		row := &domain.BulkIssueRow{Row: i + 2, Status: domain.BulkRowPending}
+		if isBlankRecord(record) {
+			continue
+		}
+		for j, value := range record {
+			if j < len(setters) && setters[j] != nil {
+				setters[j](&row.Request, strings.TrimSpace(value))
+			}
+		}
+
+		row.Errors = validationMessages(validation.CertificateRequest(row.Request))
+		key := strings.ToLower(row.Request.RecipientEmail) + "\x00" + strings.ToLower(row.Request.CertificateTitle)
+		if first, ok := seen[key]; ok && row.Request.RecipientEmail != "" {
+			row.Errors = append(row.Errors, fmt.Sprintf("duplicates row %d", first))
+		} else {
+			seen[key] = row.Row
+		}
+		if len(row.Errors) > 0 {
+			row.Status = domain.BulkRowInvalid
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, domain.Invalid("empty_file", "the file has no data rows")
+	}
+	return rows, nil
+}
+
+// validationMessages flattens a validation error into "field: message" strings.
+func validationMessages(err error) []string {
+	var errs validation.Errors
+	if !errors.As(err, &errs) {
+		if err != nil {
+			return []string{err.Error()}
+		}
+		return nil
+	}
+	messages := make([]string, len(errs))
+	for i, fe := range errs {
+		messages[i] = fe.String()
+	}
+	return messages
+}
+
+func normalizeHeader(header string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(header) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func isBlankRecord(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"certificate-ledger/domain"
+)
+
+const bulkHeader = "Recipient Name,Recipient Email,Title,Issue Date,Issuer Name\n"
+
+// newTestBulkIssues returns a bulk issue service whose jobs run until the
+// test ends.
+func newTestBulkIssues(t *testing.T, env *testEnv) *BulkIssueService {
+	t.Helper()
+	jobs, cancel := context.WithCancel(context.Background())
+	bulk := NewBulkIssueService(jobs, env.repos.BulkIssues, env.certs, env.audit)
+	t.Cleanup(func() {
+		cancel()
+		bulk.Wait()
+	})
+	return bulk
+}
+
+func TestBulkIssueDryRunReportsInvalidRows(t *testing.T) {
+	env := newTestEnv(t)
+	issuer := env.saveUser(t, "issuer@example.com", domain.RoleIssuer)
+	bulk := newTestBulkIssues(t, env)
+
+	file := bulkHeader +
+		"Alice,alice@example.com,Data Science,2024-01-02,Example University\n" +
+		"Bob,not an email,Data Science,2024-01-02,Example University\n" +
+		",,,,\n" +
+		"Alice again,ALICE@example.com,data science,2024-01-02,Example University\n"
+	report, err := bulk.Upload(context.Background(), "people.csv", strings.NewReader(file), true, issuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.TotalRows != 3 || report.ValidRows != 1 || report.InvalidRows != 2 || report.Job != nil {
+		t.Fatalf("got %+v, want one valid and two invalid rows and no job", report)
+	}
+	if report.Errors[0].Row != 3 || report.Errors[1].Row != 5 || !strings.Contains(strings.Join(report.Errors[1].Errors, ""), "duplicates row 2") {
+		t.Errorf("errors are %+v, want row 3 invalid and row 5 a duplicate of row 2", report.Errors)
+	}
+	if height := env.chain.Info().Height; height != 0 {
+		t.Errorf("a dry run mined %d blocks", height)
+	}
+}
+
+func TestBulkIssueRejectsUnreadableFiles(t *testing.T) {
+	env := newTestEnv(t)
+	issuer := env.saveUser(t, "issuer@example.com", domain.RoleIssuer)
+	bulk := newTestBulkIssues(t, env)
+
+	for name, file := range map[string]string{
+		"people.txt": bulkHeader,
+		"people.csv": "Name,Email\nAlice,alice@example.com\n",
+		"empty.csv":  bulkHeader,
+	} {
+		if _, err := bulk.Upload(context.Background(), name, strings.NewReader(file), true, issuer); !errors.Is(err, domain.ErrValidation) {
+			t.Errorf("%s: got %v, want a validation error", name, err)
+		}
+	}
+}
+
+func TestBulkIssueJobIssuesValidRows(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	issuer := env.saveUser(t, "issuer@example.com", domain.RoleIssuer)
+	other := env.saveUser(t, "other@example.com", domain.RoleIssuer)
+	bulk := newTestBulkIssues(t, env)
+
+	file := bulkHeader +
+		"Alice,alice@example.com,Data Science,2024-01-02,Example University\n" +
+		"Bob,bob@example.com,Data Science,2024-01-02,Example University\n" +
+		"Carol,not an email,Data Science,2024-01-02,Example University\n"
+	report, err := bulk.Upload(ctx, "people.csv", strings.NewReader(file), false, issuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := waitForBulkJob(t, bulk, report.Job.ID, issuer)
+	if status.Succeeded != 2 || status.Failed != 0 || status.Progress != 1 {
+		t.Errorf("job finished as %+v, want two certificates issued", status.BulkIssueJob)
+	}
+	rows, err := bulk.GetRows(ctx, report.Job.ID, issuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if row.Status == domain.BulkRowInvalid {
+			continue
+		}
+		if row.Status != domain.BulkRowIssued || len(row.CertificateID) != len("CERT-")+36 {
+			t.Errorf("row %d is %s with ID %q, want issued under a full UUID", row.Row, row.Status, row.CertificateID)
+		}
+		if valid, err := env.certs.VerifyCertificate(ctx, row.Hash); err != nil || !valid {
+			t.Errorf("row %d: VerifyCertificate = %v, %v; want true", row.Row, valid, err)
+		}
+	}
+
+	if _, err := bulk.GetJob(ctx, report.Job.ID, other); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("another issuer's job: got %v, want not found", err)
+	}
+}
+
+func TestBulkIssueResumeSettlesInterruptedRows(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	issuer := env.saveUser(t, "issuer@example.com", domain.RoleIssuer)
+	issued := env.issue(t, issuer, "Issued before the restart")
+	foreign := env.issue(t, issuer, "Someone else's")
+	height := env.chain.Info().Height
+
+	request := func(title string) domain.CertificateRequest {
+		return domain.CertificateRequest{
+			RecipientName:    "Alice",
+			RecipientEmail:   "alice@example.com",
+			CertificateTitle: title,
+			IssueDate:        "2024-01-02",
+			IssuerName:       "Example University",
+		}
+	}
+	job := &domain.BulkIssueJob{ID: "job", IssuerID: issuer.ID, FileName: "people.csv", Status: domain.BulkJobRunning, TotalRows: 3, ValidRows: 3, CreatedAt: time.Now()}
+	rows := []*domain.BulkIssueRow{
+		// Issued just before the job was interrupted.
+		{JobID: job.ID, Row: 2, Request: request(issued.CertificateTitle), Status: domain.BulkRowIssuing, CertificateID: issued.ID},
+		// Its ID was taken by another certificate meanwhile.
+		{JobID: job.ID, Row: 3, Request: request("Clashing"), Status: domain.BulkRowIssuing, CertificateID: foreign.ID},
+		{JobID: job.ID, Row: 4, Request: request("Not started"), Status: domain.BulkRowPending},
+	}
+	if err := env.repos.BulkIssues.SaveJob(ctx, job, rows); err != nil {
+		t.Fatal(err)
+	}
+
+	bulk := newTestBulkIssues(t, env)
+	if err := bulk.resumeUnfinished(ctx); err != nil {
+		t.Fatal(err)
+	}
+	status := waitForBulkJob(t, bulk, job.ID, issuer)
+	if status.Succeeded != 2 || status.Failed != 1 {
+		t.Errorf("job finished as %+v, want two rows issued and one failed", status.BulkIssueJob)
+	}
+
+	stored, err := bulk.GetRows(ctx, job.ID, issuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored[0].Status != domain.BulkRowIssued || stored[0].Hash != issued.Hash {
+		t.Errorf("interrupted row is %+v, want the certificate issued before the restart", stored[0])
+	}
+	if stored[1].Status != domain.BulkRowFailed {
+		t.Errorf("clashing row is %s, want failed", stored[1].Status)
+	}
+	// Only the pending row was mined; the clash was caught before mining.
+	if got := env.chain.Info().Height; got != height+1 {
+		t.Errorf("chain grew from %d to %d blocks, want one new block", height, got)
+	}
+}
+
+func TestCreateCertificateWithTakenIDLeavesNoBlock(t *testing.T) {
+	env := newTestEnv(t)
+	issuer := env.saveUser(t, "issuer@example.com", domain.RoleIssuer)
+	cert := env.issue(t, issuer, "First")
+	height := env.chain.Info().Height
+
+	_, err := env.certs.CreateCertificateWithID(context.Background(), cert.ID, domain.CertificateRequest{
+		RecipientName:    "Bob",
+		RecipientEmail:   "bob@example.com",
+		CertificateTitle: "Second",
+		IssueDate:        "2024-01-02",
+		IssuerName:       "Example University",
+	}, issuer.ID)
+	if !errors.Is(err, domain.ErrConflict) {
+		t.Fatalf("got %v, want a conflict", err)
+	}
+	if got := env.chain.Info().Height; got != height {
+		t.Errorf("chain grew from %d to %d blocks, want no block for the refused certificate", height, got)
+	}
+}
+
+// waitForBulkJob polls the job until it has finished.
+func waitForBulkJob(t *testing.T, bulk *BulkIssueService, id string, user *domain.User) *domain.BulkIssueJobStatus {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		status, err := bulk.GetJob(context.Background(), id, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.Status == domain.BulkJobCompleted || status.Status == domain.BulkJobFailed {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s still %s after 10s", id, status.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"certificate-ledger/domain"
+)
+
+func TestListCertificatesPagesWithCursor(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	issuer := env.saveUser(t, "issuer@example.com", domain.RoleIssuer)
+	titles := []string{"Echo", "Alpha", "Delta", "Charlie", "Bravo"}
+	for _, title := range titles {
+		env.issue(t, issuer, title)
+	}
+
+	var got []string
+	q := domain.CertificateQuery{Sort: domain.CertificateSortTitle, Limit: 2}
+	for pages := 0; ; pages++ {
+		if pages > len(titles) {
+			t.Fatal("the cursor does not advance")
+		}
+		page, err := env.certs.ListCertificates(ctx, issuer, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != len(titles) || page.Limit != 2 {
+			t.Errorf("page reports total %d and limit %d, want %d and 2", page.Total, page.Limit, len(titles))
+		}
+		for _, cert := range page.Items {
+			got = append(got, cert.CertificateTitle)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+
+	want := []string{"Alpha", "Bravo", "Charlie", "Delta", "Echo"}
+	if len(got) != len(want) {
+		t.Fatalf("pages gave %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("pages gave %v, want %v", got, want)
+		}
+	}
+}
+
+func TestListCertificatesIsScopedByRole(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	issuer := env.saveUser(t, "issuer@example.com", domain.RoleIssuer)
+	other := env.saveUser(t, "other@example.com", domain.RoleIssuer)
+	admin := env.saveUser(t, "admin@example.com", domain.RoleAdmin)
+	alice := env.saveUser(t, "alice@example.com", domain.RoleUser)
+	bob := env.saveUser(t, "bob@example.com", domain.RoleUser)
+	env.issue(t, issuer, "First")
+	env.issue(t, issuer, "Second")
+	env.issue(t, other, "Third")
+
+	for _, tc := range []struct {
+		name string
+		user *domain.User
+		want int
+	}{
+		{"admin", admin, 3},
+		{"issuer", issuer, 2},
+		{"other issuer", other, 1},
+		{"recipient", alice, 3},
+		{"stranger", bob, 0},
+	} {
+		page, err := env.certs.ListCertificates(ctx, tc.user, domain.CertificateQuery{})
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if page.Total != tc.want || len(page.Items) != tc.want {
+			t.Errorf("%s sees %d certificates (total %d), want %d", tc.name, len(page.Items), page.Total, tc.want)
+		}
+	}
+
+	_, err := env.certs.ListCertificates(ctx, issuer, domain.CertificateQuery{Filter: domain.CertificateFilter{IssuerID: other.ID}})
+	if !errors.Is(err, domain.ErrForbidden) {
+		t.Errorf("issuer filtering by another issuer: got %v, want forbidden", err)
+	}
+	page, err := env.certs.ListCertificates(ctx, admin, domain.CertificateQuery{Filter: domain.CertificateFilter{IssuerID: other.ID}})
+	if err != nil || page.Total != 1 {
+		t.Errorf("admin filtering by issuer: got %+v, %v; want one certificate", page, err)
+	}
+}
+
+func TestListCertificatesRejectsInvalidQueries(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.saveUser(t, "admin@example.com", domain.RoleAdmin)
+
+	for name, q := range map[string]domain.CertificateQuery{
+		"sort":   {Sort: "password"},
+		"order":  {Order: "sideways"},
+		"status": {Filter: domain.CertificateFilter{Status: "lost"}},
+		"cursor": {Cursor: "not a cursor"},
+	} {
+		if _, err := env.certs.ListCertificates(context.Background(), admin, q); !errors.Is(err, domain.ErrValidation) {
+			t.Errorf("invalid %s: got %v, want a validation error", name, err)
+		}
+	}
+}
+
+func TestSearchCertificatesIsScopedByRole(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	issuer := env.saveUser(t, "issuer@example.com", domain.RoleIssuer)
+	other := env.saveUser(t, "other@example.com", domain.RoleIssuer)
+	admin := env.saveUser(t, "admin@example.com", domain.RoleAdmin)
+	alice := env.saveUser(t, "alice@example.com", domain.RoleUser)
+	bob := env.saveUser(t, "bob@example.com", domain.RoleUser)
+	env.issue(t, issuer, "Data Science")
+	env.issue(t, other, "Data Engineering")
+
+	for _, tc := range []struct {
+		name string
+		user *domain.User
+		want int
+	}{
+		{"admin", admin, 2},
+		{"issuer", issuer, 1},
+		{"recipient", alice, 2},
+		{"stranger", bob, 0},
+	} {
+		result, err := env.certs.SearchCertificates(ctx, "data", 0, tc.user)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if len(result.Hits) != tc.want {
+			t.Errorf("%s found %d certificates, want %d", tc.name, len(result.Hits), tc.want)
+		}
+	}
+
+	result, err := env.certs.SearchCertificates(ctx, "science", 0, issuer)
+	if err != nil || len(result.Hits) != 1 {
+		t.Fatalf("got %+v, %v; want one hit", result, err)
+	}
+	if got := result.Hits[0].Highlights[domain.FieldCertificateTitle]; got != "Data <mark>Science</mark>" {
+		t.Errorf("title highlight is %q", got)
+	}
+
+	if _, err := env.certs.SearchCertificates(ctx, " ?! ", 0, admin); !errors.Is(err, domain.ErrValidation) {
+		t.Errorf("empty query: got %v, want a validation error", err)
+	}
+}
@@ -1,34 +1,138 @@
 package service
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"certificate-ledger/blockchain"
 	"certificate-ledger/domain"
+	"certificate-ledger/metrics"
 	"certificate-ledger/repository"
+	"certificate-ledger/search"
+	"certificate-ledger/validation"
 	"github.com/google/uuid"
 )
 
+// Labels of the certificate metrics: what was issued and how it went.
+const (
+	issuanceNew     = "new"
+	issuanceRenewal = "renewal"
+
+	issuanceIssued    = "issued"
+	issuanceInvalid   = "invalid"
+	issuanceCancelled = "cancelled"
+	issuanceFailed    = "failed"
+
+	verificationValid    = "valid"
+	verificationRevoked  = "revoked"
+	verificationMismatch = "mismatch"
+	verificationNotFound = "not_found"
+	verificationError    = "error"
+)
+
+var (
+	certificateIssuances = metrics.NewCounterVec("ledger_certificate_issuances_total",
+		"Certificates issued or renewed, by kind and result.", "kind", "result")
+	certificateVerifications = metrics.NewCounterVec("ledger_certificate_verifications_total",
+		"Certificate verifications by result.", "result")
+)
+
+func issuanceResult(err error) string {
+	switch {
+	case err == nil:
+		return issuanceIssued
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return issuanceCancelled
+	}
+	return issuanceFailed
+}
+
 type CertificateService struct {
-	repo       *repository.CertificateRepository
+	repo       repository.CertificateRepository
 	blockchain *blockchain.Blockchain
+	index      search.Index
+	audit      *AuditService
+	listeners  []CertificateListener
 }
 
-func NewCertificateService(repo *repository.CertificateRepository, bc *blockchain.Blockchain) *CertificateService {
+// CertificateListener is told about every certificate issued, revoked or
+// renewed, after the change is stored. Listeners run on the caller's
+// goroutine, so they should hand slow work off.
+type CertificateListener interface {
+	CertificateChanged(ctx context.Context, event *domain.CertificateEvent)
+}
+
+func NewCertificateService(repo repository.CertificateRepository, bc *blockchain.Blockchain, index search.Index, audit *AuditService) *CertificateService {
 	return &CertificateService{
 		repo:       repo,
 		blockchain: bc,
+		index:      index,
+		audit:      audit,
+	}
+}
+
+func (s *CertificateService) CreateCertificate(ctx context.Context, req domain.CertificateRequest, userID string) (*domain.Certificate, error) {
+	return s.CreateCertificateWithID(ctx, newCertificateID(), req, userID)
+}
+
+// CreateCertificateWithID issues a certificate under an ID chosen by the
+// caller, who can then tell after a crash whether it was issued by looking
+// the ID up.
+func (s *CertificateService) CreateCertificateWithID(ctx context.Context, id string, req domain.CertificateRequest, userID string) (*domain.Certificate, error) {
+	if err := validation.CertificateRequest(req); err != nil {
+		certificateIssuances.Inc(issuanceNew, issuanceInvalid)
+		return nil, err
+	}
+
+	cert, claimCode, err := s.issue(ctx, id, req, userID, nil)
+	if err != nil {
+		return nil, err
 	}
+	s.audit.Record(ctx, AuditEvent{
+		ActorID:    userID,
+		Action:     domain.AuditCertificateIssue,
+		TargetType: domain.AuditTargetCertificate,
+		TargetID:   cert.ID,
+		After:      cert,
+	})
+	s.emit(ctx, domain.EventCertificateIssued, cert, "", claimCode)
+
+	cert.ClaimCode = claimCode
+	return cert, nil
 }
 
-func (s *CertificateService) CreateCertificate(req domain.CertificateRequest, userID string) (*domain.Certificate, error) {
-	certID := fmt.Sprintf("CERT-%s", uuid.New().String()[:6])
+// issue mines and stores a new certificate and returns it with its claim
+// code, which is kept off the certificate until the caller has recorded it.
+// A renewal passes the certificate it replaces.
+func (s *CertificateService) issue(ctx context.Context, certID string, req domain.CertificateRequest, issuerID string, previous *domain.Certificate) (*domain.Certificate, string, error) {
+	kind := issuanceNew
+	if previous != nil {
+		kind = issuanceRenewal
+	}
+	cert, claimCode, err := s.mint(ctx, certID, req, issuerID, previous)
+	certificateIssuances.Inc(kind, issuanceResult(err))
+	return cert, claimCode, err
+}
 
-	issueDate, err := time.Parse("2006-01-02", req.IssueDate)
+func (s *CertificateService) mint(ctx context.Context, certID string, req domain.CertificateRequest, issuerID string, previous *domain.Certificate) (*domain.Certificate, string, error) {
+	issueDate, err := time.Parse(validation.DateLayout, req.IssueDate)
 	if err != nil {
-		return nil, fmt.Errorf("invalid issue date format: %v", err)
+		return nil, "", fmt.Errorf("invalid issue date format: %v", err)
+	}
+	// A taken ID must be refused before mining: the row could not be saved
+	// afterwards and the block would be left without a certificate.
+	if _, err := s.repo.FindByID(ctx, certID); err == nil {
+		return nil, "", domain.Conflict("certificate_exists", "certificate %s already exists", certID)
+	} else if !errors.Is(err, domain.ErrNotFound) {
+		return nil, "", err
 	}
 
 	cert := &domain.Certificate{
@@ -37,70 +141,486 @@ func (s *CertificateService) CreateCertificate(req domain.CertificateRequest, us
 		RecipientEmail:   req.RecipientEmail,
 		CertificateTitle: req.CertificateTitle,
 		IssueDate:        issueDate,
-		IssuerID:         userID,
+		IssuerID:         issuerID,
 		IssuerName:       req.IssuerName,
 		Description:      req.Description,
 		Timestamp:        time.Now(),
 	}
+	if previous != nil {
+		cert.RenewedFrom = previous.ID
+		// A renewal goes straight to the wallet the original was claimed into.
+		if previous.RecipientUserID != "" {
+			cert.RecipientUserID = previous.RecipientUserID
+			cert.ClaimedAt = &cert.Timestamp
+		}
+	}
+
+	if err := commitFields(cert); err != nil {
+		return nil, "", err
+	}
 
-	certData, err := json.Marshal(cert)
+	certData, err := json.Marshal(domain.NewCertificateBlock(cert))
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal certificate: %v", err)
+		return nil, "", fmt.Errorf("failed to marshal certificate: %v", err)
 	}
 
-	block := s.blockchain.AddBlock(certData)
+	block, err := s.blockchain.AddBlock(ctx, certData)
+	if err != nil {
+		return nil, "", err
+	}
 
 	cert.Hash = block.Hash
 	cert.BlockNumber = block.Index
+	cert.Status = domain.CertificateStatusActive
+	cert.RecipientLocale = req.RecipientLocale
 
-	if err := s.repo.Save(cert); err != nil {
-		return nil, fmt.Errorf("failed to save certificate: %v", err)
+	// The claim code lets the recipient add the certificate to their wallet
+	// under any account; only its hash is stored and it never enters the block.
+	claimCode, err := generateClaimCode()
+	if err != nil {
+		return nil, "", err
 	}
+	cert.ClaimCodeHash = hashClaimCode(claimCode)
 
+	// The block is on the chain now, so the row must follow even if the
+	// caller has gone away.
+	if err := s.repo.Save(context.WithoutCancel(ctx), cert); err != nil {
+		return nil, "", fmt.Errorf("failed to save certificate: %v", err)
+	}
+	s.index.Add(cert)
+	return cert, claimCode, nil
+}
+
+// RevokeCertificate withdraws a certificate. It stays on the chain but no
+// longer verifies. Only its issuer or an admin may revoke it.
+func (s *CertificateService) RevokeCertificate(ctx context.Context, id string, req domain.RevokeCertificateRequest, user *domain.User) (*domain.Certificate, error) {
+	if err := validation.RevokeCertificateRequest(req); err != nil {
+		return nil, err
+	}
+	cert, err := s.findManaged(ctx, id, user)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	reason := strings.TrimSpace(req.Reason)
+	ok, err := s.repo.Revoke(ctx, cert.ID, reason, now)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.Conflict("certificate_revoked", "certificate %s is already revoked", id)
+	}
+
+	revoked := *cert
+	revoked.Status = domain.CertificateStatusRevoked
+	revoked.RevokedAt = &now
+	revoked.RevocationReason = reason
+	s.audit.Record(ctx, AuditEvent{
+		ActorID:    user.ID,
+		Action:     domain.AuditCertificateRevoke,
+		TargetType: domain.AuditTargetCertificate,
+		TargetID:   cert.ID,
+		Before:     cert,
+		After:      &revoked,
+	})
+	s.emit(ctx, domain.EventCertificateRevoked, &revoked, "", "")
+	s.blockchain.PublishFor(blockchain.EventRevocation, cert.IssuerID, blockchain.Revocation{
+		CertificateID: cert.ID,
+		BlockIndex:    cert.BlockNumber,
+		RevokedAt:     now,
+	})
+	return &revoked, nil
+}
+
+// RenewCertificate reissues an active certificate with the same contents and
+// a new issue date, and marks the original superseded. The renewal keeps the
+// original issuer. Only that issuer or an admin may renew.
+func (s *CertificateService) RenewCertificate(ctx context.Context, id string, req domain.RenewCertificateRequest, user *domain.User) (*domain.Certificate, error) {
+	if err := validation.RenewCertificateRequest(req); err != nil {
+		return nil, err
+	}
+	previous, err := s.findManaged(ctx, id, user)
+	if err != nil {
+		return nil, err
+	}
+
+	issueDate := req.IssueDate
+	if issueDate == "" {
+		issueDate = time.Now().Format(validation.DateLayout)
+	}
+
+	// Superseding first means two concurrent renewals cannot both issue.
+	ok, err := s.repo.UpdateStatus(ctx, previous.ID, domain.CertificateStatusActive, domain.CertificateStatusSuperseded)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.Conflict("certificate_not_active", "only active certificates can be renewed")
+	}
+
+	cert, claimCode, err := s.issue(ctx, newCertificateID(), domain.CertificateRequest{
+		RecipientName:    previous.RecipientName,
+		RecipientEmail:   previous.RecipientEmail,
+		CertificateTitle: previous.CertificateTitle,
+		IssueDate:        issueDate,
+		IssuerName:       previous.IssuerName,
+		Description:      previous.Description,
+		RecipientLocale:  previous.RecipientLocale,
+	}, previous.IssuerID, previous)
+	if err != nil {
+		if _, restoreErr := s.repo.UpdateStatus(context.WithoutCancel(ctx), previous.ID, domain.CertificateStatusSuperseded, domain.CertificateStatusActive); restoreErr != nil {
+			log.Printf("Failed to restore certificate %s after a failed renewal: %v", previous.ID, restoreErr)
+		}
+		return nil, err
+	}
+
+	superseded := *previous
+	superseded.Status = domain.CertificateStatusSuperseded
+	s.audit.Record(ctx, AuditEvent{
+		ActorID:    user.ID,
+		Action:     domain.AuditCertificateRenew,
+		TargetType: domain.AuditTargetCertificate,
+		TargetID:   previous.ID,
+		Before:     previous,
+		After:      &superseded,
+	})
+	s.audit.Record(ctx, AuditEvent{
+		ActorID:    user.ID,
+		Action:     domain.AuditCertificateIssue,
+		TargetType: domain.AuditTargetCertificate,
+		TargetID:   cert.ID,
+		After:      cert,
+	})
+	s.emit(ctx, domain.EventCertificateRenewed, cert, previous.ID, claimCode)
+
+	cert.ClaimCode = claimCode
 	return cert, nil
 }
 
-func (s *CertificateService) GetCertificate(id string) (*domain.Certificate, error) {
-	return s.repo.FindByID(id)
+// findManaged loads a certificate the user may revoke or renew. Other
+// issuers' certificates are reported as not found.
+func (s *CertificateService) findManaged(ctx context.Context, id string, user *domain.User) (*domain.Certificate, error) {
+	cert, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if cert.IssuerID != user.ID && user.Role != domain.RoleAdmin {
+		return nil, domain.NotFound("certificate_not_found", "certificate with ID %s not found", id)
+	}
+	return cert, nil
+}
+
+// AddListener registers a listener. Call it before the service is in use.
+func (s *CertificateService) AddListener(listener CertificateListener) {
+	s.listeners = append(s.listeners, listener)
+}
+
+// emit sends listeners a copy of the certificate, so they may keep it. The
+// claim code travels on the event rather than the copy.
+func (s *CertificateService) emit(ctx context.Context, eventType string, cert *domain.Certificate, previousID, claimCode string) {
+	snapshot := *cert
+	snapshot.ClaimCode = ""
+	event := &domain.CertificateEvent{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data: domain.CertificateEventData{
+			Certificate:           &snapshot,
+			PreviousCertificateID: previousID,
+		},
+		ClaimCode: claimCode,
+	}
+	for _, listener := range s.listeners {
+		listener.CertificateChanged(ctx, event)
+	}
 }
 
-func (s *CertificateService) GetCertificateByHash(hash string) (*domain.Certificate, error) {
-	return s.repo.FindByHash(hash)
+func (s *CertificateService) GetCertificate(ctx context.Context, id string) (*domain.Certificate, error) {
+	return s.repo.FindByID(ctx, id)
 }
 
-func (s *CertificateService) VerifyCertificate(hash string) (bool, error) {
-	cert, err := s.repo.FindByHash(hash)
+func (s *CertificateService) GetCertificateByHash(ctx context.Context, hash string) (*domain.Certificate, error) {
+	return s.repo.FindByHash(ctx, hash)
+}
+
+func (s *CertificateService) VerifyCertificate(ctx context.Context, hash string) (bool, error) {
+	result, err := s.verify(ctx, hash)
 	if err != nil {
-		return false, err
+		result = verificationError
+		if errors.Is(err, domain.ErrNotFound) {
+			result = verificationNotFound
+		}
+	}
+	certificateVerifications.Inc(result)
+	return result == verificationValid, err
+}
+
+// verify returns the verification result of the certificate with the hash.
+func (s *CertificateService) verify(ctx context.Context, hash string) (string, error) {
+	cert, err := s.repo.FindByHash(ctx, hash)
+	if err != nil {
+		return "", err
 	}
 
 	block, err := s.blockchain.GetBlock(hash)
 	if err != nil {
-		return false, err
+		return "", err
+	}
+
+	if cert.Status == domain.CertificateStatusRevoked {
+		return verificationRevoked, nil
+	}
+
+	record, err := domain.ParseCertificateBlock(block.Data)
+	if err != nil {
+		return "", err
+	}
+	salts, err := s.repo.FindFieldSalts(ctx, cert.ID)
+	if err != nil {
+		return "", err
 	}
 
-	var blockCert domain.Certificate
-	if err := json.Unmarshal(block.Data, &blockCert); err != nil {
-		return false, err
+	if len(record.Differences(cert, salts)) > 0 {
+		s.blockchain.Publish(blockchain.EventAlert, blockchain.Alert{
+			Message:    fmt.Sprintf("certificate %s does not match block %d", cert.ID, block.Index),
+			BlockIndex: block.Index,
+			At:         time.Now(),
+		})
+		return verificationMismatch, nil
 	}
 
-	if cert.ID != blockCert.ID || 
-	   cert.RecipientName != blockCert.RecipientName || 
-	   cert.CertificateTitle != blockCert.CertificateTitle {
-		return false, nil
+	return verificationValid, nil
+}
+
+func (s *CertificateService) GetAllCertificates(ctx context.Context) ([]*domain.Certificate, error) {
+	return s.repo.FindAll(ctx)
+}
+
+func (s *CertificateService) GetCertificatesByIssuer(ctx context.Context, userID string) ([]*domain.Certificate, error) {
+	return s.repo.FindByIssuerID(ctx, userID)
+}
+
+func (s *CertificateService) GetCertificatesByRecipient(ctx context.Context, email string) ([]*domain.Certificate, error) {
+	return s.repo.FindByRecipientEmail(ctx, email)
+}
+
+// ListCertificates returns one page of the certificates the user may see,
+// matching the query: admins see all of them, issuers their own and other
+// users their wallet, as in SearchCertificates.
+func (s *CertificateService) ListCertificates(ctx context.Context, user *domain.User, q domain.CertificateQuery) (*domain.CertificatePage, error) {
+	switch user.Role {
+	case domain.RoleAdmin:
+	case domain.RoleIssuer:
+		if q.Filter.IssuerID != "" && q.Filter.IssuerID != user.ID {
+			return nil, domain.Forbidden("issuer_filter_not_allowed", "issuers can only list their own certificates")
+		}
+		q.Filter.IssuerID = user.ID
+	default:
+		return s.ListWallet(ctx, user, q)
 	}
+	return s.findPage(ctx, q)
+}
 
-	return true, nil
+// ListWallet is the paginated form of GetWallet. Filters in q narrow the wallet further.
+func (s *CertificateService) ListWallet(ctx context.Context, user *domain.User, q domain.CertificateQuery) (*domain.CertificatePage, error) {
+	q.Filter.WalletUserID = user.ID
+	q.Filter.WalletEmail = ""
+	if user.EmailVerified {
+		q.Filter.WalletEmail = user.Email
+	}
+	return s.findPage(ctx, q)
+}
+
+func (s *CertificateService) findPage(ctx context.Context, q domain.CertificateQuery) (*domain.CertificatePage, error) {
+	if err := normalizeCertificateQuery(&q); err != nil {
+		return nil, err
+	}
+	return s.repo.FindPage(ctx, q)
 }
 
-func (s *CertificateService) GetAllCertificates() ([]*domain.Certificate, error) {
-	return s.repo.FindAll()
+// SearchCertificates runs a full-text search limited to what the user may see:
+// admins search everything, issuers the certificates they issued, and
+// everyone else their wallet.
+func (s *CertificateService) SearchCertificates(ctx context.Context, text string, limit int, user *domain.User) (*domain.CertificateSearchResult, error) {
+	text = strings.TrimSpace(text)
+	terms := search.Terms(text)
+	if len(terms) == 0 {
+		return nil, domain.Invalid("empty_search_query", "search query must contain at least one word")
+	}
+
+	if limit <= 0 {
+		limit = domain.DefaultSearchLimit
+	}
+	if limit > domain.MaxSearchLimit {
+		limit = domain.MaxSearchLimit
+	}
+
+	q := domain.CertificateSearchQuery{Text: text, Limit: limit}
+	switch user.Role {
+	case domain.RoleAdmin:
+	case domain.RoleIssuer:
+		q.Filter.IssuerID = user.ID
+	default:
+		q.Filter.WalletUserID = user.ID
+		if user.EmailVerified {
+			q.Filter.WalletEmail = user.Email
+		}
+	}
+
+	hits, err := s.index.Search(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	if hits == nil {
+		hits = []*domain.CertificateSearchHit{}
+	}
+	for _, hit := range hits {
+		hit.Highlights = search.Highlights(hit.Certificate, terms)
+	}
+	return &domain.CertificateSearchResult{Query: text, Hits: hits}, nil
 }
 
-func (s *CertificateService) GetCertificatesByIssuer(userID string) ([]*domain.Certificate, error) {
-	return s.repo.FindByIssuerID(userID)
+// GetWallet returns the certificates belonging to a recipient: those they have
+// claimed and, once their email is verified, those issued to that email.
+func (s *CertificateService) GetWallet(ctx context.Context, user *domain.User) ([]*domain.Certificate, error) {
+	email := ""
+	if user.EmailVerified {
+		email = user.Email
+	}
+	return s.repo.FindByRecipient(ctx, user.ID, email)
 }
 
-func (s *CertificateService) GetCertificatesByRecipient(email string) ([]*domain.Certificate, error) {
-	return s.repo.FindByRecipientEmail(email)
+// ClaimCertificate adds a certificate to the user's wallet. The claim code
+// handed out at issuance is required unless the certificate was issued to the
+// user's verified email address.
+func (s *CertificateService) ClaimCertificate(ctx context.Context, id, claimCode string, user *domain.User) (*domain.Certificate, error) {
+	cert, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if cert.RecipientUserID == user.ID {
+		return cert, nil
+	}
+	if cert.RecipientUserID != "" {
+		return nil, domain.Conflict("certificate_already_claimed", "certificate %s has already been claimed", id)
+	}
+
+	emailMatches := user.EmailVerified && strings.EqualFold(cert.RecipientEmail, user.Email)
+	codeMatches := cert.ClaimCodeHash != "" &&
+		subtle.ConstantTimeCompare([]byte(cert.ClaimCodeHash), []byte(hashClaimCode(claimCode))) == 1
+	if !emailMatches && !codeMatches {
+		return nil, domain.Forbidden("invalid_claim_code", "invalid claim code")
+	}
+
+	now := time.Now()
+	ok, err := s.repo.Claim(ctx, cert.ID, user.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.Conflict("certificate_already_claimed", "certificate %s has already been claimed", id)
+	}
+
+	cert.RecipientUserID = user.ID
+	cert.ClaimedAt = &now
+	return cert, nil
+}
+
+// ClaimByVerifiedEmail claims every unclaimed certificate issued to the user's verified email.
+func (s *CertificateService) ClaimByVerifiedEmail(ctx context.Context, user *domain.User) (int64, error) {
+	if !user.EmailVerified {
+		return 0, domain.Forbidden("email_not_verified", "email is not verified")
+	}
+	return s.repo.ClaimByEmail(ctx, user.ID, user.Email, time.Now())
+}
+
+// newCertificateID returns a random certificate ID. It carries the whole
+// UUID so that IDs do not collide however many certificates are issued.
+func newCertificateID() string {
+	return "CERT-" + uuid.New().String()
+}
+
+func generateClaimCode() (string, error) {
+	code, err := generateRecoveryCode()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate claim code: %v", err)
+	}
+	return strings.ToUpper(code), nil
+}
+
+func hashClaimCode(code string) string {
+	normalized := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// isRecipient reports whether the certificate sits in the user's wallet.
+func isRecipient(cert *domain.Certificate, user *domain.User) bool {
+	if cert.RecipientUserID != "" {
+		return cert.RecipientUserID == user.ID
+	}
+	return user.EmailVerified && strings.EqualFold(cert.RecipientEmail, user.Email)
+}
+
+// commitFields salts and hashes every disclosable field so the commitments can
+// be recorded in the block while the salts stay private in the database.
+func commitFields(cert *domain.Certificate) error {
+	cert.Commitments = make(map[string]string, len(domain.DisclosableFields))
+	cert.FieldSalts = make(map[string]string, len(domain.DisclosableFields))
+	for _, field := range domain.DisclosableFields {
+		salt, err := randomHex(16)
+		if err != nil {
+			return fmt.Errorf("failed to generate field salt: %v", err)
+		}
+		cert.FieldSalts[field] = salt
+		cert.Commitments[field] = domain.FieldCommitment(field, salt, domain.FieldValue(cert, field))
+	}
+	return nil
+}
+
+// normalizeCertificateQuery applies listing defaults and rejects unknown values.
+// Listings default to the newest issue date first.
+func normalizeCertificateQuery(q *domain.CertificateQuery) error {
+	if q.Sort == "" {
+		q.Sort = domain.CertificateSortIssueDate
+		if q.Order == "" {
+			q.Order = domain.SortDescending
+		}
+	}
+	if !containsString(domain.CertificateSortFields, q.Sort) {
+		return domain.Invalid("invalid_sort", "cannot sort by %s; use one of %s", q.Sort, strings.Join(domain.CertificateSortFields, ", "))
+	}
+
+	switch q.Order {
+	case "":
+		q.Order = domain.SortAscending
+	case domain.SortAscending, domain.SortDescending:
+	default:
+		return domain.Invalid("invalid_order", "order must be %s or %s", domain.SortAscending, domain.SortDescending)
+	}
+
+	if q.Limit <= 0 {
+		q.Limit = domain.DefaultPageLimit
+	}
+	if q.Limit > domain.MaxPageLimit {
+		q.Limit = domain.MaxPageLimit
+	}
+
+	if q.Filter.Status != "" && !containsString(domain.CertificateStatuses, q.Filter.Status) {
+		return domain.Invalid("invalid_status", "unknown status %s; use one of %s", q.Filter.Status, strings.Join(domain.CertificateStatuses, ", "))
+	}
+	if q.Filter.IssuedFrom != nil && q.Filter.IssuedTo != nil && q.Filter.IssuedTo.Before(*q.Filter.IssuedFrom) {
+		return domain.Invalid("invalid_date_range", "issuedTo must not be before issuedFrom")
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
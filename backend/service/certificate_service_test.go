@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/repository"
+)
+
+func TestCreateCertificateCommitsFieldsToTheChain(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	issuer := env.saveUser(t, "issuer@example.com", domain.RoleIssuer)
+
+	cert := env.issue(t, issuer, "Bachelor of Science")
+
+	if cert.ClaimCode == "" || cert.Status != domain.CertificateStatusActive {
+		t.Errorf("got claim code %q and status %q, want a claim code and %q", cert.ClaimCode, cert.Status, domain.CertificateStatusActive)
+	}
+	block, err := env.chain.GetBlock(cert.Hash)
+	if err != nil {
+		t.Fatalf("block of %s: %v", cert.ID, err)
+	}
+	record, err := domain.ParseCertificateBlock(block.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.ID != cert.ID || record.IssuerID != issuer.ID || len(record.Commitments) != len(domain.DisclosableFields) {
+		t.Errorf("block holds %+v, want the IDs and a commitment per disclosable field", record)
+	}
+	for _, value := range []string{"Alice", "alice@example.com", "Bachelor of Science"} {
+		if strings.Contains(string(block.Data), value) {
+			t.Errorf("block data reveals %q", value)
+		}
+	}
+
+	valid, err := env.certs.VerifyCertificate(ctx, cert.Hash)
+	if err != nil || !valid {
+		t.Errorf("VerifyCertificate = %v, %v; want true", valid, err)
+	}
+}
+
+func TestCreateCertificateRejectsInvalidRequests(t *testing.T) {
+	env := newTestEnv(t)
+	issuer := env.saveUser(t, "issuer@example.com", domain.RoleIssuer)
+
+	_, err := env.certs.CreateCertificate(context.Background(), domain.CertificateRequest{RecipientEmail: "not an email"}, issuer.ID)
+	if !errors.Is(err, domain.ErrValidation) {
+		t.Fatalf("got %v, want a validation error", err)
+	}
+	if height := env.chain.Info().Height; height != 0 {
+		t.Errorf("chain height is %d, want nothing mined", height)
+	}
+}
+
+// tamperedCertificates serves stored certificates changed by edit, as if
+// someone edited the rows.
+type tamperedCertificates struct {
+	repository.CertificateRepository
+	edit func(cert *domain.Certificate)
+}
+
+func (r tamperedCertificates) FindByHash(ctx context.Context, hash string) (*domain.Certificate, error) {
+	cert, err := r.CertificateRepository.FindByHash(ctx, hash)
+	if err == nil {
+		r.edit(cert)
+	}
+	return cert, err
+}
+
+func TestVerifyCertificateDetectsEditedRows(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	issuer := env.saveUser(t, "issuer@example.com", domain.RoleIssuer)
+	other := env.saveUser(t, "other@example.com", domain.RoleIssuer)
+	cert := env.issue(t, issuer, "Bachelor of Science")
+
+	for name, edit := range map[string]func(*domain.Certificate){
+		"recipient": func(cert *domain.Certificate) { cert.RecipientName = "Mallory" },
+		"issuer":    func(cert *domain.Certificate) { cert.IssuerID = other.ID },
+	} {
+		tampered := NewCertificateService(tamperedCertificates{env.repos.Certificates, edit}, env.chain, nil, env.audit)
+		valid, err := tampered.VerifyCertificate(ctx, cert.Hash)
+		if err != nil || valid {
+			t.Errorf("VerifyCertificate with the %s edited = %v, %v; want false", name, valid, err)
+		}
+	}
+}
+
+func TestRevokeAndRenewCertificate(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	issuer := env.saveUser(t, "issuer@example.com", domain.RoleIssuer)
+	other := env.saveUser(t, "other@example.com", domain.RoleIssuer)
+	first := env.issue(t, issuer, "First")
+	second := env.issue(t, issuer, "Second")
+
+	if _, err := env.certs.RevokeCertificate(ctx, first.ID, domain.RevokeCertificateRequest{Reason: "issued in error"}, other); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("revoke by another issuer: got %v, want not found", err)
+	}
+	if _, err := env.certs.RevokeCertificate(ctx, first.ID, domain.RevokeCertificateRequest{Reason: "issued in error"}, issuer); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if valid, err := env.certs.VerifyCertificate(ctx, first.Hash); err != nil || valid {
+		t.Errorf("VerifyCertificate of a revoked certificate = %v, %v; want false", valid, err)
+	}
+	if _, err := env.certs.RevokeCertificate(ctx, first.ID, domain.RevokeCertificateRequest{}, issuer); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("second revoke: got %v, want a conflict", err)
+	}
+
+	renewed, err := env.certs.RenewCertificate(ctx, second.ID, domain.RenewCertificateRequest{}, issuer)
+	if err != nil {
+		t.Fatalf("renew: %v", err)
+	}
+	if renewed.RenewedFrom != second.ID || renewed.CertificateTitle != "Second" {
+		t.Errorf("renewal is %+v, want a copy of %s", renewed, second.ID)
+	}
+	previous, err := env.certs.GetCertificate(ctx, second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous.Status != domain.CertificateStatusSuperseded {
+		t.Errorf("renewed certificate has status %q, want %q", previous.Status, domain.CertificateStatusSuperseded)
+	}
+	if _, err := env.certs.RenewCertificate(ctx, second.ID, domain.RenewCertificateRequest{}, issuer); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("second renewal: got %v, want a conflict", err)
+	}
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"certificate-ledger/blockchain"
+	"certificate-ledger/domain"
+	"certificate-ledger/repository"
+	"certificate-ledger/witness"
+	"github.com/google/uuid"
+)
+
+// CheckpointConfig names this ledger to witnesses and sets how often its head
+// is checkpointed. Key signs the requests to witnesses, which know its public
+// half as Origin's. A checkpoint is co-signed once Quorum witnesses signed it.
+type CheckpointConfig struct {
+	Origin   string
+	Key      ed25519.PrivateKey
+	Interval time.Duration
+	Quorum   int
+}
+
+// CheckpointService has witnesses co-sign checkpoints of the chain's head.
+// Co-signed checkpoints are pinned on the chain, so a chain rewritten below
+// one of them no longer validates.
+type CheckpointService struct {
+	checkpoints repository.CheckpointRepository
+	blockchain  *blockchain.Blockchain
+	witnesses   []*witness.Client
+	audit       *AuditService
+	config      CheckpointConfig
+	// checkpointing keeps two checkpoints from being made at once.
+	checkpointing sync.Mutex
+	running       sync.WaitGroup
+}
+
+func NewCheckpointService(checkpoints repository.CheckpointRepository, bc *blockchain.Blockchain, witnesses []*witness.Client, audit *AuditService, config CheckpointConfig) *CheckpointService {
+	return &CheckpointService{
+		checkpoints: checkpoints,
+		blockchain:  bc,
+		witnesses:   witnesses,
+		audit:       audit,
+		config:      config,
+	}
+}
+
+// ListCheckpoints returns the latest checkpoints, newest first.
+func (s *CheckpointService) ListCheckpoints(ctx context.Context, limit int) ([]*domain.Checkpoint, error) {
+	if limit <= 0 {
+		limit = domain.DefaultCheckpointLimit
+	}
+	if limit > domain.MaxCheckpointLimit {
+		limit = domain.MaxCheckpointLimit
+	}
+	checkpoints, err := s.checkpoints.FindRecent(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, checkpoint := range checkpoints {
+		s.markCosigned(checkpoint)
+	}
+	return checkpoints, nil
+}
+
+func (s *CheckpointService) GetCheckpoint(ctx context.Context, id string) (*domain.Checkpoint, error) {
+	checkpoint, err := s.checkpoints.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.markCosigned(checkpoint)
+	return checkpoint, nil
+}
+
+// PinCosigned pins the co-signed checkpoints stored under this ledger's
+// origin, including those of earlier runs. Their genesis block is pinned too,
+// so a chain started over from a new genesis block is invalid rather than
+// free of checkpoints.
+func (s *CheckpointService) PinCosigned(ctx context.Context) error {
+	checkpoints, err := s.checkpoints.FindByOrigin(ctx, s.config.Origin)
+	if err != nil {
+		return err
+	}
+	for _, checkpoint := range checkpoints {
+		if s.markCosigned(checkpoint) {
+			s.blockchain.Pin(0, checkpoint.GenesisHash)
+			s.blockchain.Pin(checkpoint.Height, checkpoint.HeadHash)
+		}
+	}
+	return nil
+}
+
+// CheckpointNow checkpoints the current head on behalf of an admin, even if
+// it is already checkpointed.
+func (s *CheckpointService) CheckpointNow(ctx context.Context, actorID string) (*domain.Checkpoint, error) {
+	if len(s.witnesses) == 0 {
+		return nil, domain.Conflict("checkpoints_disabled", "no witnesses are configured")
+	}
+	checkpoint, err := s.checkpoint(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	s.audit.Record(ctx, AuditEvent{
+		ActorID:    actorID,
+		Action:     domain.AuditCheckpointCreate,
+		TargetType: domain.AuditTargetCheckpoint,
+		TargetID:   checkpoint.ID,
+		After: map[string]interface{}{
+			"height":     checkpoint.Height,
+			"headHash":   checkpoint.HeadHash,
+			"signatures": len(checkpoint.Signatures),
+			"cosigned":   checkpoint.Cosigned,
+		},
+	})
+	return checkpoint, nil
+}
+
+// Start checkpoints the head at once and then every interval until ctx is
+// cancelled. A head that is already checkpointed is skipped.
+func (s *CheckpointService) Start(ctx context.Context) {
+	if len(s.witnesses) == 0 {
+		return
+	}
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+		for {
+			if checkpoint, err := s.checkpoint(ctx, false); err != nil && ctx.Err() == nil {
+				log.Printf("Failed to checkpoint the chain: %v", err)
+			} else if checkpoint != nil {
+				log.Printf("Checkpoint of block %d signed by %d of %d witnesses", checkpoint.Height, len(checkpoint.Signatures), len(s.witnesses))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Wait blocks until the checkpointing loop has stopped.
+func (s *CheckpointService) Wait() {
+	s.running.Wait()
+}
+
+// checkpoint asks every witness to co-sign the head, stores the checkpoint
+// with the signatures it got and pins it if they reach the quorum. Unless
+// force is set it returns nil if the latest checkpoint is of the head. A chain
+// that fails validation is never checkpointed.
+func (s *CheckpointService) checkpoint(ctx context.Context, force bool) (*domain.Checkpoint, error) {
+	s.checkpointing.Lock()
+	defer s.checkpointing.Unlock()
+
+	if health := s.blockchain.Health(); !health.Valid {
+		return nil, domain.Conflict("chain_invalid", "the chain is broken at block %d: %s", health.FirstInvalid.Index, health.FirstInvalid.Reason)
+	}
+	info := s.blockchain.Info()
+	if !force {
+		latest, err := s.checkpoints.FindRecent(ctx, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(latest) > 0 && latest[0].GenesisHash == info.GenesisHash && latest[0].HeadHash == info.HeadHash {
+			return nil, nil
+		}
+	}
+
+	cp := witness.Checkpoint{
+		Origin:      s.config.Origin,
+		GenesisHash: info.GenesisHash,
+		Height:      info.Height,
+		HeadHash:    info.HeadHash,
+	}
+	checkpoint := &domain.Checkpoint{
+		ID:          uuid.New().String(),
+		Origin:      cp.Origin,
+		GenesisHash: cp.GenesisHash,
+		Height:      cp.Height,
+		HeadHash:    cp.HeadHash,
+		CreatedAt:   time.Now().UTC(),
+		Signatures:  []domain.CheckpointSignature{},
+	}
+	for _, w := range s.witnesses {
+		cosignature, err := s.cosign(ctx, w, cp)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			s.reportRefusal(w, cp, err)
+			continue
+		}
+		checkpoint.Signatures = append(checkpoint.Signatures, domain.CheckpointSignature{
+			Witness:   w.Name,
+			PublicKey: hex.EncodeToString(w.PublicKey),
+			SignedAt:  cosignature.SignedAt,
+			Signature: cosignature.Signature,
+		})
+	}
+
+	if err := s.checkpoints.Save(context.WithoutCancel(ctx), checkpoint); err != nil {
+		return nil, err
+	}
+	if s.markCosigned(checkpoint) {
+		s.blockchain.Pin(0, checkpoint.GenesisHash)
+		s.blockchain.Pin(checkpoint.Height, checkpoint.HeadHash)
+	}
+	return checkpoint, nil
+}
+
+// cosign sends a witness the headers of the blocks above the last checkpoint
+// it co-signed for this ledger's origin, in a request signed with the
+// origin's key. A witness holding a checkpoint the chain contradicts is
+// reported as a conflict without asking it to sign.
+func (s *CheckpointService) cosign(ctx context.Context, w *witness.Client, cp witness.Checkpoint) (*witness.Cosignature, error) {
+	last, err := w.Latest(ctx, cp.Origin)
+	if err != nil {
+		return nil, err
+	}
+	from := -1
+	if last != nil {
+		if last.GenesisHash != cp.GenesisHash {
+			return nil, fmt.Errorf("%w: it co-signed the chain from genesis %s", witness.ErrConflict, last.GenesisHash)
+		}
+		block, ok := s.blockchain.BlockByIndex(last.Height)
+		if !ok || block.Hash != last.HeadHash {
+			return nil, fmt.Errorf("%w: it co-signed block %d as %s", witness.ErrConflict, last.Height, last.HeadHash)
+		}
+		from = last.Height
+	}
+
+	proof := []witness.Header{}
+	for _, block := range s.blockchain.BlocksAfter(from) {
+		if block.Index > cp.Height {
+			break
+		}
+		proof = append(proof, witness.Header{Index: block.Index, Hash: block.Hash, PreviousHash: block.PreviousHash})
+	}
+	return w.Cosign(ctx, witness.NewCosignRequest(s.config.Key, cp, proof, time.Now()))
+}
+
+// reportRefusal logs why a witness did not sign. A conflict means the chain
+// contradicts what the witness saw before, so it is also raised as an alert.
+func (s *CheckpointService) reportRefusal(w *witness.Client, cp witness.Checkpoint, err error) {
+	log.Printf("Witness %s did not sign the checkpoint of block %d: %v", w.Name, cp.Height, err)
+	if errors.Is(err, witness.ErrConflict) {
+		s.blockchain.Publish(blockchain.EventAlert, blockchain.Alert{
+			Message:    fmt.Sprintf("witness %s holds a checkpoint the chain contradicts: %v", w.Name, err),
+			BlockIndex: cp.Height,
+			At:         time.Now(),
+		})
+	}
+}
+
+// markCosigned sets and returns whether the checkpoint reaches the quorum.
+func (s *CheckpointService) markCosigned(checkpoint *domain.Checkpoint) bool {
+	checkpoint.Cosigned = len(checkpoint.Signatures) >= s.config.Quorum
+	return checkpoint.Cosigned
+}
@@ -0,0 +1,495 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"certificate-ledger/blockchain"
+	"certificate-ledger/domain"
+	"certificate-ledger/mail"
+	"certificate-ledger/metrics"
+	"certificate-ledger/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	// integrityAlertDetails bounds the discrepancies spelled out in one alert
+	// email and the alert events published on the chain's hub; the webhook
+	// carries them all.
+	integrityAlertDetails = 20
+	// integrityHistory is how many earlier scans are searched for the last
+	// one that finished, to tell new discrepancies from known ones.
+	integrityHistory = 10
+	// integrityAlertLocale is the language of alert emails, which go to
+	// operators rather than recipients.
+	integrityAlertLocale = domain.LocaleEnglish
+	// integritySettleTime is how long a new block may go without its
+	// certificate row, which is saved just after the block is mined.
+	integritySettleTime = time.Minute
+	// maxIntegrityErrorLength matches the error column.
+	maxIntegrityErrorLength = 1024
+)
+
+var (
+	integrityScans = metrics.NewCounterVec("ledger_integrity_scans_total",
+		"Integrity scans by status.", "status")
+	integrityNewDiscrepancies = metrics.NewCounterVec("ledger_integrity_new_discrepancies_total",
+		"Discrepancies that the scan before did not find.")
+	integrityLastStatus = metrics.NewGaugeVec("ledger_integrity_last_scan_status",
+		"1 for the status of the latest integrity scan.", "status")
+	integrityLastDiscrepancies = metrics.NewGaugeVec("ledger_integrity_last_scan_discrepancies",
+		"Discrepancies the latest integrity scan found.")
+	integrityLastCertificates = metrics.NewGaugeVec("ledger_integrity_last_scan_certificates_checked",
+		"Certificates the latest integrity scan checked.")
+	integrityLastHeight = metrics.NewGaugeVec("ledger_integrity_last_scan_chain_height",
+		"Chain height the latest integrity scan checked up to.")
+	integrityLastFinished = metrics.NewGaugeVec("ledger_integrity_last_scan_finished_timestamp_seconds",
+		"When the latest integrity scan finished, in Unix time.")
+	integrityLastDuration = metrics.NewGaugeVec("ledger_integrity_last_scan_duration_seconds",
+		"How long the latest integrity scan took.")
+)
+
+// IntegrityConfig sets how often the ledger is scanned and who is emailed
+// about discrepancies.
+type IntegrityConfig struct {
+	Interval    time.Duration
+	AlertEmails []string
+}
+
+// IntegrityService re-validates the whole chain, compares it with the blocks
+// table and compares every certificate row with its block, so tampering is found even if nobody
+// verifies the certificate concerned. Discrepancies the previous scan did not
+// find are alerted on: in the log, as alert events on the chain's hub, to
+// admins' integrity.discrepancy webhooks and by email.
+type IntegrityService struct {
+	certs      repository.CertificateRepository
+	scans      repository.IntegrityScanRepository
+	blocks     repository.BlockRepository
+	blockchain *blockchain.Blockchain
+	webhooks   *WebhookService
+	mailer     mail.Mailer
+	audit      *AuditService
+	config     IntegrityConfig
+	// scanning keeps scans from overlapping.
+	scanning sync.Mutex
+	running  sync.WaitGroup
+}
+
+func NewIntegrityService(certs repository.CertificateRepository, scans repository.IntegrityScanRepository, blocks repository.BlockRepository, bc *blockchain.Blockchain, webhooks *WebhookService, mailer mail.Mailer, audit *AuditService, config IntegrityConfig) *IntegrityService {
+	return &IntegrityService{
+		certs:      certs,
+		scans:      scans,
+		blocks:     blocks,
+		blockchain: bc,
+		webhooks:   webhooks,
+		mailer:     mailer,
+		audit:      audit,
+		config:     config,
+	}
+}
+
+// integrityAlertMail fills in the integrity_alert templates.
+type integrityAlertMail struct {
+	ScanID        string
+	StartedAt     string
+	NewCount      int
+	TotalCount    int
+	Discrepancies []domain.IntegrityDiscrepancy
+	Omitted       int
+}
+
+// ListScans returns the latest scans, newest first.
+func (s *IntegrityService) ListScans(ctx context.Context, limit int) ([]*domain.IntegrityScan, error) {
+	if limit <= 0 {
+		limit = domain.DefaultIntegrityScanLimit
+	}
+	if limit > domain.MaxIntegrityScanLimit {
+		limit = domain.MaxIntegrityScanLimit
+	}
+	return s.scans.FindRecent(ctx, limit)
+}
+
+func (s *IntegrityService) GetScan(ctx context.Context, id string) (*domain.IntegrityScan, error) {
+	return s.scans.FindByID(ctx, id)
+}
+
+// RunScan scans the ledger now on behalf of an admin.
+func (s *IntegrityService) RunScan(ctx context.Context, actorID string) (*domain.IntegrityScan, error) {
+	if !s.scanning.TryLock() {
+		return nil, domain.Conflict("integrity_scan_running", "an integrity scan is already running")
+	}
+	defer s.scanning.Unlock()
+
+	scan, err := s.scan(ctx, domain.IntegrityTriggerManual)
+	if err != nil {
+		return nil, err
+	}
+	s.audit.Record(ctx, AuditEvent{
+		ActorID:    actorID,
+		Action:     domain.AuditIntegrityScan,
+		TargetType: domain.AuditTargetIntegrityScan,
+		TargetID:   scan.ID,
+		After: map[string]interface{}{
+			"status":           scan.Status,
+			"discrepancyCount": scan.DiscrepancyCount,
+		},
+	})
+	return scan, nil
+}
+
+// Start scans the ledger at once and then every interval until ctx is
+// cancelled. A scheduled scan is skipped while a manual one runs.
+func (s *IntegrityService) Start(ctx context.Context) {
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+		for {
+			if s.scanning.TryLock() {
+				if _, err := s.scan(ctx, domain.IntegrityTriggerSchedule); err != nil && ctx.Err() == nil {
+					log.Printf("Integrity scan failed: %v", err)
+				}
+				s.scanning.Unlock()
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Wait blocks until the scanner has stopped.
+func (s *IntegrityService) Wait() {
+	s.running.Wait()
+}
+
+// scan runs one scan, stores it and raises alerts. The caller holds
+// s.scanning. A scan that cannot finish is stored as failed; one cut off by
+// ctx is dropped.
+func (s *IntegrityService) scan(ctx context.Context, trigger string) (*domain.IntegrityScan, error) {
+	scan := &domain.IntegrityScan{
+		ID:            uuid.New().String(),
+		Trigger:       trigger,
+		StartedAt:     time.Now().UTC(),
+		Discrepancies: []domain.IntegrityDiscrepancy{},
+	}
+	known, err := s.knownDiscrepancies(ctx)
+	if err == nil {
+		err = s.check(ctx, scan)
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	finishedAt := time.Now().UTC()
+	scan.FinishedAt = &finishedAt
+	var fresh []domain.IntegrityDiscrepancy
+	switch {
+	case err != nil:
+		scan.Status = domain.IntegrityFailed
+		scan.Error = truncateRunes(err.Error(), maxIntegrityErrorLength)
+		log.Printf("Integrity scan %s failed: %v", scan.ID, err)
+	case scan.DiscrepancyCount == 0:
+		scan.Status = domain.IntegrityClean
+	default:
+		scan.Status = domain.IntegrityDiscrepancies
+		for _, d := range scan.Discrepancies {
+			if !known[d.Key()] {
+				fresh = append(fresh, d)
+			}
+		}
+		scan.NewDiscrepancies = len(fresh)
+	}
+	recordIntegrityMetrics(scan)
+
+	if err := s.scans.Save(context.WithoutCancel(ctx), scan); err != nil {
+		return nil, err
+	}
+	if len(fresh) > 0 {
+		s.alert(ctx, scan, fresh)
+	}
+	return scan, nil
+}
+
+// knownDiscrepancies returns the discrepancies found by the last scan that
+// finished, keyed by IntegrityDiscrepancy.Key.
+func (s *IntegrityService) knownDiscrepancies(ctx context.Context) (map[string]bool, error) {
+	scans, err := s.scans.FindRecent(ctx, integrityHistory)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool)
+	for _, previous := range scans {
+		if previous.Status == domain.IntegrityFailed {
+			continue
+		}
+		for _, d := range previous.Discrepancies {
+			known[d.Key()] = true
+		}
+		break
+	}
+	return known, nil
+}
+
+// check validates the chain, compares it with the blocks table, then compares
+// every certificate with its block and every block with its certificate.
+func (s *IntegrityService) check(ctx context.Context, scan *domain.IntegrityScan) error {
+	if err := s.checkStoredBlocks(ctx, scan); err != nil {
+		return err
+	}
+	health := s.blockchain.Health()
+	scan.ChainValid = health.Valid
+	scan.ChainHeight = health.Height
+	if invalid := health.FirstInvalid; invalid != nil {
+		addDiscrepancy(scan, domain.IntegrityDiscrepancy{
+			Kind:       domain.DiscrepancyChainInvalid,
+			BlockIndex: invalid.Index,
+			Detail:     invalid.Reason,
+		})
+	}
+
+	certs, err := s.certs.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	recorded := make(map[string]bool, len(certs))
+	for _, cert := range certs {
+		recorded[cert.ID] = true
+		if err := s.checkCertificate(ctx, scan, cert); err != nil {
+			return err
+		}
+		scan.CertificatesChecked++
+	}
+
+	for _, block := range s.blockchain.BlocksAfter(0) {
+		record, err := domain.ParseCertificateBlock(block.Data)
+		if err != nil {
+			addDiscrepancy(scan, domain.IntegrityDiscrepancy{
+				Kind:       domain.DiscrepancyUnrecordedBlock,
+				BlockIndex: block.Index,
+				Detail:     "block does not hold a certificate",
+			})
+			continue
+		}
+		if !recorded[record.ID] && block.Timestamp.Before(scan.StartedAt.Add(-integritySettleTime)) {
+			addDiscrepancy(scan, domain.IntegrityDiscrepancy{
+				Kind:          domain.DiscrepancyUnrecordedBlock,
+				CertificateID: record.ID,
+				BlockIndex:    block.Index,
+				Detail:        "no certificate row for the certificate in this block",
+			})
+		}
+	}
+	return ctx.Err()
+}
+
+// checkStoredBlocks compares the blocks table with the chain in memory, which
+// was loaded from it at startup and is what the other checks read. The chain
+// is read first: a block stored since then is only reported once it has had
+// integritySettleTime to reach the chain.
+func (s *IntegrityService) checkStoredBlocks(ctx context.Context, scan *domain.IntegrityScan) error {
+	chain := s.blockchain.BlocksAfter(-1)
+	stored, err := s.blocks.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	for i, block := range chain {
+		if i >= len(stored) {
+			addDiscrepancy(scan, domain.IntegrityDiscrepancy{
+				Kind:       domain.DiscrepancyStoredBlock,
+				BlockIndex: block.Index,
+				Detail:     "block is missing from the blocks table",
+			})
+			continue
+		}
+		if fields := blockDifferences(block, stored[i]); len(fields) > 0 {
+			addDiscrepancy(scan, domain.IntegrityDiscrepancy{
+				Kind:       domain.DiscrepancyStoredBlock,
+				BlockIndex: block.Index,
+				Detail:     "stored block differs from the chain in " + strings.Join(fields, ", "),
+			})
+		}
+	}
+	for _, block := range stored[min(len(chain), len(stored)):] {
+		if block.Timestamp.Before(scan.StartedAt.Add(-integritySettleTime)) {
+			addDiscrepancy(scan, domain.IntegrityDiscrepancy{
+				Kind:       domain.DiscrepancyStoredBlock,
+				BlockIndex: block.Index,
+				Detail:     "stored block is not on the chain",
+			})
+		}
+	}
+	return nil
+}
+
+// blockDifferences names the fields in which a stored block differs from
+// the chain's block.
+func blockDifferences(chain, stored *blockchain.Block) []string {
+	var fields []string
+	if stored.Index != chain.Index {
+		fields = append(fields, "index")
+	}
+	if !stored.Timestamp.Equal(chain.Timestamp) {
+		fields = append(fields, "timestamp")
+	}
+	if !bytes.Equal(stored.Data, chain.Data) {
+		fields = append(fields, "data")
+	}
+	if stored.PreviousHash != chain.PreviousHash {
+		fields = append(fields, "previousHash")
+	}
+	if stored.Hash != chain.Hash {
+		fields = append(fields, "hash")
+	}
+	if stored.Nonce != chain.Nonce {
+		fields = append(fields, "nonce")
+	}
+	return fields
+}
+
+// checkCertificate compares a certificate row with the commitments recorded
+// in its block.
+func (s *IntegrityService) checkCertificate(ctx context.Context, scan *domain.IntegrityScan, cert *domain.Certificate) error {
+	block, ok := s.blockchain.BlockByIndex(cert.BlockNumber)
+	if !ok || block.Hash != cert.Hash {
+		found, err := s.blockchain.GetBlock(cert.Hash)
+		if err != nil {
+			addDiscrepancy(scan, domain.IntegrityDiscrepancy{
+				Kind:          domain.DiscrepancyBlockMissing,
+				CertificateID: cert.ID,
+				BlockIndex:    cert.BlockNumber,
+				Detail:        fmt.Sprintf("no block has hash %s", cert.Hash),
+			})
+			return nil
+		}
+		addDiscrepancy(scan, domain.IntegrityDiscrepancy{
+			Kind:          domain.DiscrepancyBlockNumber,
+			CertificateID: cert.ID,
+			BlockIndex:    cert.BlockNumber,
+			Detail:        fmt.Sprintf("the block with the certificate's hash is block %d", found.Index),
+		})
+		block = found
+	}
+
+	record, err := domain.ParseCertificateBlock(block.Data)
+	if err != nil {
+		addDiscrepancy(scan, domain.IntegrityDiscrepancy{
+			Kind:          domain.DiscrepancyBlockData,
+			CertificateID: cert.ID,
+			BlockIndex:    block.Index,
+			Detail:        "block does not hold a certificate",
+		})
+		return nil
+	}
+	salts, err := s.certs.FindFieldSalts(ctx, cert.ID)
+	if err != nil {
+		return err
+	}
+	if fields := record.Differences(cert, salts); len(fields) > 0 {
+		addDiscrepancy(scan, domain.IntegrityDiscrepancy{
+			Kind:          domain.DiscrepancyBlockData,
+			CertificateID: cert.ID,
+			BlockIndex:    block.Index,
+			Detail:        "differs from the block in " + strings.Join(fields, ", "),
+		})
+	}
+	return nil
+}
+
+func addDiscrepancy(scan *domain.IntegrityScan, d domain.IntegrityDiscrepancy) {
+	scan.DiscrepancyCount++
+	if len(scan.Discrepancies) < domain.MaxIntegrityDiscrepancies {
+		scan.Discrepancies = append(scan.Discrepancies, d)
+	}
+}
+
+// alert reports new discrepancies. Failures are logged: the scan is stored
+// either way.
+func (s *IntegrityService) alert(ctx context.Context, scan *domain.IntegrityScan, fresh []domain.IntegrityDiscrepancy) {
+	log.Printf("Integrity scan %s found %d new discrepancies (%d in all)", scan.ID, scan.NewDiscrepancies, scan.DiscrepancyCount)
+	shown := fresh
+	if len(shown) > integrityAlertDetails {
+		shown = shown[:integrityAlertDetails]
+	}
+	for _, d := range shown {
+		log.Printf("Integrity discrepancy: %s certificate=%s block=%d: %s", d.Kind, d.CertificateID, d.BlockIndex, d.Detail)
+		s.blockchain.Publish(blockchain.EventAlert, blockchain.Alert{
+			Message:    discrepancyMessage(d),
+			BlockIndex: d.BlockIndex,
+			At:         scan.StartedAt,
+		})
+	}
+	if omitted := scan.NewDiscrepancies - len(shown); omitted > 0 {
+		s.blockchain.Publish(blockchain.EventAlert, blockchain.Alert{
+			Message: fmt.Sprintf("integrity scan %s found %d more new discrepancies", scan.ID, omitted),
+			At:      scan.StartedAt,
+		})
+	}
+
+	if s.webhooks != nil {
+		s.webhooks.IntegrityAlert(ctx, &domain.IntegrityAlertEvent{
+			ID:        uuid.New().String(),
+			Type:      domain.EventIntegrityDiscrepancy,
+			CreatedAt: time.Now().UTC(),
+			Data: domain.IntegrityAlertData{
+				ScanID:           scan.ID,
+				DiscrepancyCount: scan.DiscrepancyCount,
+				Discrepancies:    fresh,
+			},
+		})
+	}
+
+	if len(s.config.AlertEmails) == 0 {
+		return
+	}
+	msg, err := mail.Render("integrity_alert", integrityAlertLocale, integrityAlertMail{
+		ScanID:        scan.ID,
+		StartedAt:     scan.StartedAt.Format(time.RFC1123),
+		NewCount:      scan.NewDiscrepancies,
+		TotalCount:    scan.DiscrepancyCount,
+		Discrepancies: shown,
+		Omitted:       scan.NewDiscrepancies - len(shown),
+	})
+	if err != nil {
+		log.Printf("Failed to render integrity alert email: %v", err)
+		return
+	}
+	msg.To = s.config.AlertEmails
+	if err := s.mailer.Send(msg); err != nil {
+		log.Printf("Failed to email integrity alert for scan %s: %v", scan.ID, err)
+	}
+}
+
+func discrepancyMessage(d domain.IntegrityDiscrepancy) string {
+	if d.CertificateID == "" {
+		return fmt.Sprintf("%s at block %d: %s", d.Kind, d.BlockIndex, d.Detail)
+	}
+	return fmt.Sprintf("%s for certificate %s at block %d: %s", d.Kind, d.CertificateID, d.BlockIndex, d.Detail)
+}
+
+// recordIntegrityMetrics updates the ledger_integrity_* metrics.
+func recordIntegrityMetrics(scan *domain.IntegrityScan) {
+	integrityScans.Inc(scan.Status)
+	integrityNewDiscrepancies.Add(float64(scan.NewDiscrepancies))
+
+	for _, status := range domain.IntegrityStatuses {
+		current := 0.0
+		if status == scan.Status {
+			current = 1
+		}
+		integrityLastStatus.Set(current, status)
+	}
+	integrityLastDiscrepancies.Set(float64(scan.DiscrepancyCount))
+	integrityLastCertificates.Set(float64(scan.CertificatesChecked))
+	integrityLastHeight.Set(float64(scan.ChainHeight))
+	integrityLastFinished.Set(float64(scan.FinishedAt.Unix()))
+	integrityLastDuration.Set(scan.FinishedAt.Sub(scan.StartedAt).Seconds())
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"certificate-ledger/blockchain"
+	"certificate-ledger/domain"
+	"certificate-ledger/repository"
+)
+
+// tamperedBlocks serves the stored blocks through edit, as if someone
+// changed the blocks table behind the server's back.
+type tamperedBlocks struct {
+	repository.BlockRepository
+	edit func([]*blockchain.Block) []*blockchain.Block
+}
+
+func (r tamperedBlocks) FindAll(ctx context.Context) ([]*blockchain.Block, error) {
+	blocks, err := r.BlockRepository.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.edit(blocks), nil
+}
+
+func (e *testEnv) newTestIntegrity(blocks repository.BlockRepository) *IntegrityService {
+	return NewIntegrityService(e.repos.Certificates, e.repos.IntegrityScans, blocks, e.chain, nil, nil, e.audit, IntegrityConfig{})
+}
+
+func TestIntegrityScanIsCleanForAnUntouchedLedger(t *testing.T) {
+	env := newTestEnv(t)
+	issuer := env.saveUser(t, "issuer@example.com", domain.RoleIssuer)
+	env.issue(t, issuer, "First")
+	env.issue(t, issuer, "Second")
+
+	scan, err := env.newTestIntegrity(env.repos.Blocks).RunScan(context.Background(), "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scan.Status != domain.IntegrityClean || scan.CertificatesChecked != 2 {
+		t.Errorf("got status %q with %d certificates checked and %+v; want a clean scan of 2", scan.Status, scan.CertificatesChecked, scan.Discrepancies)
+	}
+}
+
+func TestIntegrityScanComparesTheBlocksTable(t *testing.T) {
+	env := newTestEnv(t)
+	issuer := env.saveUser(t, "issuer@example.com", domain.RoleIssuer)
+	env.issue(t, issuer, "First")
+	env.issue(t, issuer, "Second")
+
+	for name, test := range map[string]struct {
+		edit   func([]*blockchain.Block) []*blockchain.Block
+		index  int
+		detail string
+	}{
+		"edited block": {
+			edit: func(blocks []*blockchain.Block) []*blockchain.Block {
+				blocks[1].Data = []byte(`{"id":"CERT-forged"}`)
+				return blocks
+			},
+			index:  1,
+			detail: "data",
+		},
+		"deleted block": {
+			edit: func(blocks []*blockchain.Block) []*blockchain.Block {
+				return blocks[:2]
+			},
+			index:  2,
+			detail: "missing from the blocks table",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			scan, err := env.newTestIntegrity(tamperedBlocks{env.repos.Blocks, test.edit}).RunScan(context.Background(), "admin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if scan.Status != domain.IntegrityDiscrepancies || len(scan.Discrepancies) != 1 {
+				t.Fatalf("got status %q with %+v, want one discrepancy", scan.Status, scan.Discrepancies)
+			}
+			d := scan.Discrepancies[0]
+			if d.Kind != domain.DiscrepancyStoredBlock || d.BlockIndex != test.index || !strings.Contains(d.Detail, test.detail) {
+				t.Errorf("got %+v, want %s at block %d naming %q", d, domain.DiscrepancyStoredBlock, test.index, test.detail)
+			}
+		})
+	}
+}
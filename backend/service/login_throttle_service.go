@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/repository"
+)
+
+// throttlePolicy describes how failed logins for one scope are slowed down and locked out.
+type throttlePolicy struct {
+	// BackoffAfter is the number of failures after which each further attempt must wait.
+	BackoffAfter int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	// Threshold is the number of failures that triggers a lockout.
+	Threshold       int
+	LockoutDuration time.Duration
+	// Window is how long a failure counts towards the totals.
+	Window time.Duration
+}
+
+var (
+	accountThrottlePolicy = throttlePolicy{
+		BackoffAfter:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		Threshold:       10,
+		LockoutDuration: 15 * time.Minute,
+		Window:          24 * time.Hour,
+	}
+	ipThrottlePolicy = throttlePolicy{
+		BackoffAfter:    10,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		Threshold:       50,
+		LockoutDuration: 30 * time.Minute,
+		Window:          time.Hour,
+	}
+)
+
+// delay returns how long a client must wait after the given number of failures.
+func (p throttlePolicy) delay(failures int) time.Duration {
+	if failures < p.BackoffAfter {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := p.BackoffAfter; i < failures && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// LoginThrottledError is returned when a login attempt arrives during backoff or lockout.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *LoginThrottledError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("too many failed login attempts, account temporarily locked; retry in %d seconds", int(e.RetryAfter.Seconds()+0.5))
+	}
+	return fmt.Sprintf("too many failed login attempts; retry in %d seconds", int(e.RetryAfter.Seconds()+0.5))
+}
+
+type LoginThrottleService struct {
+	repo     repository.LoginThrottleRepository
+	userRepo repository.UserRepository
+	audit    *AuditService
+}
+
+func NewLoginThrottleService(repo repository.LoginThrottleRepository, userRepo repository.UserRepository, audit *AuditService) *LoginThrottleService {
+	return &LoginThrottleService{
+		repo:     repo,
+		userRepo: userRepo,
+		audit:    audit,
+	}
+}
+
+// Begin counts a login attempt as a failure before the credentials are
+// checked, so that concurrent attempts cannot all pass while the count is
+// still low; every attempt must end with Fail, Succeed or Release. An attempt
+// is rejected if the account or client IP is locked or still in backoff, or
+// if counting it exceeds the lockout threshold, which starts the lockout. It
+// ignores cancellation of ctx, so dropping the connection does not spare a
+// client the failure.
+func (s *LoginThrottleService) Begin(ctx context.Context, email, ip string) error {
+	ctx = context.WithoutCancel(ctx)
+	if err := s.check(ctx, email, ip); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	targets := throttleTargets(email, ip)
+	for i, t := range targets {
+		throttle, err := s.repo.RecordFailure(ctx, t.scope, t.key, now, now.Add(-t.policy.Window))
+		if err != nil {
+			s.forgive(ctx, targets[:i])
+			return err
+		}
+		if throttle.Failures > t.policy.Threshold {
+			s.forgive(ctx, targets[:i])
+			s.lock(ctx, t, ip, now)
+			return &LoginThrottledError{RetryAfter: t.policy.LockoutDuration, Locked: true}
+		}
+	}
+	return nil
+}
+
+// Fail ends an attempt whose credentials were wrong. The attempt was already
+// counted by Begin; a lockout starts once the threshold is reached.
+func (s *LoginThrottleService) Fail(ctx context.Context, email, ip string) {
+	ctx = context.WithoutCancel(ctx)
+	now := time.Now()
+	for _, t := range throttleTargets(email, ip) {
+		s.lock(ctx, t, ip, now)
+	}
+}
+
+// Succeed ends a completed login. It clears the account counter and takes
+// back the attempt from the IP counter, which is otherwise left alone so one
+// valid account cannot be used to reset it.
+func (s *LoginThrottleService) Succeed(ctx context.Context, email, ip string) {
+	ctx = context.WithoutCancel(ctx)
+	if _, err := s.repo.Delete(ctx, domain.ThrottleScopeAccount, normalizeEmail(email)); err != nil {
+		log.Printf("Failed to reset login throttle: %v", err)
+	}
+	if ip != "" {
+		s.forgive(ctx, []throttleTarget{{scope: domain.ThrottleScopeIP, key: ip, policy: ipThrottlePolicy}})
+	}
+}
+
+// Release takes back an attempt whose credentials were right but which did
+// not complete the login, e.g. one that now has to pass a second factor.
+func (s *LoginThrottleService) Release(ctx context.Context, email, ip string) {
+	s.forgive(context.WithoutCancel(ctx), throttleTargets(email, ip))
+}
+
+// check rejects the attempt if either the account or the client IP is locked or still in backoff.
+func (s *LoginThrottleService) check(ctx context.Context, email, ip string) error {
+	now := time.Now()
+	for _, t := range throttleTargets(email, ip) {
+		throttle, err := s.repo.Find(ctx, t.scope, t.key)
+		if err != nil {
+			return err
+		}
+		if throttle == nil {
+			continue
+		}
+
+		if throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil) {
+			return &LoginThrottledError{RetryAfter: throttle.LockedUntil.Sub(now), Locked: true}
+		}
+		if now.Sub(throttle.LastFailureAt) > t.policy.Window {
+			continue
+		}
+		if wait := throttle.LastFailureAt.Add(t.policy.delay(throttle.Failures)).Sub(now); wait > 0 {
+			return &LoginThrottledError{RetryAfter: wait}
+		}
+	}
+	return nil
+}
+
+// lock starts a lockout if the target's counter reached the threshold and
+// none is active.
+func (s *LoginThrottleService) lock(ctx context.Context, t throttleTarget, ip string, now time.Time) {
+	started, err := s.repo.Lock(ctx, t.scope, t.key, t.policy.Threshold, now, now.Add(t.policy.LockoutDuration))
+	if err != nil {
+		log.Printf("Failed to lock %s %s: %v", t.scope, t.key, err)
+		return
+	}
+	if started {
+		log.Printf("Login lockout started for %s %s", t.scope, t.key)
+		s.recordEvent(ctx, t.scope, t.key, domain.LockoutEventLocked, "", ip)
+	}
+}
+
+// forgive takes back an attempt counted by Begin.
+func (s *LoginThrottleService) forgive(ctx context.Context, targets []throttleTarget) {
+	for _, t := range targets {
+		if err := s.repo.Forgive(ctx, t.scope, t.key); err != nil {
+			log.Printf("Failed to take back login attempt: %v", err)
+		}
+	}
+}
+
+func (s *LoginThrottleService) ResetAccount(ctx context.Context, email string) error {
+	_, err := s.repo.Delete(ctx, domain.ThrottleScopeAccount, normalizeEmail(email))
+	return err
+}
+
+func (s *LoginThrottleService) UnlockUser(ctx context.Context, userID, actorID, ip string) error {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.unlock(ctx, domain.ThrottleScopeAccount, normalizeEmail(user.Email), actorID, ip)
+}
+
+func (s *LoginThrottleService) UnlockIP(ctx context.Context, clientIP, actorID, ip string) error {
+	return s.unlock(ctx, domain.ThrottleScopeIP, clientIP, actorID, ip)
+}
+
+func (s *LoginThrottleService) ListLocked(ctx context.Context) ([]*domain.LoginThrottle, error) {
+	return s.repo.FindLocked(ctx, time.Now())
+}
+
+func (s *LoginThrottleService) ListEvents(ctx context.Context, limit int) ([]*domain.LockoutEvent, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	return s.repo.FindEvents(ctx, limit)
+}
+
+func (s *LoginThrottleService) unlock(ctx context.Context, scope, key, actorID, ip string) error {
+	before, err := s.repo.Find(ctx, scope, key)
+	if err != nil {
+		return err
+	}
+	deleted, err := s.repo.Delete(ctx, scope, key)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return domain.NotFound("lockout_not_found", "no failed logins recorded for %s %s", scope, key)
+	}
+	s.recordEvent(ctx, scope, key, domain.LockoutEventUnlocked, actorID, ip)
+	s.audit.Record(ctx, AuditEvent{
+		ActorID:    actorID,
+		Action:     domain.AuditLockoutUnlock,
+		TargetType: domain.AuditTargetLockout,
+		TargetID:   scope + ":" + key,
+		Before:     before,
+	})
+	return nil
+}
+
+func (s *LoginThrottleService) recordEvent(ctx context.Context, scope, key, event, actorID, ip string) {
+	err := s.repo.SaveEvent(ctx, &domain.LockoutEvent{
+		Scope:     scope,
+		Key:       key,
+		Event:     event,
+		ActorID:   actorID,
+		IP:        ip,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		log.Printf("Failed to record lockout event: %v", err)
+	}
+}
+
+type throttleTarget struct {
+	scope  string
+	key    string
+	policy throttlePolicy
+}
+
+func throttleTargets(email, ip string) []throttleTarget {
+	targets := []throttleTarget{
+		{scope: domain.ThrottleScopeAccount, key: normalizeEmail(email), policy: accountThrottlePolicy},
+	}
+	if ip != "" {
+		targets = append(targets, throttleTarget{scope: domain.ThrottleScopeIP, key: ip, policy: ipThrottlePolicy})
+	}
+	return targets
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
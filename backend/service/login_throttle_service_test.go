@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"certificate-ledger/domain"
+)
+
+func TestConcurrentLoginsCannotExceedTheLockout(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	env.saveUser(t, "alice@example.com", domain.RoleUser)
+
+	// One failure short of the lockout, with the backoff long over.
+	earlier := time.Now().Add(-2 * accountThrottlePolicy.MaxDelay)
+	for i := 0; i < accountThrottlePolicy.Threshold-1; i++ {
+		if _, err := env.repos.LoginThrottles.RecordFailure(ctx, domain.ThrottleScopeAccount, "alice@example.com", earlier, earlier.Add(-time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const attempts = 20
+	var mu sync.Mutex
+	var wrongPassword, throttled int
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := env.auth.Login(ctx, domain.LoginRequest{Email: "alice@example.com", Password: "not the password"}, "")
+			var throttledErr *LoginThrottledError
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case errors.Is(err, domain.ErrUnauthorized):
+				wrongPassword++
+			case errors.As(err, &throttledErr):
+				throttled++
+			default:
+				t.Errorf("unexpected result %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if wrongPassword != 1 || throttled != attempts-1 {
+		t.Errorf("%d passwords checked and %d attempts throttled, want 1 and %d", wrongPassword, throttled, attempts-1)
+	}
+	var lockedErr *LoginThrottledError
+	_, err := env.auth.Login(ctx, domain.LoginRequest{Email: "alice@example.com", Password: testPassword}, "")
+	if !errors.As(err, &lockedErr) || !lockedErr.Locked {
+		t.Errorf("login with the right password during the lockout: got %v, want locked", err)
+	}
+}
+
+func TestLoginBacksOffAfterFailures(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	env.saveUser(t, "alice@example.com", domain.RoleUser)
+	wrong := domain.LoginRequest{Email: "alice@example.com", Password: "not the password"}
+
+	for i := 0; i < accountThrottlePolicy.BackoffAfter; i++ {
+		if _, err := env.auth.Login(ctx, wrong, "192.0.2.1"); !errors.Is(err, domain.ErrUnauthorized) {
+			t.Fatalf("failure %d: got %v, want unauthorized", i+1, err)
+		}
+	}
+	var throttled *LoginThrottledError
+	_, err := env.auth.Login(ctx, domain.LoginRequest{Email: "alice@example.com", Password: testPassword}, "192.0.2.1")
+	if !errors.As(err, &throttled) || throttled.Locked || throttled.RetryAfter <= 0 {
+		t.Errorf("attempt during backoff: got %v, want a delay without lockout", err)
+	}
+}
+
+func TestSuccessfulLoginClearsTheAccountButNotTheIP(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	env.saveUser(t, "alice@example.com", domain.RoleUser)
+
+	if _, err := env.auth.Login(ctx, domain.LoginRequest{Email: "alice@example.com", Password: "not the password"}, "192.0.2.1"); !errors.Is(err, domain.ErrUnauthorized) {
+		t.Fatal(err)
+	}
+	if _, err := env.auth.Login(ctx, domain.LoginRequest{Email: "alice@example.com", Password: testPassword}, "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if account, _ := env.repos.LoginThrottles.Find(ctx, domain.ThrottleScopeAccount, "alice@example.com"); account != nil {
+		t.Errorf("account counter %+v left after a login", account)
+	}
+	ip, err := env.repos.LoginThrottles.Find(ctx, domain.ThrottleScopeIP, "192.0.2.1")
+	if err != nil || ip == nil || ip.Failures != 1 {
+		t.Errorf("IP counter %+v, %v; want the one failure without the login", ip, err)
+	}
+}
+
+func TestAdminUnlockLiftsTheLockout(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	user := env.saveUser(t, "alice@example.com", domain.RoleUser)
+	admin := env.saveUser(t, "admin@example.com", domain.RoleAdmin)
+	env.lockAccount(t, user.Email)
+
+	if err := env.throttle.UnlockUser(ctx, user.ID, admin.ID, "192.0.2.9"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.auth.Login(ctx, domain.LoginRequest{Email: user.Email, Password: testPassword}, "192.0.2.1"); err != nil {
+		t.Errorf("login after unlock: %v", err)
+	}
+	if err := env.throttle.UnlockUser(ctx, user.ID, admin.ID, "192.0.2.9"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("second unlock: got %v, want not found", err)
+	}
+}
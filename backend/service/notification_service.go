@@ -0,0 +1,304 @@
+package service
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/mail"
+	"certificate-ledger/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	notificationBatchSize = 20
+	// notificationLease is how long a claimed notification is left to its
+	// worker before another may send it. It must outlast a slow SMTP server.
+	notificationLease = 5 * time.Minute
+	// maxNotificationErrorLength matches the last_error column.
+	maxNotificationErrorLength = 1024
+)
+
+// NotificationConfig tunes email delivery. A failed email is retried after
+// RetryBase, doubling each time up to RetryMax, until MaxAttempts attempts
+// have failed.
+type NotificationConfig struct {
+	// BaseURL is the frontend origin the links in emails point to.
+	BaseURL       string
+	DefaultLocale string
+	MaxAttempts   int
+	RetryBase     time.Duration
+	RetryMax      time.Duration
+	PollInterval  time.Duration
+}
+
+// NotificationService emails recipients when a certificate is issued to
+// them. Emails are rendered and stored when the certificate is issued and
+// sent by a background worker, so a mail server that is down or slow never
+// fails or delays issuance.
+type NotificationService struct {
+	repo    repository.NotificationRepository
+	mailer  mail.Mailer
+	audit   *AuditService
+	config  NotificationConfig
+	wake    chan struct{}
+	running sync.WaitGroup
+}
+
+func NewNotificationService(repo repository.NotificationRepository, mailer mail.Mailer, audit *AuditService, config NotificationConfig) *NotificationService {
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+	return &NotificationService{
+		repo:   repo,
+		mailer: mailer,
+		audit:  audit,
+		config: config,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// certificateIssuedMail fills in the certificate_issued templates.
+type certificateIssuedMail struct {
+	RecipientName    string
+	RecipientEmail   string
+	CertificateTitle string
+	IssuerName       string
+	IssueDate        string
+	VerifyURL        string
+	RegisterURL      string
+	ClaimCode        string
+	Renewal          bool
+}
+
+// CertificateChanged queues the email telling the recipient about a new or
+// renewed certificate. Failures are logged, never returned: the certificate
+// has already been issued.
+func (s *NotificationService) CertificateChanged(ctx context.Context, event *domain.CertificateEvent) {
+	if event.Type != domain.EventCertificateIssued && event.Type != domain.EventCertificateRenewed {
+		return
+	}
+	cert := event.Data.Certificate
+	if cert.RecipientEmail == "" || event.ClaimCode == "" {
+		return
+	}
+
+	locale := cert.RecipientLocale
+	if !mail.HasTemplate(domain.NotificationCertificateIssued, locale) {
+		locale = s.config.DefaultLocale
+	}
+	msg, err := mail.Render(domain.NotificationCertificateIssued, locale, certificateIssuedMail{
+		RecipientName:    singleLine(cert.RecipientName),
+		RecipientEmail:   cert.RecipientEmail,
+		CertificateTitle: singleLine(cert.CertificateTitle),
+		IssuerName:       singleLine(cert.IssuerName),
+		IssueDate:        formatMailDate(cert.IssueDate, locale),
+		VerifyURL:        s.config.BaseURL + "/verify?id=" + url.QueryEscape(cert.ID),
+		RegisterURL:      s.config.BaseURL + "/register",
+		ClaimCode:        event.ClaimCode,
+		Renewal:          event.Type == domain.EventCertificateRenewed,
+	})
+	if err != nil {
+		log.Printf("Failed to render email for certificate %s: %v", cert.ID, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	err = s.repo.Save(context.WithoutCancel(ctx), &domain.Notification{
+		ID:            uuid.New().String(),
+		CertificateID: cert.ID,
+		Template:      domain.NotificationCertificateIssued,
+		Recipient:     cert.RecipientEmail,
+		Locale:        locale,
+		Subject:       msg.Subject,
+		TextBody:      msg.TextBody,
+		HTMLBody:      msg.HTMLBody,
+		Status:        domain.NotificationPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+	if err != nil {
+		log.Printf("Failed to queue email for certificate %s: %v", cert.ID, err)
+		return
+	}
+	s.notify()
+}
+
+// QueueAccountMail queues an email rendered by the caller to an account
+// holder, so that requests sending it neither wait for the mail server nor
+// take longer than those that send nothing.
+func (s *NotificationService) QueueAccountMail(ctx context.Context, template, recipient string, msg mail.Message) error {
+	now := time.Now().UTC()
+	err := s.repo.Save(context.WithoutCancel(ctx), &domain.Notification{
+		ID:            uuid.New().String(),
+		Template:      template,
+		Recipient:     recipient,
+		Locale:        s.config.DefaultLocale,
+		Subject:       msg.Subject,
+		TextBody:      msg.TextBody,
+		HTMLBody:      msg.HTMLBody,
+		Status:        domain.NotificationPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+	if err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+// ListNotifications returns queued and sent emails, newest first. Passing the
+// failed status lists the ones that ran out of attempts.
+func (s *NotificationService) ListNotifications(ctx context.Context, status string, limit int) ([]*domain.Notification, error) {
+	if status != "" && !containsString(domain.NotificationStatuses, status) {
+		return nil, domain.Invalid("invalid_status", "unknown notification status %s", status)
+	}
+	if limit <= 0 {
+		limit = domain.DefaultNotificationLimit
+	}
+	if limit > domain.MaxNotificationLimit {
+		limit = domain.MaxNotificationLimit
+	}
+	return s.repo.FindByStatus(ctx, status, limit)
+}
+
+// RetryNotification sends a failed email again with a fresh set of attempts.
+func (s *NotificationService) RetryNotification(ctx context.Context, id, actorID string) (*domain.Notification, error) {
+	n, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.repo.Retry(ctx, n.ID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.Conflict("notification_not_failed", "only failed notifications can be retried")
+	}
+	s.audit.Record(ctx, AuditEvent{
+		ActorID:    actorID,
+		Action:     domain.AuditNotificationRetry,
+		TargetType: domain.AuditTargetNotification,
+		TargetID:   n.ID,
+		Before:     n,
+	})
+	s.notify()
+	return s.repo.FindByID(ctx, n.ID)
+}
+
+// Start runs the sending worker until ctx is cancelled.
+func (s *NotificationService) Start(ctx context.Context) {
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		ticker := time.NewTicker(s.config.PollInterval)
+		defer ticker.Stop()
+		for {
+			s.sendDue(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+		}
+	}()
+}
+
+// Wait blocks until the worker has stopped.
+func (s *NotificationService) Wait() {
+	s.running.Wait()
+}
+
+func (s *NotificationService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// sendDue sends every email that is due, a batch at a time.
+func (s *NotificationService) sendDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		now := time.Now().UTC()
+		due, err := s.repo.FindDue(ctx, now, notificationBatchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Failed to find due notifications: %v", err)
+			}
+			return
+		}
+
+		claimed := 0
+		for _, n := range due {
+			if ctx.Err() != nil {
+				return
+			}
+			ok, err := s.repo.Claim(ctx, n.ID, now, now.Add(notificationLease))
+			if err != nil {
+				log.Printf("Failed to claim notification %s: %v", n.ID, err)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			claimed++
+			s.send(ctx, n)
+		}
+
+		if len(due) < notificationBatchSize || claimed == 0 {
+			return
+		}
+	}
+}
+
+// send makes one attempt and records its outcome.
+func (s *NotificationService) send(ctx context.Context, n *domain.Notification) {
+	err := s.mailer.Send(mail.Message{
+		To:       []string{n.Recipient},
+		Subject:  n.Subject,
+		TextBody: n.TextBody,
+		HTMLBody: n.HTMLBody,
+	})
+
+	now := time.Now().UTC()
+	n.Attempts++
+	n.LastError = ""
+	switch {
+	case err == nil:
+		n.Status = domain.NotificationSent
+		n.SentAt = &now
+		// The bodies carry the claim code; it has no business staying in the
+		// database once the recipient has it.
+		n.TextBody = ""
+		n.HTMLBody = ""
+	case n.Attempts >= s.config.MaxAttempts:
+		n.Status = domain.NotificationFailed
+		n.LastError = truncateRunes(err.Error(), maxNotificationErrorLength)
+		log.Printf("Email %s (%s) failed after %d attempts: %v", n.ID, n.Template, n.Attempts, err)
+	default:
+		n.LastError = truncateRunes(err.Error(), maxNotificationErrorLength)
+		n.NextAttemptAt = now.Add(backoff(s.config.RetryBase, s.config.RetryMax, n.Attempts))
+	}
+
+	if err := s.repo.Update(context.WithoutCancel(ctx), n); err != nil {
+		log.Printf("Failed to record notification attempt for %s: %v", n.ID, err)
+	}
+}
+
+// formatMailDate writes a date the way readers of the locale expect.
+func formatMailDate(t time.Time, locale string) string {
+	if locale == domain.LocaleVietnamese {
+		return t.Format("02/01/2006")
+	}
+	return t.Format("January 2, 2006")
+}
+
+// singleLine collapses whitespace, so values that end up in the subject
+// cannot break the message header.
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
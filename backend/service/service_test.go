@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"certificate-ledger/blockchain"
+	"certificate-ledger/domain"
+	"certificate-ledger/repository"
+	"certificate-ledger/repository/memory"
+	"certificate-ledger/search"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testEnv wires the services under test to the in-memory repositories, as
+// the server does with STORAGE_BACKEND=memory.
+type testEnv struct {
+	repos    *repository.Repositories
+	chain    *blockchain.Blockchain
+	audit    *AuditService
+	certs    *CertificateService
+	throttle *LoginThrottleService
+	auth     *AuthService
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	repos := memory.NewRepositories()
+	chain, err := blockchain.NewBlockchain(context.Background(), 1, repos.Blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit := NewAuditService(repos.Audit)
+	throttle := NewLoginThrottleService(repos.LoginThrottles, repos.Users, audit)
+	return &testEnv{
+		repos:    repos,
+		chain:    chain,
+		audit:    audit,
+		certs:    NewCertificateService(repos.Certificates, chain, search.NewMemoryIndex(repos.Certificates.FindByID), audit),
+		throttle: throttle,
+		auth: NewAuthService(repos.Users, repos.RecoveryCodes, throttle, JWTConfig{
+			Secret: "0123456789abcdef0123456789abcdef",
+			TTL:    time.Hour,
+		}, audit, "Certificate Ledger"),
+	}
+}
+
+// testPassword is the password of every user saveUser stores.
+const testPassword = "correct horse battery"
+
+// saveUser stores a user with the role and testPassword.
+func (e *testEnv) saveUser(t *testing.T, email, role string) *domain.User {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &domain.User{Name: "Test " + role, Email: email, Password: string(hash), Role: role, EmailVerified: true}
+	if err := e.repos.Users.Save(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// issue issues a certificate from issuer to alice@example.com.
+func (e *testEnv) issue(t *testing.T, issuer *domain.User, title string) *domain.Certificate {
+	t.Helper()
+	cert, err := e.certs.CreateCertificate(context.Background(), domain.CertificateRequest{
+		RecipientName:    "Alice",
+		RecipientEmail:   "alice@example.com",
+		CertificateTitle: title,
+		IssueDate:        "2024-01-02",
+		IssuerName:       "Example University",
+		Description:      "With distinction",
+	}, issuer.ID)
+	if err != nil {
+		t.Fatalf("issue %q: %v", title, err)
+	}
+	return cert
+}
+
+// lockAccount records failed logins for the email until its account is locked.
+func (e *testEnv) lockAccount(t *testing.T, email string) {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Now()
+	for i := 0; i < accountThrottlePolicy.Threshold; i++ {
+		if _, err := e.repos.LoginThrottles.RecordFailure(ctx, domain.ThrottleScopeAccount, normalizeEmail(email), now, now.Add(-time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e.throttle.Fail(ctx, email, "")
+	var throttled *LoginThrottledError
+	if err := e.throttle.check(ctx, email, ""); !errors.As(err, &throttled) || !throttled.Locked {
+		t.Fatalf("account not locked: %v", err)
+	}
+}
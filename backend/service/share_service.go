@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"certificate-ledger/blockchain"
+	"certificate-ledger/domain"
+	"certificate-ledger/repository"
+)
+
+const (
+	defaultShareLinkTTL = 7 * 24 * time.Hour
+	maxShareLinkTTL     = 365 * 24 * time.Hour
+)
+
+// ShareService manages recipient-created share links that reveal only chosen
+// certificate fields, each verifiable against the commitments in its block.
+type ShareService struct {
+	repo       repository.ShareLinkRepository
+	certRepo   repository.CertificateRepository
+	blockchain *blockchain.Blockchain
+}
+
+func NewShareService(repo repository.ShareLinkRepository, certRepo repository.CertificateRepository, bc *blockchain.Blockchain) *ShareService {
+	return &ShareService{
+		repo:       repo,
+		certRepo:   certRepo,
+		blockchain: bc,
+	}
+}
+
+func (s *ShareService) CreateLink(ctx context.Context, certificateID string, req domain.ShareLinkRequest, user *domain.User) (*domain.ShareLink, error) {
+	cert, err := s.certRepo.FindByID(ctx, certificateID)
+	if err != nil {
+		return nil, err
+	}
+	if !isRecipient(cert, user) {
+		return nil, domain.Forbidden("not_recipient", "only the recipient can share certificate %s", certificateID)
+	}
+
+	if len(req.Fields) == 0 {
+		return nil, domain.Invalid("no_fields", "at least one field must be disclosed")
+	}
+	seen := make(map[string]bool)
+	var fields []string
+	for _, field := range req.Fields {
+		if !domain.IsDisclosableField(field) {
+			return nil, domain.Invalid("field_not_disclosable", "field %s cannot be disclosed", field)
+		}
+		if !seen[field] {
+			seen[field] = true
+			fields = append(fields, field)
+		}
+	}
+
+	salts, err := s.certRepo.FindFieldSalts(ctx, cert.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(salts) == 0 {
+		return nil, domain.Conflict("disclosure_unsupported", "certificate %s was issued before selective disclosure and cannot be shared", certificateID)
+	}
+
+	ttl := defaultShareLinkTTL
+	if req.ExpiresInHours > 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+	if ttl > maxShareLinkTTL {
+		return nil, domain.Invalid("expiry_too_long", "share links can last at most %d hours", int(maxShareLinkTTL.Hours()))
+	}
+
+	token := make([]byte, 24)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("failed to generate share link: %v", err)
+	}
+
+	now := time.Now()
+	link := &domain.ShareLink{
+		ID:            base64.RawURLEncoding.EncodeToString(token),
+		CertificateID: cert.ID,
+		OwnerID:       user.ID,
+		Fields:        fields,
+		ExpiresAt:     now.Add(ttl),
+		CreatedAt:     now,
+	}
+	if err := s.repo.Save(ctx, link); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+func (s *ShareService) ListLinks(ctx context.Context, certificateID string, user *domain.User) ([]*domain.ShareLink, error) {
+	return s.repo.FindByCertificate(ctx, certificateID, user.ID)
+}
+
+func (s *ShareService) RevokeLink(ctx context.Context, id string, user *domain.User) error {
+	link, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if link.OwnerID != user.ID {
+		return domain.NotFound("share_link_not_found", "share link not found")
+	}
+	return s.repo.Revoke(ctx, link.ID, time.Now())
+}
+
+// View resolves a share link for an anonymous viewer and counts the view.
+// Each disclosed field is checked against the commitment recorded on chain.
+// As with VerifyCertificate, a revoked certificate is not verified.
+func (s *ShareService) View(ctx context.Context, id string) (*domain.SharedCertificate, error) {
+	link, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.repo.RecordView(ctx, link.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.NotFound("share_link_expired", "share link has expired or been revoked")
+	}
+
+	cert, err := s.certRepo.FindByID(ctx, link.CertificateID)
+	if err != nil {
+		return nil, err
+	}
+	salts, err := s.certRepo.FindFieldSalts(ctx, cert.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	shared := &domain.SharedCertificate{
+		CertificateID: cert.ID,
+		Hash:          cert.Hash,
+		BlockNumber:   cert.BlockNumber,
+		Timestamp:     cert.Timestamp,
+		Disclosed:     make(map[string]domain.DisclosedField, len(link.Fields)),
+		Status:        cert.Status,
+		RevokedAt:     cert.RevokedAt,
+		ExpiresAt:     link.ExpiresAt,
+	}
+
+	var commitments map[string]string
+	if block, err := s.blockchain.GetBlock(cert.Hash); err == nil {
+		if record, err := domain.ParseCertificateBlock(block.Data); err == nil && record.ID == cert.ID {
+			commitments = record.Commitments
+		}
+	}
+	shared.Commitments = commitments
+
+	allVerified := commitments != nil
+	for _, field := range link.Fields {
+		value := domain.FieldValue(cert, field)
+		salt := salts[field]
+		disclosed := domain.DisclosedField{
+			Value:      value,
+			Salt:       salt,
+			Commitment: commitments[field],
+		}
+		disclosed.Verified = disclosed.Commitment != "" && domain.FieldCommitment(field, salt, value) == disclosed.Commitment
+		allVerified = allVerified && disclosed.Verified
+		shared.Disclosed[field] = disclosed
+	}
+	shared.Verified = allVerified && cert.Status != domain.CertificateStatusRevoked
+	return shared, nil
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"certificate-ledger/domain"
+)
+
+func TestShareLinkDisclosesOnlyChosenFields(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	issuer := env.saveUser(t, "issuer@example.com", domain.RoleIssuer)
+	alice := env.saveUser(t, "alice@example.com", domain.RoleUser)
+	cert := env.issue(t, issuer, "Bachelor of Science")
+	shares := NewShareService(env.repos.ShareLinks, env.repos.Certificates, env.chain)
+
+	if _, err := shares.CreateLink(ctx, cert.ID, domain.ShareLinkRequest{Fields: []string{domain.FieldCertificateTitle}}, issuer); !errors.Is(err, domain.ErrForbidden) {
+		t.Errorf("link by the issuer: got %v, want forbidden", err)
+	}
+	link, err := shares.CreateLink(ctx, cert.ID, domain.ShareLinkRequest{Fields: []string{domain.FieldCertificateTitle, domain.FieldIssueDate}}, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shared, err := shares.View(ctx, link.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !shared.Verified || shared.Status != domain.CertificateStatusActive || len(shared.Disclosed) != 2 {
+		t.Fatalf("view gave %+v, want two verified fields of an active certificate", shared)
+	}
+	title := shared.Disclosed[domain.FieldCertificateTitle]
+	if title.Value != "Bachelor of Science" || !title.Verified || domain.FieldCommitment(domain.FieldCertificateTitle, title.Salt, title.Value) != title.Commitment {
+		t.Errorf("title disclosed as %+v, want it checkable against its commitment", title)
+	}
+	if _, ok := shared.Disclosed[domain.FieldRecipientEmail]; ok {
+		t.Error("the recipient's email was disclosed without being chosen")
+	}
+
+	if err := shares.RevokeLink(ctx, link.ID, alice); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := shares.View(ctx, link.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("revoked link: got %v, want not found", err)
+	}
+}
+
+func TestShareLinkOfRevokedCertificateIsNotVerified(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	issuer := env.saveUser(t, "issuer@example.com", domain.RoleIssuer)
+	alice := env.saveUser(t, "alice@example.com", domain.RoleUser)
+	cert := env.issue(t, issuer, "Bachelor of Science")
+	shares := NewShareService(env.repos.ShareLinks, env.repos.Certificates, env.chain)
+
+	link, err := shares.CreateLink(ctx, cert.ID, domain.ShareLinkRequest{Fields: []string{domain.FieldCertificateTitle}}, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.certs.RevokeCertificate(ctx, cert.ID, domain.RevokeCertificateRequest{Reason: "issued in error"}, issuer); err != nil {
+		t.Fatal(err)
+	}
+
+	shared, err := shares.View(ctx, link.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shared.Verified || shared.Status != domain.CertificateStatusRevoked || shared.RevokedAt == nil {
+		t.Errorf("view gave verified %v, status %q; want a revoked, unverified certificate", shared.Verified, shared.Status)
+	}
+}
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/oidc"
+	"certificate-ledger/repository"
+)
+
+// ssoStateTTL bounds how long a user may spend at the identity provider.
+const ssoStateTTL = 10 * time.Minute
+
+type SSOConfig struct {
+	// AllowedDomains restricts sign-in to email addresses in these domains.
+	AllowedDomains []string
+	// RoleClaim names the ID token claim (e.g. "groups") used for role mapping.
+	RoleClaim string
+	// RoleMappings maps a RoleClaim value to a local role.
+	RoleMappings map[string]string
+	// DefaultRole is given to newly provisioned users without a mapped role.
+	DefaultRole string
+}
+
+// SSOService signs users in through an OpenID Connect provider, provisioning
+// accounts for allowed domains on first login.
+type SSOService struct {
+	client       *oidc.Client
+	issuer       string
+	config       SSOConfig
+	userRepo     repository.UserRepository
+	identityRepo repository.IdentityRepository
+	auth         *AuthService
+}
+
+func NewSSOService(client *oidc.Client, issuer string, config SSOConfig, userRepo repository.UserRepository, identityRepo repository.IdentityRepository, auth *AuthService) *SSOService {
+	if config.DefaultRole == "" {
+		config.DefaultRole = domain.RoleUser
+	}
+	return &SSOService{
+		client:       client,
+		issuer:       issuer,
+		config:       config,
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		auth:         auth,
+	}
+}
+
+type ssoState struct {
+	oidc.AuthRequest
+	ExpiresAt int64 `json:"exp"`
+}
+
+// Begin starts a login. It returns the provider URL to redirect to and a
+// signed state value that must be handed back to Callback, usually via a cookie.
+func (s *SSOService) Begin(ctx context.Context) (string, string, error) {
+	req, err := oidc.NewAuthRequest()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := s.client.AuthCodeURL(ctx, req)
+	if err != nil {
+		return "", "", err
+	}
+
+	payload, err := json.Marshal(ssoState{AuthRequest: *req, ExpiresAt: time.Now().Add(ssoStateTTL).Unix()})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode sso state: %v", err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return authURL, encoded + "." + s.signState(encoded), nil
+}
+
+// Callback completes a login from the provider's redirect.
+func (s *SSOService) Callback(ctx context.Context, code, state, signedState string) (*domain.AuthResponse, error) {
+	req, err := s.decodeState(signedState)
+	if err != nil {
+		return nil, err
+	}
+	if state == "" || !hmac.Equal([]byte(state), []byte(req.State)) {
+		return nil, domain.Unauthorized("sso_state_mismatch", "sso state mismatch")
+	}
+
+	claims, err := s.client.Exchange(ctx, code, req)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.resolveUser(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	return s.auth.CompleteExternalLogin(ctx, user, providerDidMFA(claims))
+}
+
+func (s *SSOService) resolveUser(ctx context.Context, claims oidc.Claims) (*domain.User, error) {
+	email := strings.ToLower(strings.TrimSpace(claims.String("email")))
+	if email == "" {
+		return nil, domain.Forbidden("sso_email_missing", "identity provider did not return an email address")
+	}
+	if !claims.Bool("email_verified") {
+		return nil, domain.Forbidden("sso_email_unverified", "identity provider has not verified %s", email)
+	}
+	if !s.domainAllowed(email) {
+		return nil, domain.Forbidden("sso_domain_not_allowed", "email domain of %s is not allowed to sign in", email)
+	}
+
+	now := time.Now()
+	subject := claims.String("sub")
+	mappedRole := s.mapRole(claims)
+
+	var user *domain.User
+	identity, err := s.identityRepo.FindBySubject(ctx, s.issuer, subject)
+	switch {
+	case err == nil:
+		user, err = s.userRepo.FindByID(ctx, identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+	case errors.Is(err, domain.ErrNotFound):
+		identity = &domain.UserIdentity{Issuer: s.issuer, Subject: subject, CreatedAt: now}
+		user, err = s.userRepo.FindByEmail(ctx, email)
+		if errors.Is(err, domain.ErrNotFound) {
+			user, err = s.provision(ctx, claims, email, mappedRole)
+		}
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	changed := false
+	if mappedRole != "" && user.Role != mappedRole {
+		user.Role = mappedRole
+		changed = true
+	}
+	if !user.EmailVerified {
+		user.EmailVerified = true
+		user.EmailVerifiedAt = &now
+		changed = true
+	}
+	if changed {
+		if err := s.userRepo.Save(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	identity.UserID = user.ID
+	identity.Email = email
+	identity.LastLoginAt = now
+	if err := s.identityRepo.Save(ctx, identity); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *SSOService) provision(ctx context.Context, claims oidc.Claims, email, role string) (*domain.User, error) {
+	name := claims.String("name")
+	if name == "" {
+		name = strings.TrimSpace(claims.String("given_name") + " " + claims.String("family_name"))
+	}
+	if name == "" {
+		name = email
+	}
+	if role == "" {
+		role = s.config.DefaultRole
+	}
+
+	now := time.Now()
+	user := &domain.User{
+		Name:            name,
+		Email:           email,
+		Role:            role,
+		EmailVerified:   true,
+		EmailVerifiedAt: &now,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if err := s.userRepo.Save(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to provision user: %v", err)
+	}
+	s.auth.audit.Record(ctx, AuditEvent{
+		ActorID:    user.ID,
+		Action:     domain.AuditUserProvision,
+		TargetType: domain.AuditTargetUser,
+		TargetID:   user.ID,
+		After:      user,
+	})
+	return user, nil
+}
+
+// mapRole returns the most privileged role mapped from the role claim, or "" if none matches.
+func (s *SSOService) mapRole(claims oidc.Claims) string {
+	if s.config.RoleClaim == "" {
+		return ""
+	}
+	best := ""
+	for _, value := range claims.Strings(s.config.RoleClaim) {
+		role, ok := s.config.RoleMappings[value]
+		if ok && rolePrivilege(role) > rolePrivilege(best) {
+			best = role
+		}
+	}
+	return best
+}
+
+func (s *SSOService) domainAllowed(email string) bool {
+	if len(s.config.AllowedDomains) == 0 {
+		return false
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	emailDomain := email[at+1:]
+	for _, allowed := range s.config.AllowedDomains {
+		if strings.EqualFold(emailDomain, strings.TrimSpace(allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+func rolePrivilege(role string) int {
+	switch role {
+	case domain.RoleAdmin:
+		return 3
+	case domain.RoleIssuer:
+		return 2
+	case domain.RoleUser:
+		return 1
+	}
+	return 0
+}
+
+// providerDidMFA reports whether the ID token's amr claim shows a second factor.
+func providerDidMFA(claims oidc.Claims) bool {
+	for _, method := range claims.Strings("amr") {
+		switch method {
+		case "mfa", "otp", "hwk", "swk", "sms":
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SSOService) decodeState(signed string) (*oidc.AuthRequest, error) {
+	invalid := domain.Unauthorized("sso_state_invalid", "invalid or expired sso state")
+
+	parts := strings.Split(signed, ".")
+	if len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(s.signState(parts[0]))) {
+		return nil, invalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, invalid
+	}
+
+	var state ssoState
+	if err := json.Unmarshal(payload, &state); err != nil {
+		return nil, invalid
+	}
+	if time.Now().Unix() >= state.ExpiresAt {
+		return nil, invalid
+	}
+	return &state.AuthRequest, nil
+}
+
+func (s *SSOService) signState(payload string) string {
+	mac := hmac.New(sha256.New, []byte(s.auth.tokens.Secret))
+	mac.Write([]byte("sso|" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
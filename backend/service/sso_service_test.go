@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/oidc"
+	"certificate-ledger/oidc/oidctest"
+)
+
+const ssoRedirectURL = "https://ledger.example.edu/api/auth/sso/callback"
+
+func newTestSSO(t *testing.T, env *testEnv) (*SSOService, *oidctest.Provider) {
+	t.Helper()
+	provider, err := oidctest.NewProvider("ledger", "client-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(provider.Close)
+
+	client := oidc.NewClient(oidc.Config{
+		IssuerURL:    provider.URL,
+		ClientID:     provider.ClientID,
+		ClientSecret: provider.ClientSecret,
+		RedirectURL:  ssoRedirectURL,
+	})
+	sso := NewSSOService(client, provider.URL, SSOConfig{
+		AllowedDomains: []string{"example.edu"},
+		RoleClaim:      "groups",
+		RoleMappings:   map[string]string{"registrar": domain.RoleIssuer},
+	}, env.repos.Users, env.repos.Identities, env.auth)
+	return sso, provider
+}
+
+// ssoLogin goes through the provider as a browser would and completes the
+// login with what the provider redirects back with.
+func ssoLogin(t *testing.T, sso *SSOService) (*domain.AuthResponse, error) {
+	t.Helper()
+	ctx := context.Background()
+	authURL, signedState, err := sso.Begin(ctx)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+
+	browser := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := browser.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("provider answered %d, want a redirect", resp.StatusCode)
+	}
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sso.Callback(ctx, callback.Query().Get("code"), callback.Query().Get("state"), signedState)
+}
+
+func TestSSOLoginProvisionsAndLinksUsers(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	sso, provider := newTestSSO(t, env)
+
+	resp, err := ssoLogin(t, sso)
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	if resp.Token == "" || resp.User.Email != "staff@example.edu" || resp.User.Role != domain.RoleUser {
+		t.Fatalf("first login gave %+v, want a session for a new user", resp)
+	}
+	userID := resp.User.ID
+
+	// The same subject signs in again, now in a group mapped to issuer and
+	// with a second factor done at the provider.
+	provider.SetIdentity(map[string]interface{}{
+		"sub":            "user-1",
+		"email":          "staff@example.edu",
+		"email_verified": true,
+		"groups":         []string{"registrar"},
+		"amr":            []string{"pwd", "mfa"},
+	})
+	resp, err = ssoLogin(t, sso)
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if resp.Token == "" || resp.User.ID != userID || resp.User.Role != domain.RoleIssuer {
+		t.Errorf("second login gave %+v, want a session for %s as issuer", resp, userID)
+	}
+	identity, err := env.repos.Identities.FindBySubject(ctx, provider.URL, "user-1")
+	if err != nil || identity.UserID != userID {
+		t.Errorf("identity = %+v, %v; want it linked to %s", identity, err, userID)
+	}
+
+	// Without a second factor at the provider, an issuer must still do the
+	// local one.
+	provider.SetIdentity(map[string]interface{}{
+		"sub":            "user-1",
+		"email":          "staff@example.edu",
+		"email_verified": true,
+		"groups":         []string{"registrar"},
+	})
+	resp, err = ssoLogin(t, sso)
+	if err != nil {
+		t.Fatalf("third login: %v", err)
+	}
+	if resp.Token != "" || !resp.MFARequired {
+		t.Errorf("third login gave %+v, want an MFA challenge", resp)
+	}
+}
+
+func TestSSOLoginRefusesUnverifiedAndForeignEmails(t *testing.T) {
+	env := newTestEnv(t)
+	sso, provider := newTestSSO(t, env)
+
+	for _, tc := range []struct {
+		name   string
+		claims map[string]interface{}
+		code   string
+	}{
+		{"foreign domain", map[string]interface{}{"sub": "u2", "email": "someone@example.com", "email_verified": true}, "sso_domain_not_allowed"},
+		{"unverified email", map[string]interface{}{"sub": "u3", "email": "staff@example.edu", "email_verified": false}, "sso_email_unverified"},
+		{"no email", map[string]interface{}{"sub": "u4"}, "sso_email_missing"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			provider.SetIdentity(tc.claims)
+			_, err := ssoLogin(t, sso)
+			var domainErr *domain.Error
+			if !errors.Is(err, domain.ErrForbidden) || !errors.As(err, &domainErr) || domainErr.Code != tc.code {
+				t.Errorf("got %v, want forbidden with code %s", err, tc.code)
+			}
+		})
+	}
+}
+
+func TestSSOCallbackRejectsForgedState(t *testing.T) {
+	env := newTestEnv(t)
+	sso, _ := newTestSSO(t, env)
+
+	_, signedState, err := sso.Begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sso.Callback(context.Background(), "code", "another-state", signedState); !errors.Is(err, domain.ErrUnauthorized) {
+		t.Errorf("mismatched state: got %v, want unauthorized", err)
+	}
+	if _, err := sso.Callback(context.Background(), "code", "state", signedState+"x"); !errors.Is(err, domain.ErrUnauthorized) {
+		t.Errorf("tampered signed state: got %v, want unauthorized", err)
+	}
+}
@@ -1,63 +1,107 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"certificate-ledger/domain"
 	"certificate-ledger/repository"
+	"certificate-ledger/validation"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 type UserService struct {
-	repo *repository.UserRepository
+	repo  repository.UserRepository
+	audit *AuditService
 }
 
-func NewUserService(repo *repository.UserRepository) *UserService {
+func NewUserService(repo repository.UserRepository, audit *AuditService) *UserService {
 	return &UserService{
-		repo: repo,
+		repo:  repo,
+		audit: audit,
 	}
 }
 
-func (s *UserService) CreateUser(req domain.UserRequest) (*domain.User, error) {
-	_, err := s.repo.FindByEmail(req.Email)
+// CreateUser creates an account with the requested role. Only admins may
+// create accounts this way, since it is the one path that sets a role.
+func (s *UserService) CreateUser(ctx context.Context, req domain.UserRequest, caller *domain.User) (*domain.User, error) {
+	if caller.Role != domain.RoleAdmin {
+		return nil, domain.Forbidden("admin_required", "only admins can create users")
+	}
+	if err := validation.UserRequest(req); err != nil {
+		return nil, err
+	}
+
+	_, err := s.repo.FindByEmail(ctx, req.Email)
 	if err == nil {
-		return nil, fmt.Errorf("user with email %s already exists", req.Email)
+		return nil, domain.Conflict("email_taken", "user with email %s already exists", req.Email)
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %v", err)
+	}
+	role := req.Role
+	if role == "" {
+		role = domain.RoleUser
 	}
 
 	user := &domain.User{
-		Name:     req.Name,
-		Email:    req.Email,
-		Password: req.Password,
-		Role:     req.Role,
+		Name:      req.Name,
+		Email:     req.Email,
+		Password:  string(hashedPassword),
+		Role:      role,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
 	}
 
-	if err := s.repo.Save(user); err != nil {
-		return nil, fmt.Errorf("failed to save user: %v", err)
+	if err := s.repo.Save(ctx, user); err != nil {
+		return nil, err
 	}
+	s.audit.Record(ctx, AuditEvent{
+		ActorID:    caller.ID,
+		Action:     domain.AuditUserCreate,
+		TargetType: domain.AuditTargetUser,
+		TargetID:   user.ID,
+		After:      user,
+	})
 
 	return user, nil
 }
 
-func (s *UserService) GetUser(id string) (*domain.User, error) {
-	return s.repo.FindByID(id)
+func (s *UserService) GetUser(ctx context.Context, id string) (*domain.User, error) {
+	return s.repo.FindByID(ctx, id)
 }
 
-func (s *UserService) GetUserByEmail(email string) (*domain.User, error) {
-	return s.repo.FindByEmail(email)
+func (s *UserService) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
+	return s.repo.FindByEmail(ctx, email)
 }
 
-func (s *UserService) GetAllUsers() ([]*domain.User, error) {
-	return s.repo.FindAll()
+func (s *UserService) GetAllUsers(ctx context.Context) ([]*domain.User, error) {
+	return s.repo.FindAll(ctx)
 }
 
-func (s *UserService) DeleteUser(id string) error {
-	user, err := s.repo.FindByID(id)
+func (s *UserService) DeleteUser(ctx context.Context, id, actorID string) error {
+	user, err := s.repo.FindByID(ctx, id)
 	if err != nil {
-		return fmt.Errorf("user with ID %s not found", id)
+		return domain.NotFound("user_not_found", "user with ID %s not found", id)
 	}
 
 	if user.Role == "admin" {
-		return fmt.Errorf("cannot delete admin user")
+		return domain.Forbidden("admin_undeletable", "cannot delete admin user")
 	}
 
-	return s.repo.Delete(id)
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.audit.Record(ctx, AuditEvent{
+		ActorID:    actorID,
+		Action:     domain.AuditUserDelete,
+		TargetType: domain.AuditTargetUser,
+		TargetID:   id,
+		Before:     user,
+	})
+	return nil
 }
\ No newline at end of file
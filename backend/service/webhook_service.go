@@ -0,0 +1,557 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/repository"
+	"certificate-ledger/validation"
+	"github.com/google/uuid"
+)
+
+// Headers sent with every webhook delivery. The event ID stays the same
+// across retries and replays, so receivers can use it to drop duplicates.
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookEventIDHeader   = "X-Webhook-Event-Id"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+const (
+	webhookSecretPrefix = "whsec_"
+	webhookUserAgent    = "certificate-ledger-webhooks/1"
+	// webhookBatchSize and webhookConcurrency bound each pass of the worker.
+	webhookBatchSize   = 20
+	webhookConcurrency = 4
+	// webhookLeaseMargin is added to the timeout when claiming a delivery, so
+	// the lease outlasts the attempt.
+	webhookLeaseMargin = 30 * time.Second
+	// maxWebhookLogLength bounds the error kept per attempt.
+	maxWebhookLogLength = 1024
+)
+
+// WebhookConfig tunes delivery. A failed delivery is retried after RetryBase,
+// doubling each time up to RetryMax, until MaxAttempts attempts have failed.
+type WebhookConfig struct {
+	Timeout      time.Duration
+	MaxAttempts  int
+	RetryBase    time.Duration
+	RetryMax     time.Duration
+	PollInterval time.Duration
+}
+
+// WebhookService manages webhook subscriptions and delivers certificate
+// events to them. Deliveries are stored before they are sent, so none are
+// lost on restart, and a background worker sends and retries them.
+type WebhookService struct {
+	repo    repository.WebhookRepository
+	audit   *AuditService
+	config  WebhookConfig
+	client  *http.Client
+	wake    chan struct{}
+	running sync.WaitGroup
+}
+
+func NewWebhookService(repo repository.WebhookRepository, audit *AuditService, config WebhookConfig) *WebhookService {
+	return &WebhookService{
+		repo:   repo,
+		audit:  audit,
+		config: config,
+		client: &http.Client{
+			Timeout:   config.Timeout,
+			Transport: newWebhookTransport(),
+			// A redirect counts as a failed attempt rather than sending the
+			// signed payload somewhere the subscriber did not register.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// SignWebhook returns the signature header value of a delivery:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>" keyed by the secret>".
+// Receivers should recompute it, compare in constant time and reject old
+// timestamps to prevent replays by third parties.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// CreateSubscription registers a webhook for the caller. Issuers receive the
+// events of their own certificates; admins receive every certificate's and
+// are the only ones who may subscribe to integrity alerts, which cover the
+// whole ledger.
+func (s *WebhookService) CreateSubscription(ctx context.Context, req domain.WebhookSubscriptionRequest, user *domain.User) (*domain.WebhookSubscriptionResponse, error) {
+	if user.Role != domain.RoleIssuer && user.Role != domain.RoleAdmin {
+		return nil, domain.Forbidden("webhooks_forbidden", "only issuers and admins can register webhooks")
+	}
+	if err := validation.WebhookSubscriptionRequest(req); err != nil {
+		return nil, err
+	}
+	if user.Role != domain.RoleAdmin && containsString(req.Events, domain.EventIntegrityDiscrepancy) {
+		return nil, domain.Forbidden("webhook_event_forbidden", "only admins can subscribe to %s", domain.EventIntegrityDiscrepancy)
+	}
+
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate webhook secret: %v", err)
+	}
+	var events []string
+	for _, event := range req.Events {
+		if !containsString(events, event) {
+			events = append(events, event)
+		}
+	}
+	sub := &domain.WebhookSubscription{
+		ID:          uuid.New().String(),
+		OwnerID:     user.ID,
+		URL:         req.URL,
+		Description: req.Description,
+		Events:      events,
+		Secret:      webhookSecretPrefix + secret,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := s.repo.SaveSubscription(ctx, sub); err != nil {
+		return nil, err
+	}
+	s.audit.Record(ctx, AuditEvent{
+		ActorID:    user.ID,
+		Action:     domain.AuditWebhookCreate,
+		TargetType: domain.AuditTargetWebhook,
+		TargetID:   sub.ID,
+		After:      sub,
+	})
+
+	return &domain.WebhookSubscriptionResponse{
+		Subscription: *sub,
+		Secret:       sub.Secret,
+	}, nil
+}
+
+func (s *WebhookService) ListSubscriptions(ctx context.Context, user *domain.User) ([]*domain.WebhookSubscription, error) {
+	return s.repo.FindSubscriptionsByOwner(ctx, user.ID)
+}
+
+func (s *WebhookService) GetSubscription(ctx context.Context, id string, user *domain.User) (*domain.WebhookSubscription, error) {
+	return s.findSubscription(ctx, id, user)
+}
+
+func (s *WebhookService) DeleteSubscription(ctx context.Context, id string, user *domain.User) error {
+	sub, err := s.findSubscription(ctx, id, user)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteSubscription(ctx, sub.ID); err != nil {
+		return err
+	}
+	s.audit.Record(ctx, AuditEvent{
+		ActorID:    user.ID,
+		Action:     domain.AuditWebhookDelete,
+		TargetType: domain.AuditTargetWebhook,
+		TargetID:   sub.ID,
+		Before:     sub,
+	})
+	return nil
+}
+
+// ListDeliveries returns a subscription's deliveries, newest first. Passing
+// the dead status lists its dead-letter queue.
+func (s *WebhookService) ListDeliveries(ctx context.Context, subscriptionID, status string, limit int, user *domain.User) ([]*domain.WebhookDelivery, error) {
+	if status != "" && !containsString(domain.WebhookDeliveryStatuses, status) {
+		return nil, domain.Invalid("invalid_status", "unknown delivery status %s", status)
+	}
+	if limit <= 0 {
+		limit = domain.DefaultWebhookDeliveryLimit
+	}
+	if limit > domain.MaxWebhookDeliveryLimit {
+		limit = domain.MaxWebhookDeliveryLimit
+	}
+	sub, err := s.findSubscription(ctx, subscriptionID, user)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindDeliveries(ctx, sub.ID, status, limit)
+}
+
+// GetDelivery returns a delivery with the log of its attempts.
+func (s *WebhookService) GetDelivery(ctx context.Context, subscriptionID, deliveryID string, user *domain.User) (*domain.WebhookDeliveryDetail, error) {
+	delivery, err := s.findDelivery(ctx, subscriptionID, deliveryID, user)
+	if err != nil {
+		return nil, err
+	}
+	attempts, err := s.repo.FindAttempts(ctx, delivery.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.WebhookDeliveryDetail{WebhookDelivery: delivery, Log: attempts}, nil
+}
+
+// ReplayDelivery sends a delivery again with a fresh set of attempts,
+// whether it succeeded or went to the dead-letter queue.
+func (s *WebhookService) ReplayDelivery(ctx context.Context, subscriptionID, deliveryID string, user *domain.User) (*domain.WebhookDelivery, error) {
+	delivery, err := s.findDelivery(ctx, subscriptionID, deliveryID, user)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.repo.Replay(ctx, delivery.ID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.Conflict("webhook_delivery_pending", "delivery %s is still being attempted", delivery.ID)
+	}
+	s.recordReplay(ctx, user, delivery.SubscriptionID, []string{delivery.ID})
+	s.notify()
+	return s.repo.FindDelivery(ctx, delivery.ID)
+}
+
+// ReplayDeadLetters requeues every dead delivery of a subscription.
+func (s *WebhookService) ReplayDeadLetters(ctx context.Context, subscriptionID string, user *domain.User) (*domain.WebhookReplayResult, error) {
+	sub, err := s.findSubscription(ctx, subscriptionID, user)
+	if err != nil {
+		return nil, err
+	}
+	replayed, err := s.repo.ReplayDead(ctx, sub.ID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if replayed > 0 {
+		s.recordReplay(ctx, user, sub.ID, nil)
+		s.notify()
+	}
+	return &domain.WebhookReplayResult{Replayed: replayed}, nil
+}
+
+// CertificateChanged queues a delivery of the event to every subscription
+// that wants it. The deliveries are stored even if ctx is cancelled, since
+// the change they report has already happened.
+func (s *WebhookService) CertificateChanged(ctx context.Context, event *domain.CertificateEvent) {
+	ctx = context.WithoutCancel(ctx)
+	subs, err := s.repo.FindSubscriptionsForIssuer(ctx, event.Data.Certificate.IssuerID)
+	if err != nil {
+		log.Printf("Failed to find webhooks for %s: %v", event.Type, err)
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to marshal %s event: %v", event.Type, err)
+		return
+	}
+	s.queue(ctx, subs, event.ID, event.Type, payload)
+}
+
+// IntegrityAlert queues a delivery of the alert to every admin subscription
+// that wants integrity.discrepancy.
+func (s *WebhookService) IntegrityAlert(ctx context.Context, event *domain.IntegrityAlertEvent) {
+	ctx = context.WithoutCancel(ctx)
+	subs, err := s.repo.FindAdminSubscriptions(ctx)
+	if err != nil {
+		log.Printf("Failed to find webhooks for %s: %v", event.Type, err)
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to marshal %s event: %v", event.Type, err)
+		return
+	}
+	s.queue(ctx, subs, event.ID, event.Type, payload)
+}
+
+// queue stores a delivery of the payload for every subscription that wants
+// the event and wakes the worker.
+func (s *WebhookService) queue(ctx context.Context, subs []*domain.WebhookSubscription, eventID, eventType string, payload []byte) {
+	queued := false
+	for _, sub := range subs {
+		if !sub.Wants(eventType) {
+			continue
+		}
+		now := time.Now().UTC()
+		err := s.repo.SaveDelivery(ctx, &domain.WebhookDelivery{
+			ID:             uuid.New().String(),
+			SubscriptionID: sub.ID,
+			EventID:        eventID,
+			EventType:      eventType,
+			Payload:        payload,
+			Status:         domain.WebhookDeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		})
+		if err != nil {
+			log.Printf("Failed to queue %s for webhook %s: %v", eventType, sub.ID, err)
+			continue
+		}
+		queued = true
+	}
+	if queued {
+		s.notify()
+	}
+}
+
+// Start runs the delivery worker until ctx is cancelled. An attempt cut off
+// by the cancellation is not counted; it is retried once its lease expires.
+func (s *WebhookService) Start(ctx context.Context) {
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		ticker := time.NewTicker(s.config.PollInterval)
+		defer ticker.Stop()
+		for {
+			s.deliverDue(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+		}
+	}()
+}
+
+// Wait blocks until the worker has stopped.
+func (s *WebhookService) Wait() {
+	s.running.Wait()
+}
+
+func (s *WebhookService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliverDue sends every delivery that is due, a batch at a time.
+func (s *WebhookService) deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		now := time.Now().UTC()
+		due, err := s.repo.FindDueDeliveries(ctx, now, webhookBatchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Failed to find due webhook deliveries: %v", err)
+			}
+			return
+		}
+
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, webhookConcurrency)
+		claimed := 0
+		for _, delivery := range due {
+			ok, err := s.repo.ClaimDelivery(ctx, delivery.ID, now, now.Add(s.config.Timeout+webhookLeaseMargin))
+			if err != nil {
+				log.Printf("Failed to claim webhook delivery %s: %v", delivery.ID, err)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			claimed++
+			wg.Add(1)
+			slots <- struct{}{}
+			go func(delivery *domain.WebhookDelivery) {
+				defer wg.Done()
+				defer func() { <-slots }()
+				s.deliver(ctx, delivery)
+			}(delivery)
+		}
+		wg.Wait()
+
+		if len(due) < webhookBatchSize || claimed == 0 {
+			return
+		}
+	}
+}
+
+// deliver makes one attempt and records its outcome.
+func (s *WebhookService) deliver(ctx context.Context, delivery *domain.WebhookDelivery) {
+	sub, err := s.repo.FindSubscription(ctx, delivery.SubscriptionID)
+	if err != nil {
+		log.Printf("Failed to load webhook %s: %v", delivery.SubscriptionID, err)
+		return
+	}
+
+	start := time.Now().UTC()
+	statusCode, err := s.send(ctx, sub, delivery, start)
+	if ctx.Err() != nil {
+		return
+	}
+
+	attempt := &domain.WebhookAttempt{
+		ID:          uuid.New().String(),
+		DeliveryID:  delivery.ID,
+		Attempt:     delivery.Attempts + 1,
+		AttemptedAt: start,
+		StatusCode:  statusCode,
+		DurationMS:  time.Since(start).Milliseconds(),
+	}
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	delivery.LastError = ""
+	switch {
+	case err != nil:
+		attempt.Error = truncateRunes(err.Error(), maxWebhookLogLength)
+	case statusCode < 200 || statusCode > 299:
+		attempt.Error = fmt.Sprintf("receiver responded with status %d", statusCode)
+	}
+
+	now := time.Now().UTC()
+	switch {
+	case attempt.Error == "":
+		delivery.Status = domain.WebhookDeliverySucceeded
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= s.config.MaxAttempts:
+		delivery.Status = domain.WebhookDeliveryDead
+		delivery.LastError = attempt.Error
+		log.Printf("Webhook delivery %s to %s dead-lettered after %d attempts: %s", delivery.ID, sub.ID, delivery.Attempts, attempt.Error)
+	default:
+		delivery.LastError = attempt.Error
+		delivery.NextAttemptAt = now.Add(backoff(s.config.RetryBase, s.config.RetryMax, delivery.Attempts))
+	}
+
+	if err := s.repo.RecordAttempt(context.WithoutCancel(ctx), delivery, attempt); err != nil {
+		log.Printf("Failed to record webhook attempt for %s: %v", delivery.ID, err)
+	}
+}
+
+// send posts the signed payload and returns the status code. The response
+// body is discarded unread: showing it to the subscriber would let them read
+// whatever the receiver's address answers.
+func (s *WebhookService) send(ctx context.Context, sub *domain.WebhookSubscription, delivery *domain.WebhookDelivery, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	if req.URL.Scheme != "https" {
+		return 0, fmt.Errorf("webhook URL must use https")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", webhookUserAgent)
+	req.Header.Set(WebhookEventHeader, delivery.EventType)
+	req.Header.Set(WebhookEventIDHeader, delivery.EventID)
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(sub.Secret, now.Unix(), delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
+
+// newWebhookTransport returns a transport that only connects to public
+// addresses. The check runs on the address actually dialled, after DNS
+// resolution, so a hostname that resolves to an internal address, or is
+// rebound to one after the subscription was created, is refused too.
+// Proxies from the environment are ignored for the same reason.
+func newWebhookTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublicAddr(addrPort.Addr()) {
+				return fmt.Errorf("webhook address %s is not public", addrPort.Addr())
+			}
+			return nil
+		},
+	}
+	transport.DialContext = dialer.DialContext
+	return transport
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, which netip does not
+// count as private.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// isPublicAddr reports whether webhooks may be sent to addr: not loopback,
+// private, link-local, multicast or unspecified.
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() &&
+		!addr.IsPrivate() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+// backoff returns the delay after the given number of failed attempts: base,
+// doubling on each further failure up to max.
+func backoff(base, max time.Duration, failures int) time.Duration {
+	delay := base
+	for i := 1; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// findSubscription loads a subscription owned by the user, or any
+// subscription for an admin. Others are reported as not found.
+func (s *WebhookService) findSubscription(ctx context.Context, id string, user *domain.User) (*domain.WebhookSubscription, error) {
+	sub, err := s.repo.FindSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if sub.OwnerID != user.ID && user.Role != domain.RoleAdmin {
+		return nil, domain.NotFound("webhook_not_found", "webhook with ID %s not found", id)
+	}
+	return sub, nil
+}
+
+func (s *WebhookService) findDelivery(ctx context.Context, subscriptionID, deliveryID string, user *domain.User) (*domain.WebhookDelivery, error) {
+	sub, err := s.findSubscription(ctx, subscriptionID, user)
+	if err != nil {
+		return nil, err
+	}
+	delivery, err := s.repo.FindDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.SubscriptionID != sub.ID {
+		return nil, domain.NotFound("webhook_delivery_not_found", "webhook delivery with ID %s not found", deliveryID)
+	}
+	return delivery, nil
+}
+
+func (s *WebhookService) recordReplay(ctx context.Context, user *domain.User, subscriptionID string, deliveryIDs []string) {
+	after := map[string]interface{}{"deadLetters": true}
+	if deliveryIDs != nil {
+		after = map[string]interface{}{"deliveryIds": deliveryIDs}
+	}
+	s.audit.Record(ctx, AuditEvent{
+		ActorID:    user.ID,
+		Action:     domain.AuditWebhookReplay,
+		TargetType: domain.AuditTargetWebhook,
+		TargetID:   subscriptionID,
+		After:      after,
+	})
+}
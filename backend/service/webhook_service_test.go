@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"certificate-ledger/domain"
+)
+
+// newTestWebhooks returns a webhook service that hears about env's
+// certificates. Deliveries are only sent when a test calls deliverDue.
+func newTestWebhooks(env *testEnv) *WebhookService {
+	webhooks := NewWebhookService(env.repos.Webhooks, env.audit, WebhookConfig{
+		Timeout:      5 * time.Second,
+		MaxAttempts:  2,
+		RetryBase:    0,
+		RetryMax:     0,
+		PollInterval: time.Hour,
+	})
+	env.certs.AddListener(webhooks)
+	return webhooks
+}
+
+// subscribe registers a webhook for the user and the events.
+func subscribe(t *testing.T, webhooks *WebhookService, user *domain.User, url string, events ...string) *domain.WebhookSubscriptionResponse {
+	t.Helper()
+	sub, err := webhooks.CreateSubscription(context.Background(), domain.WebhookSubscriptionRequest{URL: url, Events: events}, user)
+	if err != nil {
+		t.Fatalf("subscribe %s: %v", user.Email, err)
+	}
+	return sub
+}
+
+// deliveries returns the event types queued for the subscription.
+func deliveries(t *testing.T, env *testEnv, subscriptionID string) []string {
+	t.Helper()
+	found, err := env.repos.Webhooks.FindDeliveries(context.Background(), subscriptionID, "", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, d := range found {
+		types = append(types, d.EventType)
+	}
+	return types
+}
+
+func TestWebhookCertificateEventsReachTheIssuerAndAdmins(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	webhooks := newTestWebhooks(env)
+	issuer := env.saveUser(t, "issuer@example.com", domain.RoleIssuer)
+	other := env.saveUser(t, "other@example.com", domain.RoleIssuer)
+	admin := env.saveUser(t, "admin@example.com", domain.RoleAdmin)
+	own := subscribe(t, webhooks, issuer, "https://issuer.example.com/hook", domain.EventCertificateIssued, domain.EventCertificateRevoked)
+	foreign := subscribe(t, webhooks, other, "https://other.example.com/hook", domain.EventCertificateIssued)
+	all := subscribe(t, webhooks, admin, "https://admin.example.com/hook", domain.EventCertificateRevoked)
+
+	cert := env.issue(t, issuer, "Data Science")
+	if _, err := env.certs.RevokeCertificate(ctx, cert.ID, domain.RevokeCertificateRequest{Reason: "issued in error"}, issuer); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct {
+		id   string
+		want string
+	}{
+		"issuer":       {own.Subscription.ID, "certificate.revoked,certificate.issued"},
+		"other issuer": {foreign.Subscription.ID, ""},
+		"admin":        {all.Subscription.ID, "certificate.revoked"},
+	} {
+		if got := strings.Join(deliveries(t, env, tc.id), ","); got != tc.want {
+			t.Errorf("%s's webhook got %q, want %q", name, got, tc.want)
+		}
+	}
+}
+
+func TestWebhookIntegrityAlertsAreForAdminsOnly(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	webhooks := newTestWebhooks(env)
+	issuer := env.saveUser(t, "issuer@example.com", domain.RoleIssuer)
+	admin := env.saveUser(t, "admin@example.com", domain.RoleAdmin)
+
+	_, err := webhooks.CreateSubscription(ctx, domain.WebhookSubscriptionRequest{
+		URL:    "https://issuer.example.com/hook",
+		Events: []string{domain.EventCertificateIssued, domain.EventIntegrityDiscrepancy},
+	}, issuer)
+	if !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("issuer subscribing to integrity alerts: got %v, want forbidden", err)
+	}
+	// One stored before subscriptions were checked.
+	stale := &domain.WebhookSubscription{ID: "stale", OwnerID: issuer.ID, URL: "https://issuer.example.com/hook", Events: []string{domain.EventIntegrityDiscrepancy}, Secret: "whsec_x", CreatedAt: time.Now()}
+	if err := env.repos.Webhooks.SaveSubscription(ctx, stale); err != nil {
+		t.Fatal(err)
+	}
+	alerts := subscribe(t, webhooks, admin, "https://admin.example.com/alerts", domain.EventIntegrityDiscrepancy)
+	certificates := subscribe(t, webhooks, admin, "https://admin.example.com/certificates", domain.EventCertificateIssued)
+
+	webhooks.IntegrityAlert(ctx, &domain.IntegrityAlertEvent{ID: "alert", Type: domain.EventIntegrityDiscrepancy, CreatedAt: time.Now()})
+
+	if got := deliveries(t, env, alerts.Subscription.ID); len(got) != 1 {
+		t.Errorf("admin alert webhook got %v, want the alert", got)
+	}
+	if got := deliveries(t, env, certificates.Subscription.ID); len(got) != 0 {
+		t.Errorf("admin certificate webhook got %v, want nothing", got)
+	}
+	if got := deliveries(t, env, stale.ID); len(got) != 0 {
+		t.Errorf("issuer webhook got %v, want no integrity alert", got)
+	}
+}
+
+// receiver records the requests it gets and answers with status.
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, body)
+	w.WriteHeader(rc.status)
+}
+
+func TestWebhookDeliveryIsSignedAndRetried(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	webhooks := newTestWebhooks(env)
+	issuer := env.saveUser(t, "issuer@example.com", domain.RoleIssuer)
+
+	rc := &receiver{status: http.StatusInternalServerError}
+	server := httptest.NewTLSServer(rc)
+	defer server.Close()
+	// The test server listens on loopback, which the real transport refuses.
+	webhooks.client = server.Client()
+	sub := subscribe(t, webhooks, issuer, server.URL, domain.EventCertificateIssued)
+	env.issue(t, issuer, "Data Science")
+
+	// Both attempts fail and the delivery is dead-lettered.
+	webhooks.deliverDue(ctx)
+	webhooks.deliverDue(ctx)
+	dead, err := env.repos.Webhooks.FindDeliveries(ctx, sub.Subscription.ID, domain.WebhookDeliveryDead, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 || dead[0].Attempts != 2 || dead[0].LastStatusCode != http.StatusInternalServerError {
+		t.Fatalf("dead deliveries are %+v, want one after two failed attempts", dead)
+	}
+
+	rc.mu.Lock()
+	rc.status = http.StatusNoContent
+	rc.mu.Unlock()
+	result, err := webhooks.ReplayDeadLetters(ctx, sub.Subscription.ID, issuer)
+	if err != nil || result.Replayed != 1 {
+		t.Fatalf("replay: got %+v, %v; want one delivery replayed", result, err)
+	}
+	webhooks.deliverDue(ctx)
+	delivered, err := env.repos.Webhooks.FindDelivery(ctx, dead[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivered.Status != domain.WebhookDeliverySucceeded {
+		t.Errorf("replayed delivery is %s, want succeeded", delivered.Status)
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if len(rc.requests) != 3 {
+		t.Fatalf("receiver got %d requests, want 3", len(rc.requests))
+	}
+	last, body := rc.requests[2], rc.bodies[2]
+	if last.Header.Get(WebhookEventIDHeader) != rc.requests[0].Header.Get(WebhookEventIDHeader) {
+		t.Error("the replay carries a different event ID")
+	}
+	signature := last.Header.Get(WebhookSignatureHeader)
+	timestamp, err := strconv.ParseInt(strings.TrimPrefix(strings.Split(signature, ",")[0], "t="), 10, 64)
+	if err != nil {
+		t.Fatalf("signature %q: %v", signature, err)
+	}
+	if want := SignWebhook(sub.Secret, timestamp, body); signature != want {
+		t.Errorf("signature is %q, want %q", signature, want)
+	}
+}
+
+func TestWebhookAddressesMustBePublic(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"192.168.0.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"::1":             false,
+		"::ffff:10.0.0.1": false,
+		"fd00::1":         false,
+		"0.0.0.0":         false,
+	} {
+		if got := isPublicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("isPublicAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/repository"
+	"certificate-ledger/witness"
+)
+
+// WitnessService makes this server a witness for other ledgers: it co-signs
+// a ledger's checkpoint only if the ledger signed the request with its key
+// and the checkpoint extends the last one co-signed for the ledger's origin.
+type WitnessService struct {
+	chains repository.WitnessedChainRepository
+	name   string
+	key    ed25519.PrivateKey
+	// origins holds the public key of each ledger this witness co-signs for.
+	origins map[string]ed25519.PublicKey
+	// signing serializes co-signing, so each checkpoint is checked against
+	// the one signed just before it.
+	signing sync.Mutex
+}
+
+func NewWitnessService(chains repository.WitnessedChainRepository, name string, key ed25519.PrivateKey, origins map[string]ed25519.PublicKey) *WitnessService {
+	return &WitnessService{
+		chains:  chains,
+		name:    name,
+		key:     key,
+		origins: origins,
+	}
+}
+
+func (s *WitnessService) Key() witness.KeyInfo {
+	return witness.KeyInfo{
+		Name:      s.name,
+		PublicKey: hex.EncodeToString(s.key.Public().(ed25519.PublicKey)),
+	}
+}
+
+// Latest returns the last checkpoint co-signed for origin.
+func (s *WitnessService) Latest(ctx context.Context, origin string) (*witness.Checkpoint, error) {
+	chain, err := s.chains.Find(ctx, origin)
+	if err != nil {
+		return nil, err
+	}
+	if chain == nil {
+		return nil, domain.NotFound("chain_not_witnessed", "no checkpoint of %s has been co-signed", origin)
+	}
+	return witnessedCheckpoint(chain), nil
+}
+
+// Cosign signs the request's checkpoint if the request is signed by the
+// checkpoint's origin and its proof leads there from the last checkpoint
+// co-signed for the origin. The first checkpoint of an origin is taken on
+// trust, provided its proof starts at the genesis block; after that the
+// origin is held to that genesis block.
+func (s *WitnessService) Cosign(ctx context.Context, req witness.CosignRequest) (*witness.Cosignature, error) {
+	cp := req.Checkpoint
+	key, ok := s.origins[cp.Origin]
+	if !ok {
+		return nil, domain.Forbidden(witness.CodeUnknownOrigin, "%v: %s", witness.ErrUnknownOrigin, cp.Origin)
+	}
+	if err := req.Authenticate(key, time.Now()); err != nil {
+		return nil, domain.Unauthorized(witness.CodeUnknownOrigin, "%v", err)
+	}
+
+	s.signing.Lock()
+	defer s.signing.Unlock()
+
+	chain, err := s.chains.Find(ctx, cp.Origin)
+	if err != nil {
+		return nil, err
+	}
+	var last *witness.Checkpoint
+	if chain != nil {
+		last = witnessedCheckpoint(chain)
+	}
+	if err := witness.Verify(last, req); err != nil {
+		switch {
+		case errors.Is(err, witness.ErrConflict):
+			log.Printf("Witness: %s presented a checkpoint of block %d (%s) that conflicts with the one co-signed: %v", cp.Origin, cp.Height, cp.HeadHash, err)
+			return nil, domain.Conflict(witness.CodeConflict, "%v", err)
+		case errors.Is(err, witness.ErrStale):
+			return nil, domain.Conflict(witness.CodeStale, "%v", err)
+		default:
+			return nil, domain.Invalid(witness.CodeInvalidProof, "%v", err)
+		}
+	}
+
+	cosignature := witness.Sign(s.key, cp, time.Now())
+	err = s.chains.Save(ctx, &domain.WitnessedChain{
+		GenesisHash: cp.GenesisHash,
+		Origin:      cp.Origin,
+		Height:      cp.Height,
+		HeadHash:    cp.HeadHash,
+		SignedAt:    cosignature.SignedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cosignature, nil
+}
+
+func witnessedCheckpoint(chain *domain.WitnessedChain) *witness.Checkpoint {
+	return &witness.Checkpoint{
+		Origin:      chain.Origin,
+		GenesisHash: chain.GenesisHash,
+		Height:      chain.Height,
+		HeadHash:    chain.HeadHash,
+	}
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"certificate-ledger/domain"
+	"certificate-ledger/repository/memory"
+	"certificate-ledger/witness"
+)
+
+// newTestWitness returns a witness that co-signs for the origin "ledger",
+// and the key the ledger signs its requests with.
+func newTestWitness(t *testing.T) (*WitnessService, ed25519.PrivateKey) {
+	t.Helper()
+	_, witnessKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledgerPublic, ledgerKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewWitnessService(memory.NewRepositories().Witnessed, "witness", witnessKey, map[string]ed25519.PublicKey{"ledger": ledgerPublic}), ledgerKey
+}
+
+// testHeaders returns the headers of a chain of n+1 blocks whose hashes
+// start with prefix, so that chains with different prefixes share no block.
+func testHeaders(prefix string, n int) []witness.Header {
+	headers := make([]witness.Header, n+1)
+	previous := "0"
+	for i := range headers {
+		hash := fmt.Sprintf("%s%d", prefix, i)
+		headers[i] = witness.Header{Index: i, Hash: hash, PreviousHash: previous}
+		previous = hash
+	}
+	return headers
+}
+
+// cosignRequest asks for a checkpoint of origin at the head of headers,
+// proving it with the headers above from.
+func cosignRequest(key ed25519.PrivateKey, origin string, headers []witness.Header, from int) witness.CosignRequest {
+	head := headers[len(headers)-1]
+	cp := witness.Checkpoint{Origin: origin, GenesisHash: headers[0].Hash, Height: head.Index, HeadHash: head.Hash}
+	return witness.NewCosignRequest(key, cp, headers[from+1:], time.Now())
+}
+
+func TestWitnessCosignsCheckpointsThatExtendTheLastOne(t *testing.T) {
+	ctx := context.Background()
+	witnessService, key := newTestWitness(t)
+	headers := testHeaders("a", 5)
+
+	if _, err := witnessService.Cosign(ctx, cosignRequest(key, "ledger", headers[:3], -1)); err != nil {
+		t.Fatalf("first checkpoint: %v", err)
+	}
+	req := cosignRequest(key, "ledger", headers, 2)
+	cosignature, err := witnessService.Cosign(ctx, req)
+	if err != nil {
+		t.Fatalf("extending checkpoint: %v", err)
+	}
+	public := witnessService.key.Public().(ed25519.PublicKey)
+	if !cosignature.Verify(public, req.Checkpoint) {
+		t.Error("cosignature does not verify with the witness's key")
+	}
+	latest, err := witnessService.Latest(ctx, "ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *latest != req.Checkpoint {
+		t.Errorf("latest checkpoint is %+v, want %+v", latest, req.Checkpoint)
+	}
+}
+
+func TestWitnessRefusesUnknownOrigins(t *testing.T) {
+	witnessService, key := newTestWitness(t)
+
+	_, err := witnessService.Cosign(context.Background(), cosignRequest(key, "other", testHeaders("a", 1), -1))
+	if !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("got %v, want forbidden", err)
+	}
+}
+
+func TestWitnessRefusesBadSignatures(t *testing.T) {
+	witnessService, _ := newTestWitness(t)
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = witnessService.Cosign(context.Background(), cosignRequest(otherKey, "ledger", testHeaders("a", 1), -1))
+	if !errors.Is(err, domain.ErrUnauthorized) {
+		t.Fatalf("got %v, want unauthorized", err)
+	}
+	if _, err := witnessService.Latest(context.Background(), "ledger"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("got %v, want nothing co-signed", err)
+	}
+}
+
+func TestWitnessRefusesAnotherGenesis(t *testing.T) {
+	ctx := context.Background()
+	witnessService, key := newTestWitness(t)
+	first := cosignRequest(key, "ledger", testHeaders("a", 2), -1)
+	if _, err := witnessService.Cosign(ctx, first); err != nil {
+		t.Fatalf("first checkpoint: %v", err)
+	}
+
+	// The chain mined again from a new genesis block, however long, is a
+	// rewrite of the one co-signed.
+	_, err := witnessService.Cosign(ctx, cosignRequest(key, "ledger", testHeaders("b", 4), -1))
+	var domainErr *domain.Error
+	if !errors.Is(err, domain.ErrConflict) || !errors.As(err, &domainErr) || domainErr.Code != witness.CodeConflict {
+		t.Fatalf("got %v, want a %s conflict", err, witness.CodeConflict)
+	}
+	latest, err := witnessService.Latest(ctx, "ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *latest != first.Checkpoint {
+		t.Errorf("latest checkpoint is %+v, want the first one kept", latest)
+	}
+}
@@ -0,0 +1,66 @@
+// Package spreadsheet reads uploaded CSV and XLSX files into rows of strings.
+package spreadsheet
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Read parses a CSV or XLSX file, chosen by the file name's extension.
+// Trailing empty rows are dropped.
+func Read(name string, r io.Reader) ([][]string, error) {
+	var rows [][]string
+	var err error
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		rows, err = ReadCSV(r)
+	case ".xlsx":
+		var data []byte
+		data, err = io.ReadAll(r)
+		if err == nil {
+			rows, err = ReadXLSX(data)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported file type %q; upload a .csv or .xlsx file", filepath.Ext(name))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for len(rows) > 0 && isBlank(rows[len(rows)-1]) {
+		rows = rows[:len(rows)-1]
+	}
+	return rows, nil
+}
+
+// ReadCSV parses comma-separated values, tolerating a UTF-8 byte order mark
+// and rows with differing numbers of fields.
+func ReadCSV(r io.Reader) ([][]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv: %v", err)
+	}
+	return rows, nil
+}
+
+func isBlank(row []string) bool {
+	for _, v := range row {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
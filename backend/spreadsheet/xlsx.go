@@ -0,0 +1,271 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxRows and MaxColumns bound the cells read from a worksheet. A cell
+	// reference can name any column, so without them a tiny file could make
+	// the reader allocate gigabytes of empty cells.
+	MaxRows    = 10000
+	MaxColumns = 256
+
+	// xlsxColumns is the number of columns Excel allows, A to XFD.
+	xlsxColumns = 16384
+)
+
+// excelEpoch is day zero of the 1900 date system, adjusted for Excel treating 1900 as a leap year.
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxRichText `xml:"si"`
+}
+
+type xlsxRichText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxRichText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+	var b strings.Builder
+	for _, r := range t.Runs {
+		b.WriteString(r.Text)
+	}
+	return b.String()
+}
+
+type xlsxStyles struct {
+	NumFmts []struct {
+		ID   int    `xml:"numFmtId,attr"`
+		Code string `xml:"formatCode,attr"`
+	} `xml:"numFmts>numFmt"`
+	CellXfs []struct {
+		NumFmtID int `xml:"numFmtId,attr"`
+	} `xml:"cellXfs>xf"`
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []struct {
+			Ref    string       `xml:"r,attr"`
+			Type   string       `xml:"t,attr"`
+			Style  int          `xml:"s,attr"`
+			Value  string       `xml:"v"`
+			Inline xlsxRichText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// ReadXLSX returns the cells of the first worksheet. Shared and inline strings
+// are resolved, and numbers formatted as dates become YYYY-MM-DD.
+func ReadXLSX(data []byte) ([][]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid xlsx: %v", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	sheetPath, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var shared xlsxSharedStrings
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decodeXML(f, &shared); err != nil {
+			return nil, err
+		}
+	}
+
+	var styles xlsxStyles
+	if f, ok := files["xl/styles.xml"]; ok {
+		if err := decodeXML(f, &styles); err != nil {
+			return nil, err
+		}
+	}
+	dateStyles := dateStyleSet(styles)
+
+	f, ok := files[sheetPath]
+	if !ok {
+		return nil, fmt.Errorf("invalid xlsx: missing %s", sheetPath)
+	}
+	var sheet xlsxSheet
+	if err := decodeXML(f, &sheet); err != nil {
+		return nil, err
+	}
+
+	if len(sheet.Rows) > MaxRows {
+		return nil, fmt.Errorf("the sheet has %d rows; at most %d can be read", len(sheet.Rows), MaxRows)
+	}
+	var rows [][]string
+	for _, row := range sheet.Rows {
+		var values []string
+		for i, c := range row.Cells {
+			col := i
+			if c.Ref != "" {
+				if col = columnIndex(c.Ref); col < 0 {
+					return nil, fmt.Errorf("invalid xlsx: bad cell reference %q", c.Ref)
+				}
+			}
+			if col >= MaxColumns {
+				return nil, fmt.Errorf("the sheet has a cell in column %d; at most %d columns can be read", col+1, MaxColumns)
+			}
+			for len(values) <= col {
+				values = append(values, "")
+			}
+
+			switch c.Type {
+			case "s":
+				idx, err := strconv.Atoi(c.Value)
+				if err != nil || idx < 0 || idx >= len(shared.Items) {
+					return nil, fmt.Errorf("invalid xlsx: bad shared string in %s", c.Ref)
+				}
+				values[col] = shared.Items[idx].String()
+			case "inlineStr":
+				values[col] = c.Inline.String()
+			case "b":
+				values[col] = map[string]string{"1": "TRUE", "0": "FALSE"}[c.Value]
+			default:
+				values[col] = c.Value
+				if c.Type == "" && dateStyles[c.Style] {
+					if serial, err := strconv.ParseFloat(c.Value, 64); err == nil {
+						values[col] = excelEpoch.AddDate(0, 0, int(serial)).Format("2006-01-02")
+					}
+				}
+			}
+		}
+		rows = append(rows, values)
+	}
+	return rows, nil
+}
+
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	var workbook xlsxWorkbook
+	f, ok := files["xl/workbook.xml"]
+	if !ok {
+		return "", fmt.Errorf("invalid xlsx: missing workbook")
+	}
+	if err := decodeXML(f, &workbook); err != nil {
+		return "", err
+	}
+	if len(workbook.Sheets) == 0 {
+		return "", fmt.Errorf("xlsx has no worksheets")
+	}
+
+	var rels xlsxRelationships
+	if f, ok := files["xl/_rels/workbook.xml.rels"]; ok {
+		if err := decodeXML(f, &rels); err != nil {
+			return "", err
+		}
+	}
+	for _, rel := range rels.Relationships {
+		if rel.ID == workbook.Sheets[0].RID {
+			if strings.HasPrefix(rel.Target, "/") {
+				return strings.TrimPrefix(rel.Target, "/"), nil
+			}
+			return path.Join("xl", rel.Target), nil
+		}
+	}
+	return "xl/worksheets/sheet1.xml", nil
+}
+
+// dateStyleSet returns the cell style indexes whose number format is a date.
+func dateStyleSet(styles xlsxStyles) map[int]bool {
+	custom := make(map[int]string, len(styles.NumFmts))
+	for _, f := range styles.NumFmts {
+		custom[f.ID] = f.Code
+	}
+
+	set := make(map[int]bool)
+	for i, xf := range styles.CellXfs {
+		id := xf.NumFmtID
+		// 14-22 and 45-47 are the built-in date and time formats.
+		if (id >= 14 && id <= 22) || (id >= 45 && id <= 47) {
+			set[i] = true
+			continue
+		}
+		if code, ok := custom[id]; ok && isDateFormat(code) {
+			set[i] = true
+		}
+	}
+	return set
+}
+
+func isDateFormat(code string) bool {
+	code = strings.ToLower(code)
+	// Drop quoted literals and bracketed sections such as colours or locales.
+	var b strings.Builder
+	inQuote, inBracket := false, false
+	for _, r := range code {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+		case r == '[' && !inQuote:
+			inBracket = true
+		case r == ']' && !inQuote:
+			inBracket = false
+		case !inQuote && !inBracket:
+			b.WriteRune(r)
+		}
+	}
+	code = b.String()
+	return strings.ContainsAny(code, "dy") || (strings.Contains(code, "m") && !strings.Contains(code, "0"))
+}
+
+// columnIndex converts a cell reference such as "AB12" to a zero-based column
+// index. It returns -1 if the reference has no column or one beyond XFD.
+func columnIndex(ref string) int {
+	col := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+		if col > xlsxColumns {
+			return -1
+		}
+	}
+	return col - 1
+}
+
+func decodeXML(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("invalid xlsx: %v", err)
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(io.LimitReader(rc, 64<<20)).Decode(v); err != nil {
+		return fmt.Errorf("invalid xlsx: %s: %v", f.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// buildXLSX zips a minimal workbook whose first sheet holds sheetData.
+func buildXLSX(t *testing.T, sheetData string) []byte {
+	t.Helper()
+	files := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/sharedStrings.xml":       `<sst><si><t>Name</t></si><si><r><t>Ali</t></r><r><t>ce</t></r></si></sst>`,
+		"xl/styles.xml":              `<styleSheet><cellXfs><xf numFmtId="0"/><xf numFmtId="14"/></cellXfs></styleSheet>`,
+		"xl/worksheets/sheet1.xml":   `<worksheet><sheetData>` + sheetData + `</sheetData></worksheet>`,
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadXLSX(t *testing.T) {
+	data := buildXLSX(t, `<row><c r="A1" t="s"><v>0</v></c><c r="C1" t="inlineStr"><is><t>Date</t></is></c></row>`+
+		`<row><c r="A2" t="s"><v>1</v></c><c r="B2" t="b"><v>1</v></c><c r="C2" s="1"><v>45293</v></c></row>`)
+
+	rows, err := ReadXLSX(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"Name", "", "Date"}, {"Alice", "TRUE", "2024-01-02"}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("got %q, want %q", rows, want)
+	}
+}
+
+func TestReadXLSXBoundsTheSheet(t *testing.T) {
+	tooManyRows := strings.Repeat(`<row><c r="A1"><v>1</v></c></row>`, MaxRows+1)
+	for name, sheetData := range map[string]string{
+		"beyond XFD":           `<row><c r="XFE1"><v>1</v></c></row>`,
+		"huge reference":       `<row><c r="ZZZZZZZZZZZZZZ1"><v>1</v></c></row>`,
+		"no column":            `<row><c r="12"><v>1</v></c></row>`,
+		"too many columns":     fmt.Sprintf(`<row><c r="%s1"><v>1</v></c></row>`, columnName(MaxColumns)),
+		"too many rows":        tooManyRows,
+		"XFD, past MaxColumns": `<row><c r="XFD1"><v>1</v></c></row>`,
+	} {
+		if _, err := ReadXLSX(buildXLSX(t, sheetData)); err == nil {
+			t.Errorf("%s: read without an error", name)
+		}
+	}
+
+	rows, err := ReadXLSX(buildXLSX(t, fmt.Sprintf(`<row><c r="%s1"><v>1</v></c></row>`, columnName(MaxColumns-1))))
+	if err != nil {
+		t.Fatalf("last allowed column: %v", err)
+	}
+	if len(rows) != 1 || len(rows[0]) != MaxColumns {
+		t.Errorf("got %d rows of %d cells, want one of %d", len(rows), len(rows[0]), MaxColumns)
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	for ref, want := range map[string]int{"A1": 0, "Z9": 25, "AA10": 26, "XFD1": 16383, "XFE1": -1, "1": -1} {
+		if got := columnIndex(ref); got != want {
+			t.Errorf("columnIndex(%q) = %d, want %d", ref, got, want)
+		}
+	}
+}
+
+// columnName returns the letters of the zero-based column index.
+func columnName(col int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name
+}
@@ -0,0 +1,59 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 appendix B test vectors.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeMatchesRFC6238(t *testing.T) {
+	// The RFC lists eight-digit codes; six-digit codes are their last six digits.
+	for _, tc := range []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	} {
+		got, err := Code(rfcSecret, time.Unix(tc.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("Code at %d = %s, want %s", tc.unix, got, tc.want)
+		}
+	}
+}
+
+func TestValidateAllowsSkewAndReturnsStep(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	previous, _ := Code(rfcSecret, now.Add(-Period*time.Second))
+
+	step, ok := Validate(rfcSecret, previous, now, 1)
+	if !ok || step != Step(now)-1 {
+		t.Errorf("Validate previous code = %d, %v; want step %d", step, ok, Step(now)-1)
+	}
+	if _, ok := Validate(rfcSecret, previous, now, 0); ok {
+		t.Error("previous code accepted without skew")
+	}
+	if _, ok := Validate(rfcSecret, "00592", now, 1); ok {
+		t.Error("short code accepted")
+	}
+	if _, ok := Validate(rfcSecret, "005 924", now, 0); !ok {
+		t.Error("code with a space rejected")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Certificate Ledger", "issuer@example.com", "ABC")
+	if !strings.HasPrefix(uri, "otpauth://totp/Certificate%20Ledger:issuer@example.com?") || !strings.Contains(uri, "secret=ABC") {
+		t.Errorf("URI = %s", uri)
+	}
+}
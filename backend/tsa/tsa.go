@@ -0,0 +1,446 @@
+// Package tsa implements the client side of the RFC 3161 Time-Stamp
+// Protocol: it asks a time-stamp authority to sign a SHA-256 digest and
+// verifies the signed reply.
+package tsa
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// Media types of the protocol over HTTP (RFC 3161 section 3.4).
+const (
+	QueryContentType = "application/timestamp-query"
+	ReplyContentType = "application/timestamp-reply"
+)
+
+// maxReplySize bounds the reply read from an authority.
+const maxReplySize = 1 << 20
+
+var (
+	OIDSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	OIDSignedData           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	OIDTSTInfo              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	OIDContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	OIDMessageDigest        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	OIDSigningCertificateV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	OIDECDSAWithSHA256      = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidRSASSAPSS            = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 10}
+)
+
+// PKIStatus values a reply can carry. Only the first two grant a time-stamp.
+const (
+	StatusGranted         = 0
+	StatusGrantedWithMods = 1
+	StatusRejection       = 2
+)
+
+// The ASN.1 structures of RFC 3161 and RFC 5652 (CMS), as far as the
+// protocol needs them.
+
+type MessageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type TimeStampReq struct {
+	Version        int
+	MessageImprint MessageImprint
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional"`
+}
+
+type PKIStatusInfo struct {
+	Status       int
+	StatusString []string       `asn1:"optional,utf8"`
+	FailInfo     asn1.BitString `asn1:"optional"`
+}
+
+type TimeStampResp struct {
+	Status         PKIStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+// ContentInfo wraps the SignedData of a token; Content holds the [0] tagged
+// SignedData, whose encoding is in Content.Bytes.
+type ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0"`
+}
+
+type SignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo EncapsulatedContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []SignerInfo  `asn1:"set"`
+}
+
+type EncapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     []byte `asn1:"explicit,optional,tag:0"`
+}
+
+// SignerInfo identifies its signer either by issuer and serial number or,
+// in version 3, by [0] subject key identifier, so SID is kept raw.
+type SignerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type IssuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+// Attribute is a signed attribute. Values is the SET of values; the
+// protocol's attributes have exactly one.
+type Attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+type TSTInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint MessageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time     `asn1:"generalized"`
+	Accuracy       Accuracy      `asn1:"optional"`
+	Ordering       bool          `asn1:"optional"`
+	Nonce          *big.Int      `asn1:"optional"`
+	TSA            asn1.RawValue `asn1:"optional,tag:0"`
+	Extensions     asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type Accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+// Token is a verified time-stamp: the authority's signed statement that the
+// digest existed at GenTime, give or take Accuracy.
+type Token struct {
+	GenTime      time.Time
+	Accuracy     time.Duration
+	SerialNumber string
+	Policy       string
+	Signer       *x509.Certificate
+	nonce        *big.Int
+}
+
+// Client asks one authority for time-stamps. Roots are the certificates the
+// authority's signing certificate must chain to; a client without roots
+// cannot verify the replies it gets.
+type Client struct {
+	URL        string
+	Roots      *x509.CertPool
+	HTTPClient *http.Client
+}
+
+func NewClient(url string, roots *x509.CertPool, timeout time.Duration) *Client {
+	return &Client{
+		URL:        url,
+		Roots:      roots,
+		HTTPClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// Timestamp has the authority sign a SHA-256 digest. It returns the raw reply,
+// which is the receipt worth keeping, and the verified token in it.
+func (c *Client) Timestamp(ctx context.Context, digest []byte) ([]byte, *Token, error) {
+	if len(digest) != crypto.SHA256.Size() {
+		return nil, nil, fmt.Errorf("tsa: digest must be %d bytes of SHA-256", crypto.SHA256.Size())
+	}
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, nil, err
+	}
+	query, err := asn1.Marshal(TimeStampReq{
+		Version: 1,
+		MessageImprint: MessageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: OIDSHA256},
+			HashedMessage: digest,
+		},
+		Nonce:   nonce,
+		CertReq: true,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(query))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", QueryContentType)
+	req.Header.Set("Accept", ReplyContentType)
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("tsa: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("tsa: authority responded with status %d", resp.StatusCode)
+	}
+	reply, err := io.ReadAll(io.LimitReader(resp.Body, maxReplySize))
+	if err != nil {
+		return nil, nil, fmt.Errorf("tsa: failed to read reply: %v", err)
+	}
+
+	token, err := Verify(reply, digest, c.Roots)
+	if err != nil {
+		return nil, nil, err
+	}
+	if token.nonce == nil || token.nonce.Cmp(nonce) != 0 {
+		return nil, nil, errors.New("tsa: reply does not carry the request's nonce")
+	}
+	return reply, token, nil
+}
+
+// Verify checks that reply grants a time-stamp of the SHA-256 digest, signed
+// by a time-stamping certificate included in the reply that chains to roots.
+// Without roots nothing is trusted, so every reply fails.
+func Verify(reply, digest []byte, roots *x509.CertPool) (*Token, error) {
+	if roots == nil {
+		return nil, errors.New("tsa: no trusted roots to verify the signer against")
+	}
+	var resp TimeStampResp
+	if err := unmarshalAll(reply, &resp); err != nil {
+		return nil, fmt.Errorf("tsa: malformed reply: %v", err)
+	}
+	if s := resp.Status.Status; s != StatusGranted && s != StatusGrantedWithMods {
+		return nil, fmt.Errorf("tsa: authority refused the request (status %d): %s", s, strings.Join(resp.Status.StatusString, "; "))
+	}
+	if len(resp.TimeStampToken.FullBytes) == 0 {
+		return nil, errors.New("tsa: reply carries no token")
+	}
+
+	var ci ContentInfo
+	if err := unmarshalAll(resp.TimeStampToken.FullBytes, &ci); err != nil {
+		return nil, fmt.Errorf("tsa: malformed token: %v", err)
+	}
+	if !ci.ContentType.Equal(OIDSignedData) {
+		return nil, errors.New("tsa: token is not signed data")
+	}
+	var sd SignedData
+	if err := unmarshalAll(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("tsa: malformed signed data: %v", err)
+	}
+	if !sd.EncapContentInfo.EContentType.Equal(OIDTSTInfo) {
+		return nil, errors.New("tsa: token does not hold time-stamp info")
+	}
+	var info TSTInfo
+	if err := unmarshalAll(sd.EncapContentInfo.EContent, &info); err != nil {
+		return nil, fmt.Errorf("tsa: malformed time-stamp info: %v", err)
+	}
+	if !info.MessageImprint.HashAlgorithm.Algorithm.Equal(OIDSHA256) || !bytes.Equal(info.MessageImprint.HashedMessage, digest) {
+		return nil, errors.New("tsa: token is for a different digest")
+	}
+
+	if len(sd.SignerInfos) != 1 {
+		return nil, fmt.Errorf("tsa: token has %d signers, want 1", len(sd.SignerInfos))
+	}
+	si := sd.SignerInfos[0]
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("tsa: malformed certificates: %v", err)
+	}
+	signer, err := findSigner(si.SID, certs)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSignature(si, signer, sd.EncapContentInfo.EContent); err != nil {
+		return nil, err
+	}
+
+	if !hasTimeStampingUsage(signer) {
+		return nil, errors.New("tsa: signer certificate is not for time-stamping")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs {
+		intermediates.AddCert(cert)
+	}
+	_, err = signer.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   info.GenTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tsa: signer certificate is not trusted: %v", err)
+	}
+
+	accuracy := time.Duration(info.Accuracy.Seconds)*time.Second +
+		time.Duration(info.Accuracy.Millis)*time.Millisecond +
+		time.Duration(info.Accuracy.Micros)*time.Microsecond
+	return &Token{
+		GenTime:      info.GenTime.UTC(),
+		Accuracy:     accuracy,
+		SerialNumber: info.SerialNumber.String(),
+		Policy:       info.Policy.String(),
+		Signer:       signer,
+		nonce:        info.Nonce,
+	}, nil
+}
+
+// findSigner picks the certificate the signer info names.
+func findSigner(sid asn1.RawValue, certs []*x509.Certificate) (*x509.Certificate, error) {
+	if sid.Class == asn1.ClassContextSpecific && sid.Tag == 0 {
+		for _, cert := range certs {
+			if len(cert.SubjectKeyId) > 0 && bytes.Equal(cert.SubjectKeyId, sid.Bytes) {
+				return cert, nil
+			}
+		}
+		return nil, errors.New("tsa: reply does not include the signer certificate")
+	}
+	var ias IssuerAndSerialNumber
+	if err := unmarshalAll(sid.FullBytes, &ias); err != nil {
+		return nil, fmt.Errorf("tsa: malformed signer identifier: %v", err)
+	}
+	for _, cert := range certs {
+		if bytes.Equal(cert.RawIssuer, ias.Issuer.FullBytes) && cert.SerialNumber.Cmp(ias.SerialNumber) == 0 {
+			return cert, nil
+		}
+	}
+	return nil, errors.New("tsa: reply does not include the signer certificate")
+}
+
+// checkSignature verifies the signed attributes against the content and the
+// signature over them (RFC 5652 section 5.4).
+func checkSignature(si SignerInfo, signer *x509.Certificate, content []byte) error {
+	hash, err := hashFor(si.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return err
+	}
+	if len(si.SignedAttrs.FullBytes) == 0 {
+		return errors.New("tsa: token has no signed attributes")
+	}
+
+	var contentType asn1.ObjectIdentifier
+	var messageDigest []byte
+	for rest := si.SignedAttrs.Bytes; len(rest) > 0; {
+		var attr Attribute
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &attr); err != nil {
+			return fmt.Errorf("tsa: malformed signed attribute: %v", err)
+		}
+		switch {
+		case attr.Type.Equal(OIDContentType):
+			_, err = asn1.Unmarshal(attr.Values.Bytes, &contentType)
+		case attr.Type.Equal(OIDMessageDigest):
+			_, err = asn1.Unmarshal(attr.Values.Bytes, &messageDigest)
+		}
+		if err != nil {
+			return fmt.Errorf("tsa: malformed signed attribute: %v", err)
+		}
+	}
+	if !contentType.Equal(OIDTSTInfo) {
+		return errors.New("tsa: signed content type is not time-stamp info")
+	}
+	h := hash.New()
+	h.Write(content)
+	if !bytes.Equal(messageDigest, h.Sum(nil)) {
+		return errors.New("tsa: signed digest does not match the time-stamp info")
+	}
+
+	// The signature covers the attributes encoded as a SET rather than with
+	// their implicit [0] tag.
+	signed := append([]byte(nil), si.SignedAttrs.FullBytes...)
+	signed[0] = 0x31
+	algorithm, err := signatureAlgorithm(si.SignatureAlgorithm.Algorithm, hash, signer)
+	if err != nil {
+		return err
+	}
+	if err := signer.CheckSignature(algorithm, signed, si.Signature); err != nil {
+		return fmt.Errorf("tsa: bad signature: %v", err)
+	}
+	return nil
+}
+
+func hashFor(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(OIDSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(oidSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(oidSHA512):
+		return crypto.SHA512, nil
+	}
+	return 0, fmt.Errorf("tsa: unsupported digest algorithm %s", oid)
+}
+
+// signatureAlgorithm maps the signer's key and digest to an x509 algorithm;
+// CMS often names only the key type in the signature algorithm.
+func signatureAlgorithm(oid asn1.ObjectIdentifier, hash crypto.Hash, signer *x509.Certificate) (x509.SignatureAlgorithm, error) {
+	pss := oid.Equal(oidRSASSAPSS)
+	table := map[x509.PublicKeyAlgorithm]map[crypto.Hash]x509.SignatureAlgorithm{
+		x509.RSA: {
+			crypto.SHA256: x509.SHA256WithRSA,
+			crypto.SHA384: x509.SHA384WithRSA,
+			crypto.SHA512: x509.SHA512WithRSA,
+		},
+		x509.ECDSA: {
+			crypto.SHA256: x509.ECDSAWithSHA256,
+			crypto.SHA384: x509.ECDSAWithSHA384,
+			crypto.SHA512: x509.ECDSAWithSHA512,
+		},
+	}
+	if pss {
+		table[x509.RSA] = map[crypto.Hash]x509.SignatureAlgorithm{
+			crypto.SHA256: x509.SHA256WithRSAPSS,
+			crypto.SHA384: x509.SHA384WithRSAPSS,
+			crypto.SHA512: x509.SHA512WithRSAPSS,
+		}
+	}
+	if algorithm, ok := table[signer.PublicKeyAlgorithm][hash]; ok {
+		return algorithm, nil
+	}
+	return 0, fmt.Errorf("tsa: unsupported signature algorithm %s", oid)
+}
+
+func hasTimeStampingUsage(cert *x509.Certificate) bool {
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageTimeStamping {
+			return true
+		}
+	}
+	return false
+}
+
+func unmarshalAll(der []byte, v interface{}) error {
+	rest, err := asn1.Unmarshal(der, v)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return errors.New("trailing data")
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+// Package tsatest provides a minimal local RFC 3161 time-stamp authority for
+// tests and local development. It signs every SHA-256 query with a
+// self-signed certificate generated at start, or kept in a file, so its
+// time-stamps prove nothing to anyone but the process that trusts that
+// certificate.
+package tsatest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"certificate-ledger/tsa"
+)
+
+// Policy is the policy OID of every time-stamp the server issues.
+var Policy = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 32473, 1, 1}
+
+type Server struct {
+	URL         string
+	Certificate *x509.Certificate
+
+	server *httptest.Server
+	key    *ecdsa.PrivateKey
+
+	mu     sync.Mutex
+	serial int64
+}
+
+// NewServer starts an authority on a loopback address. Call Close when done.
+func NewServer() (*Server, error) {
+	key, cert, err := newIdentity()
+	if err != nil {
+		return nil, err
+	}
+	return start(key, cert), nil
+}
+
+// NewServerFromFile starts an authority that signs with the key and
+// certificate kept in path, creating them there on first use, so its earlier
+// time-stamps still verify after a restart.
+func NewServerFromFile(path string) (*Server, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		key, cert, err := newIdentity()
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		data = append(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			return nil, err
+		}
+		return start(key, cert), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var key *ecdsa.PrivateKey
+	var cert *x509.Certificate
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case "EC PRIVATE KEY":
+			if key, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+				return nil, fmt.Errorf("%s: %v", path, err)
+			}
+		case "CERTIFICATE":
+			if cert, err = x509.ParseCertificate(block.Bytes); err != nil {
+				return nil, fmt.Errorf("%s: %v", path, err)
+			}
+		}
+	}
+	if key == nil || cert == nil {
+		return nil, fmt.Errorf("%s: want an EC private key and a certificate", path)
+	}
+	return start(key, cert), nil
+}
+
+// newIdentity generates a key and a self-signed time-stamping certificate
+// for it.
+func newIdentity() (*ecdsa.PrivateKey, *x509.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	// RFC 3161 requires the time-stamping extended key usage to be the only
+	// one and critical, which x509.CreateCertificate does not do on its own.
+	eku, err := asn1.Marshal([]asn1.ObjectIdentifier{{1, 3, 6, 1, 5, 5, 7, 3, 8}})
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{CommonName: "certificate-ledger test TSA"},
+		NotBefore:       now.Add(-time.Hour),
+		NotAfter:        now.AddDate(10, 0, 0),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtraExtensions: []pkix.Extension{{Id: asn1.ObjectIdentifier{2, 5, 29, 37}, Critical: true, Value: eku}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, cert, nil
+}
+
+func start(key *ecdsa.PrivateKey, cert *x509.Certificate) *Server {
+	s := &Server{Certificate: cert, key: key}
+	s.server = httptest.NewServer(s)
+	s.URL = s.server.URL
+	return s
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Roots returns a pool holding the server's certificate.
+func (s *Server) Roots() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(s.Certificate)
+	return pool
+}
+
+// CertificatePEM returns the server's certificate, for tools such as
+// "openssl ts -verify -CAfile".
+func (s *Server) CertificatePEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate.Raw})
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	reply, err := s.reply(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", tsa.ReplyContentType)
+	w.Write(reply)
+}
+
+// reply answers a query with a signed token, or a rejection when the query is
+// not a SHA-256 time-stamp request.
+func (s *Server) reply(query []byte) ([]byte, error) {
+	var req tsa.TimeStampReq
+	rest, err := asn1.Unmarshal(query, &req)
+	if err != nil || len(rest) > 0 || req.Version != 1 {
+		return reject("malformed request", 5)
+	}
+	if !req.MessageImprint.HashAlgorithm.Algorithm.Equal(tsa.OIDSHA256) || len(req.MessageImprint.HashedMessage) != sha256.Size {
+		return reject("only SHA-256 is supported", 0)
+	}
+
+	s.mu.Lock()
+	s.serial++
+	serial := s.serial
+	s.mu.Unlock()
+
+	info, err := asn1.Marshal(tsa.TSTInfo{
+		Version:        1,
+		Policy:         Policy,
+		MessageImprint: req.MessageImprint,
+		SerialNumber:   big.NewInt(serial),
+		GenTime:        time.Now().UTC().Truncate(time.Second),
+		Accuracy:       tsa.Accuracy{Seconds: 1},
+		Nonce:          req.Nonce,
+	})
+	if err != nil {
+		return nil, err
+	}
+	token, err := s.sign(info, req.CertReq)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(tsa.TimeStampResp{
+		Status:         tsa.PKIStatusInfo{Status: tsa.StatusGranted},
+		TimeStampToken: asn1.RawValue{FullBytes: token},
+	})
+}
+
+// sign wraps the time-stamp info in CMS signed data.
+func (s *Server) sign(info []byte, includeCertificate bool) ([]byte, error) {
+	digest := sha256.Sum256(info)
+	certHash := sha256.Sum256(s.Certificate.Raw)
+	// SigningCertificateV2 ::= SEQUENCE { certs SEQUENCE OF ESSCertIDv2 },
+	// with the hash algorithm left at its SHA-256 default.
+	signingCertificate, err := asn1.Marshal(struct {
+		Certs []struct{ CertHash []byte }
+	}{Certs: []struct{ CertHash []byte }{{CertHash: certHash[:]}}})
+	if err != nil {
+		return nil, err
+	}
+
+	var attrs [][]byte
+	for _, a := range []struct {
+		oid   asn1.ObjectIdentifier
+		value interface{}
+	}{
+		{tsa.OIDContentType, tsa.OIDTSTInfo},
+		{tsa.OIDMessageDigest, digest[:]},
+		{tsa.OIDSigningCertificateV2, asn1.RawValue{FullBytes: signingCertificate}},
+	} {
+		value, err := asn1.Marshal(a.value)
+		if err != nil {
+			return nil, err
+		}
+		attr, err := asn1.Marshal(tsa.Attribute{
+			Type:   a.oid,
+			Values: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: value},
+		})
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, attr)
+	}
+	// DER sorts the members of a SET OF by their encoding.
+	sort.Slice(attrs, func(i, j int) bool { return string(attrs[i]) < string(attrs[j]) })
+	var attrBytes []byte
+	for _, attr := range attrs {
+		attrBytes = append(attrBytes, attr...)
+	}
+
+	signed, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: attrBytes})
+	if err != nil {
+		return nil, err
+	}
+	signedDigest := sha256.Sum256(signed)
+	signature, err := ecdsa.SignASN1(rand.Reader, s.key, signedDigest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	sid, err := asn1.Marshal(tsa.IssuerAndSerialNumber{
+		Issuer:       asn1.RawValue{FullBytes: s.Certificate.RawIssuer},
+		SerialNumber: s.Certificate.SerialNumber,
+	})
+	if err != nil {
+		return nil, err
+	}
+	sha256ID := pkix.AlgorithmIdentifier{Algorithm: tsa.OIDSHA256}
+	sd := tsa.SignedData{
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{sha256ID},
+		EncapContentInfo: tsa.EncapsulatedContentInfo{EContentType: tsa.OIDTSTInfo, EContent: info},
+		SignerInfos: []tsa.SignerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: sid},
+			DigestAlgorithm:    sha256ID,
+			SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attrBytes},
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: tsa.OIDECDSAWithSHA256},
+			Signature:          signature,
+		}},
+	}
+	if includeCertificate {
+		sd.Certificates = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: s.Certificate.Raw}
+	}
+	sdBytes, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(tsa.ContentInfo{
+		ContentType: tsa.OIDSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sdBytes},
+	})
+}
+
+// reject builds a rejection carrying the PKIFailureInfo bit.
+func reject(reason string, failBit int) ([]byte, error) {
+	failInfo := asn1.BitString{Bytes: make([]byte, 4), BitLength: 26}
+	failInfo.Bytes[failBit/8] |= 0x80 >> uint(failBit%8)
+	return asn1.Marshal(tsa.TimeStampResp{
+		Status: tsa.PKIStatusInfo{
+			Status:       tsa.StatusRejection,
+			StatusString: []string{reason},
+			FailInfo:     failInfo,
+		},
+	})
+}
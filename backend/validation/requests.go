@@ -0,0 +1,130 @@
+package validation
+
+import (
+	"fmt"
+	"time"
+
+	"certificate-ledger/domain"
+)
+
+// Length limits follow the column sizes of the certificates and users tables.
+const (
+	MaxNameLength        = 255
+	MaxEmailLength       = 255
+	MaxTitleLength       = 255
+	MaxDescriptionLength = 5000
+	MinPasswordLength    = 8
+	// MaxPasswordBytes is bcrypt's input limit, which counts bytes.
+	MaxPasswordBytes = 72
+	MaxURLLength      = 2048
+)
+
+func CertificateRequest(req domain.CertificateRequest) error {
+	v := New()
+	v.Required("recipientName", req.RecipientName)
+	v.MaxLength("recipientName", req.RecipientName, MaxNameLength)
+	v.Required("recipientEmail", req.RecipientEmail)
+	v.MaxLength("recipientEmail", req.RecipientEmail, MaxEmailLength)
+	v.Email("recipientEmail", req.RecipientEmail)
+	v.Required("certificateTitle", req.CertificateTitle)
+	v.MaxLength("certificateTitle", req.CertificateTitle, MaxTitleLength)
+	v.Required("issueDate", req.IssueDate)
+	v.PastDate("issueDate", req.IssueDate, time.Now())
+	v.Required("issuerName", req.IssuerName)
+	v.MaxLength("issuerName", req.IssuerName, MaxNameLength)
+	v.MaxLength("description", req.Description, MaxDescriptionLength)
+	v.OneOf("recipientLocale", req.RecipientLocale, domain.Locales...)
+	return v.Err()
+}
+
+// UserRequest checks a user created by an admin, who may give any role.
+func UserRequest(req domain.UserRequest) error {
+	v := New()
+	userFields(v, req)
+	v.OneOf("role", req.Role, domain.RoleAdmin, domain.RoleIssuer, domain.RoleUser)
+	return v.Err()
+}
+
+// RegisterRequest checks a self-registration. Such accounts are always
+// users, so any other role is refused rather than silently dropped.
+func RegisterRequest(req domain.UserRequest) error {
+	v := New()
+	userFields(v, req)
+	if req.Role != "" && req.Role != domain.RoleUser {
+		v.Add("role", CodeInvalidValue, "self-registered accounts have the user role")
+	}
+	return v.Err()
+}
+
+func userFields(v *Validator, req domain.UserRequest) {
+	v.Required("name", req.Name)
+	v.MaxLength("name", req.Name, MaxNameLength)
+	v.Required("email", req.Email)
+	v.MaxLength("email", req.Email, MaxEmailLength)
+	v.Email("email", req.Email)
+	newPassword(v, "password", req.Password)
+}
+
+// newPassword checks a password about to be hashed.
+func newPassword(v *Validator, field, value string) {
+	v.Required(field, value)
+	v.MinLength(field, value, MinPasswordLength)
+	v.MaxBytes(field, value, MaxPasswordBytes)
+}
+
+func ChangePasswordRequest(req domain.ChangePasswordRequest) error {
+	v := New()
+	v.Required("currentPassword", req.CurrentPassword)
+	newPassword(v, "newPassword", req.NewPassword)
+	if req.NewPassword != "" && req.NewPassword == req.CurrentPassword {
+		v.Add("newPassword", CodeInvalidValue, "must differ from the current password")
+	}
+	return v.Err()
+}
+
+// LoginRequest only checks the shape of the credentials; whether they are
+// correct is up to the login itself.
+func LoginRequest(req domain.LoginRequest) error {
+	v := New()
+	v.Required("email", req.Email)
+	v.MaxLength("email", req.Email, MaxEmailLength)
+	v.Email("email", req.Email)
+	v.Required("password", req.Password)
+	v.MaxBytes("password", req.Password, MaxPasswordBytes)
+	return v.Err()
+}
+
+func ResetPasswordRequest(req domain.ResetPasswordRequest) error {
+	v := New()
+	newPassword(v, "password", req.Password)
+	return v.Err()
+}
+
+func RevokeCertificateRequest(req domain.RevokeCertificateRequest) error {
+	v := New()
+	v.MaxLength("reason", req.Reason, domain.MaxRevocationReasonLength)
+	return v.Err()
+}
+
+func RenewCertificateRequest(req domain.RenewCertificateRequest) error {
+	v := New()
+	v.PastDate("issueDate", req.IssueDate, time.Now())
+	return v.Err()
+}
+
+func WebhookSubscriptionRequest(req domain.WebhookSubscriptionRequest) error {
+	v := New()
+	v.Required("url", req.URL)
+	v.MaxLength("url", req.URL, MaxURLLength)
+	v.HTTPSURL("url", req.URL)
+	v.MaxLength("description", req.Description, MaxNameLength)
+	if len(req.Events) == 0 {
+		v.Add("events", CodeRequired, "must list at least one event")
+	}
+	for i, event := range req.Events {
+		field := fmt.Sprintf("events[%d]", i)
+		v.Required(field, event)
+		v.OneOf(field, event, domain.WebhookEventTypes...)
+	}
+	return v.Err()
+}
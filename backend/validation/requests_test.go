@@ -0,0 +1,50 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"certificate-ledger/domain"
+)
+
+func TestPasswordLimitCountsBytes(t *testing.T) {
+	// 30 characters but 90 bytes: under a character limit of 72, yet bcrypt
+	// would only hash the first 72 bytes.
+	long := strings.Repeat("ậ", 30)
+	if utf8.RuneCountInString(long) > MaxPasswordBytes || len(long) <= MaxPasswordBytes {
+		t.Fatalf("test password has %d characters and %d bytes", utf8.RuneCountInString(long), len(long))
+	}
+	// 24 characters and 72 bytes: exactly at the limit.
+	fits := strings.Repeat("ệ", MaxPasswordBytes/len("ệ"))
+
+	user := domain.UserRequest{Name: "Alice", Email: "alice@example.com"}
+	for name, check := range map[string]func(password string) error{
+		"register": func(p string) error {
+			req := user
+			req.Password = p
+			return RegisterRequest(req)
+		},
+		"create user": func(p string) error {
+			req := user
+			req.Password = p
+			req.Role = domain.RoleUser
+			return UserRequest(req)
+		},
+		"change password": func(p string) error {
+			return ChangePasswordRequest(domain.ChangePasswordRequest{CurrentPassword: "old password", NewPassword: p})
+		},
+		"reset password": func(p string) error {
+			return ResetPasswordRequest(domain.ResetPasswordRequest{Token: "token", Password: p})
+		},
+	} {
+		var errs Errors
+		if err := check(long); !errors.As(err, &errs) || len(errs) != 1 || errs[0].Code != CodeTooLong {
+			t.Errorf("%s with a %d-byte password: got %v, want it too long", name, len(long), err)
+		}
+		if err := check(fits); err != nil {
+			t.Errorf("%s with a %d-byte password: %v", name, len(fits), err)
+		}
+	}
+}
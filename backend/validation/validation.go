@@ -0,0 +1,175 @@
+// Package validation checks request payloads and reports every problem as a
+// field-level error with a stable, machine-readable code.
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"certificate-ledger/domain"
+)
+
+// Error codes. Clients may rely on these; messages are for humans and may change.
+const (
+	CodeRequired     = "required"
+	CodeInvalidEmail = "invalid_email"
+	CodeTooLong      = "too_long"
+	CodeTooShort     = "too_short"
+	CodeInvalidDate  = "invalid_date"
+	CodeFutureDate   = "date_in_future"
+	CodeInvalidValue = "invalid_value"
+	CodeInvalidURL   = "invalid_url"
+)
+
+// DateLayout is the format of date-only request fields.
+const DateLayout = "2006-01-02"
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) String() string {
+	return e.Field + ": " + e.Message
+}
+
+// Errors is the error returned when a request fails validation.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.String()
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// Is makes errors.Is(err, domain.ErrValidation) hold for validation errors.
+func (e Errors) Is(target error) bool {
+	return target == domain.ErrValidation
+}
+
+// Validator collects field errors. Checks on a field stop after its first
+// error, so each field reports at most one problem.
+type Validator struct {
+	errs   Errors
+	failed map[string]bool
+}
+
+func New() *Validator {
+	return &Validator{failed: make(map[string]bool)}
+}
+
+// Err returns the collected errors, or nil if there were none.
+func (v *Validator) Err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// Add records an error for field unless the field already has one.
+func (v *Validator) Add(field, code, message string) {
+	if v.failed[field] {
+		return
+	}
+	v.failed[field] = true
+	v.errs = append(v.errs, FieldError{Field: field, Code: code, Message: message})
+}
+
+func (v *Validator) Required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.Add(field, CodeRequired, "is required")
+	}
+}
+
+// MaxLength limits value to max characters.
+func (v *Validator) MaxLength(field, value string, max int) {
+	if utf8.RuneCountInString(value) > max {
+		v.Add(field, CodeTooLong, fmt.Sprintf("must be at most %d characters", max))
+	}
+}
+
+// MaxBytes limits value to max bytes of UTF-8, for limits such as bcrypt's
+// that count bytes rather than characters.
+func (v *Validator) MaxBytes(field, value string, max int) {
+	if len(value) > max {
+		v.Add(field, CodeTooLong, fmt.Sprintf("must be at most %d bytes; accented and other non-ASCII characters take several", max))
+	}
+}
+
+// MinLength requires at least min characters.
+func (v *Validator) MinLength(field, value string, min int) {
+	if utf8.RuneCountInString(value) < min {
+		v.Add(field, CodeTooShort, fmt.Sprintf("must be at least %d characters", min))
+	}
+}
+
+// Email checks that a non-empty value is a bare address such as name@example.com.
+func (v *Validator) Email(field, value string) {
+	if value == "" {
+		return
+	}
+	addr, err := mail.ParseAddress(value)
+	if err != nil || addr.Address != value || !strings.Contains(value[strings.LastIndex(value, "@")+1:], ".") {
+		v.Add(field, CodeInvalidEmail, "must be a valid email address")
+	}
+}
+
+// PastDate checks that a non-empty value is a DateLayout date no later than
+// today. Today is judged in the latest time zone, so a date that has already
+// started anywhere is accepted.
+func (v *Validator) PastDate(field, value string, now time.Time) {
+	if value == "" {
+		return
+	}
+	date, err := time.Parse(DateLayout, value)
+	if err != nil {
+		v.Add(field, CodeInvalidDate, "must be a date in YYYY-MM-DD format")
+		return
+	}
+	latestToday := now.UTC().Add(14 * time.Hour).Format(DateLayout)
+	if date.Format(DateLayout) > latestToday {
+		v.Add(field, CodeFutureDate, "must not be in the future")
+	}
+}
+
+// URL checks that a non-empty value is an absolute http or https URL.
+func (v *Validator) URL(field, value string) {
+	if value == "" {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.Add(field, CodeInvalidURL, "must be an absolute http or https URL")
+	}
+}
+
+// HTTPSURL checks that a non-empty value is an absolute https URL.
+func (v *Validator) HTTPSURL(field, value string) {
+	if value == "" {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		v.Add(field, CodeInvalidURL, "must be an absolute https URL")
+	}
+}
+
+// OneOf checks that a non-empty value is one of allowed.
+func (v *Validator) OneOf(field, value string, allowed ...string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.Add(field, CodeInvalidValue, "must be one of "+strings.Join(allowed, ", "))
+}
@@ -0,0 +1,117 @@
+package witness
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxResponseSize bounds what is read of a witness's response.
+const maxResponseSize = 64 << 10
+
+// Error codes of a witness's refusals, in the problem's "code" member.
+const (
+	CodeConflict      = "checkpoint_conflict"
+	CodeStale         = "checkpoint_stale"
+	CodeInvalidProof  = "invalid_proof"
+	CodeUnknownOrigin = "unknown_origin"
+)
+
+// Client talks to one witness, whose signatures must verify with PublicKey.
+type Client struct {
+	Name       string
+	URL        string
+	PublicKey  ed25519.PublicKey
+	HTTPClient *http.Client
+}
+
+func NewClient(name, url string, key ed25519.PublicKey, timeout time.Duration) *Client {
+	return &Client{
+		Name:       name,
+		URL:        strings.TrimRight(url, "/"),
+		PublicKey:  key,
+		HTTPClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// Latest returns the last checkpoint the witness co-signed for origin, or
+// nil if it has none.
+func (c *Client) Latest(ctx context.Context, origin string) (*Checkpoint, error) {
+	var checkpoint Checkpoint
+	found, err := c.do(ctx, http.MethodGet, "/chains/"+origin, nil, &checkpoint)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+// Cosign asks the witness to co-sign req.Checkpoint and checks the signature.
+// A refusal wraps ErrConflict, ErrStale, ErrInvalidProof or ErrUnknownOrigin
+// when the witness gives one of those reasons.
+func (c *Client) Cosign(ctx context.Context, req CosignRequest) (*Cosignature, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var cosignature Cosignature
+	if _, err := c.do(ctx, http.MethodPost, "/cosign", body, &cosignature); err != nil {
+		return nil, err
+	}
+	if !cosignature.Verify(c.PublicKey, req.Checkpoint) {
+		return nil, fmt.Errorf("witness %s: signature does not verify with its public key", c.Name)
+	}
+	return &cosignature, nil
+}
+
+// do sends a request to the witness and decodes its JSON answer into out. It
+// reports false without an error when the witness answers 404.
+func (c *Client) do(ctx context.Context, method, path string, body []byte, out interface{}) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.URL+PathPrefix+path, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("witness %s: %v", c.Name, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return false, fmt.Errorf("witness %s: failed to read response: %v", c.Name, err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode != http.StatusOK:
+		var problem struct {
+			Code   string `json:"code"`
+			Detail string `json:"detail"`
+		}
+		json.Unmarshal(data, &problem)
+		reason := map[string]error{
+			CodeConflict:      ErrConflict,
+			CodeStale:         ErrStale,
+			CodeInvalidProof:  ErrInvalidProof,
+			CodeUnknownOrigin: ErrUnknownOrigin,
+		}[problem.Code]
+		if reason == nil {
+			reason = errors.New("refused")
+		}
+		return false, fmt.Errorf("witness %s: %w (status %d): %s", c.Name, reason, resp.StatusCode, problem.Detail)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return false, fmt.Errorf("witness %s: malformed response: %v", c.Name, err)
+	}
+	return true, nil
+}
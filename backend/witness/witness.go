@@ -0,0 +1,223 @@
+// Package witness implements the protocol by which witnesses, servers run by
+// other institutions, co-sign checkpoints of a ledger's chain. A witness only
+// signs a checkpoint that extends the last one it signed for the same chain,
+// so a ledger that rewrites its history cannot have the rewrite co-signed,
+// and its co-signed checkpoints keep contradicting the rewritten chain.
+//
+// Witnesses check that each checkpoint extends the last by following the
+// blocks' hash links; they are never sent the blocks' contents.
+//
+// A witness keeps one chain per origin, the ledger's name, and only co-signs
+// for origins it knows the public key of: each ledger signs its requests, so
+// no one else can start a chain under its name. A chain with a new genesis
+// block under a known origin is a rewrite like any other.
+//
+// Over HTTP, below a witness's base URL:
+//
+//	GET  /witness/v1/key             the witness's name and public key
+//	GET  /witness/v1/chains/{origin} the last checkpoint it co-signed for the origin
+//	POST /witness/v1/cosign          a CosignRequest, answered with a Cosignature
+package witness
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// PathPrefix is where the protocol lives below a witness's base URL.
+const PathPrefix = "/witness/v1"
+
+// MaxRequestAge bounds how far a request's RequestedAt may be from the
+// witness's clock, which keeps a captured request from being replayed later.
+const MaxRequestAge = 5 * time.Minute
+
+// originPattern keeps origins usable as a path segment.
+var originPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,255}$`)
+
+var (
+	// ErrConflict means a checkpoint contradicts one the witness already
+	// co-signed: the chain has been rewritten or forked.
+	ErrConflict = errors.New("checkpoint conflicts with the one last co-signed")
+	// ErrStale means a checkpoint is below the one the witness last
+	// co-signed, so it cannot tell whether the two agree.
+	ErrStale = errors.New("checkpoint is older than the one last co-signed")
+	// ErrInvalidProof means the proof does not lead to the checkpoint.
+	ErrInvalidProof = errors.New("invalid proof")
+	// ErrUnknownOrigin means the witness does not co-sign for the origin, or
+	// the request is not signed with the origin's key.
+	ErrUnknownOrigin = errors.New("origin is not known to the witness")
+)
+
+// ValidOrigin reports whether s can name a ledger: letters, digits, dots,
+// dashes and underscores.
+func ValidOrigin(s string) bool {
+	return originPattern.MatchString(s)
+}
+
+// Checkpoint states that the chain starting at GenesisHash had HeadHash as
+// its block at Height. Origin names the ledger.
+type Checkpoint struct {
+	Origin      string `json:"origin"`
+	GenesisHash string `json:"genesisHash"`
+	Height      int    `json:"height"`
+	HeadHash    string `json:"headHash"`
+}
+
+// Message is the text a witness signs: the checkpoint and when it was signed.
+func (c Checkpoint) Message(signedAt time.Time) []byte {
+	return []byte(fmt.Sprintf("certificate-ledger checkpoint v1\norigin %s\ngenesis %s\nheight %d\nhead %s\ntime %d\n",
+		c.Origin, c.GenesisHash, c.Height, c.HeadHash, signedAt.Unix()))
+}
+
+// Cosignature is a witness's signature of a checkpoint's Message. SignedAt
+// has a precision of one second, as signed.
+type Cosignature struct {
+	SignedAt  time.Time `json:"signedAt"`
+	Signature []byte    `json:"signature"`
+}
+
+func Sign(key ed25519.PrivateKey, c Checkpoint, now time.Time) *Cosignature {
+	signedAt := time.Unix(now.Unix(), 0).UTC()
+	return &Cosignature{
+		SignedAt:  signedAt,
+		Signature: ed25519.Sign(key, c.Message(signedAt)),
+	}
+}
+
+// Verify reports whether the cosignature is key's signature of c.
+func (s *Cosignature) Verify(key ed25519.PublicKey, c Checkpoint) bool {
+	return ed25519.Verify(key, c.Message(s.SignedAt), s.Signature)
+}
+
+// Header is what a witness sees of a block.
+type Header struct {
+	Index        int    `json:"index"`
+	Hash         string `json:"hash"`
+	PreviousHash string `json:"previousHash"`
+}
+
+// CosignRequest asks a witness to co-sign Checkpoint. Proof holds the headers
+// of the blocks above the witness's last checkpoint for the origin, up to the
+// checkpoint's head, oldest first; it starts at the genesis block when the
+// witness has no checkpoint for the origin yet. Signature is the origin's
+// signature of the checkpoint's RequestMessage at RequestedAt.
+type CosignRequest struct {
+	Checkpoint  Checkpoint `json:"checkpoint"`
+	Proof       []Header   `json:"proof"`
+	RequestedAt time.Time  `json:"requestedAt"`
+	Signature   []byte     `json:"signature"`
+}
+
+// NewCosignRequest returns a request for the checkpoint signed with the
+// origin's key.
+func NewCosignRequest(key ed25519.PrivateKey, c Checkpoint, proof []Header, now time.Time) CosignRequest {
+	requestedAt := time.Unix(now.Unix(), 0).UTC()
+	return CosignRequest{
+		Checkpoint:  c,
+		Proof:       proof,
+		RequestedAt: requestedAt,
+		Signature:   ed25519.Sign(key, c.RequestMessage(requestedAt)),
+	}
+}
+
+// RequestMessage is the text an origin signs to ask for the checkpoint to be
+// co-signed. It differs from Message so neither signature passes for the
+// other.
+func (c Checkpoint) RequestMessage(requestedAt time.Time) []byte {
+	return []byte(fmt.Sprintf("certificate-ledger cosign request v1\norigin %s\ngenesis %s\nheight %d\nhead %s\ntime %d\n",
+		c.Origin, c.GenesisHash, c.Height, c.HeadHash, requestedAt.Unix()))
+}
+
+// Authenticate checks that req is signed with key, the origin's public key,
+// and was made within MaxRequestAge of now. The error wraps ErrUnknownOrigin.
+func (req CosignRequest) Authenticate(key ed25519.PublicKey, now time.Time) error {
+	if !ed25519.Verify(key, req.Checkpoint.RequestMessage(req.RequestedAt), req.Signature) {
+		return fmt.Errorf("%w: request is not signed with the key of %s", ErrUnknownOrigin, req.Checkpoint.Origin)
+	}
+	if d := now.Sub(req.RequestedAt); d > MaxRequestAge || d < -MaxRequestAge {
+		return fmt.Errorf("%w: request was made at %s, too far from now", ErrUnknownOrigin, req.RequestedAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// KeyInfo is a witness's answer to GET /witness/v1/key.
+type KeyInfo struct {
+	Name      string `json:"name"`
+	PublicKey string `json:"publicKey"`
+}
+
+// Verify checks that req.Checkpoint extends last, the checkpoint the witness
+// last co-signed for the origin, or nil if it has none. A checkpoint from
+// another genesis block than last is a conflict: the origin's chain has been
+// replaced. The errors wrap ErrConflict, ErrStale or ErrInvalidProof.
+func Verify(last *Checkpoint, req CosignRequest) error {
+	c := req.Checkpoint
+	if !ValidOrigin(c.Origin) || c.GenesisHash == "" || c.HeadHash == "" || c.Height < 0 {
+		return fmt.Errorf("%w: checkpoint is incomplete", ErrInvalidProof)
+	}
+
+	from := Header{Index: -1}
+	if last != nil {
+		if last.Origin != c.Origin {
+			return fmt.Errorf("%w: checkpoint is for another origin", ErrInvalidProof)
+		}
+		if last.GenesisHash != c.GenesisHash {
+			return fmt.Errorf("%w: the chain of %s was co-signed from genesis %s, not %s", ErrConflict, c.Origin, last.GenesisHash, c.GenesisHash)
+		}
+		switch {
+		case c.Height < last.Height:
+			return fmt.Errorf("%w: height %d is below %d", ErrStale, c.Height, last.Height)
+		case c.Height == last.Height && c.HeadHash != last.HeadHash:
+			return fmt.Errorf("%w: block %d was co-signed with hash %s", ErrConflict, last.Height, last.HeadHash)
+		case c.Height == last.Height:
+			return nil
+		}
+		from = Header{Index: last.Height, Hash: last.HeadHash}
+	}
+
+	if len(req.Proof) != c.Height-from.Index {
+		return fmt.Errorf("%w: want the %d headers above block %d, got %d", ErrInvalidProof, c.Height-from.Index, from.Index, len(req.Proof))
+	}
+	if last == nil && req.Proof[0].Hash != c.GenesisHash {
+		return fmt.Errorf("%w: first header is not the genesis block", ErrInvalidProof)
+	}
+	previous := from
+	for i, h := range req.Proof {
+		if h.Index != from.Index+1+i {
+			return fmt.Errorf("%w: header %d has index %d", ErrInvalidProof, i, h.Index)
+		}
+		if previous.Index >= 0 && h.PreviousHash != previous.Hash {
+			if previous == from {
+				return fmt.Errorf("%w: block %d does not link to the co-signed block %d", ErrConflict, h.Index, from.Index)
+			}
+			return fmt.Errorf("%w: block %d does not link to block %d", ErrInvalidProof, h.Index, previous.Index)
+		}
+		previous = h
+	}
+	if previous.Hash != c.HeadHash {
+		return fmt.Errorf("%w: last header is not the checkpoint's head", ErrInvalidProof)
+	}
+	return nil
+}
+
+// ParsePublicKey decodes a hex public key.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("witness: public key must be %d hex-encoded bytes", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// ParsePrivateKey decodes a hex private key seed.
+func ParsePrivateKey(s string) (ed25519.PrivateKey, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != ed25519.SeedSize {
+		return nil, fmt.Errorf("witness: signing key must be a %d-byte hex-encoded seed", ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(b), nil
+}
@@ -2,6 +2,35 @@ const API_BASE_URL = "http://localhost:8080/api"
 
 import { getToken, getCurrentUser } from "./auth"
 
+// Problem is the RFC 7807 body the API returns for every error.
+export interface Problem {
+  type: string
+  title: string
+  status: number
+  detail?: string
+  instance?: string
+  code: string
+  errors?: FieldError[]
+}
+
+export interface FieldError {
+  field: string
+  code: string
+  message: string
+}
+
+export class ApiError extends Error {
+  constructor(
+    message: string,
+    public status: number,
+    public code: string,
+    public fieldErrors: FieldError[] = [],
+  ) {
+    super(message)
+    this.name = "ApiError"
+  }
+}
+
 async function fetchAPI<T>(endpoint: string, options: RequestInit = {}): Promise<T> {
   const url = `${API_BASE_URL}${endpoint}`
 
@@ -20,15 +49,25 @@ async function fetchAPI<T>(endpoint: string, options: RequestInit = {}): Promise
     })
 
     if (!response.ok) {
-      if (response.status === 401) {
-        throw new Error("Unauthorized: Invalid or expired token. Please log in again.")
-      }
+      let problem: Problem | undefined
       try {
-        const errorData = await response.json()
-        throw new Error(errorData.message || `API error: ${response.status}`)
+        problem = await response.json()
       } catch {
-        throw new Error(`API error: ${response.status}`)
+        // Not a problem+json body; fall back to the status code below.
+      }
+      if (response.status === 401 && problem?.code !== "invalid_credentials") {
+        throw new ApiError(
+          "Unauthorized: Invalid or expired token. Please log in again.",
+          response.status,
+          problem?.code ?? "unauthorized",
+        )
       }
+      throw new ApiError(
+        problem?.detail || problem?.title || `API error: ${response.status}`,
+        response.status,
+        problem?.code ?? "unknown_error",
+        problem?.errors ?? [],
+      )
     }
 
     if (response.status === 204) {
@@ -54,6 +93,50 @@ export interface Certificate {
   description: string
   blockNumber: number
   timestamp: string
+  status?: string
+}
+
+export interface CertificatePage {
+  items: Certificate[]
+  total: number
+  limit: number
+  nextCursor?: string
+}
+
+export interface CertificateQuery {
+  issuerId?: string
+  recipientEmail?: string
+  title?: string
+  issuedFrom?: string
+  issuedTo?: string
+  status?: string
+  sort?: string
+  order?: "asc" | "desc"
+  cursor?: string
+  limit?: number
+}
+
+function queryString(query: CertificateQuery = {}): string {
+  const params = new URLSearchParams()
+  for (const [key, value] of Object.entries(query)) {
+    if (value !== undefined && value !== "") {
+      params.set(key, String(value))
+    }
+  }
+  const qs = params.toString()
+  return qs ? `?${qs}` : ""
+}
+
+// fetchAllPages follows nextCursor until every matching certificate is loaded.
+async function fetchAllPages(endpoint: string, query: CertificateQuery = {}): Promise<Certificate[]> {
+  const certificates: Certificate[] = []
+  let cursor: string | undefined
+  do {
+    const page = await fetchAPI<CertificatePage>(`${endpoint}${queryString({ ...query, limit: 100, cursor })}`)
+    certificates.push(...page.items)
+    cursor = page.nextCursor
+  } while (cursor)
+  return certificates
 }
 
 export interface CertificateRequest {
@@ -104,8 +187,12 @@ export async function verifyCertificate(hash: string): Promise<{ valid: boolean
   return fetchAPI<{ valid: boolean }>(`/certificates/verify/${hash}`)
 }
 
-export async function getAllCertificates(): Promise<Certificate[]> {
-  return fetchAPI<Certificate[]>("/certificates")
+export async function listCertificates(query: CertificateQuery = {}): Promise<CertificatePage> {
+  return fetchAPI<CertificatePage>(`/certificates${queryString(query)}`)
+}
+
+export async function getAllCertificates(query: CertificateQuery = {}): Promise<Certificate[]> {
+  return fetchAllPages("/certificates", query)
 }
 
 export async function login(credentials: LoginRequest): Promise<AuthResponse> {
@@ -123,7 +210,7 @@ export async function register(userData: RegisterRequest): Promise<AuthResponse>
 }
 
 export async function getUserCertificates(userId: string): Promise<Certificate[]> {
-  return fetchAPI<Certificate[]>(`/users/${userId}/certificates`)
+  return fetchAllPages(`/users/${userId}/certificates`)
 }
 
 export async function getUserIssuedCertificates(): Promise<Certificate[]> {
@@ -132,8 +219,7 @@ export async function getUserIssuedCertificates(): Promise<Certificate[]> {
     throw new Error("User not authenticated")
   }
 
-  const allCerts = await getAllCertificates()
-  return allCerts.filter((cert) => cert.issuerId === user.id)
+  return getAllCertificates({ issuerId: user.id })
 }
 
 export async function getUserReceivedCertificates(): Promise<Certificate[]> {
@@ -142,5 +228,5 @@ export async function getUserReceivedCertificates(): Promise<Certificate[]> {
     throw new Error("User not authenticated")
   }
 
-  return fetchAPI<Certificate[]>(`/users/${user.id}/certificates`)
+  return fetchAllPages(`/users/${user.id}/certificates`)
 }
\ No newline at end of file